@@ -5,23 +5,58 @@
 package main // import "github.com/open-telemetry/opentelemetry-collector-contrib/trazr-gen/internal/trazr-gen"
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 
 	"github.com/medxops/trazr-gen/internal/common"
 	"github.com/medxops/trazr-gen/pkg/logs"
 	"github.com/medxops/trazr-gen/pkg/metrics"
+	"github.com/medxops/trazr-gen/pkg/scenario"
 	"github.com/medxops/trazr-gen/pkg/traces"
 )
 
 var (
-	tracesCfg  *traces.Config
-	metricsCfg *metrics.Config
-	logsCfg    *logs.Config
-	configFile string
+	tracesCfg   *traces.Config
+	metricsCfg  *metrics.Config
+	logsCfg     *logs.Config
+	allCfg      *common.Config
+	configFile  string
+	printConfig bool
+	dumpFlags   bool
+	profile     string
+
+	// scenario file whose phases the root command runs
+	scenarioFile string
+
+	// name of the command being run, used to pick the rate to apply on reload
+	activeCommand string
+
+	// signals enabled for the all command
+	allTraces  bool
+	allMetrics bool
+	allLogs    bool
+
+	// whether the logs and the exemplars of the metrics of the all command carry the span
+	// contexts of its traces
+	allCorrelateLogs      bool
+	allCorrelateExemplars bool
+
+	// settings of the bench command
+	benchSignal       string
+	benchWorkers      int
+	benchStartRate    float64
+	benchStepDuration time.Duration
 
 	// Version information, injected by GoReleaser via ldflags
 	version = "-development"
@@ -61,17 +96,35 @@ const rootHelpTemplate = `
 var rootCmd = &cobra.Command{
 	Use:     "trazr-gen",
 	Short:   "Trazr-gen simulates a client generating traces, metrics, and logs",
-	Example: "trazr-gen traces\ntrazr-gen metrics\ntrazr-gen logs",
+	Example: common.FormatExamples([]common.Example{traces.Examples[0], metrics.Examples[0], logs.Examples[0]}),
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		showVersion, _ := cmd.Flags().GetBool("version")
 		if showVersion {
 			fmt.Printf("version: v%s\n", version)
 			return nil
 		}
+		if scenarioFile != "" {
+			return runScenario()
+		}
 		return cmd.Help()
 	},
 }
 
+// runScenario runs the phases of the `--scenario` file, each on top of the config of its
+// signal: the defaults, the config file and the global flags.
+func runScenario() error {
+	s, err := scenario.Load(scenarioFile)
+	if err != nil {
+		return err
+	}
+	logger, err := common.CreateLogger(logsCfg.LogLevel, logsCfg.TerminalOutput)
+	if err != nil {
+		return err
+	}
+	_, err = (&scenario.Runner{Traces: tracesCfg, Metrics: metricsCfg, Logs: logsCfg}).Run(s, logger)
+	return err
+}
+
 // Version command prints the version in v.x.x.x format
 var versionCmd = &cobra.Command{
 	Use:   "version",
@@ -85,13 +138,14 @@ var versionCmd = &cobra.Command{
 var tracesCmd = &cobra.Command{
 	Use:     "traces",
 	Short:   "Simulates a client generating traces. (Stability level: alpha)",
-	Example: "trazr-gen traces",
+	Example: common.FormatExamples(traces.Examples),
 	RunE: func(_ *cobra.Command, _ []string) error {
-		logger, err := common.CreateLogger(tracesCfg.LogLevel, tracesCfg.TerminalOutput)
+		logger, err := common.CreateLogger(cmp.Or(tracesCfg.TracesLogLevel, tracesCfg.LogLevel), tracesCfg.TerminalOutput)
 		if err != nil {
 			return err
 		}
-		return traces.Start(tracesCfg, logger)
+		_, err = traces.Start(tracesCfg, logger)
+		return err
 	},
 }
 
@@ -99,13 +153,14 @@ var tracesCmd = &cobra.Command{
 var metricsCmd = &cobra.Command{
 	Use:     "metrics",
 	Short:   "Simulates a client generating metrics. (Stability level: development)",
-	Example: "trazr-gen metrics",
+	Example: common.FormatExamples(metrics.Examples),
 	RunE: func(_ *cobra.Command, _ []string) error {
-		logger, err := common.CreateLogger(metricsCfg.LogLevel, metricsCfg.TerminalOutput)
+		logger, err := common.CreateLogger(cmp.Or(metricsCfg.MetricsLogLevel, metricsCfg.LogLevel), metricsCfg.TerminalOutput)
 		if err != nil {
 			return err
 		}
-		return metrics.Start(metricsCfg, logger)
+		_, err = metrics.Start(metricsCfg, logger)
+		return err
 	},
 }
 
@@ -113,18 +168,240 @@ var metricsCmd = &cobra.Command{
 var logsCmd = &cobra.Command{
 	Use:     "logs",
 	Short:   "Simulates a client generating metrics. (Stability level: development)",
-	Example: "trazr-gen logs",
+	Example: common.FormatExamples(logs.Examples),
+	RunE: func(_ *cobra.Command, _ []string) error {
+		logger, err := common.CreateLogger(cmp.Or(logsCfg.LogsLogLevel, logsCfg.LogLevel), logsCfg.TerminalOutput)
+		if err != nil {
+			return err
+		}
+		_, err = logs.Start(logsCfg, logger)
+		return err
+	},
+}
+
+// allCmd is the command responsible for sending several signals concurrently
+var allCmd = &cobra.Command{
+	Use:     "all",
+	Short:   "Simulates a client generating traces, metrics and logs concurrently. (Stability level: development)",
+	Example: common.FormatExamples(allExamples),
 	RunE: func(_ *cobra.Command, _ []string) error {
-		logger, err := common.CreateLogger(logsCfg.LogLevel, logsCfg.TerminalOutput)
+		if !allTraces && !allMetrics && !allLogs {
+			return errors.New("at least one of `--traces`, `--metrics` or `--logs` must be set")
+		}
+		if allCfg.TUI {
+			return errors.New("`--tui` shows a single signal, use it with the traces, metrics or logs command")
+		}
+		if allCorrelateLogs && (!allTraces || !allLogs) {
+			return errors.New("`--correlate-logs` takes the span contexts of the logs from the traces, it requires both `--traces` and `--logs`")
+		}
+		if allCorrelateExemplars && (!allTraces || !allMetrics) {
+			return errors.New("`--correlate-exemplars` takes the span contexts of the exemplars from the traces, it requires both `--traces` and `--metrics`")
+		}
+		logger, err := common.CreateLogger(logsCfg.LogLevel, allCfg.TerminalOutput)
 		if err != nil {
 			return err
 		}
-		return logs.Start(logsCfg, logger)
+
+		var correlated []string
+		if allCorrelateLogs {
+			correlated = append(correlated, "logs")
+		}
+		if allCorrelateExemplars {
+			correlated = append(correlated, "metrics")
+		}
+		if len(correlated) > 0 {
+			defer common.EnableCorrelation(correlated...)()
+		}
+
+		// before the signals copy allCfg, so that their generators do not seed it again
+		allCfg.SeedSharedRandomness()
+		var starts []func() error
+		schemaURLs := map[string]string{}
+		if allTraces {
+			shareCommonConfig(&tracesCfg.Config, allCfg)
+			schemaURLs["traces"] = tracesCfg.ResourceSchemaURL()
+			starts = append(starts, func() error {
+				signalLogger, err := allSignalLogger(logger, "traces", tracesCfg.TracesLogLevel)
+				if err != nil {
+					return err
+				}
+				_, err = traces.Start(tracesCfg, signalLogger)
+				return err
+			})
+		}
+		if allMetrics {
+			shareCommonConfig(&metricsCfg.Config, allCfg)
+			schemaURLs["metrics"] = metricsCfg.ResourceSchemaURL()
+			if allTraces || allLogs {
+				// it bounds the queues of the other signals, metrics queue nothing
+				metricsCfg.BoundedMemory = false
+			}
+			starts = append(starts, func() error {
+				signalLogger, err := allSignalLogger(logger, "metrics", metricsCfg.MetricsLogLevel)
+				if err != nil {
+					return err
+				}
+				_, err = metrics.Start(metricsCfg, signalLogger)
+				return err
+			})
+		}
+		if allLogs {
+			shareCommonConfig(&logsCfg.Config, allCfg)
+			schemaURLs["logs"] = logsCfg.ResourceSchemaURL()
+			starts = append(starts, func() error {
+				signalLogger, err := allSignalLogger(logger, "logs", logsCfg.LogsLogLevel)
+				if err != nil {
+					return err
+				}
+				_, err = logs.Start(logsCfg, signalLogger)
+				return err
+			})
+		}
+
+		warnMixedSchemaURLs(logger, schemaURLs)
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(starts))
+		for i, start := range starts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = start()
+			}()
+		}
+		wg.Wait()
+		return errors.Join(errs...)
 	},
 }
 
+// warnMixedSchemaURLs warns when the resources of the signals of the all command, the
+// schema URLs of which schemaURLs holds by signal, don't share a schema URL: the backends
+// correlating the signals have to translate between their schemas.
+func warnMixedSchemaURLs(logger *zap.Logger, schemaURLs map[string]string) {
+	if len(slices.Compact(slices.Sorted(maps.Values(schemaURLs)))) < 2 {
+		return
+	}
+	fields := make([]zap.Field, 0, len(schemaURLs))
+	for _, signal := range slices.Sorted(maps.Keys(schemaURLs)) {
+		fields = append(fields, zap.String(signal+"-schema-url", schemaURLs[signal]))
+	}
+	logger.Warn("the signals have resources of different schema URLs, which backends correlating them have to translate between", fields...)
+}
+
+// allSignalLogger returns the logger of a signal of the all command: logger, or one of its
+// own when the signal overrides the global `log-level`, like with `traces-log-level`.
+func allSignalLogger(logger *zap.Logger, signal, level string) (*zap.Logger, error) {
+	if level == "" {
+		return logger.Named(signal), nil
+	}
+	signalLogger, err := common.CreateLogger(level, allCfg.TerminalOutput)
+	if err != nil {
+		return nil, err
+	}
+	return signalLogger.Named(signal), nil
+}
+
+// allExamples are the command lines shown in the help of the all command.
+var allExamples = []common.Example{
+	{Description: "Send the three signals to a local collector for a minute", Command: "trazr-gen all --traces --metrics --logs --duration 1m"},
+	{Description: "Send traces and logs sharing mock resource attributes", Command: "trazr-gen all --traces --logs --duration 30s --mock-data --otlp-attributes 'host.name=\"{{DomainName}}\"'"},
+	{Description: "Send logs correlated with the spans generated alongside them", Command: "trazr-gen all --traces --logs --duration 1m --correlate-logs"},
+	{Description: "Send the three signals linked by trace and span IDs, the logs and the exemplars of the metrics pointing at the spans", Command: "trazr-gen all --traces --metrics --logs --duration 1m --correlate-logs --correlate-exemplars"},
+	{Description: "Debug the traces generator while keeping the other signals quiet", Command: "trazr-gen all --traces --metrics --logs --duration 1m --log-level warn --traces-log-level debug"},
+}
+
+// benchCmd is the command responsible for measuring the maximum generation rate
+var benchCmd = &cobra.Command{
+	Use:     "bench",
+	Short:   "Measures the maximum rate at which traces, metrics or logs can be generated, without exporting them. (Stability level: development)",
+	Example: "trazr-gen bench --signal traces --workers 4",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		logger, err := common.CreateLogger(logsCfg.LogLevel, true)
+		if err != nil {
+			return err
+		}
+
+		var measure func(rate float64) (common.RunStats, error)
+		switch benchSignal {
+		case "traces":
+			measure = func(rate float64) (common.RunStats, error) {
+				cfg := traces.NewConfig()
+				// the rate limits spans, and each trace has a parent and its child spans
+				setBenchConfig(&cfg.Config, rate*float64(1+cfg.NumChildSpans))
+				return traces.RunDiscarding(cfg, logger)
+			}
+		case "metrics":
+			measure = func(rate float64) (common.RunStats, error) {
+				cfg := metrics.NewConfig()
+				setBenchConfig(&cfg.Config, rate)
+				return metrics.RunDiscarding(cfg, logger)
+			}
+		case "logs":
+			measure = func(rate float64) (common.RunStats, error) {
+				cfg := logs.NewConfig()
+				setBenchConfig(&cfg.Config, rate)
+				return logs.RunDiscarding(cfg, logger)
+			}
+		default:
+			return fmt.Errorf("expected `signal` to be one of traces, metrics or logs, got %q instead", benchSignal)
+		}
+		if benchWorkers <= 0 || benchStepDuration <= 0 {
+			return errors.New("`workers` and `step-duration` must be greater than 0")
+		}
+
+		out := common.NewConsoleOutput()
+		out.Printf("Benchmarking %s generation with %d worker(s), %s per step\n", benchSignal, benchWorkers, benchStepDuration)
+		steps, err := common.RampRate(benchStartRate, func(rate float64) (common.RunStats, error) {
+			stats, err := measure(rate)
+			if err == nil {
+				out.Printf("target %.0f/s: achieved %.0f/s\n", rate, stats.AchievedRate)
+			}
+			return stats, err
+		})
+		if err != nil {
+			return err
+		}
+		// the rate limiter has its own overhead, so the unthrottled generator is measured too
+		unthrottled, err := measure(0)
+		if err != nil {
+			return err
+		}
+		out.Printf("unthrottled: achieved %.0f/s\n", unthrottled.AchievedRate)
+		out.Successln(fmt.Sprintf("Max throttled generation rate: %.0f %s/s", common.MaxAchievedRate(steps), benchSignal))
+		out.Successln(fmt.Sprintf("Max unthrottled generation rate: %.0f %s/s", unthrottled.AchievedRate, benchSignal))
+		return nil
+	},
+}
+
+// setBenchConfig prepares a signal config for one step of the bench command, generating
+// at the given total rate (0 means unthrottled) for the step duration, without terminal output.
+func setBenchConfig(c *common.Config, rate float64) {
+	c.WorkerCount = benchWorkers
+	c.Rate = rate / float64(benchWorkers)
+	c.TotalDuration = benchStepDuration
+	c.TerminalOutput = false
+}
+
+// shareCommonConfig copies the shared common configuration of the all command into a
+// signal config. The signal keeps its own log level, and its own OTLP HTTP path unless the
+// all command overrides it, and gets its own copy of the attribute maps since every
+// generator initializes them independently.
+func shareCommonConfig(dst *common.Config, shared *common.Config) {
+	httpPath, logLevel := dst.HTTPPath, dst.LogLevel
+	*dst = *shared
+	dst.HTTPPath, dst.LogLevel = httpPath, logLevel
+	if shared.HTTPPath != "" {
+		dst.HTTPPath = shared.HTTPPath
+	}
+	dst.Headers = maps.Clone(shared.Headers)
+	dst.ResourceAttributes = maps.Clone(shared.ResourceAttributes)
+	dst.TelemetryAttributes = maps.Clone(shared.TelemetryAttributes)
+	dst.Tags = maps.Clone(shared.Tags)
+	dst.SensitiveData = slices.Clone(shared.SensitiveData)
+}
+
 func init() {
-	rootCmd.AddCommand(tracesCmd, metricsCmd, logsCmd)
+	rootCmd.AddCommand(tracesCmd, metricsCmd, logsCmd, allCmd, benchCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	// Prevent Cobra from printing usage on error
@@ -139,6 +416,24 @@ func init() {
 	logsCfg = logs.NewConfig()
 	logsCfg.Flags(logsCmd.Flags())
 
+	allCfg = &common.Config{}
+	allCfg.SetDefaults()
+	allCfg.CommonFlags(allCmd.Flags())
+	allCmd.Flags().BoolVar(&allTraces, "traces", false, "Generate traces")
+	allCmd.Flags().BoolVar(&allMetrics, "metrics", false, "Generate metrics")
+	allCmd.Flags().BoolVar(&allLogs, "logs", false, "Generate logs")
+	allCmd.Flags().BoolVar(&allCorrelateLogs, "correlate-logs", false, "Make the logs carry the trace and span IDs of the spans generated alongside them, to test correlated logs and traces")
+	allCmd.Flags().BoolVar(&allCorrelateExemplars, "correlate-exemplars", false, "Give the metric data points exemplars with the trace and span IDs of the spans generated alongside them, to test exemplar-to-trace links")
+	allCmd.Flags().StringVar(&allCfg.HTTPPath, "otlp-http-url-path", allCfg.HTTPPath, "URL path of every signal, where {signal} stands for the name of each, like '/otlp/v1/{signal}' (default: the path of each signal)")
+	allCmd.Flags().StringVar(&tracesCfg.TracesLogLevel, "traces-log-level", tracesCfg.TracesLogLevel, "Log level of the traces generator, overriding --log-level: debug, info, warn, error")
+	allCmd.Flags().StringVar(&metricsCfg.MetricsLogLevel, "metrics-log-level", metricsCfg.MetricsLogLevel, "Log level of the metrics generator, overriding --log-level: debug, info, warn, error")
+	allCmd.Flags().StringVar(&logsCfg.LogsLogLevel, "logs-log-level", logsCfg.LogsLogLevel, "Log level of the logs generator, overriding --log-level: debug, info, warn, error")
+
+	benchCmd.Flags().StringVar(&benchSignal, "signal", "traces", "Signal to benchmark: traces, metrics or logs")
+	benchCmd.Flags().IntVar(&benchWorkers, "workers", 1, "Number of workers (goroutines) to run")
+	benchCmd.Flags().Float64Var(&benchStartRate, "start-rate", 1000, "Total rate of the first step, in items per second. It doubles every step until the generator saturates")
+	benchCmd.Flags().DurationVar(&benchStepDuration, "step-duration", 2*time.Second, "How long each step of the ramp runs")
+
 	// Set custom help templates for each subcommand
 	traces.SetHelpTemplateForCmd(tracesCmd)
 	metrics.SetHelpTemplateForCmd(metricsCmd)
@@ -157,6 +452,8 @@ func init() {
 			fmt.Println("failed to bind config flag:", err)
 		}
 	}
+	rootCmd.Flags().StringVar(&scenarioFile, "scenario", "", "Run the phases of a scenario file (YAML or JSON) one after the other, each generating a signal with its own options and duration, like normal traffic followed by an error spike")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Profile of the config file to use: the top-level key, like 'load', holding the options of one of the scenarios the file keeps")
 
 	// Register log-level flag
 	rootCmd.PersistentFlags().StringVar(&logsCfg.LogLevel, "log-level", logsCfg.LogLevel, "Log level: debug, info, warn, error")
@@ -166,19 +463,31 @@ func init() {
 		}
 	}
 
+	rootCmd.PersistentFlags().BoolVar(&printConfig, "print-config", false, "Print the full effective configuration (config file, environment and flags merged) and exit without generating anything")
+	rootCmd.PersistentFlags().BoolVar(&dumpFlags, "dump-effective-flags", false, "Print the final value of every option along with where it comes from (default, flag, file or env) and exit without generating anything")
+
 	// Ensure config is loaded after flags are parsed
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
 		initConfig()
+		activeCommand = cmd.Name()
+		loadedAttributes = attributeOptions()
 
+		cfg := configForCommand(cmd.Name())
+		if cfg == nil {
+			return nil
+		}
+		if printConfig {
+			common.ShowFullConfig(cfg)
+			os.Exit(0)
+		}
+		if dumpFlags {
+			common.ShowEffectiveFlags(cfg, func(key string) string { return optionSource(cmd, key) })
+			os.Exit(0)
+		}
 		if logsCfg.TerminalOutput {
-			switch cmd.Name() {
-			case "traces":
-				common.ShowNonDefaultConfig(tracesCfg)
-			case "metrics":
-				common.ShowNonDefaultConfig(metricsCfg)
-			case "logs":
-				common.ShowNonDefaultConfig(logsCfg)
-			}
+			common.ShowNonDefaultConfig(cfg)
 		}
 		return nil
 	}
@@ -186,6 +495,50 @@ func init() {
 	rootCmd.SetHelpTemplate(rootHelpTemplate)
 }
 
+// configForCommand returns the configuration used by the named command, or nil
+// for commands that do not generate telemetry.
+func configForCommand(name string) any {
+	switch name {
+	case "traces":
+		return tracesCfg
+	case "metrics":
+		return metricsCfg
+	case "logs":
+		return logsCfg
+	case "all":
+		return allCfg
+	}
+	return nil
+}
+
+// optionSource returns where the effective value of an option of cmd comes from, given
+// its dotted key: "env" or "file" when initConfig took it from the config file or its
+// `--profile`, whose keys the environment overrides, "flag" when it was set on the command
+// line, or else "default". The options of the command section of the file win over the global ones.
+func optionSource(cmd *cobra.Command, key string) string {
+	if file, err := fileConfig(); configFile != "" && err == nil {
+		keys := []string{key}
+		if slices.Contains(commandSections, cmd.Name()) {
+			keys = []string{cmd.Name() + "." + key, key}
+		}
+		for _, k := range keys {
+			if !file.InConfig(k) {
+				continue
+			}
+			if _, ok := os.LookupEnv(strings.ToUpper(k)); ok {
+				return "env"
+			}
+			return "file"
+		}
+	}
+	// nested options, like `client-auth.mtls`, have a flag named after their last part
+	name := key[strings.LastIndex(key, ".")+1:]
+	if f := cmd.Flags().Lookup(name); f != nil && f.Changed {
+		return "flag"
+	}
+	return "default"
+}
+
 func initConfig() {
 	if configFile != "" {
 		viper.SetConfigFile(configFile)
@@ -194,19 +547,12 @@ func initConfig() {
 			fmt.Println("Error reading config file:", err)
 			os.Exit(1)
 		}
-		// Unmarshal global/common fields into each config struct
-		_ = viper.Unmarshal(tracesCfg)
-		_ = viper.Unmarshal(metricsCfg)
-		_ = viper.Unmarshal(logsCfg)
-		// Unmarshal subcommand-specific fields if present
-		if sub := viper.Sub("traces"); sub != nil {
-			_ = sub.Unmarshal(tracesCfg)
-		}
-		if sub := viper.Sub("metrics"); sub != nil {
-			_ = sub.Unmarshal(metricsCfg)
-		}
-		if sub := viper.Sub("logs"); sub != nil {
-			_ = sub.Unmarshal(logsCfg)
+		if problems := loadConfigFile(); len(problems) > 0 {
+			fmt.Printf("Invalid config file %s:\n", configFile)
+			for _, p := range problems {
+				fmt.Println(" ", p)
+			}
+			os.Exit(1)
 		}
 	} else {
 		// No config file specified, just use environment variables and flags
@@ -216,7 +562,10 @@ func initConfig() {
 
 // Execute tries to run the input command
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	stopReloads := watchReloads()
+	err := rootCmd.Execute()
+	stopReloads()
+	if err != nil {
 		fmt.Println("Error executing command:", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,189 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// quotedKeyPattern matches the option a mapstructure decoding error is about, like
+// 'workers' in "cannot parse 'workers' as int: ...".
+var quotedKeyPattern = regexp.MustCompile(`'([^']+)'`)
+
+// configDecodeHook decodes the options of the config file with common.ConfigDecodeHook.
+var configDecodeHook = viper.DecodeHook(common.ConfigDecodeHook)
+
+// commandSections are the config file sections holding the options of a single command:
+// those of each signal, and those the all command shares with the signals it generates.
+var commandSections = []string{"traces", "metrics", "logs", "all"}
+
+// configProblem is an unknown key or a mistyped value of the config file.
+type configProblem struct {
+	key     string // dotted path of the option
+	message string
+}
+
+// fileConfig returns the options of the config file read by viper: those of the whole
+// file or, with `--profile`, those of the top-level key of the profile.
+func fileConfig() (*viper.Viper, error) {
+	return profileConfig(viper.GetViper())
+}
+
+// profileConfig returns the options of the config file read by v, those of the top-level
+// key of `--profile` when set.
+func profileConfig(v *viper.Viper) (*viper.Viper, error) {
+	if profile == "" {
+		return v, nil
+	}
+	sub := v.Sub(profile)
+	if sub == nil {
+		return nil, fmt.Errorf("no profile `%s` in the config file, expected a top-level key holding its options", profile)
+	}
+	sub.AutomaticEnv()
+	return sub, nil
+}
+
+// loadConfigFile unmarshals the config file read by viper, or its `--profile`, into the
+// config of every command, global options first and then the options of each signal
+// section. It returns the problems found, each prefixed with the line of the file it is on.
+func loadConfigFile() []string {
+	file, err := fileConfig()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var problems []configProblem
+	for _, cfg := range []any{tracesCfg, metricsCfg, logsCfg, allCfg} {
+		problems = append(problems, decodeProblems(file, "", file.Unmarshal(cfg, configDecodeHook))...)
+	}
+	sections := map[string]any{"traces": tracesCfg, "metrics": metricsCfg, "logs": logsCfg, "all": allCfg}
+	for _, name := range commandSections {
+		if sub := file.Sub(name); sub != nil {
+			problems = append(problems, decodeProblems(file, name, sub.Unmarshal(sections[name], configDecodeHook))...)
+		}
+	}
+	problems = append(problems, unknownKeyProblems(sections)...)
+	return locateProblems(problems)
+}
+
+// decodeProblems splits a decoding error of the options of section of file, empty for
+// the global ones, into one problem per option.
+func decodeProblems(file *viper.Viper, section string, err error) []configProblem {
+	if err == nil {
+		return nil
+	}
+	var problems []configProblem
+	for _, line := range strings.Split(err.Error(), "\n") {
+		m := quotedKeyPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := m[1]
+		if section == "" {
+			// the signal sections share their name with the item count of their command
+			if _, isMap := file.Get(key).(map[string]any); isMap {
+				continue
+			}
+		} else {
+			key = section + "." + key
+		}
+		problems = append(problems, configProblem{key: key, message: line})
+	}
+	return problems
+}
+
+// unknownKeyProblems reports the keys of the config file no command reads. A command
+// section only takes the options of its command, the global options those of any.
+func unknownKeyProblems(sections map[string]any) []configProblem {
+	file := viper.New()
+	file.SetConfigFile(viper.ConfigFileUsed())
+	if err := file.ReadInConfig(); err != nil {
+		return nil
+	}
+	settings := file.AllSettings()
+	if profile != "" {
+		// the other profiles are not read
+		settings, _ = settings[profile].(map[string]any)
+	}
+
+	var unknown []string
+	for _, name := range commandSections {
+		if section, ok := settings[name].(map[string]any); ok {
+			delete(settings, name)
+			for _, k := range common.UnknownConfigKeys(section, sections[name]) {
+				unknown = append(unknown, name+"."+k)
+			}
+		}
+	}
+	unknown = append(unknown, common.UnknownConfigKeys(settings, tracesCfg, metricsCfg, logsCfg)...)
+
+	problems := make([]configProblem, 0, len(unknown))
+	for _, k := range unknown {
+		problems = append(problems, configProblem{key: k, message: fmt.Sprintf("unknown key `%s`", k)})
+	}
+	return problems
+}
+
+// locateProblems formats problems as "line N: message", in line order. The options
+// shared by every command are decoded once per command, so duplicates are dropped.
+func locateProblems(problems []configProblem) []string {
+	// #nosec G304 -- the path is the config file given with --config
+	data, _ := os.ReadFile(viper.ConfigFileUsed())
+	lines := strings.Split(string(data), "\n")
+
+	type located struct {
+		line    int
+		message string
+	}
+	seen := make(map[string]bool)
+	var found []located
+	for _, p := range problems {
+		if seen[p.message] {
+			continue
+		}
+		seen[p.message] = true
+		key := p.key
+		if profile != "" {
+			key = profile + "." + key
+		}
+		found = append(found, located{line: keyLine(lines, key), message: p.message})
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].line < found[j].line })
+
+	result := make([]string, 0, len(found))
+	for _, f := range found {
+		result = append(result, fmt.Sprintf("line %d: %s", f.line, f.message))
+	}
+	return result
+}
+
+// keyLine returns the 1-based line of a dotted key in a YAML or JSON file, looking
+// for each part of the key after the line of the previous one. It returns 0 when the
+// key can't be found.
+func keyLine(lines []string, key string) int {
+	start := 0
+	line := 0
+	for _, part := range strings.Split(key, ".") {
+		pattern := regexp.MustCompile(`(?i)^[\s{,]*"?` + regexp.QuoteMeta(part) + `"?\s*:`)
+		line = 0
+		for i := start; i < len(lines); i++ {
+			if pattern.MatchString(lines[i]) {
+				line = i + 1
+				break
+			}
+		}
+		if line == 0 {
+			return 0
+		}
+		start = line
+	}
+	return line
+}
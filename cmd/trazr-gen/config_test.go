@@ -4,9 +4,27 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/time/rate"
+
+	"github.com/medxops/trazr-gen/internal/common"
+	"github.com/medxops/trazr-gen/pkg/logs"
+	"github.com/medxops/trazr-gen/pkg/metrics"
+	"github.com/medxops/trazr-gen/pkg/traces"
 )
 
 // TestConfig_HTTPPath verifies that the HTTPPath configuration defaults are correctly set for each sub-command.
@@ -29,3 +47,338 @@ func TestExecute_Smoke(t *testing.T) {
 	// (Cobra will not actually run commands in this context)
 	assert.NotPanics(t, func() { Execute() })
 }
+
+func TestShareCommonConfig(t *testing.T) {
+	shared := &common.Config{}
+	shared.SetDefaults()
+	shared.CustomEndpoint = "collector:4317"
+	shared.HTTPPath = ""
+	shared.ResourceAttributes = common.KeyValue{"env": "staging"}
+	shared.SensitiveData = []string{"env"}
+
+	dst := traces.NewConfig()
+	dst.LogLevel = "debug"
+	shareCommonConfig(&dst.Config, shared)
+
+	assert.Equal(t, "collector:4317", dst.CustomEndpoint)
+	assert.Equal(t, "/v1/traces", dst.HTTPPath, "the signal keeps its own URL path")
+	assert.Equal(t, "debug", dst.LogLevel, "the signal keeps its log level")
+	assert.Equal(t, shared.ResourceAttributes, dst.ResourceAttributes)
+
+	dst.ResourceAttributes["env"] = "changed"
+	dst.SensitiveData[0] = "changed"
+	assert.Equal(t, "staging", shared.ResourceAttributes["env"], "attribute maps must not be shared between signals")
+	assert.Equal(t, "env", shared.SensitiveData[0])
+}
+
+func TestShareCommonConfig_SignalURLPath(t *testing.T) {
+	shared := &common.Config{}
+	shared.SetDefaults()
+	shared.HTTPPath = "/otlp/v1/{signal}"
+
+	dst := traces.NewConfig()
+	shareCommonConfig(&dst.Config, shared)
+	assert.Equal(t, "/otlp/v1/traces", dst.URLPath("traces"), "the all command overrides the URL path of every signal")
+}
+
+func TestAllCmd_RequiresSignal(t *testing.T) {
+	err := allCmd.RunE(allCmd, nil)
+	assert.EqualError(t, err, "at least one of `--traces`, `--metrics` or `--logs` must be set")
+}
+
+func TestAllCmd_CorrelateLogsRequiresTracesAndLogs(t *testing.T) {
+	allLogs, allCorrelateLogs = true, true
+	defer func() { allLogs, allCorrelateLogs = false, false }()
+	err := allCmd.RunE(allCmd, nil)
+	assert.EqualError(t, err, "`--correlate-logs` takes the span contexts of the logs from the traces, it requires both `--traces` and `--logs`")
+}
+
+func TestAllSignalLogger(t *testing.T) {
+	logger, err := common.CreateLogger("warn", false)
+	require.NoError(t, err)
+
+	same, err := allSignalLogger(logger, "metrics", "")
+	require.NoError(t, err)
+	assert.False(t, same.Core().Enabled(zapcore.DebugLevel), "without an override the signal logs at the global level")
+
+	own, err := allSignalLogger(logger, "traces", "debug")
+	require.NoError(t, err)
+	assert.True(t, own.Core().Enabled(zapcore.DebugLevel), "the signal overrides the global level")
+}
+
+func TestWarnMixedSchemaURLs(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	warnMixedSchemaURLs(zap.New(core), map[string]string{"traces": "https://opentelemetry.io/schemas/1.26.0", "logs": "https://opentelemetry.io/schemas/1.26.0"})
+	assert.Zero(t, logs.Len(), "the signals share a schema URL")
+
+	warnMixedSchemaURLs(zap.New(core), map[string]string{"traces": "https://opentelemetry.io/schemas/1.26.0", "logs": "https://opentelemetry.io/schemas/1.20.0"})
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.26.0", fields["traces-schema-url"])
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.20.0", fields["logs-schema-url"])
+}
+
+func TestAllCmd_CorrelateExemplarsRequiresTracesAndMetrics(t *testing.T) {
+	allTraces, allCorrelateExemplars = true, true
+	defer func() { allTraces, allCorrelateExemplars = false, false }()
+	err := allCmd.RunE(allCmd, nil)
+	assert.EqualError(t, err, "`--correlate-exemplars` takes the span contexts of the exemplars from the traces, it requires both `--traces` and `--metrics`")
+}
+
+func TestRootCmd_Scenario(t *testing.T) {
+	scenarioFile = filepath.Join(t.TempDir(), "incident.yaml")
+	defer func() { scenarioFile = "" }()
+	require.NoError(t, os.WriteFile(scenarioFile, []byte("phases:\n  - name: spike\n    signal: traces\n    options:\n      status-code: Boom\n"), 0o600))
+
+	err := rootCmd.RunE(rootCmd, nil)
+	assert.ErrorContains(t, err, `phase "spike": `)
+	assert.ErrorContains(t, err, "status-code")
+}
+
+func TestBenchCmd_InvalidSignal(t *testing.T) {
+	benchSignal = "spans"
+	defer func() { benchSignal = "traces" }()
+	err := benchCmd.RunE(benchCmd, nil)
+	assert.EqualError(t, err, "expected `signal` to be one of traces, metrics or logs, got \"spans\" instead")
+}
+
+func TestSetBenchConfig(t *testing.T) {
+	benchWorkers, benchStepDuration = 4, time.Second
+	defer func() { benchWorkers, benchStepDuration = 1, 2*time.Second }()
+
+	cfg := &common.Config{TerminalOutput: true}
+	setBenchConfig(cfg, 1000)
+	assert.Equal(t, 4, cfg.WorkerCount)
+	assert.InDelta(t, 250.0, cfg.Rate, 1e-9)
+	assert.Equal(t, time.Second, cfg.TotalDuration)
+	assert.False(t, cfg.TerminalOutput)
+}
+
+func TestConfigForCommand(t *testing.T) {
+	assert.Same(t, tracesCfg, configForCommand("traces"))
+	assert.Same(t, metricsCfg, configForCommand("metrics"))
+	assert.Same(t, logsCfg, configForCommand("logs"))
+	assert.Same(t, allCfg, configForCommand("all"))
+	assert.Nil(t, configForCommand("version"))
+}
+
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rate: 10\nmetrics:\n  rate: 30\n"), 0o600))
+	configFile, activeCommand = path, "metrics"
+	defer func() { configFile, activeCommand = "", "" }()
+	t.Cleanup(common.ResetAppliedRate)
+
+	limiters, release := metricsCfg.RunLimiters(1)
+	defer release()
+	require.NoError(t, reloadConfig())
+	assert.Equal(t, rate.Limit(30), limiters[0].Limit(), "the section rate wins over the global one")
+
+	activeCommand = "all"
+	require.NoError(t, reloadConfig())
+	assert.Equal(t, rate.Limit(10), limiters[0].Limit(), "the all command uses the global rate")
+
+	require.NoError(t, os.WriteFile(path, []byte("rate: -5\n"), 0o600))
+	require.ErrorContains(t, reloadConfig(), "`rate` must be a finite number of at least 0")
+	assert.Equal(t, rate.Limit(10), limiters[0].Limit(), "an invalid rate is not applied")
+
+	require.NoError(t, os.WriteFile(path, []byte("workers: 2\n"), 0o600))
+	require.NoError(t, reloadConfig())
+	assert.Equal(t, rate.Limit(10), limiters[0].Limit(), "without a rate the current one is kept")
+}
+
+func TestChangedAttributeOptions(t *testing.T) {
+	read := func(content string) map[string]any {
+		v := viper.New()
+		v.SetConfigType("yaml")
+		require.NoError(t, v.ReadConfig(strings.NewReader(content)))
+		return fileAttributeOptions(v)
+	}
+	loaded := read("rate: 1\ntelemetry-attributes:\n  user.id: \"1\"\nmetrics:\n  metric-datapoint-attributes:\n    env: dev\n")
+	assert.Empty(t, changedAttributeOptions(loaded, read("rate: 2\ntelemetry-attributes:\n  user.id: \"1\"\nmetrics:\n  metric-datapoint-attributes:\n    env: dev\n")))
+	assert.Equal(t, []string{"`metrics.metric-datapoint-attributes`", "`telemetry-attributes`"},
+		changedAttributeOptions(loaded, read("telemetry-attributes:\n  user.id: \"2\"\n")), "changed and removed options")
+}
+
+func TestReloadConfig_NoConfigFile(t *testing.T) {
+	assert.EqualError(t, reloadConfig(), "no `--config` file to reload")
+}
+
+func TestLoadConfigFile_Problems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "workers: 1\nwrokers: 2\ntraces:\n  child-spans: many\n  child-span: 3\nmetrics:\n  aggregation-temporality: delta\nall:\n  workers: 4\n  child-spans: 2\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	viper.SetConfigFile(path)
+	require.NoError(t, viper.ReadInConfig())
+	defer func() {
+		metricsCfg.AggregationTemporality = metrics.AggregationTemporality(metricdata.CumulativeTemporality)
+		tracesCfg.NumChildSpans = traces.NewConfig().NumChildSpans
+		allCfg.WorkerCount = 1
+	}()
+
+	problems := loadConfigFile()
+	require.Len(t, problems, 4)
+	assert.Equal(t, "line 2: unknown key `wrokers`", problems[0])
+	assert.Contains(t, problems[1], "line 4: cannot parse 'child-spans'")
+	assert.Equal(t, "line 5: unknown key `traces.child-span`", problems[2])
+	assert.Equal(t, "line 10: unknown key `all.child-spans`", problems[3], "the all command shares the common options only")
+	assert.Equal(t, 4, allCfg.WorkerCount)
+	assert.Equal(t, metricdata.DeltaTemporality, metricsCfg.AggregationTemporality.AsTemporality(), "temporalities are set by name")
+}
+
+func TestLoadConfigFile_Profile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "dev:\n  wrokers: 1\nload:\n  traces:\n    child-spans: 7\n    child-span: 3\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	viper.SetConfigFile(path)
+	require.NoError(t, viper.ReadInConfig())
+	defer func() {
+		profile = ""
+		tracesCfg.NumChildSpans = traces.NewConfig().NumChildSpans
+	}()
+
+	profile = "load"
+	problems := loadConfigFile()
+	assert.Equal(t, []string{"line 6: unknown key `traces.child-span`"}, problems, "only the selected profile is read")
+	assert.Equal(t, 7, tracesCfg.NumChildSpans)
+
+	profile = "soak"
+	assert.Equal(t, []string{"no profile `soak` in the config file, expected a top-level key holding its options"}, loadConfigFile())
+}
+
+func TestReloadConfig_Profile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rate: 10\nload:\n  rate: 50\n"), 0o600))
+	configFile, activeCommand, profile = path, "all", "load"
+	defer func() { configFile, activeCommand, profile = "", "", "" }()
+	t.Cleanup(common.ResetAppliedRate)
+
+	limiters, release := allCfg.RunLimiters(1)
+	defer release()
+	require.NoError(t, reloadConfig())
+	assert.Equal(t, rate.Limit(50), limiters[0].Limit(), "the rate of the profile is applied")
+}
+
+func TestOptionSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("workers: 3\nrate: 2\nmetrics:\n  rate: 7\nclient-auth:\n  mtls: true\n"), 0o600))
+	configFile = path
+	viper.SetConfigFile(path)
+	require.NoError(t, viper.ReadInConfig())
+	defer func() { configFile = "" }()
+	t.Setenv("WORKERS", "4")
+
+	cmd := &cobra.Command{Use: "metrics"}
+	cfg := metrics.NewConfig()
+	cfg.Flags(cmd.Flags())
+	require.NoError(t, cmd.Flags().Set("service", "flagged"))
+	require.NoError(t, cmd.Flags().Set("rate", "9"))
+
+	assert.Equal(t, "env", optionSource(cmd, "workers"))
+	assert.Equal(t, "file", optionSource(cmd, "rate"), "the config file is applied after the flags")
+	assert.Equal(t, "file", optionSource(cmd, "client-auth.mtls"))
+	assert.Equal(t, "flag", optionSource(cmd, "service"))
+	assert.Equal(t, "default", optionSource(cmd, "metric-name"))
+}
+
+func TestKeyLine(t *testing.T) {
+	lines := []string{"rate: 1", "traces:", "  rate: 2", `{"logs": {`, `  "rate": 3`}
+	assert.Equal(t, 1, keyLine(lines, "rate"))
+	assert.Equal(t, 3, keyLine(lines, "traces.rate"))
+	assert.Equal(t, 5, keyLine(lines, "logs.rate"))
+	assert.Equal(t, 0, keyLine(lines, "metrics.rate"))
+}
+
+// exampleConfig is the config of a signal command, to check its examples.
+type exampleConfig interface {
+	Flags(fs *pflag.FlagSet)
+	InitAttributes() error
+	Validate() error
+}
+
+func TestExamples(t *testing.T) {
+	for _, tt := range []struct {
+		cmd       *cobra.Command
+		examples  []common.Example
+		newConfig func() (exampleConfig, *common.Config)
+	}{
+		{cmd: tracesCmd, examples: traces.Examples, newConfig: func() (exampleConfig, *common.Config) {
+			cfg := traces.NewConfig()
+			return cfg, &cfg.Config
+		}},
+		{cmd: metricsCmd, examples: metrics.Examples, newConfig: func() (exampleConfig, *common.Config) {
+			cfg := metrics.NewConfig()
+			return cfg, &cfg.Config
+		}},
+		{cmd: logsCmd, examples: logs.Examples, newConfig: func() (exampleConfig, *common.Config) {
+			cfg := logs.NewConfig()
+			return cfg, &cfg.Config
+		}},
+	} {
+		t.Run(tt.cmd.Name(), func(t *testing.T) {
+			assert.Equal(t, common.FormatExamples(tt.examples), tt.cmd.Example)
+			for _, example := range tt.examples {
+				args := example.Args()
+				require.NotEmpty(t, args, example.Command)
+				assert.Equal(t, tt.cmd.Name(), args[0], example.Command)
+
+				cfg, commonCfg := tt.newConfig()
+				fs := pflag.NewFlagSet(tt.cmd.Name(), pflag.ContinueOnError)
+				cfg.Flags(fs)
+				require.NoError(t, fs.Parse(args[1:]), example.Command)
+				assertValidExample(t, example, cfg, commonCfg)
+			}
+		})
+	}
+}
+
+func TestAllExamples(t *testing.T) {
+	assert.Equal(t, common.FormatExamples(allExamples), allCmd.Example)
+	for _, example := range allExamples {
+		args := example.Args()
+		require.NotEmpty(t, args, example.Command)
+		assert.Equal(t, "all", args[0], example.Command)
+
+		shared := &common.Config{}
+		shared.SetDefaults()
+		fs := pflag.NewFlagSet("all", pflag.ContinueOnError)
+		shared.CommonFlags(fs)
+		var withTraces, withMetrics, withLogs, correlateLogs, correlateExemplars bool
+		fs.BoolVar(&withTraces, "traces", false, "")
+		fs.BoolVar(&withMetrics, "metrics", false, "")
+		fs.BoolVar(&withLogs, "logs", false, "")
+		fs.BoolVar(&correlateLogs, "correlate-logs", false, "")
+		fs.BoolVar(&correlateExemplars, "correlate-exemplars", false, "")
+		fs.StringVar(&shared.HTTPPath, "otlp-http-url-path", shared.HTTPPath, "")
+		cfg := traces.NewConfig()
+		var logLevel, metricsLogLevel, logsLogLevel string
+		fs.StringVar(&logLevel, "log-level", "", "")
+		fs.StringVar(&cfg.TracesLogLevel, "traces-log-level", "", "")
+		fs.StringVar(&metricsLogLevel, "metrics-log-level", "", "")
+		fs.StringVar(&logsLogLevel, "logs-log-level", "", "")
+		require.NoError(t, fs.Parse(args[1:]), example.Command)
+		assert.True(t, withTraces || withMetrics || withLogs, example.Command)
+		assert.True(t, !correlateLogs || withTraces && withLogs, example.Command)
+		assert.True(t, !correlateExemplars || withTraces && withMetrics, example.Command)
+
+		shareCommonConfig(&cfg.Config, shared)
+		assertValidExample(t, example, cfg, &cfg.Config)
+	}
+}
+
+// assertValidExample checks that the parsed config of example validates, and that its
+// mock templates expand.
+func assertValidExample(t *testing.T, example common.Example, cfg exampleConfig, commonCfg *common.Config) {
+	t.Helper()
+	require.NoError(t, cfg.InitAttributes(), example.Command)
+	require.NoError(t, cfg.Validate(), example.Command)
+	if !commonCfg.MockData {
+		return
+	}
+	_, err := commonCfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err, example.Command)
+	_, err = commonCfg.GetTelemetryAttrWithMockMarker()
+	require.NoError(t, err, example.Command)
+	_, err = commonCfg.GetHeadersWithMockMarker()
+	require.NoError(t, err, example.Command)
+}
@@ -0,0 +1,165 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/viper"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+var (
+	// reloadMu keeps a reload from reading the settings of the command while they are
+	// loaded: activeCommand, the config file and the configs it is unmarshaled into.
+	reloadMu sync.Mutex
+
+	// loadedAttributes are the attribute options of the config file as loaded at startup,
+	// see attributeOptions.
+	loadedAttributes map[string]any
+)
+
+// watchReloads reloads the config file each time the process gets SIGHUP, until stop is
+// called. Windows never delivers SIGHUP, so there the config is never reloaded.
+func watchReloads() (stop func()) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range hup {
+			if err := reloadConfig(); err != nil {
+				fmt.Println("Error reloading config file:", err)
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hup)
+		close(hup)
+		<-done
+	}
+}
+
+// reloadConfig re-reads the config file and applies its rate to the running generators,
+// and to their next runs with `repeat`. The other settings are only read at startup, so
+// changing them needs a restart: the attributes are reported as such when they changed.
+func reloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	if configFile == "" {
+		return errors.New("no `--config` file to reload")
+	}
+	// a viper of its own, the running command being configured from the global one
+	v := viper.New()
+	v.SetConfigFile(configFile)
+	v.AutomaticEnv()
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+	file, err := profileConfig(v)
+	if err != nil {
+		return err
+	}
+
+	var section, schedule string // `rate-schedule`, whose next step overrides the reloaded rate
+	switch activeCommand {
+	case "traces":
+		section, schedule = "traces", tracesCfg.RateSchedule
+	case "metrics":
+		section, schedule = "metrics", metricsCfg.RateSchedule
+	case "logs":
+		section, schedule = "logs", logsCfg.RateSchedule
+	case "all":
+		// the all command shares its settings with every signal
+		section, schedule = "all", allCfg.RateSchedule
+	default:
+		return nil
+	}
+	if changed := changedAttributeOptions(loadedAttributes, fileAttributeOptions(file)); len(changed) > 0 {
+		fmt.Printf("Not reloaded: the attributes are only read at startup, restart to apply the changes of %s\n", strings.Join(changed, ", "))
+	}
+	r, ok := reloadedRate(file, section)
+	if !ok {
+		fmt.Printf("Reloaded config file %s: it sets no rate, the current one is kept\n", configFile)
+		return nil
+	}
+	if err := common.ApplyRate(r); err != nil {
+		return err
+	}
+	fmt.Printf("Reloaded config file %s: rate is now %v per second, other changes need a restart\n", configFile, r)
+	if schedule != "" {
+		fmt.Printf("The `rate-schedule` %s sets the rate again at its next step\n", schedule)
+	}
+	return nil
+}
+
+// reloadedRate returns the rate the config file sets in section, falling back on its
+// global rate, and false when it sets none.
+func reloadedRate(file *viper.Viper, section string) (float64, bool) {
+	if section != "" && file.IsSet(section+".rate") {
+		return file.GetFloat64(section + ".rate"), true
+	}
+	if file.IsSet("rate") {
+		return file.GetFloat64("rate"), true
+	}
+	return 0, false
+}
+
+// attributeOptions returns the attribute options of the config file read by viper, or
+// nil without a config file.
+func attributeOptions() map[string]any {
+	if configFile == "" {
+		return nil
+	}
+	file, err := fileConfig()
+	if err != nil {
+		return nil
+	}
+	return fileAttributeOptions(file)
+}
+
+// fileAttributeOptions returns the value of each attribute option of file, like
+// `telemetry-attributes` or `metrics.metric-datapoint-attributes`, by dotted key.
+func fileAttributeOptions(file *viper.Viper) map[string]any {
+	options := map[string]any{}
+	for _, key := range file.AllKeys() {
+		// viper flattens the attribute maps into a key per attribute
+		parts := strings.Split(key, ".")
+		for i, part := range parts {
+			if strings.Contains(part, "attribute") {
+				option := strings.Join(parts[:i+1], ".")
+				options[option] = file.Get(option)
+				break
+			}
+		}
+	}
+	return options
+}
+
+// changedAttributeOptions returns the sorted keys of the attribute options set, changed
+// or removed between loaded and reloaded.
+func changedAttributeOptions(loaded, reloaded map[string]any) []string {
+	var changed []string
+	for key, value := range reloaded {
+		if !reflect.DeepEqual(loaded[key], value) {
+			changed = append(changed, "`"+key+"`")
+		}
+	}
+	for key := range loaded {
+		if _, ok := reloaded[key]; !ok {
+			changed = append(changed, "`"+key+"`")
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
@@ -0,0 +1,26 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import "strings"
+
+// DropsAttribute reports whether to leave the attribute of key out of a span or log
+// record, as an SDK over its attribute limits does, which happens with probability p for
+// `attribute-drop-probability`. The trazr.* markers describing the generated data are
+// never left out.
+func DropsAttribute(key string, p float64) bool {
+	return !strings.HasPrefix(key, "trazr.") && Chance(p)
+}
+
+// KeepAttributes returns the attributes of attrs, whose keys key returns, that
+// DropsAttribute keeps with probability p, along with the number of the others.
+func KeepAttributes[T any](attrs []T, key func(T) string, p float64) ([]T, int) {
+	kept := make([]T, 0, len(attrs))
+	for _, attr := range attrs {
+		if !DropsAttribute(key(attr), p) {
+			kept = append(kept, attr)
+		}
+	}
+	return kept, len(attrs) - len(kept)
+}
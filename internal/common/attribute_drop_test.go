@@ -0,0 +1,27 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropsAttribute(t *testing.T) {
+	assert.False(t, DropsAttribute("user.id", 0))
+	assert.True(t, DropsAttribute("user.id", 1))
+	assert.False(t, DropsAttribute("trazr.mock.data", 1), "the markers are always kept")
+}
+
+func TestKeepAttributes(t *testing.T) {
+	key := func(k string) string { return k }
+	kept, dropped := KeepAttributes([]string{"user.id", "trazr.mock.data"}, key, 1)
+	assert.Equal(t, []string{"trazr.mock.data"}, kept)
+	assert.Equal(t, 1, dropped)
+
+	kept, dropped = KeepAttributes([]string{"user.id"}, key, 0)
+	assert.Equal(t, []string{"user.id"}, kept)
+	assert.Zero(t, dropped)
+}
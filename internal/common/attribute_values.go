@@ -0,0 +1,75 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// loadAttributeValues reads the candidate values of each `attribute-values` key from its
+// file, one value per line. Blank lines are skipped.
+func (c *Config) loadAttributeValues() error {
+	if len(c.AttributeValueFiles) == 0 {
+		c.attributeValues = nil
+		return nil
+	}
+	convert := func(k string) string { return k }
+	if c.AttributesCase != "" {
+		var err error
+		if convert, err = keyNormalizer(c.AttributesCase); err != nil {
+			return err
+		}
+	}
+
+	c.attributeValues = make(map[string][]string, len(c.AttributeValueFiles))
+	for k, path := range c.AttributeValueFiles {
+		key := convert(k)
+		if _, ok := c.TelemetryAttributes[key]; ok {
+			return fmt.Errorf("attribute %q is set by both `telemetry-attributes` and `attribute-values`", key)
+		}
+		values, err := readValueLines(fmt.Sprint(path))
+		if err != nil {
+			return fmt.Errorf("failed to read the values of attribute %q: %w", key, err)
+		}
+		c.attributeValues[key] = values
+	}
+	return nil
+}
+
+// readValueLines returns the non-blank lines of a file, trimmed.
+// #nosec G304 -- path is controlled by configuration, not user input
+func readValueLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			values = append(values, line)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s has no values", path)
+	}
+	return values, nil
+}
+
+// drawAttributeValues returns one attribute per `attribute-values` key, whose value is
+// a line of its file picked at random.
+func (c *Config) drawAttributeValues() []attribute.KeyValue {
+	if len(c.attributeValues) == 0 {
+		return nil
+	}
+	result := make([]attribute.KeyValue, 0, len(c.attributeValues))
+	for _, k := range sortedKeys(c.attributeValues) {
+		values := c.attributeValues[k]
+		result = append(result, attribute.String(k, values[RandIntN(len(values))]))
+	}
+	return result
+}
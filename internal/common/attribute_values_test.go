@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeValuesFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "values.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestAttributeValues(t *testing.T) {
+	agents := []string{"Mozilla/5.0 (X11; Linux x86_64)", "curl/8.5.0", "okhttp/4.12.0"}
+	cfg := &Config{
+		TelemetryAttributes: KeyValue{"env": "prod"},
+		AttributeValueFiles: KeyValue{"user_agent.original": writeValuesFile(t, "Mozilla/5.0 (X11; Linux x86_64)\n\n  curl/8.5.0  \nokhttp/4.12.0\n")},
+	}
+	require.NoError(t, cfg.InitAttributes())
+	assert.Equal(t, agents, cfg.attributeValues["user_agent.original"], "blank lines should be skipped and values trimmed")
+
+	seen := map[string]bool{}
+	for range 100 {
+		attrs, err := cfg.GetTelemetryAttrWithMockMarker()
+		require.NoError(t, err)
+		require.Len(t, attrs, 2)
+		assert.Equal(t, "env", string(attrs[0].Key))
+		assert.Equal(t, "user_agent.original", string(attrs[1].Key))
+		assert.Contains(t, agents, attrs[1].Value.AsString())
+		seen[attrs[1].Value.AsString()] = true
+	}
+	assert.Len(t, seen, len(agents), "every value should eventually be picked")
+}
+
+func TestAttributeValues_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr string
+	}{
+		{
+			name: "missing file",
+			cfg: &Config{
+				AttributeValueFiles: KeyValue{"url.full": filepath.Join(t.TempDir(), "missing.txt")},
+			},
+			wantErr: "failed to read the values of attribute \"url.full\"",
+		},
+		{
+			name: "empty file",
+			cfg: &Config{
+				AttributeValueFiles: KeyValue{"url.full": writeValuesFile(t, "\n \n")},
+			},
+			wantErr: "has no values",
+		},
+		{
+			name: "also a telemetry attribute",
+			cfg: &Config{
+				TelemetryAttributes: KeyValue{"url.full": "https://example.com"},
+				AttributeValueFiles: KeyValue{"url.full": writeValuesFile(t, "https://example.org\n")},
+			},
+			wantErr: "attribute \"url.full\" is set by both `telemetry-attributes` and `attribute-values`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.InitAttributes()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -1,25 +1,50 @@
 package common
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
 )
 
 // ProcessMockMarkers expands gofakeit/mock templates in attrs (if mockData is true), type-detects values, and appends a trazr.mock.data marker if any mock keys.
+// Attributes come out sorted by key, and so do the keys listed in the marker.
 // It does NOT perform key injection; it operates on the raw attribute values.
 func ProcessMockMarkers(attrs map[string]any) ([]attribute.KeyValue, error) {
+	result, _, err := processMockMarkers(attrs, false)
+	return result, err
+}
+
+// ProcessMockMarkersSkipping is ProcessMockMarkers, except that attributes whose template
+// fails are dropped instead of failing the whole set. The returned error joins their failures.
+func ProcessMockMarkersSkipping(attrs map[string]any) ([]attribute.KeyValue, error) {
+	result, skipped, _ := processMockMarkers(attrs, true)
+	return result, errors.Join(skipped...)
+}
+
+// processMockMarkers returns the processed attributes and, when skipBad is set, the
+// failures of the attributes it dropped.
+func processMockMarkers(attrs map[string]any, skipBad bool) ([]attribute.KeyValue, []error, error) {
 	var result []attribute.KeyValue
 	var mockKeys []string
-	for k, t := range attrs {
-		switch v := t.(type) {
+	var skipped []error
+	for _, k := range sortedKeys(attrs) {
+		switch v := attrs[k].(type) {
 		case string:
 			if strings.Contains(v, "{{") && strings.Contains(v, "}}") {
 				parsed, err := ProcessMockTemplate(v, nil)
 				if err != nil {
-					return nil, err
+					if !skipBad {
+						return nil, nil, err
+					}
+					skipped = append(skipped, fmt.Errorf("attribute %q: %w", k, err))
+					continue
 				}
 				// Try to parse as int
 				if intVal, err := strconv.Atoi(parsed); err == nil {
@@ -45,82 +70,267 @@ func ProcessMockMarkers(attrs map[string]any) ([]attribute.KeyValue, error) {
 				continue
 			}
 			result = append(result, attribute.String(k, v))
-		case bool:
-			result = append(result, attribute.Bool(k, v))
-		case int:
-			result = append(result, attribute.Int(k, v))
+		default:
+			if kv, ok := scalarAttribute(k, v); ok {
+				result = append(result, kv)
+			}
 		}
 	}
-	fmt.Println("RESULT:", result)
 	if len(mockKeys) > 0 {
 		result = append(result, attribute.String("trazr.mock.data", strings.Join(mockKeys, ",")))
 	}
-	return result, nil
+	return result, skipped, nil
 }
 
-// attributesFromMap converts a map[string]any to a slice of attribute.KeyValue.
+// sortedKeys returns the keys of attrs in sorted order. Attributes are built in that
+// order so that every item, and every run with the same configuration, lists them
+// the same way, which keeps captured payloads comparable.
+func sortedKeys[V any](attrs map[string]V) []string {
+	return slices.Sorted(maps.Keys(attrs))
+}
+
+// attributesFromMap converts a map[string]any to a slice of attribute.KeyValue, sorted by key.
 func attributesFromMap(attrs map[string]any) []attribute.KeyValue {
 	var result []attribute.KeyValue
-	for k, v := range attrs {
-		switch val := v.(type) {
+	for _, k := range sortedKeys(attrs) {
+		if kv, ok := scalarAttribute(k, attrs[k]); ok {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+// scalarAttribute converts a config scalar to an attribute of the matching type, so that
+// YAML ints, floats, bools and strings keep their type. Arrays become slice attributes,
+// see sliceAttribute. Other values are not supported.
+func scalarAttribute(k string, v any) (attribute.KeyValue, bool) {
+	switch val := v.(type) {
+	case string:
+		return attribute.String(k, val), true
+	case bool:
+		return attribute.Bool(k, val), true
+	case int:
+		return attribute.Int(k, val), true
+	case int64:
+		return attribute.Int64(k, val), true
+	case float64:
+		return attribute.Float64(k, val), true
+	case []any:
+		return sliceAttribute(k, val), true
+	}
+	return attribute.KeyValue{}, false
+}
+
+// sliceAttribute converts a YAML or JSON array to a slice attribute, like the string
+// array semconv defines for host.ip. Arrays of whole numbers become int64 slices, other
+// numbers float64 slices. Mixed arrays become string slices of their formatted elements.
+func sliceAttribute(k string, arr []any) attribute.KeyValue {
+	var strs []string
+	var bools []bool
+	var ints []int64
+	var floats []float64
+	integral := true
+	for _, e := range arr {
+		switch val := e.(type) {
 		case string:
-			result = append(result, attribute.String(k, val))
+			strs = append(strs, val)
 		case bool:
-			result = append(result, attribute.Bool(k, val))
+			bools = append(bools, val)
 		case int:
-			result = append(result, attribute.Int(k, val))
+			ints = append(ints, int64(val))
+			floats = append(floats, float64(val))
+		case int64:
+			ints = append(ints, val)
+			floats = append(floats, float64(val))
+		case float64:
+			if val != math.Trunc(val) || math.Abs(val) > math.MaxInt64 {
+				integral = false
+			}
+			ints = append(ints, int64(val))
+			floats = append(floats, val)
 		}
 	}
-	return result
+	switch len(arr) {
+	case len(strs):
+		return attribute.StringSlice(k, strs)
+	case len(bools):
+		return attribute.BoolSlice(k, bools)
+	case len(floats):
+		if integral {
+			return attribute.Int64Slice(k, ints)
+		}
+		return attribute.Float64Slice(k, floats)
+	}
+	mixed := make([]string, len(arr))
+	for i, e := range arr {
+		mixed[i] = fmt.Sprint(e)
+	}
+	return attribute.StringSlice(k, mixed)
+}
+
+// forceInt64 converts the attributes of attrs listed in `attributes-int64` to int64 ones:
+// their string values are parsed, and their float values must be whole numbers.
+func (c *Config) forceInt64(attrs []attribute.KeyValue) ([]attribute.KeyValue, error) {
+	if len(c.Int64Attributes) == 0 {
+		return attrs, nil
+	}
+	for i, attr := range attrs {
+		if !slices.Contains(c.Int64Attributes, string(attr.Key)) {
+			continue
+		}
+		switch attr.Value.Type() {
+		case attribute.INT64:
+			continue
+		case attribute.STRING:
+			v, err := strconv.ParseInt(strings.TrimSpace(attr.Value.AsString()), 10, 64)
+			if err != nil {
+				return nil, ValidationErrorf("attributes-int64", "attribute %q of `attributes-int64`: expected a 64-bit integer, got %q instead", attr.Key, attr.Value.AsString())
+			}
+			attrs[i] = attribute.Int64(string(attr.Key), v)
+		case attribute.FLOAT64:
+			v := attr.Value.AsFloat64()
+			// 2^63 itself is out of range, as is anything above it
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return nil, ValidationErrorf("attributes-int64", "attribute %q of `attributes-int64`: expected a 64-bit integer, got %v instead", attr.Key, v)
+			}
+			attrs[i] = attribute.Int64(string(attr.Key), int64(v))
+		default:
+			return nil, ValidationErrorf("attributes-int64", "attribute %q of `attributes-int64`: expected a 64-bit integer, got a %s value instead", attr.Key, strings.ToLower(attr.Value.Type().String()))
+		}
+	}
+	return attrs, nil
+}
+
+// resolveMockMarkers processes the mock markers of attrs, dropping the attributes with
+// a failing template when `skip-bad-attributes` is set. WarnBadAttributes reports those.
+func (c *Config) resolveMockMarkers(attrs map[string]any) ([]attribute.KeyValue, error) {
+	if c.SkipBadAttributes {
+		result, _ := ProcessMockMarkersSkipping(attrs)
+		return result, nil
+	}
+	return ProcessMockMarkers(attrs)
+}
+
+// WarnBadAttributes logs the resource and telemetry attributes whose mock template fails
+// and that `skip-bad-attributes` therefore drops. It does nothing when the flag is unset.
+func (c *Config) WarnBadAttributes(logger *zap.Logger) {
+	if !c.SkipBadAttributes || !c.MockData {
+		return
+	}
+	WarnBadTemplates(logger, "resource", c.ResourceAttributes)
+	WarnBadTemplates(logger, "telemetry", c.TelemetryAttributes)
+}
+
+// WarnBadTemplates logs a warning for each attribute of attrs whose mock template fails.
+func WarnBadTemplates(logger *zap.Logger, kind string, attrs map[string]any) {
+	_, skipped, _ := processMockMarkers(attrs, true)
+	for _, err := range skipped {
+		logger.Warn("skipping attribute with a bad mock template", zap.String("attributes", kind), zap.Error(err))
+	}
 }
 
 // GetResourceAttrWithMockMarker returns resource attributes as OpenTelemetry KeyValue pairs, including:
 // - service.name
 // - all resource attributes
 // - trazr.mock.data (keys with mock data templates)
+// - the attributes set to null, when NullAttributes is set
+// - trazr.generated=true when TagSynthetic is set
+// - trazr.pad.<n> synthetic attributes when ResourceAttrCount is set
 // Note: logBody is not relevant for resource attributes, so pass "".
 func (c *Config) GetResourceAttrWithMockMarker() ([]attribute.KeyValue, error) {
 	var attrs []attribute.KeyValue
 	var err error
 	if c.MockData {
-		attrs, err = ProcessMockMarkers(c.ResourceAttributes)
+		attrs, err = c.resolveMockMarkers(c.ResourceAttributes)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		attrs = attributesFromMap(c.ResourceAttributes)
 	}
+	if attrs, err = c.forceInt64(attrs); err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, c.nullAttributes(c.ResourceAttributes)...)
 	// Ensure service.name is always present as a resource attribute
-	found := false
+	found, tagged := false, false
 	for _, attr := range attrs {
-		if string(attr.Key) == "service.name" {
+		switch string(attr.Key) {
+		case "service.name":
 			found = true
-			break
+		case syntheticAttributeKey:
+			tagged = true
 		}
 	}
 	if !found && c.ServiceName != "" {
 		attrs = append(attrs, attribute.String("service.name", c.ServiceName))
 	}
-	return attrs, nil
+	// an explicit resource attribute takes precedence
+	if c.TagSynthetic && !tagged {
+		attrs = append(attrs, attribute.Bool(syntheticAttributeKey, true))
+	}
+	return append(attrs, paddingAttributes(c.ResourceAttrCount)...), nil
+}
+
+// syntheticAttributeKey is the resource attribute of `tag-synthetic`, telling generated
+// telemetry apart from real traffic.
+const syntheticAttributeKey = "trazr.generated"
+
+// paddingAttributes returns n synthetic attributes used to inflate the resource for stress testing.
+func paddingAttributes(n int) []attribute.KeyValue {
+	if n <= 0 {
+		return nil
+	}
+	result := make([]attribute.KeyValue, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, attribute.String("trazr.pad."+strconv.Itoa(i), "value-"+strconv.Itoa(i)))
+	}
+	return result
 }
 
 // GetTelemetryAttrWithMockMarker returns telemetry attributes as OpenTelemetry KeyValue pairs, including:
 // - all telemetry attributes
 // - trazr.mock.data (keys with mock data templates)
+// - one attribute per `attribute-values` key, with a value drawn from its file
+// - the attributes set to null, when NullAttributes is set
+// - service.name, when DuplicateServiceName is set and no telemetry attribute overrides it
 // Note: logBody is not relevant for telemetry attributes, so pass "".
 func (c *Config) GetTelemetryAttrWithMockMarker() ([]attribute.KeyValue, error) {
+	return c.SignalAttrWithMockMarker(c.TelemetryAttributes)
+}
+
+// SignalAttrWithMockMarker returns the given span, metric or log attributes as OpenTelemetry
+// KeyValue pairs, with the mock data marker, `attribute-values` and duplicated service.name of GetTelemetryAttrWithMockMarker.
+func (c *Config) SignalAttrWithMockMarker(signalAttrs KeyValue) ([]attribute.KeyValue, error) {
+	var attrs []attribute.KeyValue
+	var err error
 	if c.MockData {
-		return ProcessMockMarkers(c.TelemetryAttributes)
+		attrs, err = c.resolveMockMarkers(signalAttrs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		attrs = attributesFromMap(signalAttrs)
+	}
+	attrs = append(attrs, c.drawAttributeValues()...)
+	if attrs, err = c.forceInt64(attrs); err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, c.nullAttributes(signalAttrs)...)
+	if c.DuplicateServiceName && c.ServiceName != "" {
+		if _, ok := signalAttrs["service.name"]; !ok {
+			attrs = append(attrs, attribute.String("service.name", c.ServiceName))
+		}
 	}
-	return attributesFromMap(c.TelemetryAttributes), nil
+	return attrs, nil
 }
 
 // GetHeadersWithMockMarker processes headers for mock templates and adds an 'X-trazr.mock.data' header listing all header keys that used mock data.
 func (c *Config) GetHeadersWithMockMarker() (map[string]string, error) {
 	result := make(map[string]string, len(c.Headers))
 	var mockKeys []string
-	for k, v := range c.Headers {
-		switch val := v.(type) {
+	for _, k := range sortedKeys(c.Headers) {
+		switch val := c.Headers[k].(type) {
 		case string:
 			if c.MockData && strings.Contains(val, "{{") && strings.Contains(val, "}}") {
 				parsed, err := ProcessMockTemplate(val, nil)
@@ -136,6 +346,10 @@ func (c *Config) GetHeadersWithMockMarker() (map[string]string, error) {
 			result[k] = strconv.FormatBool(val)
 		case int:
 			result[k] = strconv.Itoa(val)
+		case int64:
+			result[k] = strconv.FormatInt(val, 10)
+		case float64:
+			result[k] = strconv.FormatFloat(val, 'f', -1, 64)
 		}
 	}
 	if len(mockKeys) > 0 {
@@ -144,17 +358,30 @@ func (c *Config) GetHeadersWithMockMarker() (map[string]string, error) {
 	return result, nil
 }
 
-// InjectSensitiveDataMarker adds the 'trazr.sensitive.data' key to attrs if any sensitive keys are present.
-// Call this once at startup after config and attributes are loaded.
+// InjectSensitiveDataMarker adds the 'trazr.sensitive.data' key to attrs if any sensitive keys are present,
+// along with a 'trazr.sensitive.data.<level>' key listing the present keys of each level, for the
+// `sensitive-data` entries with one, like patient.ssn:pii.
+// Each key is listed once, and the markers replace any previous ones, so that injecting them again is a no-op.
 func InjectSensitiveDataMarker(attrs map[string]any, sensitiveKeys []string) {
 	var present []string
-	for _, k := range sensitiveKeys {
-		if _, ok := attrs[k]; ok {
+	byLevel := map[string][]string{}
+	for _, entry := range sensitiveKeys {
+		k, level := SensitiveKey(entry)
+		if _, ok := attrs[k]; !ok || strings.HasPrefix(k, sensitiveDataMarker) {
+			continue
+		}
+		if !slices.Contains(present, k) {
 			present = append(present, k)
 		}
+		if level != "" && !slices.Contains(byLevel[level], k) {
+			byLevel[level] = append(byLevel[level], k)
+		}
 	}
 	if len(present) > 0 {
-		attrs["trazr.sensitive.data"] = strings.Join(present, ",")
+		attrs[sensitiveDataMarker] = strings.Join(present, ",")
+	}
+	for level, keys := range byLevel {
+		attrs[sensitiveDataMarker+"."+level] = strings.Join(keys, ",")
 	}
 }
 
@@ -7,8 +7,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestGetResourceAttrWithMockMarker_SensitiveAndNonSensitive(t *testing.T) {
@@ -210,6 +214,44 @@ func TestProcessMockMarkers_Error(t *testing.T) {
 	}
 }
 
+func TestProcessMockMarkersSkipping(t *testing.T) {
+	attrs := map[string]any{
+		"bad":  "{{InvalidFunc}}",
+		"user": "{{FirstName}}",
+	}
+	result, err := ProcessMockMarkersSkipping(attrs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `attribute "bad"`)
+
+	attrMap := map[string]attribute.KeyValue{}
+	for _, a := range result {
+		attrMap[string(a.Key)] = a
+	}
+	assert.NotContains(t, attrMap, "bad")
+	assert.Contains(t, attrMap, "user")
+	assert.Equal(t, "user", attrMap["trazr.mock.data"].Value.AsString())
+}
+
+func TestSkipBadAttributes(t *testing.T) {
+	cfg := &Config{
+		TelemetryAttributes: KeyValue{"bad": "{{InvalidFunc}}", "good": "ok"},
+		MockData:            true,
+	}
+	_, err := cfg.GetTelemetryAttrWithMockMarker()
+	require.Error(t, err, "a bad template fails the attributes by default")
+
+	cfg.SkipBadAttributes = true
+	attrs, err := cfg.GetTelemetryAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Equal(t, []attribute.KeyValue{attribute.String("good", "ok")}, attrs)
+
+	core, logs := observer.New(zap.WarnLevel)
+	cfg.WarnBadAttributes(zap.New(core))
+	entries := logs.FilterMessage("skipping attribute with a bad mock template").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "telemetry", entries[0].ContextMap()["attributes"])
+}
+
 func TestGetHeadersWithMockMarker_Error(t *testing.T) {
 	cfg := &Config{
 		Headers:  KeyValue{"bad": "{{InvalidFunc}}"},
@@ -226,7 +268,8 @@ func TestAttributesFromMap(t *testing.T) {
 		"str":         "value",
 		"bool":        true,
 		"int":         42,
-		"unsupported": 3.14, // should be ignored
+		"float":       3.14,
+		"unsupported": []string{"a"}, // should be ignored
 	}
 	result := attributesFromMap(attrs)
 	attrMap := map[string]attribute.KeyValue{}
@@ -236,9 +279,114 @@ func TestAttributesFromMap(t *testing.T) {
 	assert.Equal(t, "value", attrMap["str"].Value.AsString())
 	assert.Equal(t, attribute.BOOL, attrMap["bool"].Value.Type())
 	assert.Equal(t, attribute.INT64, attrMap["int"].Value.Type())
+	assert.Equal(t, attribute.FLOAT64, attrMap["float"].Value.Type())
 	assert.NotContains(t, attrMap, "unsupported")
 }
 
+func TestForceInt64(t *testing.T) {
+	cfg := &Config{
+		MockData:           true,
+		ServiceName:        "svc",
+		Int64Attributes:    []string{"nanos", "mock", "whole", "int"},
+		ResourceAttributes: KeyValue{"nanos": "1700000000123456789", "other": "42"},
+		TelemetryAttributes: KeyValue{
+			"mock":  "{{Number 1 9}}",
+			"whole": 1e15,
+			"int":   7,
+		},
+	}
+	res, err := cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Contains(t, res, attribute.Int64("nanos", 1700000000123456789))
+	assert.Contains(t, res, attribute.String("other", "42"), "unlisted keys keep their type")
+
+	tel, err := cfg.GetTelemetryAttrWithMockMarker()
+	require.NoError(t, err)
+	types := map[attribute.Key]attribute.Type{}
+	for _, a := range tel {
+		types[a.Key] = a.Value.Type()
+	}
+	assert.Equal(t, attribute.INT64, types["mock"])
+	assert.Equal(t, attribute.INT64, types["whole"])
+	assert.Equal(t, attribute.INT64, types["int"])
+	assert.Contains(t, tel, attribute.Int64("whole", 1e15))
+
+	for _, value := range []any{"not a number", 1.5, true} {
+		cfg.TelemetryAttributes = KeyValue{"int": value}
+		_, err = cfg.GetTelemetryAttrWithMockMarker()
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr, "value %v", value)
+		assert.Equal(t, "attributes-int64", validationErr.Field)
+		assert.Contains(t, err.Error(), "attribute \"int\" of `attributes-int64`: expected a 64-bit integer")
+	}
+}
+
+func TestAttributeOrder(t *testing.T) {
+	cfg := &Config{
+		MockData: true,
+		TelemetryAttributes: KeyValue{
+			"zeta":  "{{FirstName}}",
+			"alpha": "a",
+			"mid":   7,
+			"beta":  "{{LastName}}",
+			"gamma": true,
+		},
+	}
+	keys := func(attrs []attribute.KeyValue) []string {
+		result := make([]string, len(attrs))
+		for i, a := range attrs {
+			result[i] = string(a.Key)
+		}
+		return result
+	}
+
+	// map iteration is random, so repeat to catch an order that depends on it
+	for range 20 {
+		attrs, err := cfg.GetTelemetryAttrWithMockMarker()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alpha", "beta", "gamma", "mid", "zeta", "trazr.mock.data"}, keys(attrs))
+		assert.Equal(t, "beta,zeta", attrs[len(attrs)-1].Value.AsString())
+
+		cfg.MockData = false
+		attrs, err = cfg.GetTelemetryAttrWithMockMarker()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alpha", "beta", "gamma", "mid", "zeta"}, keys(attrs))
+		cfg.MockData = true
+	}
+}
+
+func TestSliceAttribute(t *testing.T) {
+	tests := []struct {
+		name     string
+		arr      []any
+		expected attribute.KeyValue
+	}{
+		{"strings", []any{"10.0.0.1", "10.0.0.2"}, attribute.StringSlice("k", []string{"10.0.0.1", "10.0.0.2"})},
+		{"bools", []any{true, false}, attribute.BoolSlice("k", []bool{true, false})},
+		{"yaml ints", []any{1, int64(2)}, attribute.Int64Slice("k", []int64{1, 2})},
+		{"json whole numbers", []any{1.0, 2.0}, attribute.Int64Slice("k", []int64{1, 2})},
+		{"floats", []any{1.0, 2.5}, attribute.Float64Slice("k", []float64{1, 2.5})},
+		{"mixed", []any{"a", 1, true}, attribute.StringSlice("k", []string{"a", "1", "true"})},
+		{"empty", []any{}, attribute.StringSlice("k", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, sliceAttribute("k", tt.arr))
+		})
+	}
+}
+
+func TestGetResourceAttrWithMockMarker_Array(t *testing.T) {
+	kv := KeyValue{}
+	require.NoError(t, kv.Set(`host.ip=["10.0.0.1","10.0.0.2"]`))
+	cfg := &Config{ResourceAttributes: kv, MockData: true}
+	require.NoError(t, cfg.InitAttributes())
+
+	attrs, err := cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Contains(t, attrs, attribute.StringSlice("host.ip", []string{"10.0.0.1", "10.0.0.2"}))
+}
+
 func TestInjectSensitiveDataMarker(t *testing.T) {
 	attrs := map[string]any{"foo": 1, "bar": 2}
 	InjectSensitiveDataMarker(attrs, []string{"foo", "baz"})
@@ -251,3 +399,106 @@ func TestInjectSensitiveDataMarker(t *testing.T) {
 	_, ok = attrs2["trazr.sensitive.data"]
 	assert.False(t, ok)
 }
+
+func TestGetResourceAttrWithMockMarker_TagSynthetic(t *testing.T) {
+	cfg := &Config{ServiceName: "svc", ResourceAttributes: KeyValue{"env": "prod"}}
+	attrs, err := cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.NotContains(t, attrs, attribute.Bool("trazr.generated", true))
+
+	cfg.TagSynthetic = true
+	attrs, err = cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Contains(t, attrs, attribute.Bool("trazr.generated", true))
+	defaults := &Config{}
+	defaults.SetDefaults()
+	assert.True(t, defaults.TagSynthetic, "synthetic telemetry is tagged by default")
+
+	cfg.ResourceAttributes["trazr.generated"] = "load-test"
+	attrs, err = cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Contains(t, attrs, attribute.String("trazr.generated", "load-test"), "an explicit resource attribute takes precedence")
+	assert.NotContains(t, attrs, attribute.Bool("trazr.generated", true))
+}
+
+func TestGetResourceAttrWithMockMarker_Padding(t *testing.T) {
+	cfg := &Config{
+		ServiceName:        "svc",
+		ResourceAttributes: KeyValue{"a": "A"},
+		ResourceAttrCount:  250,
+	}
+	attrs, err := cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	// a + service.name + padding
+	assert.Len(t, attrs, 252)
+	attrMap := map[string]attribute.KeyValue{}
+	for _, a := range attrs {
+		attrMap[string(a.Key)] = a
+	}
+	assert.Equal(t, "value-0", attrMap["trazr.pad.0"].Value.AsString())
+	assert.Equal(t, "value-249", attrMap["trazr.pad.249"].Value.AsString())
+	assert.Empty(t, paddingAttributes(0))
+}
+
+func TestGetTelemetryAttrWithMockMarker_DuplicateServiceName(t *testing.T) {
+	cfg := &Config{
+		ServiceName:         "checkout",
+		TelemetryAttributes: KeyValue{"a": "A"},
+	}
+	attrs, err := cfg.GetTelemetryAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.NotContains(t, attrs, attribute.String("service.name", "checkout"), "service.name is resource-only by default")
+
+	cfg.DuplicateServiceName = true
+	attrs, err = cfg.GetTelemetryAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Contains(t, attrs, attribute.String("service.name", "checkout"))
+
+	cfg.TelemetryAttributes["service.name"] = "override"
+	attrs, err = cfg.GetTelemetryAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Len(t, attrs, 2)
+	assert.Contains(t, attrs, attribute.String("service.name", "override"))
+}
+
+func TestGetResourceAttrWithMockMarker_YAMLTypes(t *testing.T) {
+	yaml := `
+otlp-attributes:
+  port: 8080
+  port_string: "8080"
+  ratio: 0.75
+  enabled: true
+  enabled_string: "true"
+  big: 9007199254740993
+  name: checkout
+  nested:
+    weight: 1.5
+`
+	for _, mockData := range []bool{false, true} {
+		v := viper.New()
+		v.SetConfigType("yaml")
+		require.NoError(t, v.ReadConfig(strings.NewReader(yaml)))
+		cfg := &Config{}
+		require.NoError(t, v.Unmarshal(cfg))
+		cfg.MockData = mockData
+		require.NoError(t, cfg.InitAttributes())
+
+		attrs, err := cfg.GetResourceAttrWithMockMarker()
+		require.NoError(t, err)
+		got := attribute.NewSet(attrs...)
+		for key, want := range map[string]attribute.Value{
+			"port":           attribute.IntValue(8080),
+			"port_string":    attribute.StringValue("8080"),
+			"ratio":          attribute.Float64Value(0.75),
+			"enabled":        attribute.BoolValue(true),
+			"enabled_string": attribute.StringValue("true"),
+			"big":            attribute.Int64Value(9007199254740993),
+			"name":           attribute.StringValue("checkout"),
+			"nested.weight":  attribute.Float64Value(1.5),
+		} {
+			value, ok := got.Value(attribute.Key(key))
+			require.True(t, ok, "mockData=%t: missing %q", mockData, key)
+			assert.Equal(t, want, value, "mockData=%t: %q", mockData, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import "errors"
+
+const (
+	// saturationRatio is the share of the target rate below which the generator is
+	// considered saturated.
+	saturationRatio = 0.9
+	// maxBenchSteps bounds the ramp in case the generator never saturates.
+	maxBenchSteps = 24
+)
+
+// BenchStep is the outcome of one step of a generation benchmark.
+type BenchStep struct {
+	TargetRate float64 // items per second the step asked for
+	Stats      RunStats
+}
+
+// RampRate measures the generator at a rate doubling from startRate until the achieved
+// rate falls below 90% of the target, meaning the generator is saturated. It returns
+// every step measured.
+func RampRate(startRate float64, measure func(rate float64) (RunStats, error)) ([]BenchStep, error) {
+	if startRate <= 0 {
+		return nil, errors.New("the start rate of the benchmark must be greater than 0")
+	}
+	var steps []BenchStep
+	for target := startRate; len(steps) < maxBenchSteps; target *= 2 {
+		stats, err := measure(target)
+		if err != nil {
+			return steps, err
+		}
+		steps = append(steps, BenchStep{TargetRate: target, Stats: stats})
+		if stats.AchievedRate < saturationRatio*target {
+			break
+		}
+	}
+	return steps, nil
+}
+
+// MaxAchievedRate returns the highest rate achieved across steps.
+func MaxAchievedRate(steps []BenchStep) float64 {
+	var highest float64
+	for _, s := range steps {
+		highest = max(highest, s.Stats.AchievedRate)
+	}
+	return highest
+}
@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRampRate(t *testing.T) {
+	// the simulated generator can't go beyond 5000 items per second
+	measure := func(rate float64) (RunStats, error) {
+		return NewRunStats(int64(min(rate, 5000)), time.Second), nil
+	}
+
+	steps, err := RampRate(1000, measure)
+	require.NoError(t, err)
+
+	var targets []float64
+	for _, s := range steps {
+		targets = append(targets, s.TargetRate)
+	}
+	assert.Equal(t, []float64{1000, 2000, 4000, 8000}, targets)
+	assert.InDelta(t, 5000, MaxAchievedRate(steps), 1e-9)
+}
+
+func TestRampRate_Errors(t *testing.T) {
+	_, err := RampRate(0, nil)
+	require.Error(t, err)
+
+	boom := errors.New("boom")
+	steps, err := RampRate(10, func(float64) (RunStats, error) { return RunStats{}, boom })
+	require.ErrorIs(t, err, boom)
+	assert.Empty(t, steps)
+}
+
+func TestRampRate_NeverSaturates(t *testing.T) {
+	steps, err := RampRate(1, func(rate float64) (RunStats, error) {
+		return NewRunStats(int64(rate), time.Second), nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, steps, maxBenchSteps)
+}
@@ -0,0 +1,30 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+// DefaultMaxQueueSize is the number of spans or log records the batch processors queue
+// for export by default, that of the OpenTelemetry SDK.
+const DefaultMaxQueueSize = 2048
+
+// QueueSize returns `max-queue-size`, the cap of the export queues of the batch span and
+// log processors, or DefaultMaxQueueSize when it isn't set.
+func (c *Config) QueueSize() int {
+	if c.MaxQueueSize > 0 {
+		return c.MaxQueueSize
+	}
+	return DefaultMaxQueueSize
+}
+
+// ValidateBoundedMemory checks `max-queue-size`, and that `bounded-memory` isn't combined
+// with `export-timing`, which keeps every interval between exports until the end of the
+// run, so that the memory of a run doesn't grow with the number of items it generates.
+func (c *Config) ValidateBoundedMemory() error {
+	if c.MaxQueueSize < 0 {
+		return ValidationErrorf("max-queue-size", "`max-queue-size` must not be negative")
+	}
+	if c.BoundedMemory && c.ExportTiming {
+		return ValidationErrorf("export-timing", "`export-timing` keeps every interval between exports, which `bounded-memory` rules out")
+	}
+	return nil
+}
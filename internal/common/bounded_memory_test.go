@@ -0,0 +1,37 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueSize(t *testing.T) {
+	assert.Equal(t, DefaultMaxQueueSize, (&Config{}).QueueSize())
+	assert.Equal(t, 100, (&Config{MaxQueueSize: 100}).QueueSize())
+}
+
+func TestValidateBoundedMemory(t *testing.T) {
+	require.NoError(t, (&Config{BoundedMemory: true}).ValidateBoundedMemory())
+	require.NoError(t, (&Config{ExportTiming: true}).ValidateBoundedMemory())
+
+	tests := map[string]struct {
+		cfg   Config
+		field string
+	}{
+		"negative queue":           {cfg: Config{MaxQueueSize: -1}, field: "max-queue-size"},
+		"export timing is unbound": {cfg: Config{BoundedMemory: true, ExportTiming: true}, field: "export-timing"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var verr *ValidationError
+			require.True(t, errors.As(tt.cfg.ValidateBoundedMemory(), &verr))
+			assert.Equal(t, tt.field, verr.Field)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OTLPFeature is a payload feature the workers only emit when the `otlp-compat`
+// release defines it.
+type OTLPFeature struct {
+	Name  string // name used in logs
+	Since string // OTLP proto release that introduced the feature, as major.minor
+}
+
+// FeatureHistogramMinMax is the min and max of histogram data points.
+var FeatureHistogramMinMax = OTLPFeature{Name: "histogram min/max", Since: "0.13"}
+
+// FeatureExponentialHistogram is the ExponentialHistogram metric type, which the metrics
+// generator can't leave out of its data points, so `otlp-compat` rejects it instead.
+var FeatureExponentialHistogram = OTLPFeature{Name: "exponential histograms", Since: "0.11"}
+
+// otlpFeatures lists the features `otlp-compat` leaves out of the payloads.
+var otlpFeatures = []OTLPFeature{FeatureHistogramMinMax}
+
+// protoVersion is a major.minor OTLP proto release.
+type protoVersion struct {
+	major, minor int
+}
+
+func parseProtoVersion(s string) (protoVersion, error) {
+	major, minor, ok := strings.Cut(strings.TrimPrefix(s, "v"), ".")
+	if !ok {
+		return protoVersion{}, fmt.Errorf("invalid `otlp-compat` %q: expected an OTLP release as major.minor, like '0.12'", s)
+	}
+	var v protoVersion
+	var errMajor, errMinor error
+	v.major, errMajor = strconv.Atoi(major)
+	v.minor, errMinor = strconv.Atoi(minor)
+	if errMajor != nil || errMinor != nil || v.major < 0 || v.minor < 0 {
+		return protoVersion{}, fmt.Errorf("invalid `otlp-compat` %q: expected an OTLP release as major.minor, like '0.12'", s)
+	}
+	return v, nil
+}
+
+func (v protoVersion) before(o protoVersion) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	return v.minor < o.minor
+}
+
+// ValidateOTLPCompat checks that an `otlp-compat` value is empty or a major.minor release.
+func ValidateOTLPCompat(compat string) error {
+	if compat == "" {
+		return nil
+	}
+	if _, err := parseProtoVersion(compat); err != nil {
+		return NewValidationError("otlp-compat", err)
+	}
+	return nil
+}
+
+// SupportsOTLP reports whether the feature can be emitted: always without `otlp-compat`,
+// else only when the feature is no newer than the configured release.
+func (c *Config) SupportsOTLP(feature OTLPFeature) bool {
+	if c.OTLPCompat == "" {
+		return true
+	}
+	compat, err := parseProtoVersion(c.OTLPCompat)
+	if err != nil {
+		return true
+	}
+	since, _ := parseProtoVersion(feature.Since)
+	return !compat.before(since)
+}
+
+// OmittedOTLPFeatures returns the names of the features `otlp-compat` leaves out.
+func (c *Config) OmittedOTLPFeatures() []string {
+	var omitted []string
+	for _, feature := range otlpFeatures {
+		if !c.SupportsOTLP(feature) {
+			omitted = append(omitted, feature.Name)
+		}
+	}
+	return omitted
+}
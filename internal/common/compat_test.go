@@ -0,0 +1,45 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOTLPCompat(t *testing.T) {
+	for _, valid := range []string{"", "0.12", "v0.19", "1.5"} {
+		assert.NoError(t, ValidateOTLPCompat(valid), valid)
+	}
+	for _, invalid := range []string{"1", "0.x", "latest", "-1.2", "0.12.1"} {
+		assert.Error(t, ValidateOTLPCompat(invalid), invalid)
+	}
+}
+
+func TestSupportsOTLP(t *testing.T) {
+	tests := []struct {
+		compat   string
+		expected bool
+	}{
+		{compat: "", expected: true},
+		{compat: "0.12", expected: false},
+		{compat: "0.13", expected: true},
+		{compat: "0.19", expected: true},
+		{compat: "1.0", expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.compat, func(t *testing.T) {
+			c := &Config{OTLPCompat: tt.compat}
+			assert.Equal(t, tt.expected, c.SupportsOTLP(FeatureHistogramMinMax))
+			if tt.expected {
+				assert.Empty(t, c.OmittedOTLPFeatures())
+			} else {
+				assert.Equal(t, []string{FeatureHistogramMinMax.Name}, c.OmittedOTLPFeatures())
+			}
+		})
+	}
+	assert.False(t, (&Config{OTLPCompat: "0.10"}).SupportsOTLP(FeatureExponentialHistogram))
+	assert.True(t, (&Config{OTLPCompat: "0.12"}).SupportsOTLP(FeatureExponentialHistogram))
+}
@@ -7,15 +7,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/spf13/pflag"
+	"go.uber.org/zap"
 )
 
-var errFormatOTLPAttributes = errors.New("value should be in one of the following formats: key=\"value\", key=true, key=false, or key=<integer>")
+var errFormatOTLPAttributes = errors.New("value should be in one of the following formats: key=\"value\", key=true, key=false, key=<integer>, or key=[\"value1\",\"value2\"]")
 
 const (
 	defaultGRPCEndpoint = "localhost:4317"
@@ -56,7 +62,7 @@ func (v *KeyValue) Set(s string) error {
 	return nil
 }
 
-// splitCommaSeparated splits on commas, but ignores commas inside quotes
+// splitCommaSeparated splits on commas, but ignores commas inside quotes and JSON arrays
 func splitCommaSeparated(s string) []string {
 	if s == "" {
 		return []string{}
@@ -64,12 +70,18 @@ func splitCommaSeparated(s string) []string {
 	var result []string
 	var current strings.Builder
 	inQuotes := false
+	depth := 0
 	for i := 0; i < len(s); i++ {
 		c := s[i]
-		if c == '"' {
+		switch {
+		case c == '"':
 			inQuotes = !inQuotes
+		case c == '[' && !inQuotes:
+			depth++
+		case c == ']' && !inQuotes && depth > 0:
+			depth--
 		}
-		if c == ',' && !inQuotes {
+		if c == ',' && !inQuotes && depth == 0 {
 			result = append(result, strings.TrimSpace(current.String()))
 			current.Reset()
 		} else {
@@ -90,6 +102,9 @@ func parseKeyValue(s string, v *KeyValue) error {
 	}
 	key := strings.TrimSpace(kv[0])
 	val := strings.TrimSpace(kv[1])
+	if key == "" {
+		return fmt.Errorf("invalid attribute %q: the key must not be empty", s)
+	}
 	// Try bool
 	if val == "true" {
 		(*v)[key] = true
@@ -104,6 +119,15 @@ func parseKeyValue(s string, v *KeyValue) error {
 		(*v)[key] = intVal
 		return nil
 	}
+	// Try JSON array, e.g. host.ip=["10.0.0.1","10.0.0.2"]
+	if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+		var arr []any
+		if err := json.Unmarshal([]byte(val), &arr); err != nil {
+			return fmt.Errorf("invalid JSON array for attribute %q: %w", key, err)
+		}
+		(*v)[key] = arr
+		return nil
+	}
 	// Try float
 	if floatVal, err := strconv.ParseFloat(val, 64); err == nil {
 		(*v)[key] = floatVal
@@ -129,18 +153,58 @@ type Config struct {
 	WorkerCount       int           `mapstructure:"workers"`
 	Rate              float64       `mapstructure:"rate"`
 	TotalDuration     time.Duration `mapstructure:"duration"`
+	TotalBytes        string        `mapstructure:"total-bytes"`
 	ReportingInterval time.Duration `mapstructure:"interval"`
+	SharedLimiter     bool          `mapstructure:"shared-limiter"`
+	RateSchedule      string        `mapstructure:"rate-schedule"`
+	Repeat            int           `mapstructure:"repeat"`
+	WorkerScope       bool          `mapstructure:"worker-scope"`
+	ScopesPerResource int           `mapstructure:"scopes-per-resource"`
+	FleetSize         int           `mapstructure:"fleet-size"`
+	WarmupDuration    time.Duration `mapstructure:"warmup-duration"`
+	ExportTiming      bool          `mapstructure:"export-timing"`
+	MaxErrors         int           `mapstructure:"max-errors"`
+	DuplicateRatio    float64       `mapstructure:"duplicate-ratio"`
+	DropRatio         float64       `mapstructure:"drop-ratio"`
+	VerifyEndpoint    string        `mapstructure:"verify-endpoint"`
+	BoundedMemory     bool          `mapstructure:"bounded-memory"`
+	MaxQueueSize      int           `mapstructure:"max-queue-size"`
 
 	// OTLP config
-	CustomEndpoint      string   `mapstructure:"otlp-endpoint"`
-	Insecure            bool     `mapstructure:"otlp-insecure"`
-	InsecureSkipVerify  bool     `mapstructure:"otlp-insecure-skip-verify"`
-	UseHTTP             bool     `mapstructure:"otlp-http"`
-	HTTPPath            string   `mapstructure:"otlp-http-url-path"`
-	Headers             KeyValue `mapstructure:"otlp-header"`
-	ResourceAttributes  KeyValue `mapstructure:"otlp-attributes"`
-	ServiceName         string   `mapstructure:"service"`
-	TelemetryAttributes KeyValue `mapstructure:"telemetry-attributes"`
+	CustomEndpoint        string   `mapstructure:"otlp-endpoint"`
+	Insecure              bool     `mapstructure:"otlp-insecure"`
+	InsecureSkipVerify    bool     `mapstructure:"otlp-insecure-skip-verify"`
+	UseHTTP               bool     `mapstructure:"otlp-http"`
+	Protocol              string   `mapstructure:"otlp-protocol"`
+	GRPCAuthority         string   `mapstructure:"grpc-authority"`
+	GRPCMetadata          KeyValue `mapstructure:"grpc-metadata"`
+	ExportTraceparent     string   `mapstructure:"export-traceparent"`
+	ExportTracestate      string   `mapstructure:"export-tracestate"`
+	ExpectTraceresponse   bool     `mapstructure:"expect-traceresponse"`
+	StreamHTTPBody        bool     `mapstructure:"otlp-http-stream"`
+	OTLPCompat            string   `mapstructure:"otlp-compat"`
+	HTTPPath              string   `mapstructure:"otlp-http-url-path"`
+	Headers               KeyValue `mapstructure:"otlp-header"`
+	HeadersJSON           string   `mapstructure:"otlp-headers-json"`
+	ResourceAttributes    KeyValue `mapstructure:"otlp-attributes"`
+	ResourceAttrCount     int      `mapstructure:"resource-attribute-count"`
+	ServiceName           string   `mapstructure:"service"`
+	DuplicateServiceName  bool     `mapstructure:"duplicate-service-name"`
+	DeploymentEnvironment string   `mapstructure:"deployment-environment"`
+	CloudProvider         string   `mapstructure:"cloud-provider"`
+	CloudRegion           string   `mapstructure:"cloud-region"`
+	K8sNamespace          string   `mapstructure:"k8s-namespace"`
+	MergeDefaultResource  bool     `mapstructure:"merge-default-resource"`
+	TelemetryAttributes   KeyValue `mapstructure:"telemetry-attributes"`
+	NoTelemetryAttributes bool     `mapstructure:"no-telemetry-attributes"`
+	AttributesAsResource  bool     `mapstructure:"attributes-as-resource"`
+	AttributeValueFiles   KeyValue `mapstructure:"attribute-values"`
+	AttributesCase        string   `mapstructure:"attributes-case"`
+	Int64Attributes       []string `mapstructure:"attributes-int64"`
+	Tags                  KeyValue `mapstructure:"tag"`
+	TagSynthetic          bool     `mapstructure:"tag-synthetic"`
+	SkipBadAttributes     bool     `mapstructure:"skip-bad-attributes"`
+	NullAttributes        bool     `mapstructure:"null-attributes"`
 
 	// Sensitive data keys (attributes or headers)
 	SensitiveData []string `mapstructure:"sensitive-data"`
@@ -155,7 +219,14 @@ type Config struct {
 
 	MockData       bool  `mapstructure:"mock-data"` // Enable mock data generation for templated fields
 	MockSeed       int64 `mapstructure:"mock-seed"` // Seed for mock data generation (used only at startup)
+	Seed           int64 `mapstructure:"seed"`      // Seed of all the randomness of the run, mock data included
 	TerminalOutput bool  `mapstructure:"terminal-output"`
+	TUI            bool  `mapstructure:"tui"`
+
+	attributeValues map[string][]string // candidate values of each `attribute-values` key, loaded by InitAttributes
+	exportVolume    *ExportVolume       // bytes exported by the run with `total-bytes`, see ExportVolume
+	sharedSeed      bool                // the randomness is seeded once for several generators, see SeedSharedRandomness
+	httpJSON        bool                // the HTTP exporters send OTLP/JSON, see ApplyProtocol
 }
 
 type ClientAuth struct {
@@ -176,27 +247,229 @@ func (c *Config) Endpoint() string {
 	return defaultGRPCEndpoint
 }
 
+// countOverride returns the option making the workers ignore their item count:
+// `duration`, else `total-bytes`, or "" when neither is set.
+func (c *Config) countOverride() string {
+	if c.TotalDuration > 0 {
+		return "duration"
+	}
+	if c.TotalBytes != "" {
+		return "total-bytes"
+	}
+	return ""
+}
+
+// IgnoresCount reports whether `duration` or `total-bytes` is set, in which case the
+// workers generate until they are stopped instead of up to their item count.
+func (c *Config) IgnoresCount() bool {
+	return c.countOverride() != ""
+}
+
+// WarnCountIgnored reports that `duration` or `total-bytes` takes precedence over an
+// explicitly configured item count. defaultCount is the signal's default count, which
+// is never reported since it is not a user choice.
+func (c *Config) WarnCountIgnored(logger *zap.Logger, countFlag string, count, defaultCount int) {
+	option := c.countOverride()
+	if option == "" {
+		return
+	}
+	optionField := zap.Duration("duration", c.TotalDuration)
+	if option == "total-bytes" {
+		optionField = zap.String("total-bytes", c.TotalBytes)
+	}
+	c.WarnCountIgnoredBy(logger, option, optionField, countFlag, count, defaultCount)
+}
+
+// WarnCountIgnoredBy reports that option, a signal's own stop condition such as
+// `intervals` whose value is optionField, takes precedence over an explicitly configured
+// item count, like WarnCountIgnored.
+func (c *Config) WarnCountIgnoredBy(logger *zap.Logger, option string, optionField zap.Field, countFlag string, count, defaultCount int) {
+	if count <= 0 || count == defaultCount {
+		return
+	}
+	msg := fmt.Sprintf("both `%s` and `%s` are set: `%s` takes precedence and `%s` is ignored", option, countFlag, option, countFlag)
+	logger.Warn(msg, optionField, zap.Int(countFlag, count))
+	if c.TerminalOutput {
+		NewConsoleOutput().Warningln("Warning:", msg)
+	}
+}
+
+// ScopeName returns the instrumentation scope name used by the given worker (1-based):
+// "trazr-gen", or "trazr-gen/worker-N" when per-worker scopes are enabled.
+func (c *Config) ScopeName(worker int) string {
+	if c.WorkerScope {
+		return fmt.Sprintf("trazr-gen/worker-%d", worker)
+	}
+	return "trazr-gen"
+}
+
+// ScopeNames returns the instrumentation scope names used by the given worker (1-based):
+// its ScopeName, or one name per `scopes-per-resource` scope derived from it, like
+// "trazr-gen/scope-2".
+func (c *Config) ScopeNames(worker int) []string {
+	name := c.ScopeName(worker)
+	if c.ScopesPerResource <= 1 {
+		return []string{name}
+	}
+	names := make([]string, c.ScopesPerResource)
+	for k := range names {
+		names[k] = fmt.Sprintf("%s/scope-%d", name, k+1)
+	}
+	return names
+}
+
+// RunRepeated calls run once per configured repetition, reshuffling mock data
+// between runs so every batch gets fresh random values. Each run takes the rate applied
+// by a reload of the config file, see ApplyRate. It stops at the first error.
+func (c *Config) RunRepeated(logger *zap.Logger, run func() error) error {
+	runs := max(c.Repeat, 1)
+	for i := 1; i <= runs; i++ {
+		if i > 1 {
+			ReshuffleMockData()
+		}
+		if runs > 1 {
+			logger.Info("starting run", zap.Int("run", i), zap.Int("runs", runs))
+			if c.TerminalOutput {
+				fmt.Printf("Run %d of %d\n", i, runs)
+			}
+		}
+		c.ExportVolume().reset()
+		c.takeAppliedRate()
+		if err := run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitWorkers waits for the workers of a run to finish. With a `duration`, stop is called
+// once it and the warmup have elapsed, and with `total-bytes` once that volume has been
+// exported. stop is also called as soon as exportErrors counts `max-errors` errors, and
+// the run then ends with ErrMaxErrors.
+func (c *Config) WaitWorkers(wg *sync.WaitGroup, stop func(), exportErrors *ExportErrors, logger *zap.Logger) error {
+	var deadline <-chan time.Time
+	if c.TotalDuration > 0 {
+		timer := time.NewTimer(c.WarmupDuration + c.TotalDuration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+		return nil
+	case <-deadline:
+	case <-c.ExportVolume().Reached():
+		logger.Info("stopping the run, `total-bytes` exported", zap.Int64("bytes", c.ExportVolume().Total()))
+	case <-exportErrors.Exceeded():
+		err = fmt.Errorf("%w: aborted after %d failed exports (`max-errors`)", ErrMaxErrors, exportErrors.Total())
+		logger.Error("aborting the run", zap.Error(err))
+		if c.TerminalOutput {
+			NewConsoleOutput().Warningln("Aborting:", err)
+		}
+	}
+	stop()
+	<-done
+	return err
+}
+
+// ApplyProtocol selects the exporter from the OTLP protocol, given by `otlp-protocol` or
+// else the standard OTEL_EXPORTER_OTLP_PROTOCOL environment variable. When neither is
+// set, `otlp-http` decides. The Go OTLP exporters only encode protobuf, so http/json
+// selects the HTTP exporter with a client transcoding its requests, see ExportHTTPClient.
+func (c *Config) ApplyProtocol() error {
+	protocol := c.Protocol
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	c.httpJSON = false
+	switch strings.ToLower(protocol) {
+	case "":
+	case "grpc":
+		c.UseHTTP = false
+	case "http/protobuf":
+		c.UseHTTP = true
+	case "http/json":
+		c.UseHTTP = true
+		c.httpJSON = true
+	default:
+		return fmt.Errorf("unsupported OTLP protocol %q, must be one of 'grpc', 'http/protobuf' or 'http/json'", protocol)
+	}
+	return nil
+}
+
 // CommonFlags registers common config flags.
 func (c *Config) CommonFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&c.WorkerCount, "workers", c.WorkerCount, "Number of workers (goroutines) to run")
 	fs.Float64Var(&c.Rate, "rate", c.Rate, "# of metrics/spans/logs per second each worker should generate. 0 means no throttling.")
 	fs.DurationVar(&c.TotalDuration, "duration", c.TotalDuration, "For how long to run the test")
-	fs.DurationVar(&c.ReportingInterval, "interval", c.ReportingInterval, "Reporting interval")
+	fs.StringVar(&c.TotalBytes, "total-bytes", c.TotalBytes, "Stop once about this volume of export requests has been sent by the OTLP exporters, e.g. 500MB or 1GiB, regardless of the item count")
+	fs.DurationVar(&c.ReportingInterval, "interval", c.ReportingInterval, "Reporting interval, at which a single progress line of the count and rate of all workers is printed")
+	fs.BoolVar(&c.SharedLimiter, "shared-limiter", c.SharedLimiter, "Share a single rate limiter across all workers, making --rate a global ceiling instead of a per-worker rate")
+	fs.StringVar(&c.RateSchedule, "rate-schedule", c.RateSchedule, "File of the rate of each worker over time, as steps like '0s:10, 60s:100, 120s:10' separated by commas or new lines, each step starting that long after the start of the run. --rate holds until the first step, and a rate reloaded from the config file on SIGHUP until the next one")
+	fs.BoolVar(&c.WorkerScope, "worker-scope", c.WorkerScope, "Use a per-worker instrumentation scope name (trazr-gen/worker-N) to tell apart the data of each worker")
+	fs.IntVar(&c.ScopesPerResource, "scopes-per-resource", c.ScopesPerResource, "Number of instrumentation scopes (trazr-gen/scope-K) each worker spreads its data over, nesting several scope spans, metrics or logs in each resource. Spans and logs take the scopes in turn, so that batches mix them, and each metric data point is emitted under every scope")
+	fs.IntVar(&c.FleetSize, "fleet-size", c.FleetSize, "Number of resources, each a mock host with its own host.name and host.id, the spans, metric data points and logs are emitted from in turn, to simulate a fleet")
+	fs.IntVar(&c.Repeat, "repeat", c.Repeat, "Number of times to run the whole scenario, reshuffling mock data between runs")
+	fs.BoolVar(&c.ExportTiming, "export-timing", c.ExportTiming, "Record the time between consecutive exports of each worker and report its distribution, to check the rate limiter accuracy")
+	fs.Float64Var(&c.DuplicateRatio, "duplicate-ratio", c.DuplicateRatio, "Share of exports, between 0 and 1, sent a second time with the same IDs and timestamps, like the retries of a lost response, to test deduplication")
+	fs.Float64Var(&c.DropRatio, "drop-ratio", c.DropRatio, "Share of the spans (by whole traces), metrics or log records, between 0 and 1, generated but never exported, like a lossy client, to test how backends handle gaps. They are reported as skipped")
+	fs.StringVar(&c.VerifyEndpoint, "verify-endpoint", c.VerifyEndpoint, "URL of the collector's internal Prometheus metrics, like http://localhost:8888/metrics. After the run, the items its receivers accepted are compared with the items delivered, failing the run when some are missing")
+	fs.BoolVar(&c.BoundedMemory, "bounded-memory", c.BoundedMemory, "For long soak tests: the traces and logs workers wait for room in the --max-queue-size queue of --batch instead of spans or log records being dropped, and options keeping state for every export, like --export-timing, are rejected, so that the live heap doesn't grow with the number of items generated. Requires --batch, and metrics, exported as they are generated, queue nothing to bound. Each item is still allocated, and collected once exported")
+	fs.IntVar(&c.MaxQueueSize, "max-queue-size", c.MaxQueueSize, "Maximum number of spans or log records the batch processors queue for export, capping the memory they take")
+	fs.IntVar(&c.MaxErrors, "max-errors", c.MaxErrors, "Abort the run once this many exports have failed in total, instead of going on regardless. 0 means never")
+	fs.DurationVar(&c.WarmupDuration, "warmup-duration", c.WarmupDuration, "Warmup during which data is generated but left out of the final counted statistics. Runs before --duration")
 
 	fs.StringVar(&c.CustomEndpoint, "otlp-endpoint", c.CustomEndpoint, "Destination endpoint for exporting logs, metrics and traces")
 	fs.BoolVar(&c.Insecure, "otlp-insecure", c.Insecure, "Whether to enable client transport security for the exporter's grpc or http connection")
 	fs.BoolVar(&c.InsecureSkipVerify, "otlp-insecure-skip-verify", c.InsecureSkipVerify, "Whether a client verifies the server's certificate chain and host name")
 	fs.BoolVar(&c.UseHTTP, "otlp-http", c.UseHTTP, "Whether to use HTTP exporter rather than a gRPC one")
+	fs.StringVar(&c.Protocol, "otlp-protocol", c.Protocol, "OTLP protocol: 'grpc', 'http/protobuf' or 'http/json'. Supersedes --otlp-http, defaults to OTEL_EXPORTER_OTLP_PROTOCOL when set. With 'http/json', each export request is held in memory to be transcoded from protobuf")
+	fs.StringVar(&c.GRPCAuthority, "grpc-authority", c.GRPCAuthority, "Override the :authority of gRPC requests, which otherwise derives from --otlp-endpoint. Ignored by the HTTP exporter")
+	fs.Var(&c.GRPCMetadata, "grpc-metadata", "gRPC metadata (key=\"value\") added to every export request, apart from --otlp-header, for gateways authenticating on custom metadata. Repeat for multiple keys. Ignored by the HTTP exporter")
+	fs.StringVar(&c.ExportTraceparent, "export-traceparent", c.ExportTraceparent, "Set a W3C traceparent header on the HTTP exporter's own requests, for infrastructure that traces them: 'random' for a new trace per request, or a fixed traceparent. Ignored by the gRPC exporter")
+	fs.StringVar(&c.ExportTracestate, "export-tracestate", c.ExportTracestate, "tracestate header sent along with --export-traceparent")
+	fs.BoolVar(&c.ExpectTraceresponse, "expect-traceresponse", c.ExpectTraceresponse, "Fail the HTTP exports whose response has no valid W3C traceresponse header, or one of another trace than the request's --export-traceparent, for conformance tests of servers echoing it. Ignored by the gRPC exporter")
+	fs.BoolVar(&c.StreamHTTPBody, "otlp-http-stream", c.StreamHTTPBody, "Stream the body of the HTTP export requests gzip-compressed with chunked transfer encoding, instead of sending it with a known length, so that no compressed copy of large --size payloads is held in memory. Ignored by the gRPC exporter")
+	fs.StringVar(&c.OTLPCompat, "otlp-compat", c.OTLPCompat, "Tailor the metrics payloads to an older OTLP proto release (major.minor, e.g. 0.12), for older collectors: histogram min and max are left out before 0.13, and the ExponentialHistogram --metric-type is rejected before 0.11. The traces and logs emit no field this gates. Defaults to the latest release")
 
 	fs.StringVar(&c.ServiceName, "service", c.ServiceName, "Service name to use")
+	fs.BoolVar(&c.DuplicateServiceName, "duplicate-service-name", c.DuplicateServiceName, "Also add service.name to span, metric and log attributes, for backends that don't read the resource")
+
+	// shortcuts for common resource attributes, which --otlp-attributes overrides
+	fs.StringVar(&c.DeploymentEnvironment, "deployment-environment", c.DeploymentEnvironment, "Value of the deployment.environment resource attribute, e.g. production")
+	fs.StringVar(&c.CloudProvider, "cloud-provider", c.CloudProvider, "Value of the cloud.provider resource attribute, e.g. aws, gcp or azure")
+	fs.StringVar(&c.CloudRegion, "cloud-region", c.CloudRegion, "Value of the cloud.region resource attribute, e.g. us-east-1")
+	fs.StringVar(&c.K8sNamespace, "k8s-namespace", c.K8sNamespace, "Value of the k8s.namespace.name resource attribute")
+	fs.BoolVar(&c.MergeDefaultResource, "merge-default-resource", c.MergeDefaultResource, "Merge the resource into the default one of the OpenTelemetry SDK, adding its telemetry.sdk.* attributes like a real SDK does. The configured attributes take precedence")
 
 	// custom headers
 	fs.Var(&c.Headers, "otlp-header", "Custom OTLP header (key=\"value\"). Repeat for multiple headers.")
+	fs.StringVar(&c.HeadersJSON, "otlp-headers-json", c.HeadersJSON, "Custom OTLP headers as a JSON object of strings, like '{\"Authorization\":\"Bearer x\",\"X-Tenant\":\"t\"}', to paste header blocks from other tools. --otlp-header takes precedence")
 
 	// custom resource attributes
 	fs.Var(&c.ResourceAttributes, "otlp-attributes", "Custom telemetry attribute (key=\"value\"). Repeat for multiple attributes.")
 
+	fs.IntVar(&c.ResourceAttrCount, "resource-attribute-count", c.ResourceAttrCount, "Number of synthetic attributes to pad the resource with, for stress testing large resources")
+
 	fs.Var(&c.TelemetryAttributes, "telemetry-attributes", "Custom telemetry attribute (key=\"value\"). Repeat for multiple attributes.")
+	fs.Var(&c.AttributeValueFiles, "attribute-values", "Telemetry attribute (key=\"file\") whose value is a line picked at random from the file for each item, e.g. real user agents. Repeat for multiple attributes.")
+	fs.BoolVar(&c.NoTelemetryAttributes, "no-telemetry-attributes", c.NoTelemetryAttributes, "Skip building span, metric and log attributes altogether, to benchmark the transport without the attribute processing cost")
+	fs.BoolVar(&c.AttributesAsResource, "attributes-as-resource", c.AttributesAsResource, "Promote the telemetry attributes to resource attributes, set once for all the items instead of on each of them, to compare resource-level and item-level attribute handling. --otlp-attributes take precedence")
+
+	// tags stamped on resource attributes, telemetry attributes and headers at once
+	fs.Var(&c.Tags, "tag", "Tag (key=\"value\") added to resource attributes, telemetry attributes and headers unless already set there. Repeat for multiple tags.")
+	fs.BoolVar(&c.TagSynthetic, "tag-synthetic", c.TagSynthetic, "Add the trazr.generated=true resource attribute to all the telemetry, so that operators can filter synthetic data out of production dashboards")
+
+	fs.StringVar(&c.AttributesCase, "attributes-case", c.AttributesCase, "Normalize resource and telemetry attribute keys: 'lower' or 'snake' (default: keys are left as-is)")
+	fs.StringSliceVar(&c.Int64Attributes, "attributes-int64", c.Int64Attributes, "Attribute keys whose values are sent as 64-bit integers, parsed from strings (mock data included) or whole numbers, e.g. epoch nanoseconds or large IDs (comma-separated or repeatable)")
+	fs.BoolVar(&c.SkipBadAttributes, "skip-bad-attributes", c.SkipBadAttributes, "Log and drop attributes whose mock template fails instead of aborting the run")
+	fs.BoolVar(&c.NullAttributes, "null-attributes", c.NullAttributes, "Send the resource and telemetry attributes configured with a null value, like {\"key\": null} or key: ~ in the config file, with an empty OTLP value instead of leaving them out, to test how backends tell them from absent ones. Only the OTLP exporters send the empty values, and the HTTP one holds each request body in memory to rewrite it, even with --otlp-http-stream")
 
 	// TLS CA configuration
 	fs.StringVar(&c.CaFile, "ca-cert", c.CaFile, "Trusted Certificate Authority to verify server certificate")
@@ -206,11 +479,13 @@ func (c *Config) CommonFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&c.ClientAuth.ClientCertFile, "client-cert", c.ClientAuth.ClientCertFile, "Client certificate file")
 	fs.StringVar(&c.ClientAuth.ClientKeyFile, "client-key", c.ClientAuth.ClientKeyFile, "Client private key file")
 
-	fs.StringSliceVar(&c.SensitiveData, "sensitive-data", c.SensitiveData, "Sensitive attribute or header keys (comma-separated or repeatable)")
+	fs.StringSliceVar(&c.SensitiveData, "sensitive-data", c.SensitiveData, "Sensitive attribute or header keys (comma-separated or repeatable), each optionally with a level, one of pii, phi, pci, secret or confidential, like patient.ssn:pii or api.key:secret, listed by the trazr.sensitive.data.<level> marker on top of trazr.sensitive.data")
 
 	fs.BoolVar(&c.MockData, "mock-data", c.MockData, "Enable mock data generation for templated fields")
 	fs.Int64Var(&c.MockSeed, "mock-seed", c.MockSeed, "Seed for mock data generation (used only at startup)")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "Seed of all the randomness of the run: mock data (unless --mock-seed is set), trace and span IDs, sampled values and distributions, so that a run with a single worker generates the same telemetry every time, timestamps aside (0 draws a new seed on every run). The all command seeds it once for its signals, which draw from it concurrently")
 	fs.BoolVar(&c.TerminalOutput, "terminal-output", c.TerminalOutput, "Enable terminal output for logs (default: true)")
+	fs.BoolVar(&c.TUI, "tui", c.TUI, "Show a live dashboard of the rate, total, export errors and a sparkline of the rate, updated in place every --interval instead of printing every item. Requires --terminal-output")
 }
 
 // SetDefaults is here to mirror the defaults for flags above,
@@ -220,16 +495,56 @@ func (c *Config) SetDefaults() {
 	c.WorkerCount = 1
 	c.Rate = 1
 	c.TotalDuration = 0
+	c.TotalBytes = ""
 	c.ReportingInterval = 1 * time.Second
+	c.SharedLimiter = false
+	c.RateSchedule = ""
+	c.Repeat = 1
+	c.WorkerScope = false
+	c.ScopesPerResource = 1
+	c.FleetSize = 1
+	c.WarmupDuration = 0
+	c.ExportTiming = false
+	c.MaxErrors = 0
+	c.DuplicateRatio = 0
+	c.DropRatio = 0
+	c.VerifyEndpoint = ""
+	c.BoundedMemory = false
+	c.MaxQueueSize = DefaultMaxQueueSize
 	c.CustomEndpoint = "localhost:4318"
 	c.Insecure = true
 	c.InsecureSkipVerify = true
 	c.UseHTTP = true
+	c.Protocol = ""
+	c.GRPCAuthority = ""
+	c.GRPCMetadata = make(KeyValue)
+	c.ExportTraceparent = ""
+	c.ExportTracestate = ""
+	c.ExpectTraceresponse = false
+	c.StreamHTTPBody = false
+	c.OTLPCompat = ""
 	c.HTTPPath = ""
 	c.Headers = make(KeyValue)
+	c.HeadersJSON = ""
 	c.ResourceAttributes = make(KeyValue)
+	c.ResourceAttrCount = 0
 	c.ServiceName = "trazr-gen"
+	c.DuplicateServiceName = false
+	c.DeploymentEnvironment = ""
+	c.CloudProvider = ""
+	c.CloudRegion = ""
+	c.K8sNamespace = ""
+	c.MergeDefaultResource = false
 	c.TelemetryAttributes = make(KeyValue)
+	c.NoTelemetryAttributes = false
+	c.AttributesAsResource = false
+	c.AttributeValueFiles = make(KeyValue)
+	c.AttributesCase = ""
+	c.Int64Attributes = []string{}
+	c.Tags = make(KeyValue)
+	c.TagSynthetic = true
+	c.SkipBadAttributes = false
+	c.NullAttributes = false
 	c.CaFile = ""
 	c.ClientAuth.Enabled = false
 	c.ClientAuth.ClientCertFile = ""
@@ -238,7 +553,9 @@ func (c *Config) SetDefaults() {
 	c.LogLevel = "info"
 	c.MockData = true
 	c.MockSeed = 0
+	c.Seed = 0
 	c.TerminalOutput = true
+	c.TUI = false
 }
 
 func (c *Config) GetHeaders() map[string]string {
@@ -278,7 +595,7 @@ func FlattenMap(prefix string, in map[string]any, out map[string]any) error {
 			if err := FlattenMap(key, childMap, out); err != nil {
 				return err
 			}
-		case nil, string, bool, int, float64:
+		case nil, string, bool, int, int64, float64, []any:
 			out[key] = v
 		default:
 			return fmt.Errorf("unsupported attribute value type for key %q: %T", key, v)
@@ -287,32 +604,203 @@ func FlattenMap(prefix string, in map[string]any, out map[string]any) error {
 	return nil
 }
 
-// InitAttributes performs one-time initialization of attribute maps, including adding the 'trazr.sensitive.data' key to both ResourceAttributes and TelemetryAttributes if any sensitive keys are present.
-// Call this once after config and attributes are loaded.
+// InitAttributes performs the initialization of attribute maps, including adding the 'trazr.sensitive.data' key to both ResourceAttributes and TelemetryAttributes if any sensitive keys are present.
+// Call this after config and attributes are loaded. It is idempotent, and replaces the maps and
+// key lists instead of modifying them, so that configs sharing them can each initialize their own.
 func (c *Config) InitAttributes() error {
-	flatRes := make(map[string]any)
-	if err := FlattenMap("", c.ResourceAttributes, flatRes); err != nil {
-		return fmt.Errorf("failed to flatten resource attributes: %w", err)
+	if c.AttributesCase != "" {
+		convert, err := keyNormalizer(c.AttributesCase)
+		if err != nil {
+			return err
+		}
+		// keep sensitive keys in sync so the sensitive marker still matches
+		c.SensitiveData = normalizeSensitiveData(c.SensitiveData, convert)
+		c.Int64Attributes = normalizeKeyList(c.Int64Attributes, convert)
+	}
+
+	if c.AttributesAsResource {
+		c.promoteTelemetryAttributes()
+	}
+
+	res, err := c.PrepareAttributes(c.ResourceAttributes)
+	if err != nil {
+		return fmt.Errorf("failed to prepare resource attributes: %w", err)
+	}
+	c.mergeResourceShortcuts(res)
+	c.ResourceAttributes = res
+
+	tel, err := c.PrepareAttributes(c.TelemetryAttributes)
+	if err != nil {
+		return fmt.Errorf("failed to prepare telemetry attributes: %w", err)
 	}
-	c.ResourceAttributes = flatRes
+	c.TelemetryAttributes = tel
 
-	flatTel := make(map[string]any)
-	if err := FlattenMap("", c.TelemetryAttributes, flatTel); err != nil {
-		return fmt.Errorf("failed to flatten telemetry attributes: %w", err)
+	if err := c.loadAttributeValues(); err != nil {
+		return err
 	}
-	c.TelemetryAttributes = flatTel
 
 	flatHeaders := make(map[string]any)
 	if err := FlattenMap("", c.Headers, flatHeaders); err != nil {
 		return fmt.Errorf("failed to flatten headers: %w", err)
 	}
+	if err := c.mergeHeadersJSON(flatHeaders); err != nil {
+		return err
+	}
+	if err := c.mergeTags(flatHeaders); err != nil {
+		return err
+	}
 	c.Headers = flatHeaders
+	return nil
+}
+
+// promoteTelemetryAttributes moves the telemetry attributes to the resource attributes,
+// for `attributes-as-resource`. The resource attributes take precedence.
+func (c *Config) promoteTelemetryAttributes() {
+	promoted := make(KeyValue, len(c.TelemetryAttributes)+len(c.ResourceAttributes))
+	maps.Copy(promoted, c.TelemetryAttributes)
+	maps.Copy(promoted, c.ResourceAttributes)
+	c.ResourceAttributes = promoted
+	c.TelemetryAttributes = make(KeyValue)
+}
 
-	InjectSensitiveDataMarker(c.ResourceAttributes, c.SensitiveData)
-	InjectSensitiveDataMarker(c.TelemetryAttributes, c.SensitiveData)
+// PrepareAttributes flattens an attribute map, adds the tags it doesn't set, applies
+// `attributes-case` and adds the sensitive data marker, as InitAttributes does for the
+// resource and telemetry attributes. It must not be called before InitAttributes.
+func (c *Config) PrepareAttributes(attrs KeyValue) (KeyValue, error) {
+	flat := make(map[string]any)
+	if err := FlattenMap("", attrs, flat); err != nil {
+		return nil, fmt.Errorf("failed to flatten attributes: %w", err)
+	}
+	if err := c.mergeTags(flat); err != nil {
+		return nil, err
+	}
+	if c.AttributesCase != "" {
+		convert, err := keyNormalizer(c.AttributesCase)
+		if err != nil {
+			return nil, err
+		}
+		flat = normalizeKeys(flat, convert)
+	}
+	if err := validateAttributeKeys(flat); err != nil {
+		return nil, err
+	}
+	InjectSensitiveDataMarker(flat, c.SensitiveData)
+	return flat, nil
+}
+
+// validateAttributeKeys checks that no key of the flattened attrs is empty or blank, as
+// OTLP requires, so that such attributes from a config file, a JSON object or a nested map
+// fail here rather than at the collector.
+func validateAttributeKeys(attrs map[string]any) error {
+	for _, k := range sortedKeys(attrs) {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("invalid attribute %q with value %v: the key must not be empty", k, attrs[k])
+		}
+	}
 	return nil
 }
 
+// mergeHeadersJSON adds the headers of `otlp-headers-json` to m; the ones set with
+// `otlp-header` take precedence.
+func (c *Config) mergeHeadersJSON(m map[string]any) error {
+	if strings.TrimSpace(c.HeadersJSON) == "" {
+		return nil
+	}
+	var headers map[string]any
+	if err := json.Unmarshal([]byte(c.HeadersJSON), &headers); err != nil {
+		return fmt.Errorf("invalid `otlp-headers-json`: expected a JSON object of strings: %w", err)
+	}
+	for k, v := range headers {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("invalid `otlp-headers-json`: the value of header %q must be a string, got %v", k, v)
+		}
+		if _, set := m[k]; !set {
+			m[k] = s
+		}
+	}
+	return nil
+}
+
+// mergeTags adds the tags to m; explicitly configured attributes and headers take precedence.
+func (c *Config) mergeTags(m map[string]any) error {
+	flatTags := make(map[string]any)
+	if err := FlattenMap("", c.Tags, flatTags); err != nil {
+		return fmt.Errorf("failed to flatten tags: %w", err)
+	}
+	for k, v := range flatTags {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return nil
+}
+
+// keyNormalizer returns the key conversion for the given attributes-case mode.
+// Supported modes are "lower" (e.g. Http.Method -> http.method) and "snake"
+// (e.g. httpMethod -> http_method).
+func keyNormalizer(mode string) (func(string) string, error) {
+	switch strings.ToLower(mode) {
+	case "lower":
+		return strings.ToLower, nil
+	case "snake":
+		return toSnakeCase, nil
+	default:
+		return nil, fmt.Errorf("unsupported attributes-case %q, must be one of 'lower' or 'snake'", mode)
+	}
+}
+
+// normalizeKeys returns a copy of attrs with every key converted. Keys are processed
+// in sorted order so that colliding keys resolve deterministically.
+func normalizeKeys(attrs map[string]any, convert func(string) string) map[string]any {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make(map[string]any, len(attrs))
+	for _, k := range keys {
+		out[convert(k)] = attrs[k]
+	}
+	return out
+}
+
+// normalizeKeyList returns a new list of the converted keys, leaving keys untouched.
+func normalizeKeyList(keys []string, convert func(string) string) []string {
+	if keys == nil {
+		return nil
+	}
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = convert(k)
+	}
+	return out
+}
+
+// toSnakeCase lower-cases s, separating camelCase words and replacing spaces and dashes with underscores.
+// Dots are kept so that namespaced keys stay namespaced.
+func toSnakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case r == ' ' || r == '-':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			// split acronyms such as HTTPStatus before the last capital
+			acronymEnd := i > 0 && unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || acronymEnd {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
 // ShowNonDefaultConfig prints all config fields that differ from their default values.
 // It works for any config struct (logs, metrics, traces, or common) that has a SetDefaults() method.
 // Fields that are not set to their default values are printed as: <field path>: <value> (default: <default value>)
@@ -340,6 +828,10 @@ func ShowNonDefaultConfig(cfg any) {
 		for i := 0; i < v.NumField(); i++ {
 			f := t.Field(i)
 			fname := f.Name
+			if !f.IsExported() && !f.Anonymous {
+				// runtime state, like the loaded `attribute-values`, isn't configuration
+				continue
+			}
 			if f.Anonymous && v.Field(i).Kind() == reflect.Struct {
 				// Embedded struct: recurse with path
 				walk(path, v.Field(i), def.Field(i))
@@ -366,6 +858,76 @@ func ShowNonDefaultConfig(cfg any) {
 	}
 }
 
+// ShowFullConfig prints every field of a config struct (logs, metrics, traces, or common)
+// with its effective value, as: <field path>: <value>
+func ShowFullConfig(cfg any) {
+	cfgVal := reflect.ValueOf(cfg)
+	if cfgVal.Kind() == reflect.Ptr {
+		cfgVal = cfgVal.Elem()
+	}
+
+	var walk func(path string, v reflect.Value)
+	walk = func(path string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous && v.Field(i).Kind() == reflect.Struct {
+				// Embedded struct: recurse with path
+				walk(path, v.Field(i))
+				continue
+			}
+			if !f.IsExported() {
+				// the state of the run, not an option
+				continue
+			}
+			fieldPath := f.Name
+			if path != "" {
+				fieldPath = path + "." + f.Name
+			}
+			fmt.Printf("%s: %v\n", fieldPath, printable(v.Field(i)))
+		}
+	}
+	fmt.Println("------------------- Effective Config Values -------------------")
+	walk("", cfgVal)
+	fmt.Println("---------------------------------------------------------------")
+}
+
+// ShowEffectiveFlags prints every option of a config struct (logs, metrics, traces, or
+// common) with its effective value and where it comes from, as: <key>: <value> (<source>).
+// source is given the dotted key of each option, like "client-auth.mtls".
+func ShowEffectiveFlags(cfg any, source func(key string) string) {
+	cfgVal := reflect.ValueOf(cfg)
+	if cfgVal.Kind() == reflect.Ptr {
+		cfgVal = cfgVal.Elem()
+	}
+
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+			if f.Anonymous && opts == "squash" {
+				walk(prefix, v.Field(i))
+				continue
+			}
+			if name == "" || !f.IsExported() {
+				continue
+			}
+			key := prefix + name
+			if v.Field(i).Kind() == reflect.Struct {
+				// nested options, like the `client-auth` ones
+				walk(key+".", v.Field(i))
+				continue
+			}
+			fmt.Printf("%s: %v (%s)\n", key, printable(v.Field(i)), source(key))
+		}
+	}
+	fmt.Println("------------------- Effective Flag Values ---------------------")
+	walk("", cfgVal)
+	fmt.Println("---------------------------------------------------------------")
+}
+
 // valuesEqual compares two reflect.Values for equality, handling slices, maps, and basic types.
 func valuesEqual(a, b reflect.Value) bool {
 	if !a.IsValid() || !b.IsValid() {
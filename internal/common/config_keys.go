@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// ConfigDecodeHook extends the default decoding of viper to the options whose type
+// parses its own text, like `aggregation-temporality`. It decodes the options of the
+// config file as well as those of the phases of a scenario.
+var ConfigDecodeHook = mapstructure.ComposeDecodeHookFunc(
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+	mapstructure.TextUnmarshallerHookFunc(),
+)
+
+// UnknownConfigKeys returns the keys of settings, as dotted paths, that none of the
+// config structs cfgs reads through its mapstructure tags. The keys of map options,
+// like `otlp-header`, are free-form and never reported.
+func UnknownConfigKeys(settings map[string]any, cfgs ...any) []string {
+	known := make(map[string]reflect.Type)
+	for _, cfg := range cfgs {
+		collectConfigKeys(reflect.TypeOf(cfg), known)
+	}
+
+	var unknown []string
+	for _, k := range sortedKeys(settings) {
+		t, ok := known[k]
+		if !ok {
+			unknown = append(unknown, k)
+			continue
+		}
+		if nested, isMap := settings[k].(map[string]any); isMap && t.Kind() == reflect.Struct {
+			for _, sub := range UnknownConfigKeys(nested, reflect.New(t).Interface()) {
+				unknown = append(unknown, k+"."+sub)
+			}
+		}
+	}
+	return unknown
+}
+
+// collectConfigKeys adds the mapstructure keys of the struct type t, and of the
+// structs it squashes, to known along with their field type.
+func collectConfigKeys(t reflect.Type, known map[string]reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if f.Anonymous && (opts == "squash" || name == "") {
+			collectConfigKeys(f.Type, known)
+			continue
+		}
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		known[name] = f.Type
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownConfigKeys(t *testing.T) {
+	type signalConfig struct {
+		Config `mapstructure:",squash"`
+		Count  int `mapstructure:"count"`
+	}
+	settings := map[string]any{
+		"workers":     2,
+		"count":       5,
+		"wrokers":     3,
+		"otlp-header": map[string]any{"anything": "goes"},
+		"client-auth": map[string]any{"mtls": true, "enabled": true},
+	}
+	assert.Equal(t, []string{"client-auth.enabled", "wrokers"}, UnknownConfigKeys(settings, &signalConfig{}))
+	assert.Equal(t, []string{"client-auth.enabled", "count", "wrokers"}, UnknownConfigKeys(settings, &Config{}))
+}
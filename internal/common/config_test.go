@@ -5,14 +5,19 @@ package common
 
 import (
 	"bytes"
+	"errors"
+	"maps"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestKeyValueSet(t *testing.T) {
@@ -65,6 +70,10 @@ func TestKeyValueSet(t *testing.T) {
 			flag:     "key=12.34",
 			expected: KeyValue(map[string]any{"key": 12.34}),
 		},
+		{
+			flag:     "host.ip=[\"10.0.0.1\",\"10.0.0.2\"],env=prod",
+			expected: KeyValue(map[string]any{"host.ip": []any{"10.0.0.1", "10.0.0.2"}, "env": "prod"}),
+		},
 	}
 
 	for _, tt := range tests {
@@ -80,6 +89,12 @@ func TestKeyValueSet(t *testing.T) {
 	}
 }
 
+func TestKeyValueSet_InvalidArray(t *testing.T) {
+	kv := KeyValue(make(map[string]any))
+	err := kv.Set("key=[1,]")
+	assert.ErrorContains(t, err, `invalid JSON array for attribute "key"`)
+}
+
 func TestEndpoint(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -304,6 +319,171 @@ func TestConfig_InitAttributes(t *testing.T) {
 	})
 }
 
+func TestConfig_InitAttributes_Idempotent(t *testing.T) {
+	sensitive := []string{"Secret", "secret", "Secret"}
+	cfg := &Config{
+		ResourceAttributes:    KeyValue{"Host": map[string]any{"Name": "h"}, "Secret": "val"},
+		TelemetryAttributes:   KeyValue{"User": map[string]any{"Id": "42"}, "secret": "val"},
+		Headers:               KeyValue{"h": map[string]any{"i": "j"}},
+		HeadersJSON:           `{"Authorization": "Bearer x"}`,
+		Tags:                  KeyValue{"team": "core"},
+		DeploymentEnvironment: "production",
+		SensitiveData:         sensitive,
+		Int64Attributes:       []string{"User.Id"},
+		AttributesCase:        "lower",
+	}
+	// a config sharing the maps and key lists, as the signals of the all command may
+	shared := *cfg
+
+	require.NoError(t, cfg.InitAttributes())
+	resource := maps.Clone(cfg.ResourceAttributes)
+	telemetry := maps.Clone(cfg.TelemetryAttributes)
+	headers := maps.Clone(cfg.Headers)
+	assert.Equal(t, "secret", resource["trazr.sensitive.data"], "each sensitive key is listed once")
+	assert.Equal(t, "secret", telemetry["trazr.sensitive.data"])
+
+	for range 2 {
+		require.NoError(t, cfg.InitAttributes())
+	}
+	assert.Equal(t, resource, cfg.ResourceAttributes)
+	assert.Equal(t, telemetry, cfg.TelemetryAttributes)
+	assert.Equal(t, headers, cfg.Headers)
+	assert.Equal(t, []string{"user.id"}, cfg.Int64Attributes)
+
+	assert.Equal(t, []string{"Secret", "secret", "Secret"}, sensitive, "the shared key list is left untouched")
+	assert.Equal(t, "val", shared.ResourceAttributes["Secret"], "the shared maps are left untouched")
+	assert.NotContains(t, shared.ResourceAttributes, "trazr.sensitive.data")
+	require.NoError(t, shared.InitAttributes())
+	assert.Equal(t, resource, shared.ResourceAttributes)
+	assert.Equal(t, telemetry, shared.TelemetryAttributes)
+}
+
+func TestConfig_InitAttributes_EmptyKeys(t *testing.T) {
+	for name, cfg := range map[string]*Config{
+		"resource":  {ResourceAttributes: KeyValue{"": "value"}},
+		"telemetry": {TelemetryAttributes: KeyValue{"  ": "value"}},
+		"tag":       {Tags: KeyValue{"": "value"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := cfg.InitAttributes()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "the key must not be empty")
+		})
+	}
+}
+
+func TestConfig_InitAttributes_Tags(t *testing.T) {
+	cfg := &Config{
+		ResourceAttributes:  KeyValue{"env": "prod"},
+		TelemetryAttributes: KeyValue{},
+		Headers:             KeyValue{},
+		Tags:                KeyValue{"env": "staging", "team": map[string]any{"name": "core"}},
+	}
+	require.NoError(t, cfg.InitAttributes())
+	assert.Equal(t, "prod", cfg.ResourceAttributes["env"], "explicit resource attributes win over tags")
+	assert.Equal(t, "staging", cfg.TelemetryAttributes["env"])
+	assert.Equal(t, "staging", cfg.Headers["env"])
+	for _, m := range []KeyValue{cfg.ResourceAttributes, cfg.TelemetryAttributes, cfg.Headers} {
+		assert.Equal(t, "core", m["team.name"])
+	}
+}
+
+func TestConfig_InitAttributes_HeadersJSON(t *testing.T) {
+	cfg := &Config{
+		Headers:     KeyValue{"X-Tenant": "explicit"},
+		HeadersJSON: `{"Authorization": "Bearer x", "X-Tenant": "t"}`,
+	}
+	require.NoError(t, cfg.InitAttributes())
+	assert.Equal(t, "Bearer x", cfg.Headers["Authorization"])
+	assert.Equal(t, "explicit", cfg.Headers["X-Tenant"], "`otlp-header` wins over `otlp-headers-json`")
+
+	cfg = &Config{HeadersJSON: `{"X-Retries": 3}`}
+	require.EqualError(t, cfg.InitAttributes(), "invalid `otlp-headers-json`: the value of header \"X-Retries\" must be a string, got 3")
+
+	cfg = &Config{HeadersJSON: `Authorization=x`}
+	require.ErrorContains(t, cfg.InitAttributes(), "invalid `otlp-headers-json`: expected a JSON object of strings")
+}
+
+func TestConfig_InitAttributes_ResourceShortcuts(t *testing.T) {
+	cfg := &Config{
+		ResourceAttributes:    KeyValue{"cloud": map[string]any{"region": "eu-west-1"}},
+		DeploymentEnvironment: "production",
+		CloudProvider:         "aws",
+		CloudRegion:           "us-east-1",
+		K8sNamespace:          "checkout",
+	}
+	require.NoError(t, cfg.InitAttributes())
+	assert.Equal(t, "production", cfg.ResourceAttributes["deployment.environment"])
+	assert.Equal(t, "aws", cfg.ResourceAttributes["cloud.provider"])
+	assert.Equal(t, "eu-west-1", cfg.ResourceAttributes["cloud.region"], "explicit resource attributes win over shortcuts")
+	assert.Equal(t, "checkout", cfg.ResourceAttributes["k8s.namespace.name"])
+	assert.Empty(t, cfg.TelemetryAttributes, "shortcuts only apply to the resource")
+}
+
+func TestConfig_InitAttributes_AttributesAsResource(t *testing.T) {
+	cfg := &Config{
+		ResourceAttributes:   KeyValue{"env": "prod"},
+		TelemetryAttributes:  KeyValue{"env": "staging", "user": map[string]any{"id": "42"}},
+		SensitiveData:        []string{"user.id"},
+		AttributesAsResource: true,
+	}
+	require.NoError(t, cfg.InitAttributes())
+	assert.Equal(t, "prod", cfg.ResourceAttributes["env"], "explicit resource attributes win over promoted ones")
+	assert.Equal(t, "42", cfg.ResourceAttributes["user.id"])
+	assert.Equal(t, "user.id", cfg.ResourceAttributes["trazr.sensitive.data"])
+	assert.Empty(t, cfg.TelemetryAttributes, "the telemetry attributes are moved to the resource")
+}
+
+func TestConfig_InitAttributes_AttributesCase(t *testing.T) {
+	t.Run("lower", func(t *testing.T) {
+		cfg := &Config{
+			ResourceAttributes:  KeyValue{"Service.Name": "svc"},
+			TelemetryAttributes: KeyValue{"Http.Method": "GET", "Secret": "val"},
+			SensitiveData:       []string{"Secret"},
+			AttributesCase:      "lower",
+		}
+		require.NoError(t, cfg.InitAttributes())
+		assert.Equal(t, "svc", cfg.ResourceAttributes["service.name"])
+		assert.Equal(t, "GET", cfg.TelemetryAttributes["http.method"])
+		assert.Equal(t, []string{"secret"}, cfg.SensitiveData)
+		assert.Equal(t, "secret", cfg.TelemetryAttributes["trazr.sensitive.data"])
+	})
+
+	t.Run("snake", func(t *testing.T) {
+		cfg := &Config{
+			TelemetryAttributes: KeyValue{"httpMethod": "GET", "user-agent": "curl", "db.statementType": "select"},
+			AttributesCase:      "snake",
+		}
+		require.NoError(t, cfg.InitAttributes())
+		assert.Equal(t, KeyValue{
+			"http_method":       "GET",
+			"user_agent":        "curl",
+			"db.statement_type": "select",
+		}, cfg.TelemetryAttributes)
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		cfg := &Config{AttributesCase: "kebab"}
+		err := cfg.InitAttributes()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported attributes-case")
+	})
+}
+
+func TestToSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"httpMethod":     "http_method",
+		"HTTPStatusCode": "http_status_code",
+		"http.method":    "http.method",
+		"Service Name":   "service_name",
+		"k8s.podName":    "k8s.pod_name",
+		"already_snake":  "already_snake",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, toSnakeCase(in), "input %q", in)
+	}
+}
+
 func TestShowNonDefaultConfig(t *testing.T) {
 	t.Run("prints only non-default fields", func(t *testing.T) {
 		cfg := &Config{}
@@ -342,6 +522,55 @@ func TestShowNonDefaultConfig(t *testing.T) {
 	})
 }
 
+func TestShowFullConfig(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	cfg.WorkerCount = 42
+
+	r, w, _ := os.Pipe()
+	origStdout := os.Stdout
+	os.Stdout = w
+	ShowFullConfig(cfg)
+	w.Close()
+	os.Stdout = origStdout
+
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+	output := buf.String()
+	assert.Contains(t, output, "Effective Config Values")
+	assert.Contains(t, output, "WorkerCount: 42\n")
+	assert.Contains(t, output, "Rate: 1\n", "default values are printed too")
+	assert.Contains(t, output, "ClientAuth: ")
+}
+
+func TestShowEffectiveFlags(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	cfg.WorkerCount = 42
+	cfg.ClientAuth.Enabled = true
+
+	r, w, _ := os.Pipe()
+	origStdout := os.Stdout
+	os.Stdout = w
+	ShowEffectiveFlags(cfg, func(key string) string {
+		if key == "workers" || key == "client-auth.mtls" {
+			return "flag"
+		}
+		return "default"
+	})
+	w.Close()
+	os.Stdout = origStdout
+
+	var buf bytes.Buffer
+	_, _ = buf.ReadFrom(r)
+	output := buf.String()
+	assert.Contains(t, output, "Effective Flag Values")
+	assert.Contains(t, output, "workers: 42 (flag)\n")
+	assert.Contains(t, output, "rate: 1 (default)\n")
+	assert.Contains(t, output, "client-auth.mtls: true (flag)\n", "nested options are listed by their dotted key")
+	assert.NotContains(t, output, "attributeValues", "runtime state isn't an option")
+}
+
 func TestSplitCommaSeparated(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -353,6 +582,7 @@ func TestSplitCommaSeparated(t *testing.T) {
 		{"a", []string{"a"}},
 		{"", []string{}},
 		{"\"a,b\",c", []string{"\"a,b\"", "c"}},
+		{"a=[1,2],b=[\"x,y\",\"z\"]", []string{"a=[1,2]", "b=[\"x,y\",\"z\"]"}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -376,6 +606,8 @@ func TestParseKeyValue(t *testing.T) {
 		{"foo=\"quoted\"", KeyValue{"foo": "quoted"}, false},
 		{"foo=", KeyValue{"foo": ""}, false},
 		{"foo", KeyValue{}, true},
+		{"=bar", KeyValue{}, true},
+		{" =bar", KeyValue{}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -509,3 +741,201 @@ func TestClientAuthStruct(t *testing.T) {
 	assert.Equal(t, "cert.pem", c.ClientCertFile)
 	assert.Equal(t, "key.pem", c.ClientKeyFile)
 }
+
+func TestConfig_ApplyProtocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		env      string
+		useHTTP  bool
+		wantHTTP bool
+		wantErr  string
+	}{
+		{name: "unset keeps otlp-http", useHTTP: true, wantHTTP: true},
+		{name: "grpc", protocol: "grpc", useHTTP: true, wantHTTP: false},
+		{name: "http/protobuf", protocol: "http/protobuf", useHTTP: false, wantHTTP: true},
+		{name: "case insensitive", protocol: "GRPC", useHTTP: true, wantHTTP: false},
+		{name: "env fallback", env: "grpc", useHTTP: true, wantHTTP: false},
+		{name: "flag wins over env", protocol: "http/protobuf", env: "grpc", wantHTTP: true},
+		{name: "http/json", protocol: "http/json", useHTTP: false, wantHTTP: true},
+		{name: "unknown", protocol: "udp", wantErr: `unsupported OTLP protocol "udp"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", tt.env)
+			cfg := &Config{Protocol: tt.protocol, UseHTTP: tt.useHTTP}
+			err := cfg.ApplyProtocol()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantHTTP, cfg.UseHTTP)
+		})
+	}
+}
+
+func TestConfig_ScopeName(t *testing.T) {
+	cfg := &Config{}
+	assert.Equal(t, "trazr-gen", cfg.ScopeName(2))
+	cfg.WorkerScope = true
+	assert.Equal(t, "trazr-gen/worker-2", cfg.ScopeName(2))
+}
+
+func TestConfig_ScopeNames(t *testing.T) {
+	cfg := &Config{}
+	assert.Equal(t, []string{"trazr-gen"}, cfg.ScopeNames(1))
+	cfg.ScopesPerResource = 2
+	assert.Equal(t, []string{"trazr-gen/scope-1", "trazr-gen/scope-2"}, cfg.ScopeNames(1))
+	cfg.WorkerScope = true
+	assert.Equal(t, []string{"trazr-gen/worker-3/scope-1", "trazr-gen/worker-3/scope-2"}, cfg.ScopeNames(3))
+}
+
+func TestConfig_RunRepeated(t *testing.T) {
+	for _, tt := range []struct {
+		repeat int
+		want   int
+	}{
+		{repeat: 0, want: 1},
+		{repeat: 1, want: 1},
+		{repeat: 3, want: 3},
+	} {
+		cfg := &Config{Repeat: tt.repeat}
+		calls := 0
+		require.NoError(t, cfg.RunRepeated(zap.NewNop(), func() error {
+			calls++
+			return nil
+		}))
+		assert.Equal(t, tt.want, calls, "repeat=%d", tt.repeat)
+	}
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		cfg := &Config{Repeat: 5}
+		calls := 0
+		err := cfg.RunRepeated(zap.NewNop(), func() error {
+			calls++
+			if calls == 2 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+		require.EqualError(t, err, "boom")
+		assert.Equal(t, 2, calls)
+	})
+}
+
+func TestConfig_WarnCountIgnored(t *testing.T) {
+	tests := []struct {
+		name       string
+		duration   time.Duration
+		totalBytes string
+		count      int
+		wantWarn   string
+	}{
+		{name: "no duration", duration: 0, count: 10},
+		{name: "duration with default count", duration: time.Second, count: 1},
+		{name: "duration with zero count", duration: time.Second, count: 0},
+		{name: "duration with explicit count", duration: time.Second, count: 10, wantWarn: "`duration` takes precedence and `traces` is ignored"},
+		{name: "total bytes with explicit count", totalBytes: "1GB", count: 10, wantWarn: "`total-bytes` takes precedence and `traces` is ignored"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			cfg := &Config{TotalDuration: tt.duration, TotalBytes: tt.totalBytes}
+			cfg.WarnCountIgnored(zap.New(core), "traces", tt.count, 1)
+			if tt.wantWarn == "" {
+				assert.Zero(t, logs.Len())
+				return
+			}
+			require.Equal(t, 1, logs.Len())
+			assert.Contains(t, logs.All()[0].Message, tt.wantWarn)
+		})
+	}
+}
+
+func TestConfig_WarnCountIgnoredBy(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	cfg := &Config{}
+	cfg.WarnCountIgnoredBy(zap.New(core), "intervals", zap.Int("intervals", 4), "metrics", 1, 1)
+	assert.Zero(t, logs.Len(), "the default count is not a user choice")
+
+	cfg.WarnCountIgnoredBy(zap.New(core), "intervals", zap.Int("intervals", 4), "metrics", 10, 1)
+	require.Equal(t, 1, logs.Len())
+	assert.Contains(t, logs.All()[0].Message, "`intervals` takes precedence and `metrics` is ignored")
+}
+
+func TestConfig_URLPath(t *testing.T) {
+	cfg := &Config{HTTPPath: "/v1/traces"}
+	assert.Equal(t, "/v1/traces", cfg.URLPath("traces"))
+	cfg.HTTPPath = "/otlp/v1/{signal}"
+	assert.Equal(t, "/otlp/v1/metrics", cfg.URLPath("metrics"))
+}
+
+func TestConfig_WarnURLPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		grpc     bool
+		wantWarn string
+	}{
+		{name: "signal path", path: "/v1/traces"},
+		{name: "custom path", path: "/ingest"},
+		{name: "template", path: "/otlp/v1/{signal}"},
+		{name: "path of another signal", path: "/v1/metrics", wantWarn: "`otlp-http-url-path` /v1/metrics is the path of metrics, not traces"},
+		{name: "prefixed path of another signal", path: "/otlp/v1/logs/", wantWarn: "is the path of logs, not traces"},
+		{name: "grpc", path: "/v1/metrics", grpc: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			cfg := &Config{HTTPPath: tt.path, UseHTTP: !tt.grpc}
+			cfg.WarnURLPath(zap.New(core), "traces")
+			if tt.wantWarn == "" {
+				assert.Zero(t, logs.Len())
+				return
+			}
+			require.Equal(t, 1, logs.Len())
+			assert.Contains(t, logs.All()[0].Message, tt.wantWarn)
+		})
+	}
+}
+
+func TestWaitWorkers(t *testing.T) {
+	t.Run("WorkersDone", func(t *testing.T) {
+		c := &Config{}
+		var wg sync.WaitGroup
+		stopped := false
+		require.NoError(t, c.WaitWorkers(&wg, func() { stopped = true }, nil, zap.NewNop()))
+		assert.False(t, stopped)
+	})
+
+	t.Run("Duration", func(t *testing.T) {
+		c := &Config{TotalDuration: 10 * time.Millisecond}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		stop := make(chan struct{})
+		go func() {
+			<-stop
+			wg.Done()
+		}()
+		require.NoError(t, c.WaitWorkers(&wg, func() { close(stop) }, nil, zap.NewNop()))
+	})
+
+	t.Run("MaxErrors", func(t *testing.T) {
+		c := &Config{TotalDuration: time.Hour}
+		exportErrors := NewExportErrors()
+		exportErrors.AbortAfter(1)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		stop := make(chan struct{})
+		go func() {
+			<-stop
+			wg.Done()
+		}()
+		exportErrors.Add(errors.New("boom"))
+		err := c.WaitWorkers(&wg, func() { close(stop) }, exportErrors, zap.NewNop())
+		require.ErrorIs(t, err, ErrMaxErrors)
+		assert.EqualError(t, err, "too many export errors: aborted after 1 failed exports (`max-errors`)")
+	})
+}
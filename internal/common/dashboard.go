@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sparklineWidth is the number of reporting intervals the sparkline of the dashboard shows.
+const sparklineWidth = 40
+
+// sparkBars are the bars of the sparkline, from the lowest rate to the highest.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// Dashboard draws the live state of a run in place on the terminal with `tui`: the
+// generation rate, the total, the export errors and a sparkline of the recent rates.
+type Dashboard struct {
+	out     io.Writer
+	signal  string        // name of the generated items, like "traces"
+	counter *int64        // shared counter of the generated items
+	errors  *ExportErrors // export errors of the run, nil when not counted
+	start   time.Time
+
+	prevTime  time.Time // time of the previous frame
+	prevCount int64     // counter at the previous frame
+	rates     []float64 // rates of the last sparklineWidth intervals, oldest first
+	lines     int       // number of lines of the previous frame, overwritten by the next one
+}
+
+// StartDashboard draws the dashboard of a run every `interval` when `tui` is set, until
+// the returned function is called, which draws the final frame. counter is read
+// atomically and exportErrors may be nil.
+func (c *Config) StartDashboard(signal string, counter *int64, exportErrors *ExportErrors) (stop func()) {
+	if !c.TUI {
+		return func() {}
+	}
+	interval := c.ReportingInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	d := newDashboard(os.Stdout, signal, counter, exportErrors, time.Now())
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		d.draw(time.Now())
+		for {
+			select {
+			case now := <-ticker.C:
+				d.draw(now)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		d.draw(time.Now())
+	}
+}
+
+func newDashboard(out io.Writer, signal string, counter *int64, exportErrors *ExportErrors, start time.Time) *Dashboard {
+	return &Dashboard{out: out, signal: signal, counter: counter, errors: exportErrors, start: start, prevTime: start}
+}
+
+// draw samples the counters at now and redraws the dashboard over its previous frame.
+func (d *Dashboard) draw(now time.Time) {
+	count := atomic.LoadInt64(d.counter)
+	if elapsed := now.Sub(d.prevTime); elapsed > 0 {
+		d.rates = append(d.rates, float64(count-d.prevCount)/elapsed.Seconds())
+		if len(d.rates) > sparklineWidth {
+			d.rates = d.rates[1:]
+		}
+	}
+	d.prevTime, d.prevCount = now, count
+
+	var rate float64
+	if len(d.rates) > 0 {
+		rate = d.rates[len(d.rates)-1]
+	}
+	var errors int64
+	if d.errors != nil {
+		errors = d.errors.Total()
+	}
+	frame := []string{
+		fmt.Sprintf("trazr-gen %s, %s elapsed", d.signal, now.Sub(d.start).Round(time.Second)),
+		fmt.Sprintf("  rate    %.1f/s", rate),
+		fmt.Sprintf("  total   %d", count),
+		fmt.Sprintf("  errors  %d", errors),
+		"  " + sparkline(d.rates),
+	}
+
+	var b strings.Builder
+	if d.lines > 0 {
+		// move back to the first line of the previous frame
+		fmt.Fprintf(&b, "\033[%dA", d.lines)
+	}
+	for _, line := range frame {
+		b.WriteString("\r\033[2K")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	d.lines = len(frame)
+	_, _ = io.WriteString(d.out, b.String())
+}
+
+// sparkline renders rates as bars scaled to the highest of them.
+func sparkline(rates []float64) string {
+	var highest float64
+	for _, r := range rates {
+		highest = max(highest, r)
+	}
+	bars := make([]rune, len(rates))
+	for i, r := range rates {
+		level := 0
+		if highest > 0 {
+			level = int(r / highest * float64(len(sparkBars)-1))
+		}
+		bars[i] = sparkBars[level]
+	}
+	return string(bars)
+}
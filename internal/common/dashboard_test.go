@@ -0,0 +1,51 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparkline(t *testing.T) {
+	assert.Empty(t, sparkline(nil))
+	assert.Equal(t, "▁▁▁", sparkline([]float64{0, 0, 0}))
+	assert.Equal(t, "▁▄█", sparkline([]float64{0, 50, 100}))
+}
+
+func TestDashboardDraw(t *testing.T) {
+	var out bytes.Buffer
+	var counter int64
+	exportErrors := NewExportErrors()
+	start := time.Now()
+	d := newDashboard(&out, "traces", &counter, exportErrors, start)
+
+	d.draw(start)
+	first := out.String()
+	assert.Contains(t, first, "trazr-gen traces, 0s elapsed")
+	assert.Contains(t, first, "total   0")
+	assert.NotContains(t, first, "\033[5A", "the first frame has nothing to overwrite")
+
+	out.Reset()
+	counter = 200
+	exportErrors.Add(errors.New("boom"))
+	d.draw(start.Add(2 * time.Second))
+	second := out.String()
+	require.True(t, strings.HasPrefix(second, "\033[5A"), "the next frames overwrite the previous one")
+	assert.Contains(t, second, "rate    100.0/s")
+	assert.Contains(t, second, "total   200")
+	assert.Contains(t, second, "errors  1")
+}
+
+func TestStartDashboardDisabled(t *testing.T) {
+	var counter int64
+	stop := (&Config{}).StartDashboard("logs", &counter, nil)
+	stop()
+}
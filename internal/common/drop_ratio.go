@@ -0,0 +1,17 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+// DropItems returns the items of an export that drops doesn't pick for `drop-ratio`,
+// counting the others as skipped in skipped, which may be nil.
+func DropItems[T any](items []T, drops func(T) bool, skipped *ExportErrors) []T {
+	kept := make([]T, 0, len(items))
+	for _, item := range items {
+		if !drops(item) {
+			kept = append(kept, item)
+		}
+	}
+	skipped.Skip(len(items) - len(kept))
+	return kept
+}
@@ -0,0 +1,19 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropItems(t *testing.T) {
+	skipped := NewExportErrors()
+	kept := DropItems([]int{1, 2, 3, 4}, func(i int) bool { return i%2 == 0 }, skipped)
+	assert.Equal(t, []int{1, 3}, kept)
+	assert.Equal(t, int64(2), skipped.Skipped())
+
+	assert.Equal(t, []int{1}, DropItems([]int{1}, func(int) bool { return false }, nil), "a nil counter is fine")
+}
@@ -0,0 +1,20 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+// Chance reports true with probability p, e.g. to pick the `duplicate-ratio` share of
+// the exports.
+func Chance(p float64) bool {
+	return p > 0 && RandFloat64() < p
+}
+
+// ExportDuplicated runs export, and runs it again for the `duplicate-ratio` share ratio of
+// the successful exports, with the same items, like the retries of an exporter whose
+// response was lost.
+func ExportDuplicated(ratio float64, export func() error) error {
+	if err := export(); err != nil || !Chance(ratio) {
+		return err
+	}
+	return export()
+}
@@ -0,0 +1,38 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChance(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		assert.False(t, Chance(0))
+		assert.True(t, Chance(1))
+	}
+}
+
+func TestExportDuplicated(t *testing.T) {
+	exports := 0
+	export := func() error {
+		exports++
+		return nil
+	}
+	assert.NoError(t, ExportDuplicated(0, export))
+	assert.Equal(t, 1, exports)
+	assert.NoError(t, ExportDuplicated(1, export))
+	assert.Equal(t, 3, exports, "the duplicated export runs twice")
+
+	failed := 0
+	err := ExportDuplicated(1, func() error {
+		failed++
+		return errors.New("unavailable")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, failed, "a failed export is not sent again")
+}
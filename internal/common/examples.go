@@ -0,0 +1,60 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import "strings"
+
+// Example is a command line shown in the help of a command. The examples are tested, so
+// that each of them parses and validates with the current flags.
+type Example struct {
+	Description string
+	Command     string // full command line, quoting arguments with single quotes like a shell
+}
+
+// FormatExamples returns examples as the Example field of a cobra command: each command
+// line indented under its description as a comment.
+func FormatExamples(examples []Example) string {
+	var b strings.Builder
+	for i, e := range examples {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString("  # ")
+		b.WriteString(e.Description)
+		b.WriteString("\n  ")
+		b.WriteString(e.Command)
+	}
+	return b.String()
+}
+
+// Args splits the command line of the example into its arguments, like a shell does with
+// single quoted arguments, leaving out the program name.
+func (e Example) Args() []string {
+	var args []string
+	var arg strings.Builder
+	quoted, inArg := false, false
+	for _, r := range e.Command {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			inArg = true
+		case r == ' ' && !quoted:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args[1:]
+}
@@ -0,0 +1,33 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatExamples(t *testing.T) {
+	got := FormatExamples([]Example{
+		{Description: "Send traces", Command: "trazr-gen traces"},
+		{Description: "Send logs", Command: "trazr-gen logs --logs 10"},
+	})
+	assert.Equal(t, "  # Send traces\n  trazr-gen traces\n\n  # Send logs\n  trazr-gen logs --logs 10", got)
+}
+
+func TestExample_Args(t *testing.T) {
+	for _, tt := range []struct {
+		command string
+		want    []string
+	}{
+		{command: "", want: nil},
+		{command: "trazr-gen traces", want: []string{"traces"}},
+		{command: "trazr-gen  logs --body 'Patient {{Name}} admitted' --logs 1", want: []string{"logs", "--body", "Patient {{Name}} admitted", "--logs", "1"}},
+		{command: `trazr-gen traces --otlp-attributes 'user.name="{{Name}}"'`, want: []string{"traces", "--otlp-attributes", `user.name="{{Name}}"`}},
+		{command: "trazr-gen logs --body ''", want: []string{"logs", "--body", ""}},
+	} {
+		assert.Equal(t, tt.want, Example{Command: tt.command}.Args(), tt.command)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorCategory is the kind of failure an export error belongs to.
+type ErrorCategory string
+
+const (
+	ErrorConnection    ErrorCategory = "connection"
+	ErrorTimeout       ErrorCategory = "timeout"
+	ErrorRejected      ErrorCategory = "rejected"
+	ErrorSerialization ErrorCategory = "serialization"
+	ErrorOther         ErrorCategory = "other"
+)
+
+// errorCategories lists the categories in summary order.
+var errorCategories = []ErrorCategory{ErrorConnection, ErrorTimeout, ErrorRejected, ErrorSerialization, ErrorOther}
+
+// httpStatusPattern matches the status the OTLP HTTP exporters put in non-retryable errors,
+// e.g. "failed to send logs to http://localhost:4318/v1/logs: 400 Bad Request (body: ...)".
+var httpStatusPattern = regexp.MustCompile(`: ([1-5]\d\d) `)
+
+// ClassifyExportError buckets an exporter error using its gRPC status, network error
+// type or, for the HTTP exporters, the response status in the message.
+func ClassifyExportError(err error) ErrorCategory {
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		switch st.Code() {
+		case codes.DeadlineExceeded:
+			return ErrorTimeout
+		case codes.Unavailable, codes.Canceled:
+			return ErrorConnection
+		case codes.ResourceExhausted, codes.InvalidArgument, codes.PermissionDenied,
+			codes.Unauthenticated, codes.FailedPrecondition, codes.Unimplemented:
+			return ErrorRejected
+		}
+	}
+
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "marshal") || strings.Contains(msg, "proto:") {
+		return ErrorSerialization
+	}
+	if m := httpStatusPattern.FindStringSubmatch(msg); m != nil {
+		if code, _ := strconv.Atoi(m[1]); code >= 400 && code < 500 {
+			return ErrorRejected
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) ||
+		strings.Contains(msg, "deadline exceeded") || strings.Contains(msg, "timeout") ||
+		strings.Contains(msg, "max retry time elapsed") {
+		return ErrorTimeout
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "connection reset") || strings.Contains(msg, "no such host") {
+		return ErrorConnection
+	}
+	return ErrorOther
+}
+
+// ExportErrors counts export errors by category, along with the items the failed
+// exports dropped, the ones the successful exports delivered and the ones partial
+// success responses rejected. It is safe for concurrent use.
+type ExportErrors struct {
+	mu        sync.Mutex
+	counts    map[ErrorCategory]int64
+	dropped   int64
+	delivered int64
+	rejected  int64
+	skipped   int64
+	limit     int64         // number of errors closing exceeded, 0 meaning no limit
+	exceeded  chan struct{} // closed once max errors are counted
+}
+
+// ErrMaxErrors is returned by the runs aborted by `max-errors`.
+var ErrMaxErrors = errors.New("too many export errors")
+
+// NewExportErrors returns an empty export error counter.
+func NewExportErrors() *ExportErrors {
+	return &ExportErrors{counts: make(map[ErrorCategory]int64)}
+}
+
+// Add classifies err and counts it, returning its category. A nil error is ignored.
+func (e *ExportErrors) Add(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	category := ClassifyExportError(err)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.counts[category]++
+	if e.limit > 0 && e.total() == e.limit {
+		close(e.exceeded)
+	}
+	return category
+}
+
+// AbortAfter makes Exceeded fire once n errors are counted. 0 means no limit.
+func (e *ExportErrors) AbortAfter(n int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.limit = n
+	if n > 0 && e.exceeded == nil {
+		e.exceeded = make(chan struct{})
+	}
+}
+
+// Exceeded returns a channel closed once the limit set by AbortAfter is reached. Without
+// a limit, it returns nil, which never fires.
+func (e *ExportErrors) Exceeded() <-chan struct{} {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.exceeded
+}
+
+// Drop counts n items lost in a failed export.
+func (e *ExportErrors) Drop(n int) {
+	e.mu.Lock()
+	e.dropped += int64(n)
+	e.mu.Unlock()
+}
+
+// Dropped returns the number of items lost in failed exports.
+func (e *ExportErrors) Dropped() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.dropped
+}
+
+// Deliver counts n items sent by a successful export.
+func (e *ExportErrors) Deliver(n int) {
+	e.mu.Lock()
+	e.delivered += int64(n)
+	e.mu.Unlock()
+}
+
+// Delivered returns the number of items sent by successful exports, less the ones
+// their partial success responses rejected.
+func (e *ExportErrors) Delivered() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.delivered - e.rejected
+}
+
+// Reject counts n items rejected by the partial success response of an export.
+func (e *ExportErrors) Reject(n int64) {
+	e.mu.Lock()
+	e.rejected += n
+	e.mu.Unlock()
+}
+
+// Rejected returns the number of items rejected by partial success responses.
+func (e *ExportErrors) Rejected() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rejected
+}
+
+// CountExport counts the outcome of an export of n items: its error by category along
+// with the n items it dropped, or else the n items it delivered. It returns err.
+func (e *ExportErrors) CountExport(err error, n int) error {
+	if err != nil {
+		e.Add(err)
+		e.Drop(n)
+	} else {
+		e.Deliver(n)
+	}
+	return err
+}
+
+// Skip counts n items generated but left out of the exports by `drop-ratio`. A nil
+// counter ignores them.
+func (e *ExportErrors) Skip(n int) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.skipped += int64(n)
+	e.mu.Unlock()
+}
+
+// Skipped returns the number of items left out of the exports by `drop-ratio`.
+func (e *ExportErrors) Skipped() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.skipped
+}
+
+// Count returns the number of errors counted for the category.
+func (e *ExportErrors) Count(category ErrorCategory) int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.counts[category]
+}
+
+// Total returns the number of errors counted across all categories.
+func (e *ExportErrors) Total() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.total()
+}
+
+func (e *ExportErrors) total() int64 {
+	var total int64
+	for _, n := range e.counts {
+		total += n
+	}
+	return total
+}
+
+// Report logs the error counts by category, the partially rejected items and the skipped
+// ones and, with terminal output, prints those that aren't zero.
+func (e *ExportErrors) Report(logger *zap.Logger, terminal bool) {
+	fields := make([]zap.Field, 0, len(errorCategories))
+	parts := make([]string, 0, len(errorCategories))
+	for _, category := range errorCategories {
+		n := e.Count(category)
+		fields = append(fields, zap.Int64(string(category), n))
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", category, n))
+		}
+	}
+	logger.Info("export errors", append(fields, zap.Int64("total", e.Total()), zap.Int64("dropped", e.Dropped()), zap.Int64("partially_rejected", e.Rejected()), zap.Int64("skipped", e.Skipped()))...)
+	if terminal && len(parts) > 0 {
+		NewConsoleOutput().Warningln("Export errors:", strings.Join(parts, " "))
+	}
+	if terminal && e.Rejected() > 0 {
+		NewConsoleOutput().Warningln("Items rejected by partial success responses:", e.Rejected())
+	}
+	if terminal && e.Skipped() > 0 {
+		NewConsoleOutput().Warningln("Items generated but not exported, for drop-ratio:", e.Skipped())
+	}
+}
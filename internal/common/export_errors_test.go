@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyExportError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorCategory
+	}{
+		{
+			name:     "grpc deadline exceeded",
+			err:      status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+			expected: ErrorTimeout,
+		},
+		{
+			name:     "grpc unavailable",
+			err:      status.Error(codes.Unavailable, "connection error"),
+			expected: ErrorConnection,
+		},
+		{
+			name:     "grpc resource exhausted",
+			err:      status.Error(codes.ResourceExhausted, "too many requests"),
+			expected: ErrorRejected,
+		},
+		{
+			name:     "http bad request",
+			err:      errors.New("failed to send logs to http://localhost:4318/v1/logs: 400 Bad Request (body: invalid)"),
+			expected: ErrorRejected,
+		},
+		{
+			name:     "context deadline",
+			err:      fmt.Errorf("export: %w", context.DeadlineExceeded),
+			expected: ErrorTimeout,
+		},
+		{
+			name:     "dial error",
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("refused")},
+			expected: ErrorConnection,
+		},
+		{
+			name:     "marshal failure",
+			err:      errors.New("proto: marshal: invalid UTF-8"),
+			expected: ErrorSerialization,
+		},
+		{
+			name:     "unknown",
+			err:      errors.New("something went wrong"),
+			expected: ErrorOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyExportError(tt.err))
+		})
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	e := NewExportErrors()
+	assert.Equal(t, ErrorCategory(""), e.Add(nil))
+	assert.Equal(t, ErrorTimeout, e.Add(context.DeadlineExceeded))
+	assert.Equal(t, ErrorTimeout, e.Add(context.DeadlineExceeded))
+	assert.Equal(t, ErrorOther, e.Add(errors.New("boom")))
+
+	assert.Equal(t, int64(2), e.Count(ErrorTimeout))
+	assert.Equal(t, int64(1), e.Count(ErrorOther))
+	assert.Equal(t, int64(0), e.Count(ErrorConnection))
+	assert.Equal(t, int64(3), e.Total())
+
+	e.Deliver(5)
+	e.Deliver(2)
+	assert.Equal(t, int64(7), e.Delivered())
+	e.Reject(3)
+	assert.Equal(t, int64(3), e.Rejected())
+	assert.Equal(t, int64(4), e.Delivered(), "partially rejected items aren't delivered")
+
+	core, logs := observer.New(zap.InfoLevel)
+	e.Report(zap.New(core), false)
+
+	entries := logs.FilterMessage("export errors").All()
+	assert.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, int64(2), fields["timeout"])
+	assert.Equal(t, int64(1), fields["other"])
+	assert.Equal(t, int64(0), fields["rejected"])
+	assert.Equal(t, int64(3), fields["total"])
+	assert.Equal(t, int64(3), fields["partially_rejected"])
+}
+
+func TestExportErrors_CountExport(t *testing.T) {
+	e := NewExportErrors()
+	assert.NoError(t, e.CountExport(nil, 5))
+	assert.ErrorIs(t, e.CountExport(context.DeadlineExceeded, 3), context.DeadlineExceeded)
+	assert.Equal(t, int64(5), e.Delivered())
+	assert.Equal(t, int64(3), e.Dropped())
+	assert.Equal(t, int64(1), e.Count(ErrorTimeout))
+}
+
+func TestExportErrors_AbortAfter(t *testing.T) {
+	e := NewExportErrors()
+	assert.Nil(t, e.Exceeded(), "no limit by default")
+	var nilErrors *ExportErrors
+	assert.Nil(t, nilErrors.Exceeded())
+
+	e.AbortAfter(2)
+	exceeded := e.Exceeded()
+	e.Add(errors.New("boom"))
+	select {
+	case <-exceeded:
+		t.Fatal("exceeded after a single error")
+	default:
+	}
+	e.Add(errors.New("boom"))
+	e.Add(errors.New("boom"))
+	select {
+	case <-exceeded:
+	default:
+		t.Fatal("not exceeded after 2 errors")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// spacingBuckets are the upper bounds, relative to the target spacing, of the
+// histogram buckets of ExportTiming.Report. The last bucket is unbounded.
+var spacingBuckets = []float64{0.5, 0.9, 1.1, 1.5, 2}
+
+// ExportTiming records, for each worker, the time between consecutive exports, to check
+// that the rate limiter delivers the configured spacing. A nil ExportTiming records nothing.
+type ExportTiming struct {
+	workers []*WorkerTiming
+}
+
+// WorkerTiming records the export intervals of a single worker. It is not safe for
+// concurrent use, each worker owns its own. A nil WorkerTiming records nothing.
+type WorkerTiming struct {
+	last      time.Time
+	intervals []time.Duration
+}
+
+// NewExportTiming returns an export timing recorder for the given number of workers.
+func NewExportTiming(workers int) *ExportTiming {
+	t := &ExportTiming{workers: make([]*WorkerTiming, workers)}
+	for i := range t.workers {
+		t.workers[i] = &WorkerTiming{}
+	}
+	return t
+}
+
+// Worker returns the recorder of worker i (0-based).
+func (t *ExportTiming) Worker(i int) *WorkerTiming {
+	if t == nil {
+		return nil
+	}
+	return t.workers[i]
+}
+
+// Mark records an export happening now.
+func (w *WorkerTiming) Mark() {
+	if w == nil {
+		return
+	}
+	now := time.Now()
+	if !w.last.IsZero() {
+		w.intervals = append(w.intervals, now.Sub(w.last))
+	}
+	w.last = now
+}
+
+// TargetSpacing returns the time the rate limiter should leave between two exports of
+// a worker, or 0 when generation isn't throttled.
+func (c *Config) TargetSpacing() time.Duration {
+	if c.Rate <= 0 {
+		return 0
+	}
+	perWorker := c.Rate
+	if c.SharedLimiter {
+		perWorker = c.Rate / float64(max(c.WorkerCount, 1))
+	}
+	return time.Duration(float64(time.Second) / perWorker)
+}
+
+// Report logs the distribution of the intervals recorded by all workers: percentiles
+// and, when generation is throttled, a histogram relative to the target spacing. It
+// must only be called once the workers are done.
+func (t *ExportTiming) Report(logger *zap.Logger, terminal bool, target time.Duration) {
+	if t == nil {
+		return
+	}
+	var all []time.Duration
+	for _, w := range t.workers {
+		all = append(all, w.intervals...)
+	}
+	if len(all) == 0 {
+		logger.Info("export timing", zap.Int("intervals", 0))
+		return
+	}
+	slices.Sort(all)
+	percentile := func(p float64) time.Duration {
+		return all[int(p*float64(len(all)-1))]
+	}
+	fields := []zap.Field{
+		zap.Int("intervals", len(all)),
+		zap.Duration("target", target),
+		zap.Duration("min", all[0]),
+		zap.Duration("p50", percentile(0.5)),
+		zap.Duration("p90", percentile(0.9)),
+		zap.Duration("p99", percentile(0.99)),
+		zap.Duration("max", all[len(all)-1]),
+	}
+	lines := []string{fmt.Sprintf("Export timing: %d intervals, min %s, p50 %s, p90 %s, p99 %s, max %s",
+		len(all), all[0], percentile(0.5), percentile(0.9), percentile(0.99), all[len(all)-1])}
+
+	if target > 0 {
+		counts := spacingHistogram(all, target)
+		for i, n := range counts {
+			label := bucketLabel(i)
+			fields = append(fields, zap.Int(label, n))
+			lines = append(lines, fmt.Sprintf("  %-12s %6.2f%% (%d)", label, 100*float64(n)/float64(len(all)), n))
+		}
+	}
+	logger.Info("export timing", fields...)
+	if terminal {
+		out := NewConsoleOutput()
+		for _, line := range lines {
+			out.Println(line)
+		}
+	}
+}
+
+// spacingHistogram counts the intervals in each of the spacingBuckets, plus the unbounded one.
+func spacingHistogram(intervals []time.Duration, target time.Duration) []int {
+	counts := make([]int, len(spacingBuckets)+1)
+	for _, d := range intervals {
+		ratio := float64(d) / float64(target)
+		i, _ := slices.BinarySearch(spacingBuckets, ratio)
+		counts[i]++
+	}
+	return counts
+}
+
+// bucketLabel names histogram bucket i, e.g. "0.9x-1.1x".
+func bucketLabel(i int) string {
+	switch i {
+	case 0:
+		return fmt.Sprintf("<%gx", spacingBuckets[0])
+	case len(spacingBuckets):
+		return fmt.Sprintf(">%gx", spacingBuckets[i-1])
+	}
+	return fmt.Sprintf("%gx-%gx", spacingBuckets[i-1], spacingBuckets[i])
+}
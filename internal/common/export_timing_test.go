@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWorkerTiming(t *testing.T) {
+	var nilTiming *ExportTiming
+	assert.Nil(t, nilTiming.Worker(0))
+	nilTiming.Worker(0).Mark()
+
+	timing := NewExportTiming(2)
+	w := timing.Worker(0)
+	w.Mark()
+	assert.Empty(t, w.intervals, "the first export has no previous one")
+	time.Sleep(5 * time.Millisecond)
+	w.Mark()
+	w.Mark()
+	assert.Len(t, w.intervals, 2)
+	assert.GreaterOrEqual(t, w.intervals[0], 5*time.Millisecond)
+	assert.Empty(t, timing.Worker(1).intervals)
+}
+
+func TestTargetSpacing(t *testing.T) {
+	c := &Config{Rate: 100, WorkerCount: 4}
+	assert.Equal(t, 10*time.Millisecond, c.TargetSpacing())
+
+	c.SharedLimiter = true
+	assert.Equal(t, 40*time.Millisecond, c.TargetSpacing())
+
+	c.Rate = 0
+	assert.Equal(t, time.Duration(0), c.TargetSpacing())
+}
+
+func TestSpacingHistogram(t *testing.T) {
+	target := 10 * time.Millisecond
+	intervals := []time.Duration{
+		time.Millisecond,       // <0.5x
+		8 * time.Millisecond,   // 0.5x-0.9x
+		10 * time.Millisecond,  // 0.9x-1.1x
+		10 * time.Millisecond,  // 0.9x-1.1x
+		12 * time.Millisecond,  // 1.1x-1.5x
+		50 * time.Millisecond,  // >2x
+		100 * time.Millisecond, // >2x
+	}
+	assert.Equal(t, []int{1, 1, 2, 1, 0, 2}, spacingHistogram(intervals, target))
+
+	assert.Equal(t, "<0.5x", bucketLabel(0))
+	assert.Equal(t, "0.9x-1.1x", bucketLabel(2))
+	assert.Equal(t, ">2x", bucketLabel(len(spacingBuckets)))
+}
+
+func TestExportTimingReport(t *testing.T) {
+	timing := NewExportTiming(2)
+	timing.workers[0].intervals = []time.Duration{10 * time.Millisecond, 10 * time.Millisecond}
+	timing.workers[1].intervals = []time.Duration{30 * time.Millisecond}
+
+	core, logs := observer.New(zap.InfoLevel)
+	timing.Report(zap.New(core), false, 10*time.Millisecond)
+
+	entries := logs.FilterMessage("export timing").All()
+	assert.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, int64(3), fields["intervals"])
+	assert.Equal(t, 10*time.Millisecond, fields["min"])
+	assert.Equal(t, 30*time.Millisecond, fields["max"])
+	assert.Equal(t, int64(2), fields["0.9x-1.1x"])
+	assert.Equal(t, int64(1), fields[">2x"])
+
+	// nothing is reported when export timing is disabled
+	var disabled *ExportTiming
+	disabled.Report(zap.New(core), false, 0)
+	assert.Len(t, logs.FilterMessage("export timing").All(), 1)
+}
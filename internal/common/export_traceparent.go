@@ -0,0 +1,109 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// randomTraceparent is the `export-traceparent` value giving every export request a
+// traceparent of its own.
+const randomTraceparent = "random"
+
+// exportTimeout mirrors the default timeout of the OTLP HTTP exporters, which they no
+// longer apply once given their own HTTP client.
+const exportTimeout = 10 * time.Second
+
+// ValidateExportTraceparent checks that `export-traceparent` is empty, "random" or a W3C
+// traceparent, and that `export-tracestate` is a valid tracestate only set along with it.
+func ValidateExportTraceparent(traceparent, tracestate string) error {
+	if traceparent != "" && traceparent != randomTraceparent {
+		carrier := propagation.MapCarrier{"traceparent": traceparent}
+		ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return ValidationErrorf("export-traceparent", "invalid `export-traceparent` %q: expected 'random' or a W3C traceparent, like '00-<32 hex digits>-<16 hex digits>-01'", traceparent)
+		}
+	}
+	if tracestate == "" {
+		return nil
+	}
+	if traceparent == "" {
+		return ValidationErrorf("export-tracestate", "`export-tracestate` requires `export-traceparent`")
+	}
+	if _, err := trace.ParseTraceState(tracestate); err != nil {
+		return ValidationErrorf("export-tracestate", "invalid `export-tracestate` %q: %w", tracestate, err)
+	}
+	return nil
+}
+
+// ExportHTTPClient returns the HTTP client the OTLP HTTP exporter of signal sends its
+// requests with when `export-traceparent`, `expect-traceresponse`, `otlp-http-stream`,
+// `null-attributes` or `total-bytes` is set or the protocol is http/json, or nil to let
+// it use its own. The exporters ignore their TLS option once given a client, so it carries tlsCfg
+// instead.
+func (c *Config) ExportHTTPClient(signal string, tlsCfg *tls.Config) *http.Client {
+	volume := c.ExportVolume()
+	if c.ExportTraceparent == "" && !c.ExpectTraceresponse && !c.StreamHTTPBody && !c.NullAttributes && !c.httpJSON && volume == nil {
+		return nil
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = tlsCfg
+	var transport http.RoundTripper = base
+	if c.StreamHTTPBody {
+		transport = streamingTransport{base: transport}
+	}
+	if c.ExpectTraceresponse {
+		// below traceparentTransport, to see the traceparent it stamps on the request
+		transport = traceresponseTransport{base: transport}
+	}
+	if c.ExportTraceparent != "" {
+		transport = traceparentTransport{base: transport, traceparent: c.ExportTraceparent, tracestate: c.ExportTracestate}
+	}
+	if volume != nil {
+		transport = volumeTransport{base: transport, volume: volume}
+	}
+	if newRequest, ok := exportRequests[signal]; ok && c.httpJSON {
+		// outside the transports above, for them to see the JSON body as sent
+		transport = jsonTransport{base: transport, newRequest: newRequest, newResponse: exportResponses[signal]}
+	}
+	if newRequest, ok := exportRequests[signal]; ok && c.NullAttributes {
+		// outermost, so that the other transports see the body as sent
+		transport = nullAttributesTransport{base: transport, newRequest: newRequest}
+	}
+	return &http.Client{Transport: transport, Timeout: exportTimeout}
+}
+
+// traceparentTransport stamps the W3C trace context headers on every request, so that
+// infrastructure tracing the export requests sees them as part of a trace.
+type traceparentTransport struct {
+	base        http.RoundTripper
+	traceparent string // header value, or "random" for a new trace per request
+	tracestate  string // header value, left out when empty
+}
+
+func (t traceparentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	traceparent := t.traceparent
+	if traceparent == randomTraceparent {
+		traceparent = newTraceparent()
+	}
+	req.Header.Set("traceparent", traceparent)
+	if t.tracestate != "" {
+		req.Header.Set("tracestate", t.tracestate)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newTraceparent returns the traceparent of a new sampled trace.
+func newTraceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", RandTraceID(), RandSpanID())
+}
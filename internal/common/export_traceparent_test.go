@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExportTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		tracestate  string
+		wantErr     string
+	}{
+		{name: "Unset"},
+		{name: "Random", traceparent: "random"},
+		{name: "Fixed", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tracestate: "vendor=value"},
+		{
+			name:        "Invalid traceparent",
+			traceparent: "00-4bf92f35-00f067aa0ba902b7-01",
+			wantErr:     "invalid `export-traceparent` \"00-4bf92f35-00f067aa0ba902b7-01\": expected 'random' or a W3C traceparent, like '00-<32 hex digits>-<16 hex digits>-01'",
+		},
+		{name: "Tracestate alone", tracestate: "vendor=value", wantErr: "`export-tracestate` requires `export-traceparent`"},
+		{name: "Invalid tracestate", traceparent: "random", tracestate: "no equal sign", wantErr: "invalid `export-tracestate` \"no equal sign\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateExportTraceparent(tt.traceparent, tt.tracestate)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestExportHTTPClient(t *testing.T) {
+	c := &Config{}
+	assert.Nil(t, c.ExportHTTPClient("traces", nil), "the exporters keep their own client by default")
+
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+	}))
+	defer server.Close()
+	send := func(client *http.Client) {
+		resp, err := client.Post(server.URL, "application/x-protobuf", http.NoBody)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	c.ExportTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	c.ExportTracestate = "vendor=value"
+	send(c.ExportHTTPClient("traces", nil))
+	assert.Equal(t, c.ExportTraceparent, headers[0].Get("traceparent"))
+	assert.Equal(t, "vendor=value", headers[0].Get("tracestate"))
+
+	c.ExportTraceparent, c.ExportTracestate = "random", ""
+	client := c.ExportHTTPClient("traces", nil)
+	send(client)
+	send(client)
+	require.NoError(t, ValidateExportTraceparent(headers[1].Get("traceparent"), ""))
+	assert.NotEqual(t, headers[1].Get("traceparent"), headers[2].Get("traceparent"), "every request gets a new trace")
+	assert.Empty(t, headers[1].Get("tracestate"))
+}
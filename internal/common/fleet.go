@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// fleetHostName is the mock template of the host.name of the hosts of a `fleet-size`
+// fleet, to which the number of the host is appended so that the names are distinct.
+const fleetHostName = "{{Adjective}}-{{Animal}}"
+
+// FleetResources returns the resources the data is emitted from in turn: one per
+// `fleet-size` host, each with its own mock host.name and host.id on top of the resource
+// attributes, whose mock templates are expanded again for each host. Without a fleet, it
+// returns the single resource of the resource attributes. The resources have schemaURL,
+// and with `merge-default-resource` the attributes of the default resource of the SDK.
+func (c *Config) FleetResources(schemaURL string) ([]*resource.Resource, error) {
+	size := max(c.FleetSize, 1)
+	resources := make([]*resource.Resource, size)
+	for k := range resources {
+		attrs, err := c.GetResourceAttrWithMockMarker()
+		if err != nil {
+			return nil, err
+		}
+		if size > 1 {
+			host, err := fleetHostAttributes(k + 1)
+			if err != nil {
+				return nil, err
+			}
+			attrs = withFleetHost(attrs, host)
+		}
+		resources[k] = resource.NewWithAttributes(schemaURL, attrs...)
+		if c.MergeDefaultResource {
+			if resources[k], err = withDefaultResource(resources[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return resources, nil
+}
+
+// withDefaultResource merges res into the default resource of the SDK, with its
+// telemetry.sdk.* attributes and fallback service.name, the attributes of res taking
+// precedence. The schema URL of the default resource is left out, so that it never
+// conflicts with that of res.
+func withDefaultResource(res *resource.Resource) (*resource.Resource, error) {
+	return resource.Merge(resource.NewSchemaless(resource.Default().Attributes()...), res)
+}
+
+// fleetHostAttributes returns the mock host.name and host.id of the host-th host of a fleet.
+func fleetHostAttributes(host int) ([]attribute.KeyValue, error) {
+	name, err := ProcessMockTemplate(fleetHostName, nil)
+	if err != nil {
+		return nil, err
+	}
+	id, err := ProcessMockTemplate("{{UUID}}", nil)
+	if err != nil {
+		return nil, err
+	}
+	return []attribute.KeyValue{
+		attribute.String("host.name", fmt.Sprintf("%s-%d", strings.ToLower(name), host)),
+		attribute.String("host.id", id),
+	}, nil
+}
+
+// withFleetHost replaces the host.name and host.id of attrs with those of host, and adds
+// them to the trazr.mock.data marker, as they are mock data.
+func withFleetHost(attrs, host []attribute.KeyValue) []attribute.KeyValue {
+	result := make([]attribute.KeyValue, 0, len(attrs)+len(host)+1)
+	marked := false
+	for _, attr := range attrs {
+		switch attr.Key {
+		case "host.name", "host.id":
+			continue
+		case "trazr.mock.data":
+			attr = attribute.String("trazr.mock.data", attr.Value.AsString()+",host.name,host.id")
+			marked = true
+		}
+		result = append(result, attr)
+	}
+	result = append(result, host...)
+	if !marked {
+		result = append(result, attribute.String("trazr.mock.data", "host.name,host.id"))
+	}
+	return result
+}
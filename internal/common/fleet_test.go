@@ -0,0 +1,84 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestFleetResources(t *testing.T) {
+	cfg := &Config{ServiceName: "svc", ResourceAttributes: map[string]any{"host.name": "ignored", "env": "test"}}
+
+	resources, err := cfg.FleetResources("")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	name, _ := resources[0].Set().Value("host.name")
+	assert.Equal(t, "ignored", name.AsString(), "without a fleet the resource attributes are kept")
+
+	cfg.FleetSize = 3
+	resources, err = cfg.FleetResources("")
+	require.NoError(t, err)
+	require.Len(t, resources, 3)
+	names := map[string]bool{}
+	ids := map[string]bool{}
+	for _, res := range resources {
+		set := res.Set()
+		name, ok := set.Value("host.name")
+		require.True(t, ok)
+		id, ok := set.Value("host.id")
+		require.True(t, ok)
+		names[name.AsString()] = true
+		ids[id.AsString()] = true
+
+		env, _ := set.Value("env")
+		assert.Equal(t, "test", env.AsString())
+		service, _ := set.Value("service.name")
+		assert.Equal(t, "svc", service.AsString())
+		marker, _ := set.Value("trazr.mock.data")
+		assert.Equal(t, "host.name,host.id", marker.AsString())
+	}
+	assert.Len(t, names, 3, "each host has its own host.name")
+	assert.Len(t, ids, 3, "each host has its own host.id")
+	assert.NotContains(t, names, "ignored")
+}
+
+func TestFleetResources_MergeDefaultResource(t *testing.T) {
+	cfg := &Config{ServiceName: "svc", ResourceAttributes: map[string]any{"env": "test"}}
+
+	resources, err := cfg.FleetResources("https://example.com/schema")
+	require.NoError(t, err)
+	_, ok := resources[0].Set().Value("telemetry.sdk.name")
+	assert.False(t, ok, "the SDK attributes are only added on demand")
+
+	cfg.MergeDefaultResource = true
+	resources, err = cfg.FleetResources("https://example.com/schema")
+	require.NoError(t, err)
+	set := resources[0].Set()
+	sdk, _ := set.Value("telemetry.sdk.name")
+	assert.Equal(t, "opentelemetry", sdk.AsString())
+	_, ok = set.Value("telemetry.sdk.version")
+	assert.True(t, ok)
+	service, _ := set.Value("service.name")
+	assert.Equal(t, "svc", service.AsString(), "the configured service.name takes precedence")
+	env, _ := set.Value("env")
+	assert.Equal(t, "test", env.AsString())
+	assert.Equal(t, "https://example.com/schema", resources[0].SchemaURL())
+}
+
+func TestWithFleetHost(t *testing.T) {
+	host := []attribute.KeyValue{attribute.String("host.name", "h-1"), attribute.String("host.id", "id-1")}
+	got := withFleetHost([]attribute.KeyValue{
+		attribute.String("host.id", "old"),
+		attribute.String("trazr.mock.data", "user.id"),
+	}, host)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("trazr.mock.data", "user.id,host.name,host.id"),
+		attribute.String("host.name", "h-1"),
+		attribute.String("host.id", "id-1"),
+	}, got)
+}
@@ -0,0 +1,52 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ValidateGRPCMetadata checks that the keys of `grpc-metadata` are valid gRPC metadata
+// keys: digits, letters, '-', '_' and '.', not starting with the reserved "grpc-".
+func ValidateGRPCMetadata(md KeyValue) error {
+	for _, k := range sortedKeys(md) {
+		key := strings.ToLower(k)
+		if key == "" || strings.HasPrefix(key, "grpc-") || strings.IndexFunc(key, invalidMetadataKeyRune) >= 0 {
+			return ValidationErrorf("grpc-metadata", "invalid `grpc-metadata` key %q: expected digits, letters, '-', '_' or '.', not starting with 'grpc-'", k)
+		}
+		switch md[k].(type) {
+		case string, bool, int, int64, float64:
+		default:
+			return ValidationErrorf("grpc-metadata", "invalid `grpc-metadata` value of %q: expected a string, got %v instead", k, md[k])
+		}
+	}
+	return nil
+}
+
+func invalidMetadataKeyRune(r rune) bool {
+	return (r < '0' || r > '9') && (r < 'a' || r > 'z') && r != '-' && r != '_' && r != '.'
+}
+
+// metadataPairs returns the key-value pairs of md, sorted by key, as metadata.Pairs and
+// metadata.AppendToOutgoingContext take them.
+func metadataPairs(md KeyValue) []string {
+	pairs := make([]string, 0, 2*len(md))
+	for _, k := range sortedKeys(md) {
+		pairs = append(pairs, strings.ToLower(k), fmt.Sprint(md[k]))
+	}
+	return pairs
+}
+
+// metadataInterceptor adds the pairs to the outgoing metadata of every request, next to
+// the OTLP headers the exporters send as metadata themselves.
+func metadataInterceptor(pairs []string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(metadata.AppendToOutgoingContext(ctx, pairs...), method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestValidateGRPCMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		md      KeyValue
+		wantErr string
+	}{
+		{name: "Empty"},
+		{name: "Valid", md: KeyValue{"X-Gateway-Key": "secret", "tenant_id": 42, "trace-bin": "\x01"}},
+		{name: "Reserved prefix", md: KeyValue{"grpc-timeout": "1S"}, wantErr: "invalid `grpc-metadata` key \"grpc-timeout\""},
+		{name: "Invalid character", md: KeyValue{"x tenant": "acme"}, wantErr: "invalid `grpc-metadata` key \"x tenant\""},
+		{name: "Nested value", md: KeyValue{"x-tenant": map[string]any{"id": "acme"}}, wantErr: "invalid `grpc-metadata` value of \"x-tenant\": expected a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGRPCMetadata(tt.md)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "grpc-metadata", validationErr.Field)
+		})
+	}
+}
+
+func TestMetadataInterceptor(t *testing.T) {
+	c := &Config{}
+	assert.Empty(t, c.ExportDialOptions(), "the exporters dial without interceptors by default")
+
+	c.GRPCMetadata = KeyValue{"X-Gateway-Key": "secret", "x-retries": 3}
+	require.Len(t, c.ExportDialOptions(), 1)
+	c.TotalBytes = "1KB"
+	require.Len(t, c.ExportDialOptions(), 1, "the interceptors are chained")
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer x")
+	var got metadata.MD
+	err := metadataInterceptor(metadataPairs(c.GRPCMetadata))(ctx, "/export", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			got, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"secret"}, got.Get("x-gateway-key"))
+	assert.Equal(t, []string{"3"}, got.Get("x-retries"))
+	assert.Equal(t, []string{"Bearer x"}, got.Get("authorization"), "the OTLP headers are kept")
+}
@@ -0,0 +1,46 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// SignalPlaceholder is replaced with the name of the signal in `otlp-http-url-path`, so
+// that one path like "/otlp/v1/{signal}" suits every signal of the all command.
+const SignalPlaceholder = "{signal}"
+
+// signals are the signals trazr-gen generates, whose default OTLP HTTP path is
+// "/v1/<signal>".
+var signals = []string{"traces", "metrics", "logs"}
+
+// URLPath returns the `otlp-http-url-path` the exporter of signal, like "traces", sends
+// to, with SignalPlaceholder replaced with the name of the signal.
+func (c *Config) URLPath(signal string) string {
+	return strings.ReplaceAll(c.HTTPPath, SignalPlaceholder, signal)
+}
+
+// WarnURLPath reports an OTLP HTTP path of signal ending with the default path of another
+// signal, like traces sent to /v1/metrics, that the collector rejects or misroutes
+// without the exports failing.
+func (c *Config) WarnURLPath(logger *zap.Logger, signal string) {
+	if !c.UseHTTP {
+		return
+	}
+	path := c.URLPath(signal)
+	for _, other := range signals {
+		if other == signal || !strings.HasSuffix(strings.TrimSuffix(path, "/"), "/v1/"+other) {
+			continue
+		}
+		msg := fmt.Sprintf("`otlp-http-url-path` %s is the path of %s, not %s: use /v1/%s or %s", path, other, signal, signal, SignalPlaceholder)
+		logger.Warn(msg, zap.String("otlp-http-url-path", path), zap.String("signal", signal))
+		if c.TerminalOutput {
+			NewConsoleOutput().Warningln("Warning:", msg)
+		}
+		return
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// liveLimiters are the rate limiters of the generators running in this process, which
+// ApplyRate changes when the configuration is reloaded.
+var liveLimiters = struct {
+	sync.Mutex
+	set      map[*rate.Limiter]struct{}
+	reloaded *float64 // rate last given to ApplyRate, taken by the runs started afterwards
+}{set: make(map[*rate.Limiter]struct{})}
+
+// RunLimiters returns the rate limiter of each worker of a run, limited at limit: the
+// same one for all of them with `shared-limiter`, else one per worker. They stay
+// registered for ApplyRate until release is called at the end of the run. A rate given to
+// ApplyRate since the run started wins over limit.
+func (c *Config) RunLimiters(limit rate.Limit) (limiters []*rate.Limiter, release func()) {
+	liveLimiters.Lock()
+	defer liveLimiters.Unlock()
+	if liveLimiters.reloaded != nil {
+		limit = rateLimit(*liveLimiters.reloaded)
+	}
+	limiters = make([]*rate.Limiter, max(c.WorkerCount, 0))
+	for i := range limiters {
+		if c.SharedLimiter && i > 0 {
+			limiters[i] = limiters[0]
+			continue
+		}
+		limiters[i] = rate.NewLimiter(limit, 1)
+	}
+	for _, l := range limiters {
+		liveLimiters.set[l] = struct{}{}
+	}
+	return limiters, func() {
+		liveLimiters.Lock()
+		defer liveLimiters.Unlock()
+		for _, l := range limiters {
+			delete(liveLimiters.set, l)
+		}
+	}
+}
+
+// ApplyRate changes the rate of every running worker to r per second, 0 meaning
+// unthrottled. The runs started afterwards, like the next one of `repeat`, take it as
+// their `rate` too. It fails, changing nothing, when r is negative or not a finite number.
+func ApplyRate(r float64) error {
+	if r < 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+		return fmt.Errorf("`rate` must be a finite number of at least 0, got %v", r)
+	}
+	liveLimiters.Lock()
+	defer liveLimiters.Unlock()
+	liveLimiters.reloaded = &r
+	for l := range liveLimiters.set {
+		l.SetLimit(rateLimit(r))
+	}
+	return nil
+}
+
+// ResetAppliedRate forgets the rate given to ApplyRate, the runs started afterwards taking
+// the `rate` of their own configuration again.
+func ResetAppliedRate() {
+	liveLimiters.Lock()
+	defer liveLimiters.Unlock()
+	liveLimiters.reloaded = nil
+}
+
+// takeAppliedRate sets the `rate` of c to the rate last given to ApplyRate, if any, for
+// the run about to start.
+func (c *Config) takeAppliedRate() {
+	liveLimiters.Lock()
+	defer liveLimiters.Unlock()
+	if liveLimiters.reloaded != nil {
+		c.Rate = *liveLimiters.reloaded
+	}
+}
+
+// rateLimit returns the limit of r per second, 0 meaning unthrottled.
+func rateLimit(r float64) rate.Limit {
+	if r == 0 {
+		return rate.Inf
+	}
+	return rate.Limit(r)
+}
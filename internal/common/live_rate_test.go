@@ -0,0 +1,66 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+func TestRunLimiters(t *testing.T) {
+	c := &Config{WorkerCount: 3}
+	limiters, release := c.RunLimiters(5)
+	defer release()
+	assert.Len(t, limiters, 3)
+	assert.NotSame(t, limiters[0], limiters[1])
+	assert.Equal(t, rate.Limit(5), limiters[0].Limit())
+
+	c.SharedLimiter = true
+	shared, releaseShared := c.RunLimiters(5)
+	defer releaseShared()
+	assert.Same(t, shared[0], shared[1])
+	assert.Same(t, shared[0], shared[2])
+}
+
+func TestApplyRate(t *testing.T) {
+	t.Cleanup(ResetAppliedRate)
+	c := &Config{WorkerCount: 2}
+	limiters, release := c.RunLimiters(5)
+
+	require.NoError(t, ApplyRate(20))
+	assert.Equal(t, rate.Limit(20), limiters[0].Limit())
+	assert.Equal(t, rate.Limit(20), limiters[1].Limit())
+	require.NoError(t, ApplyRate(0))
+	assert.Equal(t, rate.Inf, limiters[0].Limit(), "0 means unthrottled")
+
+	release()
+	require.NoError(t, ApplyRate(3))
+	assert.Equal(t, rate.Inf, limiters[0].Limit(), "released limiters are left alone")
+
+	next, releaseNext := c.RunLimiters(5)
+	defer releaseNext()
+	assert.Equal(t, rate.Limit(3), next[0].Limit(), "the applied rate wins over that of the config")
+
+	for _, r := range []float64{-1, math.NaN(), math.Inf(1)} {
+		require.ErrorContains(t, ApplyRate(r), "`rate` must be a finite number of at least 0")
+	}
+	assert.Equal(t, rate.Limit(3), next[0].Limit(), "an invalid rate changes nothing")
+}
+
+func TestApplyRate_Repeat(t *testing.T) {
+	t.Cleanup(ResetAppliedRate)
+	c := &Config{Rate: 5, Repeat: 2}
+	var rates []float64
+	require.NoError(t, c.RunRepeated(zap.NewNop(), func() error {
+		rates = append(rates, c.Rate)
+		return ApplyRate(20)
+	}))
+	assert.Equal(t, []float64{5, 20}, rates, "the next run takes the applied rate")
+	assert.InDelta(t, 20, c.Rate, 0)
+}
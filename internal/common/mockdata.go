@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"regexp"
 	"sync"
 	"time"
 
@@ -30,12 +31,17 @@ func InitMockData(seed int64) {
 	fakerMutex.Unlock()
 }
 
-// ReshuffleMockData generates a new seed based on the current time,
+// ReshuffleMockData generates a new seed based on the current time, or drawn from the
+// source of `seed` when set so that the reshuffled data is reproducible too,
 // creating a new gofakeit.Faker instance. This effectively provides a "new set"
 // of random data for subsequent template calls.
 func ReshuffleMockData() {
+	seed := uint64(time.Now().UnixNano() & 0x7FFFFFFFFFFFFFFF) //nolint:gosec // masking ensures safe conversion
+	if random.Load() != nil {
+		seed = RandUint64() & 0x7FFFFFFFFFFFFFFF
+	}
 	fakerMutex.Lock()
-	fakerInstance = gofakeit.New(uint64(time.Now().UnixNano() & 0x7FFFFFFFFFFFFFFF)) //nolint:gosec // masking ensures safe conversion
+	fakerInstance = gofakeit.New(seed)
 	fakerMutex.Unlock()
 }
 
@@ -50,8 +56,42 @@ func ProcessMockTemplate(tmplStr string, out UserOutput) (string, error) {
 	currentFaker := fakerInstance // Get the current faker instance for this operation
 	fakerMutex.RUnlock()
 	// Step 2: Process with gofakeit.Template, passing the Faker instance itself as Data.
-	value, err := gofakeit.Template(tmplStr, &gofakeit.TemplateOptions{
-		Data: currentFaker, // Pass the Faker instance for template field access
+	return processMockTemplate(tmplStr, currentFaker, out)
+}
+
+var (
+	// templateAction matches a template action, like {{.Severity}}.
+	templateAction = regexp.MustCompile(`\{\{.*?\}\}`)
+	// templateField matches a field reference inside an action, along with the character before it.
+	templateField = regexp.MustCompile(`(^|[^\w.])\.([A-Za-z_]\w*)`)
+)
+
+// ProcessMockTemplateWithData is ProcessMockTemplate, except that the template can also
+// reference the values of data as fields, e.g. {{.Severity}} when data has a "Severity" key.
+func ProcessMockTemplateWithData(tmplStr string, data map[string]any, out UserOutput) (string, error) {
+	// gofakeit executes templates against its options, which hold data in their Data field
+	tmplStr = templateAction.ReplaceAllStringFunc(tmplStr, func(action string) string {
+		return templateField.ReplaceAllStringFunc(action, func(ref string) string {
+			m := templateField.FindStringSubmatch(ref)
+			if _, ok := data[m[2]]; !ok {
+				return ref
+			}
+			return m[1] + ".Data." + m[2]
+		})
+	})
+	return processMockTemplate(tmplStr, data, out)
+}
+
+func processMockTemplate(tmplStr string, data any, out UserOutput) (string, error) {
+	fakerMutex.RLock()
+	faker := fakerInstance
+	fakerMutex.RUnlock()
+	if faker == nil {
+		// InitMockData was never called
+		faker = gofakeit.GlobalFaker
+	}
+	value, err := faker.Template(tmplStr, &gofakeit.TemplateOptions{
+		Data: data,
 	})
 	if err != nil {
 		if out != nil {
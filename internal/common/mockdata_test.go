@@ -77,6 +77,28 @@ func TestProcessMockTemplate_Error(t *testing.T) {
 	}
 }
 
+func TestProcessMockTemplateWithData(t *testing.T) {
+	InitMockData(42)
+	data := map[string]any{"Severity": "Error", "SeverityNumber": 17}
+
+	got, err := ProcessMockTemplateWithData("{{.Severity}} ({{ .SeverityNumber }}): {{if eq .Severity \"Error\"}}failed{{end}}", data, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "Error (17): failed" {
+		t.Errorf("unexpected result %q", got)
+	}
+
+	// fields already qualified, and text outside actions, are left as-is
+	got, err = ProcessMockTemplateWithData(".Severity {{.Data.Severity}} {{Number 1 1}}", data, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != ".Severity Error 1" {
+		t.Errorf("unexpected result %q", got)
+	}
+}
+
 func writeTempFile(t *testing.T, content string) string {
 	// Use the root-level testdata directory for temp files
 	dir := "../../testdata"
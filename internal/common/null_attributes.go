@@ -0,0 +1,152 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// nullAttributeValue stands for the value of a null attribute until it is exported. The
+// OpenTelemetry API has no attribute without a value, and the SDKs drop or rename invalid
+// ones, so `null-attributes` emits these attributes with this string, which the OTLP
+// exporters replace with an empty AnyValue on the wire.
+const nullAttributeValue = "\x00trazr.null"
+
+// nullAttributes returns the attributes of attrs configured with a null value, like `k: ~`
+// in the config file or {"k": null} in JSON, when `null-attributes` is set. Otherwise
+// they are left out.
+func (c *Config) nullAttributes(attrs map[string]any) []attribute.KeyValue {
+	if !c.NullAttributes {
+		return nil
+	}
+	var result []attribute.KeyValue
+	for _, k := range sortedKeys(attrs) {
+		if attrs[k] == nil {
+			result = append(result, attribute.String(k, nullAttributeValue))
+		}
+	}
+	return result
+}
+
+// clearNullValues empties every AnyValue of m holding nullAttributeValue, whatever the
+// signal of the export request.
+func clearNullValues(m protoreflect.Message) {
+	if v, ok := m.Interface().(*commonpb.AnyValue); ok {
+		if v.GetStringValue() == nullAttributeValue {
+			v.Value = nil
+		}
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind || fd.IsMap() {
+			return true
+		}
+		if fd.IsList() {
+			list := v.List()
+			for i := range list.Len() {
+				clearNullValues(list.Get(i).Message())
+			}
+			return true
+		}
+		clearNullValues(v.Message())
+		return true
+	})
+}
+
+// nullAttributesInterceptor sends a copy of the requests whose null attribute values are
+// empty AnyValues.
+func nullAttributesInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if msg, ok := req.(proto.Message); ok {
+		// the exporter owns the request
+		msg = proto.Clone(msg)
+		clearNullValues(msg.ProtoReflect())
+		req = msg
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// exportRequests create an empty export request of each signal, to decode the bodies of
+// the OTLP HTTP exporters into.
+var exportRequests = map[string]func() proto.Message{
+	"traces":  func() proto.Message { return &coltracepb.ExportTraceServiceRequest{} },
+	"metrics": func() proto.Message { return &colmetricpb.ExportMetricsServiceRequest{} },
+	"logs":    func() proto.Message { return &collogspb.ExportLogsServiceRequest{} },
+}
+
+// nullAttributesTransport rewrites the protobuf body of the export requests of a signal,
+// gzip-compressed with `otlp-compression` or not, so that their null attribute values
+// are empty AnyValues. It holds each body in memory to rewrite it, so that with
+// `otlp-http-stream` only the compressed copy is streamed.
+type nullAttributesTransport struct {
+	base       http.RoundTripper
+	newRequest func() proto.Message
+}
+
+func (t nullAttributesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	encoding := req.Header.Get("Content-Encoding")
+	if req.Body == nil || req.Body == http.NoBody || (encoding != "" && encoding != "gzip") {
+		return t.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if rewritten, err := t.clearNullValues(body, encoding == "gzip"); err == nil {
+		body = rewritten
+	}
+	// a RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return t.base.RoundTrip(req)
+}
+
+// clearNullValues returns body, gzip-compressed when gzipped is set, with its null
+// attribute values emptied.
+func (t nullAttributesTransport) clearNullValues(body []byte, gzipped bool) ([]byte, error) {
+	if gzipped {
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	msg := t.newRequest()
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	clearNullValues(msg.ProtoReflect())
+	rewritten, err := proto.Marshal(msg)
+	if err != nil || !gzipped {
+		return rewritten, err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(rewritten); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
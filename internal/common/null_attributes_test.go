@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringAttr(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
+
+func TestNullAttributes(t *testing.T) {
+	cfg := &Config{ServiceName: "svc", ResourceAttributes: KeyValue{"env": "prod", "region": nil}}
+
+	attrs, err := cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.NotContains(t, attrs, attribute.String("region", nullAttributeValue), "null attributes are left out by default")
+
+	cfg.NullAttributes = true
+	attrs, err = cfg.GetResourceAttrWithMockMarker()
+	require.NoError(t, err)
+	assert.Contains(t, attrs, attribute.String("region", nullAttributeValue))
+	assert.Contains(t, attrs, attribute.String("env", "prod"))
+
+	cfg.MockData = true
+	attrs, err = cfg.SignalAttrWithMockMarker(KeyValue{"user.id": "{{UUID}}", "user.name": nil})
+	require.NoError(t, err)
+	assert.Contains(t, attrs, attribute.String("user.name", nullAttributeValue), "with mock data too")
+}
+
+func TestClearNullValues(t *testing.T) {
+	req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttr("region", nullAttributeValue)}},
+		ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{
+			Name:       "span",
+			Attributes: []*commonpb.KeyValue{stringAttr("user.name", nullAttributeValue), stringAttr("env", "prod")},
+		}}}},
+	}}}
+
+	clearNullValues(req.ProtoReflect())
+
+	assert.Nil(t, req.ResourceSpans[0].Resource.Attributes[0].Value.GetValue())
+	spanAttrs := req.ResourceSpans[0].ScopeSpans[0].Spans[0].Attributes
+	require.NotNil(t, spanAttrs[0].Value, "the attribute is kept, with an empty value")
+	assert.Nil(t, spanAttrs[0].Value.GetValue())
+	assert.Equal(t, "prod", spanAttrs[1].Value.GetStringValue())
+}
+
+func TestNullAttributesInterceptor(t *testing.T) {
+	c := &Config{NullAttributes: true}
+	require.Len(t, c.ExportDialOptions(), 1)
+
+	req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttr("region", nullAttributeValue)}},
+	}}}
+	var sent *coltracepb.ExportTraceServiceRequest
+	err := nullAttributesInterceptor(context.Background(), "/export", req, nil, nil,
+		func(_ context.Context, _ string, req, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			sent = req.(*coltracepb.ExportTraceServiceRequest)
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Nil(t, sent.ResourceSpans[0].Resource.Attributes[0].Value.GetValue())
+	assert.Equal(t, nullAttributeValue, req.ResourceSpans[0].Resource.Attributes[0].Value.GetStringValue(), "the request of the exporter is left as is")
+}
+
+func TestNullAttributesTransport(t *testing.T) {
+	var got collogspb.ExportLogsServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(body)), r.ContentLength)
+		assert.NoError(t, proto.Unmarshal(body, &got))
+	}))
+	defer srv.Close()
+
+	req := &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+		ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{
+			Attributes: []*commonpb.KeyValue{stringAttr("user.name", nullAttributeValue)},
+		}}}},
+	}}}
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	client := (&Config{NullAttributes: true}).ExportHTTPClient("logs", nil)
+	require.NotNil(t, client)
+	resp, err := client.Post(srv.URL, "application/x-protobuf", bytes.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	attr := got.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Attributes[0]
+	assert.Equal(t, "user.name", attr.Key)
+	require.NotNil(t, attr.Value)
+	assert.Nil(t, attr.Value.GetValue(), "the null attribute has an empty value on the wire")
+}
+
+func TestNullAttributesTransportGzip(t *testing.T) {
+	var got collogspb.ExportLogsServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		gz, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		body, err := io.ReadAll(gz)
+		assert.NoError(t, err)
+		assert.NoError(t, proto.Unmarshal(body, &got))
+	}))
+	defer srv.Close()
+
+	req := &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+		ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{
+			Attributes: []*commonpb.KeyValue{stringAttr("user.name", nullAttributeValue)},
+		}}}},
+	}}}
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	client := (&Config{NullAttributes: true}).ExportHTTPClient("logs", nil)
+	require.NotNil(t, client)
+	httpReq, err := http.NewRequest(http.MethodPost, srv.URL, &compressed)
+	require.NoError(t, err)
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	resp, err := client.Do(httpReq)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	attr := got.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Attributes[0]
+	require.NotNil(t, attr.Value)
+	assert.Nil(t, attr.Value.GetValue(), "the null attribute of a compressed request has an empty value on the wire")
+}
@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// exportResponses create an empty export response of each signal, to decode the JSON
+// responses of `http/json` into.
+var exportResponses = map[string]func() proto.Message{
+	"traces":  func() proto.Message { return &coltracepb.ExportTraceServiceResponse{} },
+	"metrics": func() proto.Message { return &colmetricpb.ExportMetricsServiceResponse{} },
+	"logs":    func() proto.Message { return &collogspb.ExportLogsServiceResponse{} },
+}
+
+// otlpJSONIDs are the fields of the OTLP messages holding trace and span IDs, which OTLP/JSON
+// encodes as hex strings rather than the base64 of the other bytes fields.
+var otlpJSONIDs = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// jsonTransport sends the export requests of a signal encoded as OTLP/JSON, for the
+// `http/json` protocol the OpenTelemetry Go exporters only encode as protobuf. It
+// transcodes their protobuf body, gzip-compressed with `otlp-compression` or not, and
+// the JSON body of the successful responses back to protobuf, for the exporters to
+// report their partial successes.
+type jsonTransport struct {
+	base        http.RoundTripper
+	newRequest  func() proto.Message
+	newResponse func() proto.Message
+}
+
+func (t jsonTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return t.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	gzipped := req.Header.Get("Content-Encoding") == "gzip"
+	if body, err = t.encodeRequest(body, gzipped); err != nil {
+		return nil, err
+	}
+	// a RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		// the exporters only decode protobuf successes, and quote the body of failures
+		return resp, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	msg := t.newResponse()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(respBody, msg); err == nil {
+		if decoded, err := proto.Marshal(msg); err == nil {
+			respBody = decoded
+			resp.Header = resp.Header.Clone()
+			resp.Header.Set("Content-Type", "application/x-protobuf")
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	resp.ContentLength = int64(len(respBody))
+	return resp, nil
+}
+
+// encodeRequest returns the OTLP/JSON encoding of the protobuf export request body,
+// gzip-compressed on both sides when gzipped is set.
+func (t jsonTransport) encodeRequest(body []byte, gzipped bool) ([]byte, error) {
+	if gzipped {
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	msg := t.newRequest()
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	encoded, err := marshalOTLPJSON(msg)
+	if err != nil || !gzipped {
+		return encoded, err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(encoded); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalOTLPJSON encodes msg as OTLP/JSON: the protobuf JSON mapping, with enums as
+// integers and trace and span IDs as hex strings.
+func marshalOTLPJSON(msg proto.Message) ([]byte, error) {
+	encoded, err := (protojson.MarshalOptions{UseEnumNumbers: true}).Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	// keeps the exact value of numbers, like the doubles of the data points
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := hexIDs(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// hexIDs replaces the base64 trace and span IDs of the decoded JSON v by hex strings.
+func hexIDs(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if s, ok := field.(string); ok && otlpJSONIDs[k] {
+				id, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return err
+				}
+				v[k] = hex.EncodeToString(id)
+				continue
+			}
+			if err := hexIDs(field); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := hexIDs(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
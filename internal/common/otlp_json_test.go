@@ -0,0 +1,67 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestJSONTransport(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(body)), r.ContentLength)
+		assert.NoError(t, json.Unmarshal(body, &got))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"partialSuccess": {"rejectedSpans": "1", "errorMessage": "too old"}}`))
+	}))
+	defer srv.Close()
+
+	req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{
+			TraceId:           []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanId:            []byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+			Name:              "span",
+			Kind:              tracepb.Span_SPAN_KIND_SERVER,
+			StartTimeUnixNano: 1544712660000000000,
+		}}}},
+	}}}
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	cfg := &Config{Protocol: "http/json"}
+	require.NoError(t, cfg.ApplyProtocol())
+	client := cfg.ExportHTTPClient("traces", nil)
+	require.NotNil(t, client)
+	resp, err := client.Post(srv.URL, "application/x-protobuf", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	span := got["resourceSpans"].([]any)[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span["traceId"], "OTLP/JSON IDs are hex")
+	assert.Equal(t, "00f067aa0ba902b7", span["spanId"])
+	assert.InDelta(t, 2, span["kind"], 0, "OTLP/JSON enums are integers")
+	assert.Equal(t, "1544712660000000000", span["startTimeUnixNano"])
+
+	assert.Equal(t, "application/x-protobuf", resp.Header.Get("Content-Type"), "the exporters decode protobuf responses")
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var partial coltracepb.ExportTraceServiceResponse
+	require.NoError(t, proto.Unmarshal(respBody, &partial))
+	assert.Equal(t, int64(1), partial.GetPartialSuccess().GetRejectedSpans())
+	assert.Equal(t, "too old", partial.GetPartialSuccess().GetErrorMessage())
+}
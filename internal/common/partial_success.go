@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+)
+
+// Kinds of the items rejected by partial success responses, as the OTLP exporters name them.
+const (
+	RejectedSpans      = "spans"
+	RejectedDataPoints = "metric data points"
+	RejectedLogRecords = "log records"
+)
+
+// partialSuccessPattern matches the errors the OTLP exporters hand to the OTel error
+// handler on partial success responses, e.g.
+// "OTLP partial success: over quota (5 spans rejected)".
+var partialSuccessPattern = regexp.MustCompile(`^OTLP partial success: (?s:.*) \((\d+) (spans|metric data points|log records) rejected\)$`)
+
+// ParsePartialSuccess returns the number and kind of the items rejected by the partial
+// success response err reports, if it reports one.
+func ParsePartialSuccess(err error) (rejected int64, kind string, ok bool) {
+	m := partialSuccessPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, "", false
+	}
+	rejected, convErr := strconv.ParseInt(m[1], 10, 64)
+	if convErr != nil {
+		return 0, "", false
+	}
+	return rejected, m[2], true
+}
+
+// partialSuccesses routes the partial success responses to the export errors of the
+// runs of each kind of item, as the OTel error handler is global to the process.
+var partialSuccesses = struct {
+	sync.Mutex
+	install    sync.Once
+	recipients map[string]*ExportErrors
+}{recipients: make(map[string]*ExportErrors)}
+
+// CountPartialSuccesses counts the items rejected by the partial success responses of
+// kind, like RejectedSpans, into e until release is called. The OTLP exporters don't fail
+// the export of these responses, they report them to the OTel error handler, which is
+// replaced by one counting them and logging the other errors as before.
+func CountPartialSuccesses(kind string, e *ExportErrors) (release func()) {
+	partialSuccesses.install.Do(func() {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(handleOTelError))
+	})
+	partialSuccesses.Lock()
+	defer partialSuccesses.Unlock()
+	partialSuccesses.recipients[kind] = e
+	return func() {
+		partialSuccesses.Lock()
+		defer partialSuccesses.Unlock()
+		if partialSuccesses.recipients[kind] == e {
+			delete(partialSuccesses.recipients, kind)
+		}
+	}
+}
+
+// handleOTelError counts partial success responses into the export errors of their kind
+// and logs any other error, like the default OTel error handler.
+func handleOTelError(err error) {
+	if rejected, kind, ok := ParsePartialSuccess(err); ok {
+		partialSuccesses.Lock()
+		e := partialSuccesses.recipients[kind]
+		partialSuccesses.Unlock()
+		if e != nil {
+			e.Reject(rejected)
+			return
+		}
+	}
+	log.Print(err)
+}
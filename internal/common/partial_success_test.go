@@ -0,0 +1,51 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestParsePartialSuccess(t *testing.T) {
+	tests := []struct {
+		err      string
+		rejected int64
+		kind     string
+		ok       bool
+	}{
+		{"OTLP partial success: over quota (5 spans rejected)", 5, RejectedSpans, true},
+		{"OTLP partial success: empty message (0 metric data points rejected)", 0, RejectedDataPoints, true},
+		{"OTLP partial success: bad (record) (12 log records rejected)", 12, RejectedLogRecords, true},
+		{"failed to upload metrics: connection refused", 0, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			rejected, kind, ok := ParsePartialSuccess(errors.New(tt.err))
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.rejected, rejected)
+			assert.Equal(t, tt.kind, kind)
+		})
+	}
+}
+
+func TestCountPartialSuccesses(t *testing.T) {
+	spans, logs := NewExportErrors(), NewExportErrors()
+	releaseSpans := CountPartialSuccesses(RejectedSpans, spans)
+	releaseLogs := CountPartialSuccesses(RejectedLogRecords, logs)
+
+	otel.Handle(errors.New("OTLP partial success: over quota (5 spans rejected)"))
+	otel.Handle(errors.New("OTLP partial success: too big (2 log records rejected)"))
+	otel.Handle(errors.New("OTLP partial success: over quota (1 spans rejected)"))
+	assert.Equal(t, int64(6), spans.Rejected())
+	assert.Equal(t, int64(2), logs.Rejected())
+
+	releaseSpans()
+	otel.Handle(errors.New("OTLP partial success: over quota (4 spans rejected)"))
+	assert.Equal(t, int64(6), spans.Rejected(), "released runs count no more partial successes")
+	releaseLogs()
+}
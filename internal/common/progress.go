@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ConsumeProgress counts the items the workers of a run report on progress, one per
+// item, until it is closed. With terminal output, it prints a single line with the count
+// and the rate over the last `interval`, rather than a line per item that bursts with
+// many workers, and the final count once progress is closed. label names the items, like
+// "Traces generated".
+func (c *Config) ConsumeProgress(label string, progress <-chan struct{}) {
+	ticker := time.NewTicker(c.progressInterval())
+	defer ticker.Stop()
+	c.consumeProgress(os.Stdout, label, progress, ticker.C)
+}
+
+// progressInterval returns the `interval` of the progress lines, a second by default.
+func (c *Config) progressInterval() time.Duration {
+	if c.ReportingInterval <= 0 {
+		return time.Second
+	}
+	return c.ReportingInterval
+}
+
+// consumeProgress is ConsumeProgress, printing to out a line on each tick of ticks.
+func (c *Config) consumeProgress(out io.Writer, label string, progress <-chan struct{}, ticks <-chan time.Time) {
+	interval := c.progressInterval()
+	count, reported := 0, 0
+	for {
+		select {
+		case _, ok := <-progress:
+			if !ok {
+				if c.TerminalOutput {
+					fmt.Fprintf(out, "%s (final count): %d\n", label, count)
+				}
+				return
+			}
+			count++
+		case <-ticks:
+			// the dashboard of `tui` shows the progress instead
+			if c.TerminalOutput && !c.TUI && count > reported {
+				fmt.Fprintf(out, "%s: %d (%.1f/s)\n", label, count, float64(count-reported)/interval.Seconds())
+			}
+			reported = count
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumeProgress(t *testing.T) {
+	c := &Config{TerminalOutput: true, ReportingInterval: 2 * time.Second}
+	progress := make(chan struct{})
+	ticks := make(chan time.Time)
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.consumeProgress(&out, "Traces generated", progress, ticks)
+	}()
+
+	for range 100 {
+		progress <- struct{}{}
+	}
+	ticks <- time.Time{}
+	ticks <- time.Time{}
+	progress <- struct{}{}
+	close(progress)
+	<-done
+
+	assert.Equal(t, "Traces generated: 100 (50.0/s)\nTraces generated (final count): 101\n", out.String(),
+		"a single line per interval with progress, instead of one per item")
+}
+
+func TestConsumeProgress_Quiet(t *testing.T) {
+	for _, c := range []*Config{{}, {TerminalOutput: true, TUI: true}} {
+		progress := make(chan struct{})
+		ticks := make(chan time.Time)
+		var out bytes.Buffer
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.consumeProgress(&out, "Logs generated", progress, ticks)
+		}()
+		progress <- struct{}{}
+		ticks <- time.Time{}
+		close(progress)
+		<-done
+		assert.NotContains(t, out.String(), "Logs generated: ")
+	}
+}
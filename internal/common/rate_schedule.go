@@ -0,0 +1,136 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// RateStep is a step of a `rate-schedule`: the rate of each worker from At after the
+// start of the run, 0 meaning unthrottled.
+type RateStep struct {
+	At   time.Duration
+	Rate float64
+}
+
+// LoadRateSchedule reads a `rate-schedule` file: steps like "60s:100", giving the rate
+// of each worker from that time after the start of the run, separated by commas or new
+// lines, like "0s:10, 60s:100, 120s:10". Lines starting with # are comments.
+// #nosec G304 -- path is controlled by configuration, not user input
+func LoadRateSchedule(path string) ([]RateStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the `rate-schedule`: %w", err)
+	}
+	var steps []RateStep
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			step, err := parseRateStep(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid `rate-schedule` %s: %w", path, err)
+			}
+			if len(steps) > 0 && step.At <= steps[len(steps)-1].At {
+				return nil, fmt.Errorf("invalid `rate-schedule` %s: step %q must come after %s", path, entry, steps[len(steps)-1].At)
+			}
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid `rate-schedule` %s: no steps, expected steps like 0s:10, 60s:100", path)
+	}
+	return steps, nil
+}
+
+// parseRateStep parses a step of a `rate-schedule`, like "60s:100".
+func parseRateStep(entry string) (RateStep, error) {
+	at, r, ok := strings.Cut(entry, ":")
+	if !ok {
+		return RateStep{}, fmt.Errorf("expected a step like 60s:100, got %q instead", entry)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(at))
+	if err != nil || d < 0 {
+		return RateStep{}, fmt.Errorf("expected the time of step %q to be a duration from the start, like 60s", entry)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+	if err != nil || v < 0 {
+		return RateStep{}, fmt.Errorf("expected the rate of step %q to be a number, 0 meaning unthrottled", entry)
+	}
+	return RateStep{At: d, Rate: v}, nil
+}
+
+// ValidateRateSchedule checks that the `rate-schedule` file, when set, can be loaded.
+func (c *Config) ValidateRateSchedule() error {
+	if c.RateSchedule == "" {
+		return nil
+	}
+	if _, err := LoadRateSchedule(c.RateSchedule); err != nil {
+		return NewValidationError("rate-schedule", err)
+	}
+	return nil
+}
+
+// StartRateSchedule changes the limit of the limiters of a run as its `rate-schedule`
+// says, until stop is called. The steps due at the start are applied before it returns,
+// and the configured rate holds until the first step. Without a schedule, it does nothing.
+// A rate applied by ApplyRate in the meantime, on a config reload, holds until the next
+// step, which sets its own.
+func (c *Config) StartRateSchedule(limiters []*rate.Limiter, logger *zap.Logger) (stop func(), err error) {
+	if c.RateSchedule == "" {
+		return func() {}, nil
+	}
+	steps, err := LoadRateSchedule(c.RateSchedule)
+	if err != nil {
+		return nil, err
+	}
+	apply := func(step RateStep) {
+		limit := rate.Limit(step.Rate)
+		if step.Rate == 0 {
+			limit = rate.Inf
+		}
+		for _, l := range limiters {
+			l.SetLimit(limit)
+		}
+		logger.Info("rate changed by the rate schedule", zap.Duration("at", step.At), zap.Float64("per-second", step.Rate))
+	}
+	for len(steps) > 0 && steps[0].At == 0 {
+		apply(steps[0])
+		steps = steps[1:]
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, step := range steps {
+			timer := time.NewTimer(time.Until(start.Add(step.At)))
+			select {
+			case <-timer.C:
+				apply(step)
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}, nil
+}
@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+func writeRateSchedule(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schedule.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadRateSchedule(t *testing.T) {
+	steps, err := LoadRateSchedule(writeRateSchedule(t, "# diurnal\n0s:10, 60s:100\n2m:0\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []RateStep{{0, 10}, {time.Minute, 100}, {2 * time.Minute, 0}}, steps)
+
+	for content, wantErr := range map[string]string{
+		"":              "no steps",
+		"10":            `expected a step like 60s:100, got "10" instead`,
+		"soon:10":       `expected the time of step "soon:10" to be a duration from the start, like 60s`,
+		"0s:-1":         `expected the rate of step "0s:-1" to be a number, 0 meaning unthrottled`,
+		"60s:10, 0s:20": `step "0s:20" must come after 1m0s`,
+	} {
+		_, err := LoadRateSchedule(writeRateSchedule(t, content))
+		require.ErrorContains(t, err, wantErr, "schedule %q", content)
+	}
+}
+
+func TestValidateRateSchedule(t *testing.T) {
+	require.NoError(t, (&Config{}).ValidateRateSchedule())
+	require.NoError(t, (&Config{RateSchedule: writeRateSchedule(t, "0s:10")}).ValidateRateSchedule())
+
+	err := (&Config{RateSchedule: writeRateSchedule(t, "10")}).ValidateRateSchedule()
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, "rate-schedule", verr.Field)
+}
+
+func TestStartRateSchedule(t *testing.T) {
+	limiters := []*rate.Limiter{rate.NewLimiter(1, 1), rate.NewLimiter(1, 1)}
+	c := &Config{RateSchedule: writeRateSchedule(t, "0s:5, 50ms:20, 100ms:0")}
+
+	stop, err := c.StartRateSchedule(limiters, zap.NewNop())
+	require.NoError(t, err)
+	defer stop()
+	assert.Equal(t, rate.Limit(5), limiters[0].Limit(), "the first step is applied at the start")
+
+	require.Eventually(t, func() bool { return limiters[1].Limit() == rate.Inf }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, rate.Inf, limiters[0].Limit())
+}
+
+func TestStartRateScheduleUnset(t *testing.T) {
+	stop, err := (&Config{}).StartRateSchedule(nil, zap.NewNop())
+	require.NoError(t, err)
+	stop()
+}
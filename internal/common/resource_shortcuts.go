@@ -0,0 +1,35 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+)
+
+// resourceShortcuts returns the resource attributes set with the shortcut flags, like
+// `deployment-environment`, by their semantic conventions key. Unset ones are left out.
+func (c *Config) resourceShortcuts() map[string]string {
+	shortcuts := map[string]string{
+		string(semconv.DeploymentEnvironmentKey): c.DeploymentEnvironment,
+		string(semconv.CloudProviderKey):         c.CloudProvider,
+		string(semconv.CloudRegionKey):           c.CloudRegion,
+		string(semconv.K8SNamespaceNameKey):      c.K8sNamespace,
+	}
+	for k, v := range shortcuts {
+		if v == "" {
+			delete(shortcuts, k)
+		}
+	}
+	return shortcuts
+}
+
+// mergeResourceShortcuts adds the shortcut resource attributes to the flattened resource
+// attributes m; the ones set with `otlp-attributes` take precedence.
+func (c *Config) mergeResourceShortcuts(m map[string]any) {
+	for k, v := range c.resourceShortcuts() {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// seededRandom is the source the random choices of the generators are drawn from once
+// `seed` is set. Workers draw from it concurrently, hence the lock.
+type seededRandom struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// random is the seeded source of the run, or nil to draw from the unseeded source of
+// math/rand/v2, which needs no lock.
+var random atomic.Pointer[seededRandom]
+
+// SeedRandomness makes the run reproducible with `seed`: the mock data, unless
+// `mock-seed` seeds it on its own, and every random choice of the generators, from
+// sampled values and distributions to the trace and span IDs, are drawn from sources
+// seeded with it. Without a seed, only `mock-seed` applies. Call it before the workers
+// start. It leaves the sources as is for the copies of a config seeded with
+// SeedSharedRandomness.
+func (c *Config) SeedRandomness() {
+	if c.sharedSeed {
+		return
+	}
+	if mockSeed := cmp.Or(c.MockSeed, c.Seed); mockSeed != 0 {
+		InitMockData(mockSeed)
+	}
+	if c.Seed == 0 {
+		random.Store(nil)
+		return
+	}
+	//nolint:gosec // reinterpreting the bits of the seed, a negative one included
+	random.Store(&seededRandom{r: rand.New(rand.NewPCG(uint64(c.Seed), 0))})
+}
+
+// SeedSharedRandomness seeds the sources like SeedRandomness, once for the generators
+// started concurrently with copies of c, like those of the all command: seeding them
+// again as each one starts would restart the sources the others already draw from.
+func (c *Config) SeedSharedRandomness() {
+	c.SeedRandomness()
+	c.sharedSeed = true
+}
+
+// draw returns seeded(r) with the seeded source r of `seed`, or else unseeded().
+func draw[T any](seeded func(r *rand.Rand) T, unseeded func() T) T {
+	s := random.Load()
+	if s == nil {
+		return unseeded()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return seeded(s.r)
+}
+
+// RandFloat64 returns a number in [0.0, 1.0), drawn from the source of `seed` when set.
+func RandFloat64() float64 {
+	//nolint:gosec // generating test data, no need for a cryptographic source
+	return draw((*rand.Rand).Float64, rand.Float64)
+}
+
+// RandIntN returns a number in [0, n), drawn from the source of `seed` when set. It
+// panics if n <= 0.
+func RandIntN(n int) int {
+	//nolint:gosec // generating test data, no need for a cryptographic source
+	return draw(func(r *rand.Rand) int { return r.IntN(n) }, func() int { return rand.IntN(n) })
+}
+
+// RandInt64N returns a number in [0, n), drawn from the source of `seed` when set. It
+// panics if n <= 0.
+func RandInt64N(n int64) int64 {
+	//nolint:gosec // generating test data, no need for a cryptographic source
+	return draw(func(r *rand.Rand) int64 { return r.Int64N(n) }, func() int64 { return rand.Int64N(n) })
+}
+
+// RandUint64 returns a number drawn from the source of `seed` when set.
+func RandUint64() uint64 {
+	//nolint:gosec // generating test data, no need for a cryptographic source
+	return draw((*rand.Rand).Uint64, rand.Uint64)
+}
+
+// RandTraceID returns a valid trace ID drawn from the source of `seed` when set.
+func RandTraceID() trace.TraceID {
+	var tid trace.TraceID
+	for !tid.IsValid() {
+		binary.BigEndian.PutUint64(tid[:8], RandUint64())
+		binary.BigEndian.PutUint64(tid[8:], RandUint64())
+	}
+	return tid
+}
+
+// RandSpanID returns a valid span ID drawn from the source of `seed` when set.
+func RandSpanID() trace.SpanID {
+	var sid trace.SpanID
+	for !sid.IsValid() {
+		binary.BigEndian.PutUint64(sid[:], RandUint64())
+	}
+	return sid
+}
@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedRandomness(t *testing.T) {
+	defer (&Config{}).SeedRandomness()
+	draws := func() []any {
+		user, err := ProcessMockTemplate("{{UUID}}", nil)
+		require.NoError(t, err)
+		return []any{RandFloat64(), RandIntN(1000), RandInt64N(1000), RandUint64(), RandTraceID(), RandSpanID(), user}
+	}
+
+	cfg := &Config{Seed: 42}
+	cfg.SeedRandomness()
+	first := draws()
+	cfg.SeedRandomness()
+	assert.Equal(t, first, draws(), "the same seed draws the same values")
+
+	cfg.Seed = 7
+	cfg.SeedRandomness()
+	assert.NotEqual(t, first, draws())
+
+	cfg.Seed = 0
+	cfg.SeedRandomness()
+	assert.Nil(t, random.Load(), "without a seed the values are drawn from the unseeded source")
+	assert.True(t, RandTraceID().IsValid())
+	assert.True(t, RandSpanID().IsValid())
+}
+
+func TestSeedSharedRandomness(t *testing.T) {
+	defer (&Config{}).SeedRandomness()
+
+	(&Config{Seed: 42}).SeedRandomness()
+	want := []uint64{RandUint64(), RandUint64()}
+
+	shared := &Config{Seed: 42}
+	shared.SeedSharedRandomness()
+	first := RandUint64()
+	signal := *shared
+	signal.SeedRandomness()
+	assert.Equal(t, want, []uint64{first, RandUint64()}, "the copies draw on from the shared sources")
+}
+
+func TestSeedRandomness_MockSeed(t *testing.T) {
+	defer (&Config{}).SeedRandomness()
+	user := func() string {
+		v, err := ProcessMockTemplate("{{UUID}}", nil)
+		require.NoError(t, err)
+		return v
+	}
+
+	(&Config{MockSeed: 42}).SeedRandomness()
+	first := user()
+	(&Config{MockSeed: 42, Seed: 7}).SeedRandomness()
+	assert.Equal(t, first, user(), "`mock-seed` seeds the mock data on its own")
+	assert.NotNil(t, random.Load())
+}
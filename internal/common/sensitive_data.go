@@ -0,0 +1,58 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"slices"
+	"strings"
+)
+
+// sensitiveDataMarker is the attribute listing the sensitive keys of an attribute set.
+// The keys of each level are also listed by the marker suffixed with the level, like
+// trazr.sensitive.data.pii.
+const sensitiveDataMarker = "trazr.sensitive.data"
+
+// SensitivityLevels are the levels a `sensitive-data` entry may end with.
+var SensitivityLevels = []string{"pii", "phi", "pci", "secret", "confidential"}
+
+// SensitiveKey splits a `sensitive-data` entry into its key and its level, the latter
+// being empty for an entry without one: "patient.ssn:pii" is the key patient.ssn of level
+// pii. Only one of SensitivityLevels following the last ':' is a level, so that keys may
+// contain colons, like "http:header" or "a:b:pii".
+func SensitiveKey(entry string) (key, level string) {
+	key = entry
+	if i := strings.LastIndex(entry, ":"); i >= 0 {
+		if suffix := strings.ToLower(strings.TrimSpace(entry[i+1:])); slices.Contains(SensitivityLevels, suffix) {
+			key, level = entry[:i], suffix
+		}
+	}
+	return strings.TrimSpace(key), level
+}
+
+// ValidateSensitiveData checks the `sensitive-data` entries: keys, optionally followed by
+// one of SensitivityLevels.
+func ValidateSensitiveData(entries []string) error {
+	for _, entry := range entries {
+		key, _ := SensitiveKey(entry)
+		if key == "" {
+			return ValidationErrorf("sensitive-data", "invalid `sensitive-data` entry %q: expected a key, optionally followed by :LEVEL", entry)
+		}
+		if strings.HasSuffix(key, ":") {
+			return ValidationErrorf("sensitive-data", "invalid `sensitive-data` level of %q: expected one of %s", entry, strings.Join(SensitivityLevels, ", "))
+		}
+	}
+	return nil
+}
+
+// normalizeSensitiveData returns a new list of the `sensitive-data` entries with their
+// keys converted, keeping their levels.
+func normalizeSensitiveData(entries []string, convert func(string) string) []string {
+	return normalizeKeyList(entries, func(entry string) string {
+		key, level := SensitiveKey(entry)
+		if level == "" {
+			return convert(key)
+		}
+		return convert(key) + ":" + level
+	})
+}
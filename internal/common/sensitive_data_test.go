@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSensitiveKey(t *testing.T) {
+	key, level := SensitiveKey("patient.ssn")
+	assert.Equal(t, "patient.ssn", key)
+	assert.Empty(t, level)
+
+	key, level = SensitiveKey(" api.key : Secret ")
+	assert.Equal(t, "api.key", key)
+	assert.Equal(t, "secret", level)
+
+	key, level = SensitiveKey("urn:patient:ssn:pii")
+	assert.Equal(t, "urn:patient:ssn", key, "the level follows the last colon")
+	assert.Equal(t, "pii", level)
+
+	key, level = SensitiveKey("http:header")
+	assert.Equal(t, "http:header", key, "a suffix that is no level is part of the key")
+	assert.Empty(t, level)
+}
+
+func TestValidateSensitiveData(t *testing.T) {
+	require.NoError(t, ValidateSensitiveData([]string{"patient.ssn", "patient.dob:pii", "api.key:secret", "a:b:pii", "http:header"}))
+
+	err := ValidateSensitiveData([]string{":pii"})
+	require.EqualError(t, err, "invalid `sensitive-data` entry \":pii\": expected a key, optionally followed by :LEVEL")
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "sensitive-data", validationErr.Field)
+
+	require.EqualError(t, ValidateSensitiveData([]string{"api.key:"}), "invalid `sensitive-data` level of \"api.key:\": expected one of pii, phi, pci, secret, confidential")
+}
+
+func TestInjectSensitiveDataMarker_Levels(t *testing.T) {
+	attrs := map[string]any{"patient.ssn": "1", "patient.dob": "2", "api.key": "3", "env": "prod"}
+	entries := []string{"patient.ssn:pii", "patient.dob:PII", "api.key:secret", "env", "missing:pii"}
+	InjectSensitiveDataMarker(attrs, entries)
+	InjectSensitiveDataMarker(attrs, entries)
+
+	assert.Equal(t, "patient.ssn,patient.dob,api.key,env", attrs["trazr.sensitive.data"])
+	assert.Equal(t, "patient.ssn,patient.dob", attrs["trazr.sensitive.data.pii"])
+	assert.Equal(t, "api.key", attrs["trazr.sensitive.data.secret"])
+	assert.Len(t, attrs, 7, "injecting the markers again changes nothing")
+}
+
+func TestConfig_InitAttributes_SensitiveLevels(t *testing.T) {
+	cfg := &Config{
+		TelemetryAttributes: KeyValue{"Patient.SSN": "1"},
+		SensitiveData:       []string{"Patient.SSN:pii"},
+		AttributesCase:      "lower",
+	}
+	require.NoError(t, cfg.InitAttributes())
+	assert.Equal(t, []string{"patient.ssn:pii"}, cfg.SensitiveData, "the keys are normalized, not the levels")
+	assert.Equal(t, "patient.ssn", cfg.TelemetryAttributes["trazr.sensitive.data"])
+	assert.Equal(t, "patient.ssn", cfg.TelemetryAttributes["trazr.sensitive.data.pii"])
+}
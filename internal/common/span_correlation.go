@@ -0,0 +1,71 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// correlatedSpans is the number of the most recent span contexts the correlated signals
+// take theirs from.
+const correlatedSpans = 1024
+
+// spanContexts are the span contexts of the spans recently generated in this process,
+// shared by the traces generator with the generators of the correlated signals while
+// correlation is enabled, nil otherwise.
+var spanContexts atomic.Pointer[spanContextRing]
+
+// spanContextRing keeps the last correlatedSpans span contexts published.
+type spanContextRing struct {
+	sync.Mutex
+	signals []string // correlated signals: "logs" for the log records, "metrics" for the exemplars
+	recent  []trace.SpanContext
+	next    int // index in recent of the next span context published once it is full
+}
+
+// EnableCorrelation makes the items of signals generated in this process carry the trace
+// and span IDs of the spans generated alongside them until disable is called: the log
+// records for "logs", as with `correlate-logs` of the all command, and the exemplars of
+// the data points for "metrics", as with `correlate-exemplars`.
+func EnableCorrelation(signals ...string) (disable func()) {
+	spanContexts.Store(&spanContextRing{signals: signals, recent: make([]trace.SpanContext, 0, correlatedSpans)})
+	return func() { spanContexts.Store(nil) }
+}
+
+// PublishSpanContext shares the span context of a span just generated with the
+// generators of the correlated signals when correlation is enabled.
+func PublishSpanContext(sc trace.SpanContext) {
+	r := spanContexts.Load()
+	if r == nil || !sc.IsValid() {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	if len(r.recent) < correlatedSpans {
+		r.recent = append(r.recent, sc)
+		return
+	}
+	r.recent[r.next] = sc
+	r.next = (r.next + 1) % correlatedSpans
+}
+
+// CorrelatedSpanContext returns one of the span contexts published recently, at random,
+// for an item of signal, or false when signal isn't correlated or no span has been
+// generated yet.
+func CorrelatedSpanContext(signal string) (trace.SpanContext, bool) {
+	r := spanContexts.Load()
+	if r == nil || !slices.Contains(r.signals, signal) {
+		return trace.SpanContext{}, false
+	}
+	r.Lock()
+	defer r.Unlock()
+	if len(r.recent) == 0 {
+		return trace.SpanContext{}, false
+	}
+	return r.recent[RandIntN(len(r.recent))], true
+}
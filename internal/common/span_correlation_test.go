@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanContextOf(n byte) trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{n, 1},
+		SpanID:  trace.SpanID{n, 2},
+	})
+}
+
+func TestSpanCorrelation(t *testing.T) {
+	PublishSpanContext(spanContextOf(1))
+	_, ok := CorrelatedSpanContext("logs")
+	assert.False(t, ok, "nothing is shared while correlation is disabled")
+
+	disable := EnableCorrelation("logs")
+	_, ok = CorrelatedSpanContext("logs")
+	assert.False(t, ok, "no span has been published yet")
+
+	PublishSpanContext(trace.SpanContext{})
+	_, ok = CorrelatedSpanContext("logs")
+	assert.False(t, ok, "invalid span contexts are not shared")
+
+	PublishSpanContext(spanContextOf(1))
+	sc, ok := CorrelatedSpanContext("logs")
+	require.True(t, ok)
+	assert.Equal(t, spanContextOf(1), sc)
+
+	// the oldest span contexts make room for the new ones
+	for i := 0; i < correlatedSpans; i++ {
+		PublishSpanContext(spanContextOf(2))
+	}
+	for i := 0; i < 10; i++ {
+		sc, _ = CorrelatedSpanContext("logs")
+		assert.Equal(t, spanContextOf(2), sc)
+	}
+
+	_, ok = CorrelatedSpanContext("metrics")
+	assert.False(t, ok, "only the enabled signals are correlated")
+
+	disable()
+	_, ok = CorrelatedSpanContext("logs")
+	assert.False(t, ok)
+}
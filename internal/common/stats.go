@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import "time"
+
+// RunStats summarizes a generator run. Each signal's run fills it in and Start returns
+// the total across repetitions.
+type RunStats struct {
+	Generated    int64         // number of traces, metrics or logs generated
+	Errors       int64         // number of failed exports
+	Dropped      int64         // spans, metrics or log records lost in failed exports
+	Delivered    int64         // spans, metrics or log records sent by successful exports and accepted
+	Rejected     int64         // spans, metrics or log records rejected by partial success responses
+	Skipped      int64         // spans, metrics or log records generated but not exported, for `drop-ratio`
+	Duration     time.Duration // how long generation took
+	AchievedRate float64       // generated items per second
+}
+
+// NewRunStats returns the stats of a run that generated the given number of items in duration.
+func NewRunStats(generated int64, duration time.Duration) RunStats {
+	s := RunStats{Generated: generated, Duration: duration}
+	s.updateRate()
+	return s
+}
+
+// Add accumulates other into s, e.g. across repeated runs.
+func (s *RunStats) Add(other RunStats) {
+	s.Generated += other.Generated
+	s.Errors += other.Errors
+	s.Dropped += other.Dropped
+	s.Delivered += other.Delivered
+	s.Rejected += other.Rejected
+	s.Skipped += other.Skipped
+	s.Duration += other.Duration
+	s.updateRate()
+}
+
+// AddExportErrors adds the failed exports, dropped, delivered, rejected and skipped items counted by e.
+func (s *RunStats) AddExportErrors(e *ExportErrors) {
+	s.Errors += e.Total()
+	s.Dropped += e.Dropped()
+	s.Delivered += e.Delivered()
+	s.Rejected += e.Rejected()
+	s.Skipped += e.Skipped()
+}
+
+func (s *RunStats) updateRate() {
+	s.AchievedRate = 0
+	if s.Duration > 0 {
+		s.AchievedRate = float64(s.Generated) / s.Duration.Seconds()
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRunStats(t *testing.T) {
+	s := NewRunStats(10, 2*time.Second)
+	assert.Equal(t, int64(10), s.Generated)
+	assert.Equal(t, 2*time.Second, s.Duration)
+	assert.InDelta(t, 5.0, s.AchievedRate, 1e-9)
+
+	assert.Zero(t, NewRunStats(10, 0).AchievedRate)
+}
+
+func TestRunStatsAdd(t *testing.T) {
+	var s RunStats
+	s.Add(NewRunStats(10, time.Second))
+	s.Add(RunStats{Generated: 20, Errors: 1, Dropped: 4, Duration: time.Second})
+
+	assert.Equal(t, RunStats{
+		Generated:    30,
+		Errors:       1,
+		Dropped:      4,
+		Duration:     2 * time.Second,
+		AchievedRate: 15,
+	}, s)
+}
+
+func TestRunStatsAddExportErrors(t *testing.T) {
+	e := NewExportErrors()
+	e.Add(context.DeadlineExceeded)
+	e.Add(errors.New("boom"))
+	e.Drop(7)
+
+	s := NewRunStats(10, time.Second)
+	s.AddExportErrors(e)
+	assert.Equal(t, int64(2), s.Errors)
+	assert.Equal(t, int64(7), s.Dropped)
+}
@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// streamingTransport sends the body of the export requests gzip-compressed as it is
+// written to the connection, with chunked transfer encoding, for `otlp-http-stream`. The
+// exporters marshal each export in memory, but no compressed copy of it is ever held,
+// unlike with a buffered body of known length.
+type streamingTransport struct {
+	base http.RoundTripper
+}
+
+func (t streamingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return t.base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Body = gzipStream(req.Body)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return gzipStream(body), nil
+		}
+	}
+	req.ContentLength = -1
+	req.Header.Set("Content-Encoding", "gzip")
+	return t.base.RoundTrip(req)
+}
+
+// gzipStream returns body gzip-compressed as it is read. The compression stops when the
+// returned reader is closed, which the transport always does.
+func gzipStream(body io.ReadCloser) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		defer body.Close()
+		gz := gzip.NewWriter(w)
+		_, err := io.Copy(gz, body)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		w.CloseWithError(err)
+	}()
+	return r
+}
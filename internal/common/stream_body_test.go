@@ -0,0 +1,54 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportHTTPClient_StreamHTTPBody(t *testing.T) {
+	var encoding string
+	var chunked bool
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+		if encoding != "gzip" {
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		body, err = io.ReadAll(gz)
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	assert.Nil(t, (&Config{}).ExportHTTPClient("traces", nil))
+	client := (&Config{StreamHTTPBody: true}).ExportHTTPClient("traces", nil)
+	require.NotNil(t, client)
+
+	payload := strings.Repeat("large payload ", 100_000)
+	resp, err := client.Post(server.URL, "application/x-protobuf", strings.NewReader(payload))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "gzip", encoding)
+	assert.True(t, chunked, "the body is streamed without a Content-Length")
+	assert.Equal(t, payload, string(body))
+
+	resp, err = client.Post(server.URL, "application/x-protobuf", http.NoBody)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, encoding, "empty bodies are sent as they are")
+}
@@ -6,7 +6,6 @@ package common
 import (
 	"crypto/tls"
 	"crypto/x509"
-	"errors"
 	"os"
 
 	"google.golang.org/grpc/credentials"
@@ -20,15 +19,34 @@ func caPool(caFile string) (*x509.CertPool, error) {
 	if caFile != "" {
 		data, err := os.ReadFile(caFile)
 		if err != nil {
-			return nil, err
+			return nil, NewValidationError("ca-cert", err)
 		}
 		if !pool.AppendCertsFromPEM(data) {
-			return nil, errors.New("failed to add CA certificate to root CA pool")
+			return nil, ValidationErrorf("ca-cert", "failed to add CA certificate to root CA pool")
 		}
 	}
 	return pool, nil
 }
 
+// clientKeyPair loads the client certificate and key of mTLS, reporting the option of
+// the file that can't be read.
+// #nosec G304 -- the files are controlled by configuration, not user input
+func clientKeyPair(cAuth ClientAuth) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(cAuth.ClientCertFile)
+	if err != nil {
+		return tls.Certificate{}, NewValidationError("client-auth.client-cert", err)
+	}
+	keyPEM, err := os.ReadFile(cAuth.ClientKeyFile)
+	if err != nil {
+		return tls.Certificate{}, NewValidationError("client-auth.client-key", err)
+	}
+	keypair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, NewValidationError("client-auth", err)
+	}
+	return keypair, nil
+}
+
 func GetTLSCredentialsForGRPCExporter(
 	caFile string,
 	cAuth ClientAuth,
@@ -64,7 +82,7 @@ func getTLSConfig(caFile string, cAuth ClientAuth, insecureSkipVerify bool) (*tl
 
 	// Configuration for mTLS
 	if cAuth.Enabled {
-		keypair, err := tls.LoadX509KeyPair(cAuth.ClientCertFile, cAuth.ClientKeyFile)
+		keypair, err := clientKeyPair(cAuth)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -45,3 +46,28 @@ func TestGetTLSConfig_mTLS(t *testing.T) {
 		t.Error("expected error for invalid cert/key, got nil")
 	}
 }
+
+func TestGetTLSConfig_ValidationError(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		caFile string
+		cAuth  ClientAuth
+		field  string
+	}{
+		{name: "ca", caFile: "/nonexistent/ca.pem", field: "ca-cert"},
+		{name: "cert", cAuth: ClientAuth{Enabled: true, ClientCertFile: "/nonexistent/cert.pem"}, field: "client-auth.client-cert"},
+		{name: "key", cAuth: ClientAuth{Enabled: true, ClientCertFile: writeTempFile(t, "cert"), ClientKeyFile: "/nonexistent/key.pem"}, field: "client-auth.client-key"},
+		{name: "pair", cAuth: ClientAuth{Enabled: true, ClientCertFile: writeTempFile(t, "cert"), ClientKeyFile: writeTempFile(t, "key")}, field: "client-auth"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := getTLSConfig(tt.caFile, tt.cAuth, false)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a *ValidationError, got %v", err)
+			}
+			if validationErr.Field != tt.field {
+				t.Errorf("expected the field %q, got %q", tt.field, validationErr.Field)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// byteUnits are the units of `total-bytes`, longest first so that "MB" isn't read as "B".
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// ParseByteSize parses a volume like "1GB", "512MiB" or "1000", which is in bytes.
+// Decimal units are powers of 1000 and binary ones, like MiB, powers of 1024.
+func ParseByteSize(s string) (int64, error) {
+	number, unit := strings.TrimSpace(s), int64(1)
+	for _, u := range byteUnits {
+		if n, ok := strings.CutSuffix(number, u.suffix); ok {
+			number, unit = strings.TrimSpace(n), u.size
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("expected a positive volume, like 500MB or 1GiB, got %q instead", s)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// TotalBytesLimit returns the volume set with `total-bytes`, or 0 when it isn't set.
+func (c *Config) TotalBytesLimit() (int64, error) {
+	if c.TotalBytes == "" {
+		return 0, nil
+	}
+	limit, err := ParseByteSize(c.TotalBytes)
+	if err != nil {
+		return 0, ValidationErrorf("total-bytes", "invalid `total-bytes`: %w", err)
+	}
+	return limit, nil
+}
+
+// ExportVolume counts the bytes of the export requests of a run, whose workers are
+// stopped once they reach `total-bytes`. A nil *ExportVolume counts nothing.
+type ExportVolume struct {
+	limit int64
+
+	mu      sync.Mutex
+	total   int64
+	reached chan struct{}
+}
+
+func newExportVolume(limit int64) *ExportVolume {
+	return &ExportVolume{limit: limit, reached: make(chan struct{})}
+}
+
+// Add counts an export request of n bytes.
+func (v *ExportVolume) Add(n int64) {
+	if v == nil || n <= 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.total += n
+	if v.total >= v.limit {
+		select {
+		case <-v.reached:
+		default:
+			close(v.reached)
+		}
+	}
+}
+
+// Total returns the bytes counted since the start of the run.
+func (v *ExportVolume) Total() int64 {
+	if v == nil {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.total
+}
+
+// Reached returns a channel closed once the run has exported its `total-bytes`. It is
+// nil, and so never ready, when v is nil.
+func (v *ExportVolume) Reached() <-chan struct{} {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.reached
+}
+
+// reset starts counting for a new run.
+func (v *ExportVolume) reset() {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.total = 0
+	v.reached = make(chan struct{})
+}
+
+// ExportVolume returns the counter of the exported bytes when `total-bytes` is set, or
+// else nil. It is shared by the exporters and the runs of the configuration, and
+// created by the first of them, before any worker starts.
+func (c *Config) ExportVolume() *ExportVolume {
+	limit, err := c.TotalBytesLimit()
+	if err != nil || limit == 0 {
+		return nil
+	}
+	if c.exportVolume == nil {
+		c.exportVolume = newExportVolume(limit)
+	}
+	return c.exportVolume
+}
+
+// ExportDialOptions returns the options of the gRPC connection of the OTLP gRPC exporters,
+// to pass in a single WithDialOption since each call replaces the options of the previous
+// one: `grpc-authority`, and with `grpc-metadata`, an interceptor adding it to the export
+// requests, with `null-attributes`, one emptying their null attribute values, and with
+// `total-bytes`, one counting their size.
+func (c *Config) ExportDialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if c.GRPCAuthority != "" {
+		opts = append(opts, grpc.WithAuthority(c.GRPCAuthority))
+	}
+	var interceptors []grpc.UnaryClientInterceptor
+	if len(c.GRPCMetadata) > 0 {
+		interceptors = append(interceptors, metadataInterceptor(metadataPairs(c.GRPCMetadata)))
+	}
+	if c.NullAttributes {
+		// before volumeInterceptor, which counts the requests as sent
+		interceptors = append(interceptors, nullAttributesInterceptor)
+	}
+	if volume := c.ExportVolume(); volume != nil {
+		interceptors = append(interceptors, volumeInterceptor(volume))
+	}
+	if len(interceptors) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(interceptors...))
+	}
+	return opts
+}
+
+// volumeInterceptor counts the protobuf size of the requests it sends into volume.
+func volumeInterceptor(volume *ExportVolume) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if msg, ok := req.(proto.Message); ok {
+			volume.Add(int64(proto.Size(msg)))
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// volumeTransport counts the body size of the requests it sends into volume, as the
+// body is read, since streamed bodies have no length known in advance.
+type volumeTransport struct {
+	base   http.RoundTripper
+	volume *ExportVolume
+}
+
+func (t volumeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return t.base.RoundTrip(req)
+	}
+	// a RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Body = &countingBody{ReadCloser: req.Body, volume: t.volume}
+	return t.base.RoundTrip(req)
+}
+
+// countingBody counts the bytes read from a request body into volume.
+type countingBody struct {
+	io.ReadCloser
+	volume *ExportVolume
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.volume.Add(int64(n))
+	return n, err
+}
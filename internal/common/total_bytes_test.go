@@ -0,0 +1,143 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1000", want: 1000},
+		{in: "512B", want: 512},
+		{in: "1GB", want: 1e9},
+		{in: "1.5 MB", want: 1.5e6},
+		{in: "2KiB", want: 2048},
+		{in: "1GiB", want: 1 << 30},
+		{in: "0", wantErr: true},
+		{in: "-1MB", wantErr: true},
+		{in: "lots", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseByteSize(tt.in)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExportVolume(t *testing.T) {
+	assert.Nil(t, (&Config{}).ExportVolume(), "no volume without `total-bytes`")
+
+	c := &Config{TotalBytes: "100B"}
+	volume := c.ExportVolume()
+	require.NotNil(t, volume)
+	assert.Same(t, volume, c.ExportVolume())
+
+	volume.Add(60)
+	select {
+	case <-volume.Reached():
+		t.Fatal("the volume should not be reached yet")
+	default:
+	}
+	volume.Add(60)
+	volume.Add(10)
+	<-volume.Reached()
+	assert.Equal(t, int64(130), volume.Total())
+
+	volume.reset()
+	assert.Zero(t, volume.Total())
+	select {
+	case <-volume.Reached():
+		t.Fatal("a new run should start counting from zero")
+	default:
+	}
+}
+
+func TestExportHTTPClientCountsVolume(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	c := &Config{TotalBytes: "1KB"}
+	client := c.ExportHTTPClient("traces", nil)
+	require.NotNil(t, client)
+	resp, err := client.Post(srv.URL, "application/x-protobuf", strings.NewReader(strings.Repeat("x", 300)))
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, int64(300), c.ExportVolume().Total())
+}
+
+func TestExportHTTPClientCountsStreamedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer srv.Close()
+
+	c := &Config{TotalBytes: "1KB"}
+	client := c.ExportHTTPClient("traces", nil)
+	require.NotNil(t, client)
+	// a reader of unknown length, sent chunked like the streamed bodies
+	body := io.MultiReader(strings.NewReader(strings.Repeat("x", 200)), strings.NewReader(strings.Repeat("y", 100)))
+	req, err := http.NewRequest(http.MethodPost, srv.URL, body)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, int64(300), c.ExportVolume().Total())
+}
+
+func TestExportDialOptionsAuthority(t *testing.T) {
+	c := &Config{GRPCAuthority: "collector.internal", TotalBytes: "1KB"}
+	assert.Len(t, c.ExportDialOptions(), 2, "the authority and the interceptors, to pass in a single WithDialOption")
+}
+
+func TestVolumeInterceptor(t *testing.T) {
+	c := &Config{TotalBytes: "1KB"}
+	require.Len(t, c.ExportDialOptions(), 1)
+
+	req := wrapperspb.String(strings.Repeat("x", 100))
+	invoked := false
+	err := volumeInterceptor(c.ExportVolume())(context.Background(), "/export", req, nil, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			invoked = true
+			return nil
+		})
+	require.NoError(t, err)
+	assert.True(t, invoked)
+	assert.Equal(t, int64(102), c.ExportVolume().Total(), "the protobuf size of the request")
+}
+
+func TestWaitWorkersTotalBytes(t *testing.T) {
+	c := &Config{TotalBytes: "10B"}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	stopped := make(chan struct{})
+	go c.ExportVolume().Add(10)
+
+	require.NoError(t, c.WaitWorkers(&wg, func() { close(stopped); wg.Done() }, nil, zap.NewNop()))
+	<-stopped
+}
@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// traceresponseTransport checks the W3C traceresponse header of the successful responses
+// to the export requests, failing the export when it is missing or invalid, or when it
+// names another trace than the traceparent of the request.
+type traceresponseTransport struct {
+	base http.RoundTripper
+}
+
+func (t traceresponseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	if err := checkTraceresponse(resp.Header.Get("traceresponse"), req.Header.Get("traceparent")); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// checkTraceresponse checks that traceresponse is a W3C traceresponse, and that its trace
+// ID is the one of traceparent when the request carried one.
+func checkTraceresponse(traceresponse, traceparent string) error {
+	if traceresponse == "" {
+		return errors.New("`expect-traceresponse`: the response has no traceresponse header")
+	}
+	traceID, err := parseTraceresponse(traceresponse)
+	if err != nil {
+		return fmt.Errorf("`expect-traceresponse`: invalid traceresponse %q: %w", traceresponse, err)
+	}
+	if parts := strings.Split(traceparent, "-"); len(parts) == 4 && parts[1] != traceID {
+		return fmt.Errorf("`expect-traceresponse`: traceresponse %q is of another trace than the traceparent %q of the request", traceresponse, traceparent)
+	}
+	return nil
+}
+
+// parseTraceresponse parses a traceresponse header of the form
+// version-traceid-childid-flags and returns its trace ID.
+func parseTraceresponse(value string) (string, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return "", errors.New("expected version-traceid-childid-flags, like '00-<32 hex digits>-<16 hex digits>-01'")
+	}
+	version, traceID, childID, flags := parts[0], parts[1], parts[2], parts[3]
+	// later versions may append fields, which version 00 forbids
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", fmt.Errorf("unsupported version %q", version)
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return "", fmt.Errorf("expected a trace ID of 32 hex digits that aren't all zero, got %q instead", traceID)
+	}
+	if !isLowerHex(childID, 16) || childID == strings.Repeat("0", 16) {
+		return "", fmt.Errorf("expected a child ID of 16 hex digits that aren't all zero, got %q instead", childID)
+	}
+	if !isLowerHex(flags, 2) {
+		return "", fmt.Errorf("expected flags of 2 hex digits, got %q instead", flags)
+	}
+	return traceID, nil
+}
+
+// isLowerHex reports whether s is n lower-case hex digits, as the W3C trace context
+// headers require.
+func isLowerHex(s string, n int) bool {
+	if len(s) != n || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
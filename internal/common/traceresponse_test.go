@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTraceresponse(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name          string
+		traceresponse string
+		traceparent   string
+		wantErr       string
+	}{
+		{name: "Same trace", traceresponse: "00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01", traceparent: traceparent},
+		{name: "No traceparent", traceresponse: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"},
+		{name: "Later version", traceresponse: "01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00-extra"},
+		{name: "Missing", wantErr: "the response has no traceresponse header"},
+		{
+			name:          "Other trace",
+			traceresponse: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			traceparent:   traceparent,
+			wantErr:       "is of another trace than the traceparent",
+		},
+		{name: "Too few fields", traceresponse: "00-0af7651916cd43dd8448eb211c80319c-01", wantErr: "expected version-traceid-childid-flags"},
+		{name: "Extra field", traceresponse: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", wantErr: "unsupported version \"00\""},
+		{name: "Invalid version", traceresponse: "ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", wantErr: "unsupported version \"ff\""},
+		{name: "Zero trace ID", traceresponse: "00-00000000000000000000000000000000-b7ad6b7169203331-01", wantErr: "expected a trace ID"},
+		{name: "Upper-case child ID", traceresponse: "00-0af7651916cd43dd8448eb211c80319c-B7AD6B7169203331-01", wantErr: "expected a child ID"},
+		{name: "Invalid flags", traceresponse: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-1", wantErr: "expected flags"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTraceresponse(tt.traceresponse, tt.traceparent)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestExportHTTPClient_ExpectTraceresponse(t *testing.T) {
+	var traceresponse string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if traceresponse == "echo" {
+			w.Header().Set("traceresponse", r.Header.Get("traceparent"))
+		} else if traceresponse != "" {
+			w.Header().Set("traceresponse", traceresponse)
+		}
+	}))
+	defer server.Close()
+
+	c := &Config{ExportTraceparent: "random", ExpectTraceresponse: true}
+	client := c.ExportHTTPClient("traces", nil)
+	send := func() error {
+		resp, err := client.Post(server.URL, "application/x-protobuf", http.NoBody)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	traceresponse = "echo"
+	require.NoError(t, send(), "the traceresponse checks the traceparent stamped on the request")
+
+	traceresponse = ""
+	err := send()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the response has no traceresponse header")
+
+	traceresponse = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	err = send()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is of another trace than the traceparent")
+}
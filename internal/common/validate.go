@@ -6,6 +6,13 @@ package common
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 var (
@@ -15,6 +22,14 @@ var (
 	errInvalidSpanID        = errors.New("failed to create SpanID byte array from the given SpanID, make sure the SpanID is a hex representation of a [8]byte, like: '5828fa4960140870'")
 )
 
+// hostnameLabel matches a single RFC 1123 hostname label, along with the underscores of
+// the names of Docker Compose services and containers, like otel_collector, which
+// resolve.
+var hostnameLabel = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?$`)
+
+// schemaVersion matches the semantic version ending a telemetry schema URL.
+var schemaVersion = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
 func ValidateTraceID(traceID string) error {
 	if len(traceID) != 32 {
 		return errInvalidTraceIDLength
@@ -39,3 +54,69 @@ func ValidateSpanID(spanID string) error {
 
 	return nil
 }
+
+// ValidateEndpoint checks that an OTLP endpoint is a host:port pair, where the host is an
+// IPv4 address, a bracketed IPv6 address like [::1], or a valid hostname.
+func ValidateEndpoint(endpoint string) error {
+	if strings.Contains(endpoint, "://") {
+		return fmt.Errorf("invalid endpoint %q: expected host:port without a scheme, like 'localhost:4317'", endpoint)
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		if strings.Count(endpoint, ":") > 1 && !strings.HasPrefix(endpoint, "[") {
+			return fmt.Errorf("invalid endpoint %q: IPv6 addresses must be in brackets, like '[::1]:4317'", endpoint)
+		}
+		return fmt.Errorf("invalid endpoint %q: expected host:port, like 'localhost:4317': %w", endpoint, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid endpoint %q: port must be a number between 1 and 65535", endpoint)
+	}
+	if host == "" {
+		return fmt.Errorf("invalid endpoint %q: missing host", endpoint)
+	}
+	if strings.HasPrefix(endpoint, "[") {
+		if net.ParseIP(host) == nil || !strings.Contains(host, ":") {
+			return fmt.Errorf("invalid endpoint %q: %q is not an IPv6 address", endpoint, host)
+		}
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("invalid endpoint %q: hostname is longer than 253 characters", endpoint)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if !hostnameLabel.MatchString(label) {
+			return fmt.Errorf("invalid endpoint %q: %q is not a valid hostname", endpoint, host)
+		}
+	}
+	return nil
+}
+
+// ResourceSchemaURL returns the schema URL of the resource of a signal: schemaURL, its
+// `{signal}-schema-url`, or else semconvURL, the one of the semantic conventions its
+// resource attributes follow.
+func ResourceSchemaURL(schemaURL, semconvURL string) string {
+	if schemaURL != "" {
+		return schemaURL
+	}
+	return semconvURL
+}
+
+// ValidateSchemaURL checks that the schema URL of an option is empty or, as telemetry
+// schemas require, an http(s) URL whose last path segment is the schema version, like
+// 'https://opentelemetry.io/schemas/1.25.0'.
+func ValidateSchemaURL(option, schemaURL string) error {
+	if schemaURL == "" {
+		return nil
+	}
+	u, err := url.Parse(schemaURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ValidationErrorf(option, "invalid `%s` %q: expected an http(s) URL, like 'https://opentelemetry.io/schemas/1.25.0'", option, schemaURL)
+	}
+	if version := path.Base(u.Path); !schemaVersion.MatchString(version) {
+		return ValidationErrorf(option, "invalid `%s` %q: the URL must end with the schema version, like '/schemas/1.25.0'", option, schemaURL)
+	}
+	return nil
+}
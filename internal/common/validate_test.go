@@ -68,3 +68,85 @@ func TestValidateSpanID(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		errMsg   string
+	}{
+		{endpoint: "localhost:4317"},
+		{endpoint: "otel-collector.monitoring.svc:4318"},
+		{endpoint: "127.0.0.1:4317"},
+		{endpoint: "[::1]:4317"},
+		{endpoint: "[2001:db8::1]:4318"},
+		{endpoint: "http://localhost:4318", errMsg: "without a scheme"},
+		{endpoint: "::1:4317", errMsg: "must be in brackets"},
+		{endpoint: "localhost", errMsg: "expected host:port"},
+		{endpoint: "localhost:otlp", errMsg: "port must be a number"},
+		{endpoint: "localhost:70000", errMsg: "port must be a number"},
+		{endpoint: ":4317", errMsg: "missing host"},
+		{endpoint: "[localhost]:4317", errMsg: "is not an IPv6 address"},
+		{endpoint: "otel_collector:4317"},
+		{endpoint: "-collector:4317", errMsg: "is not a valid hostname"},
+		{endpoint: "collector..svc:4317", errMsg: "is not a valid hostname"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			err := ValidateEndpoint(tt.endpoint)
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestResourceSchemaURL(t *testing.T) {
+	assert.Equal(t, "https://example.com/schemas/1.0.0", ResourceSchemaURL("https://example.com/schemas/1.0.0", "https://opentelemetry.io/schemas/1.25.0"))
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.25.0", ResourceSchemaURL("", "https://opentelemetry.io/schemas/1.25.0"))
+}
+
+func TestValidateSchemaURL(t *testing.T) {
+	tests := []struct {
+		schemaURL string
+		errMsg    string
+	}{
+		{schemaURL: ""},
+		{schemaURL: "https://opentelemetry.io/schemas/1.25.0"},
+		{schemaURL: "http://schemas.example.com:8080/telemetry/2.0.0-rc.1"},
+		{schemaURL: "opentelemetry.io/schemas/1.25.0", errMsg: "expected an http(s) URL"},
+		{schemaURL: "ftp://opentelemetry.io/schemas/1.25.0", errMsg: "expected an http(s) URL"},
+		{schemaURL: "https://opentelemetry.io/schemas/latest", errMsg: "must end with the schema version"},
+		{schemaURL: "https://opentelemetry.io/schemas/1.25", errMsg: "must end with the schema version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schemaURL, func(t *testing.T) {
+			err := ValidateSchemaURL("traces-schema-url", tt.schemaURL)
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errMsg)
+			assert.ErrorContains(t, err, "`traces-schema-url`")
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := NewValidationError("trace-id", errInvalidTraceID)
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "trace-id", validationErr.Field)
+	assert.ErrorIs(t, err, errInvalidTraceID)
+	assert.Equal(t, errInvalidTraceID.Error(), err.Error())
+
+	err = ValidationErrorf("total-bytes", "invalid `total-bytes`: %w", errInvalidSpanID)
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "total-bytes", validationErr.Field)
+	assert.ErrorIs(t, err, errInvalidSpanID)
+
+	schemaErr := ValidateSchemaURL("traces-schema-url", "not a url")
+	assert.ErrorAs(t, schemaErr, &validationErr)
+	assert.Equal(t, "traces-schema-url", validationErr.Field)
+}
@@ -0,0 +1,32 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import "fmt"
+
+// ValidationError is an invalid option of the configuration, as returned by the Validate
+// methods of the configs and by the TLS helpers. Programs embedding trazr-gen can tell
+// which option is wrong with errors.As.
+type ValidationError struct {
+	Field string // key of the invalid option, like "rate" or "client-auth.client-cert"
+	Err   error  // what is wrong with it
+}
+
+// NewValidationError returns a *ValidationError of field, reporting err.
+func NewValidationError(field string, err error) error {
+	return &ValidationError{Field: field, Err: err}
+}
+
+// ValidationErrorf returns a *ValidationError of field, reporting the formatted message.
+func ValidationErrorf(field, format string, args ...any) error {
+	return &ValidationError{Field: field, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
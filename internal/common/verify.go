@@ -0,0 +1,182 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// receivedMetrics are the counters of the collector's internal telemetry counting the
+// items its receivers accepted, by signal.
+var receivedMetrics = map[string]string{
+	"traces":  "otelcol_receiver_accepted_spans",
+	"metrics": "otelcol_receiver_accepted_metric_points",
+	"logs":    "otelcol_receiver_accepted_log_records",
+}
+
+// verifyTimeout is how long Finish waits for the collector to count the delivered items.
+const verifyTimeout = 10 * time.Second
+
+// verifyPollInterval is how often Finish reads the collector's counter while waiting.
+const verifyPollInterval = 500 * time.Millisecond
+
+// ErrVerificationFailed is returned when the collector received fewer items than were
+// delivered to it.
+var ErrVerificationFailed = errors.New("verification failed")
+
+// ValidateVerifyEndpoint checks that `verify-endpoint` is empty or an http(s) URL.
+func ValidateVerifyEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ValidationErrorf("verify-endpoint", "invalid `verify-endpoint` %q: expected the http(s) URL of the collector's Prometheus metrics, like 'http://localhost:8888/metrics'", endpoint)
+	}
+	return nil
+}
+
+// Verification compares the items a collector received during a run, read from the
+// internal telemetry it serves at `verify-endpoint`, with the items delivered to it.
+type Verification struct {
+	signal   string
+	url      string
+	metric   string  // counter of the accepted items of the signal
+	baseline float64 // counter before the run, from earlier traffic
+	client   *http.Client
+	timeout  time.Duration
+}
+
+// StartVerification reads the collector's count of the accepted items of signal, like
+// "traces", before the run when `verify-endpoint` is set. It returns nil otherwise.
+func (c *Config) StartVerification(signal string) (*Verification, error) {
+	if c.VerifyEndpoint == "" {
+		return nil, nil
+	}
+	if err := ValidateVerifyEndpoint(c.VerifyEndpoint); err != nil {
+		return nil, err
+	}
+	metric, ok := receivedMetrics[signal]
+	if !ok {
+		return nil, fmt.Errorf("no collector metric counts the received %s", signal)
+	}
+	v := &Verification{
+		signal:  signal,
+		url:     c.VerifyEndpoint,
+		metric:  metric,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		timeout: verifyTimeout,
+	}
+	baseline, err := v.received(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the `verify-endpoint`: %w", err)
+	}
+	v.baseline = baseline
+	return v, nil
+}
+
+// Finish waits until the collector has counted the delivered items, or for its timeout,
+// and reports the received and delivered counts. It returns ErrVerificationFailed when
+// fewer items were received. A nil *Verification verifies nothing.
+func (v *Verification) Finish(delivered int64, logger *zap.Logger, terminal bool) error {
+	if v == nil {
+		return nil
+	}
+	deadline := time.Now().Add(v.timeout)
+	var received float64
+	for {
+		total, err := v.received(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to query the `verify-endpoint`: %w", err)
+		}
+		received = total - v.baseline
+		if received >= float64(delivered) || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(verifyPollInterval)
+	}
+
+	logger.Info("verification", zap.String("signal", v.signal), zap.Int64("delivered", delivered), zap.Float64("received", received))
+	if received < float64(delivered) {
+		err := fmt.Errorf("%w: the collector received %.0f of the %d %s delivered", ErrVerificationFailed, received, delivered, v.signal)
+		if terminal {
+			NewConsoleOutput().Warningln("Verification:", err)
+		}
+		return err
+	}
+	if terminal {
+		fmt.Printf("Verification: the collector received %.0f %s, %d delivered\n", received, v.signal, delivered)
+	}
+	if received > float64(delivered) {
+		logger.Warn("the collector received more items than were delivered, from duplicates or other sources", zap.String("signal", v.signal))
+	}
+	return nil
+}
+
+// received returns the current value of the counter of the accepted items, summed over
+// all the receivers of the collector.
+func (v *Verification) received(ctx context.Context) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, http.NoBody)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return sumCounter(resp.Body, v.metric)
+}
+
+// sumCounter sums the samples of a counter in the Prometheus text format, whose name
+// may have the _total suffix newer collectors add. A missing counter counts as 0, as
+// collectors only expose it once a receiver has accepted something.
+func sumCounter(r io.Reader, metric string) (float64, error) {
+	var sum float64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest := line, ""
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name, rest = line[:i], line[i:]
+		}
+		if name != metric && name != metric+"_total" {
+			continue
+		}
+		if strings.HasPrefix(rest, "{") {
+			end := strings.LastIndex(rest, "}")
+			if end < 0 {
+				return 0, fmt.Errorf("malformed sample %q", line)
+			}
+			rest = rest[end+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("malformed sample %q", line)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("malformed sample %q: %w", line, err)
+		}
+		sum += value
+	}
+	return sum, scanner.Err()
+}
@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSumCounter(t *testing.T) {
+	body := `# HELP otelcol_receiver_accepted_spans Number of spans successfully pushed into the pipeline.
+# TYPE otelcol_receiver_accepted_spans counter
+otelcol_receiver_accepted_spans{receiver="otlp",transport="grpc"} 12
+otelcol_receiver_accepted_spans{receiver="otlp",transport="http"} 30 1700000000000
+otelcol_receiver_accepted_spans_total{receiver="zipkin",transport="http"} 3
+otelcol_receiver_refused_spans{receiver="otlp",transport="grpc"} 7
+otelcol_receiver_accepted_log_records 4
+`
+	sum, err := sumCounter(strings.NewReader(body), "otelcol_receiver_accepted_spans")
+	require.NoError(t, err)
+	assert.InDelta(t, 45, sum, 0)
+
+	sum, err = sumCounter(strings.NewReader(body), "otelcol_receiver_accepted_metric_points")
+	require.NoError(t, err)
+	assert.Zero(t, sum, "a counter the collector doesn't expose yet counts as 0")
+
+	_, err = sumCounter(strings.NewReader(`otelcol_receiver_accepted_spans{receiver="otlp" 12`), "otelcol_receiver_accepted_spans")
+	require.Error(t, err)
+}
+
+func TestValidateVerifyEndpoint(t *testing.T) {
+	require.NoError(t, ValidateVerifyEndpoint(""))
+	require.NoError(t, ValidateVerifyEndpoint("http://localhost:8888/metrics"))
+	require.EqualError(t, ValidateVerifyEndpoint("localhost:8888"),
+		"invalid `verify-endpoint` \"localhost:8888\": expected the http(s) URL of the collector's Prometheus metrics, like 'http://localhost:8888/metrics'")
+}
+
+func TestVerification(t *testing.T) {
+	var accepted atomic.Int64
+	accepted.Store(100) // from earlier traffic
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, "otelcol_receiver_accepted_log_records{receiver=\"otlp\"} %d\n", accepted.Load())
+	}))
+	defer server.Close()
+
+	c := &Config{VerifyEndpoint: server.URL}
+	v, err := c.StartVerification("logs")
+	require.NoError(t, err)
+	v.timeout = 0
+
+	accepted.Add(5)
+	require.NoError(t, v.Finish(5, zap.NewNop(), false))
+
+	err = v.Finish(8, zap.NewNop(), false)
+	require.ErrorIs(t, err, ErrVerificationFailed)
+	assert.EqualError(t, err, "verification failed: the collector received 5 of the 8 logs delivered")
+}
+
+func TestVerificationWaitsForTheCollector(t *testing.T) {
+	var queries atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// the collector counts the items after the first query following the run
+		if queries.Add(1) > 2 {
+			fmt.Fprintln(w, "otelcol_receiver_accepted_spans 3")
+		}
+	}))
+	defer server.Close()
+
+	c := &Config{VerifyEndpoint: server.URL}
+	v, err := c.StartVerification("traces")
+	require.NoError(t, err)
+	v.timeout = time.Minute
+
+	require.NoError(t, v.Finish(3, zap.NewNop(), false))
+	assert.Equal(t, int64(3), queries.Load())
+}
+
+func TestStartVerificationUnset(t *testing.T) {
+	v, err := (&Config{}).StartVerification("traces")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	assert.NoError(t, v.Finish(10, zap.NewNop(), false))
+}
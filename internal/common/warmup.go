@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CountedWindow tracks the items generated once the warmup has elapsed, so the
+// final statistics only cover the steady state.
+type CountedWindow struct {
+	counter *int64
+	timer   *time.Timer
+	warmup  time.Duration
+
+	mu      sync.Mutex
+	started bool
+	start   time.Time
+	base    int64
+}
+
+// StartCountedWindow starts the warmup. Once `warmup-duration` elapses, the counted
+// window begins and the items already in counter are excluded from it.
+func (c *Config) StartCountedWindow(counter *int64) *CountedWindow {
+	w := &CountedWindow{counter: counter, warmup: c.WarmupDuration}
+	if w.warmup <= 0 {
+		w.begin()
+		return w
+	}
+	w.timer = time.AfterFunc(w.warmup, w.begin)
+	return w
+}
+
+func (w *CountedWindow) begin() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.started = true
+	w.start = time.Now()
+	w.base = atomic.LoadInt64(w.counter)
+}
+
+// Counted returns the number of items generated in the counted window and how long
+// the window has lasted. Both are zero while warming up.
+func (w *CountedWindow) Counted() (int64, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		return 0, 0
+	}
+	return atomic.LoadInt64(w.counter) - w.base, time.Since(w.start)
+}
+
+// Report stops the warmup if it is still running and logs the number of items counted
+// after it, along with their rate. With terminal output, it also prints them when a
+// warmup was configured.
+func (w *CountedWindow) Report(logger *zap.Logger, signal string, terminal bool) {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	n, elapsed := w.Counted()
+	var perSecond float64
+	if elapsed > 0 {
+		perSecond = float64(n) / elapsed.Seconds()
+	}
+	logger.Info("counted window",
+		zap.Duration("warmup", w.warmup),
+		zap.Int64(signal+"_counted", n),
+		zap.Float64("per-second", perSecond),
+	)
+	if terminal && w.warmup > 0 {
+		fmt.Printf("Counted %s after warmup: %d (%.2f/s)\n", signal, n, perSecond)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCountedWindow_NoWarmup(t *testing.T) {
+	var counter int64
+	w := (&Config{}).StartCountedWindow(&counter)
+	atomic.AddInt64(&counter, 5)
+
+	n, elapsed := w.Counted()
+	assert.Equal(t, int64(5), n)
+	assert.Positive(t, elapsed)
+}
+
+func TestCountedWindow_ExcludesWarmup(t *testing.T) {
+	var counter int64
+	w := (&Config{WarmupDuration: 50 * time.Millisecond}).StartCountedWindow(&counter)
+	atomic.AddInt64(&counter, 3)
+
+	n, _ := w.Counted()
+	assert.Equal(t, int64(0), n, "nothing is counted while warming up")
+
+	assert.Eventually(t, func() bool {
+		n, elapsed := w.Counted()
+		return n == 0 && elapsed > 0
+	}, time.Second, 5*time.Millisecond)
+	atomic.AddInt64(&counter, 4)
+
+	n, _ = w.Counted()
+	assert.Equal(t, int64(4), n)
+}
+
+func TestCountedWindow_Report(t *testing.T) {
+	var counter int64
+	w := (&Config{WarmupDuration: time.Hour}).StartCountedWindow(&counter)
+	atomic.AddInt64(&counter, 2)
+
+	core, logs := observer.New(zap.InfoLevel)
+	w.Report(zap.New(core), "traces", false)
+
+	entries := logs.FilterMessage("counted window").All()
+	assert.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, int64(0), fields["traces_counted"])
+	assert.Equal(t, time.Hour, fields["warmup"])
+}
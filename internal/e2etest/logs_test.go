@@ -45,7 +45,7 @@ func TestGenerateLogsWithSelectiveSensitiveAttributes(t *testing.T) {
 	cfg.UseHTTP = false
 	cfg.TerminalOutput = false
 
-	go func() { _ = logs.Start(cfg, zap.NewNop()) }()
+	go func() { _, _ = logs.Start(cfg, zap.NewNop()) }()
 
 	require.Eventually(t, func() bool {
 		return len(sink.AllLogs()) > 0
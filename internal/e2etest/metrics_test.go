@@ -44,7 +44,7 @@ func TestGenerateMetrics(t *testing.T) {
 	cfg.TerminalOutput = false
 
 	go func() {
-		err = metrics.Start(cfg, zap.NewNop())
+		_, err = metrics.Start(cfg, zap.NewNop())
 		require.NoError(t, err)
 	}()
 	require.Eventually(t, func() bool {
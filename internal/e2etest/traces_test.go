@@ -45,7 +45,7 @@ func TestGenerateTraces(t *testing.T) {
 	cfg.TerminalOutput = false
 
 	go func() {
-		err = traces.Start(cfg, zap.NewNop())
+		_, err = traces.Start(cfg, zap.NewNop())
 		assert.NoError(t, err)
 	}()
 	require.Eventually(t, func() bool {
@@ -79,7 +79,7 @@ func TestGenerateTracesWithSelectiveSensitiveAttributes(t *testing.T) {
 	cfg.UseHTTP = false
 	cfg.TerminalOutput = false
 
-	go func() { _ = traces.Start(cfg, zap.NewNop()) }()
+	go func() { _, _ = traces.Start(cfg, zap.NewNop()) }()
 
 	require.Eventually(t, func() bool {
 		return len(sink.AllTraces()) > 0
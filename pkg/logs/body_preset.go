@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logs
+
+import (
+	"go.opentelemetry.io/otel/log"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// bodyPresetSeverity is the `body-preset` deriving each log body from its severity.
+const bodyPresetSeverity = "severity"
+
+// severityBodies are the body templates of the severity preset, by the lowest severity
+// number they apply to. They use the same mock data templates as --body.
+var severityBodies = []struct {
+	from      log.Severity
+	templates []string
+}{
+	{log.SeverityTrace1, []string{
+		"entering {{Verb}}{{Noun}} with request id {{UUID}}",
+		"cache lookup for key {{UUID}} took {{Number 1 900}}us",
+	}},
+	{log.SeverityDebug1, []string{
+		"resolved {{AppName}} to {{IPv4Address}}",
+		"query plan for {{Noun}} chosen in {{Number 1 50}}ms",
+	}},
+	{log.SeverityInfo1, []string{
+		"{{HTTPMethod}} {{URL}} completed with status 200 in {{Number 5 300}}ms",
+		"user {{Username}} signed in from {{IPv4Address}}",
+	}},
+	{log.SeverityWarn1, []string{
+		"retrying request to {{AppName}} after {{Number 100 2000}}ms (attempt {{Number 2 5}})",
+		"slow query on {{Noun}} took {{Number 1000 9000}}ms",
+	}},
+	{log.SeverityError1, []string{
+		"failed to process request {{UUID}}: {{ErrorDatabase}}",
+		"{{HTTPMethod}} {{URL}} failed with status {{Number 500 504}}: {{ErrorHTTPServer}}",
+	}},
+	{log.SeverityFatal1, []string{
+		"shutting down {{AppName}}: {{ErrorRuntime}}",
+	}},
+}
+
+// severityBodyTemplate picks one of the body templates of the severity preset for severity.
+func severityBodyTemplate(severity log.Severity) string {
+	templates := severityBodies[0].templates
+	for _, b := range severityBodies {
+		if severity >= b.from {
+			templates = b.templates
+		}
+	}
+	return templates[common.RandIntN(len(templates))]
+}
@@ -0,0 +1,51 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logs
+
+import (
+	"context"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// queueSlots are the slots of the queue of the batch log processor with
+// `bounded-memory`. Unlike the batch span processor, it has no blocking option and drops
+// its oldest records once full, so each record takes a slot as it is emitted, released
+// once it is exported: the workers wait for room in the queue instead.
+type queueSlots chan struct{}
+
+// slottedProcessor hands each record to the batch processor once it got a slot.
+type slottedProcessor struct {
+	sdklog.Processor
+	slots queueSlots
+}
+
+func (p slottedProcessor) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	err := p.Processor.OnEmit(ctx, record)
+	if err != nil {
+		// the record never makes it to the queue
+		<-p.slots
+	}
+	return err
+}
+
+// releasingExporter releases the slots of the records the batch processor exported.
+type releasingExporter struct {
+	sdklog.Exporter
+	slots queueSlots
+}
+
+func (e releasingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	defer func() {
+		for range records {
+			<-e.slots
+		}
+	}()
+	return e.Exporter.Export(ctx, records)
+}
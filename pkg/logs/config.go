@@ -4,23 +4,39 @@
 package logs
 
 import (
-	"errors"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
 
+// defaultNumLogs is the default number of logs generated by each worker.
+const defaultNumLogs = 1
+
 // Config holds all logs subcommand configuration for CLI and config file.
 // All fields must have a `mapstructure` tag matching the CLI/config key (dashed, lower-case).
 type Config struct {
-	common.Config  `mapstructure:",squash"`
-	NumLogs        int    `mapstructure:"logs"`
-	Body           string `mapstructure:"body"`
-	SeverityText   string `mapstructure:"severity-text"`
-	SeverityNumber string `mapstructure:"severity-number"`
-	TraceID        string `mapstructure:"trace-id"`
-	SpanID         string `mapstructure:"span-id"`
+	common.Config            `mapstructure:",squash"`
+	NumLogs                  int           `mapstructure:"logs"`
+	Body                     string        `mapstructure:"body"`
+	BodyPreset               string        `mapstructure:"body-preset"`
+	DebugTemplates           bool          `mapstructure:"debug-templates"`
+	SeverityText             string        `mapstructure:"severity-text"`
+	SeverityNumber           string        `mapstructure:"severity-number"`
+	SeverityDistributionFile string        `mapstructure:"severity-distribution-file"`
+	TraceID                  string        `mapstructure:"trace-id"`
+	SpanID                   string        `mapstructure:"span-id"`
+	FromStdin                bool          `mapstructure:"from-stdin"`
+	Batch                    bool          `mapstructure:"batch"`
+	BatchSize                int           `mapstructure:"batch-size"`
+	BatchTimeout             time.Duration `mapstructure:"batch-timeout"`
+	LogScopes                []string      `mapstructure:"log-scopes"`
+	AttrDropChance           float64       `mapstructure:"attribute-drop-probability"`
+	SchemaURL                string        `mapstructure:"logs-schema-url"`
+	LogsLogLevel             string        `mapstructure:"logs-log-level"`
 }
 
 func NewConfig() *Config {
@@ -33,14 +49,25 @@ func NewConfig() *Config {
 func (c *Config) Flags(fs *pflag.FlagSet) {
 	c.CommonFlags(fs)
 
-	fs.StringVar(&c.HTTPPath, "otlp-http-url-path", c.HTTPPath, "OTLP HTTP URL path (default: /v1/logs)")
+	fs.StringVar(&c.HTTPPath, "otlp-http-url-path", c.HTTPPath, "OTLP HTTP URL path, where {signal} stands for 'logs' (default: /v1/logs)")
 
 	fs.IntVar(&c.NumLogs, "logs", c.NumLogs, "Number of logs to generate per worker (default: 1)")
-	fs.StringVar(&c.Body, "body", c.Body, "Log body message")
+	fs.StringVar(&c.Body, "body", c.Body, "Log body message. With mock data, {{.Severity}} and {{.SeverityNumber}} stand for the severity of the log")
+	fs.StringVar(&c.BodyPreset, "body-preset", c.BodyPreset, "Body preset used instead of --body: 'severity' picks messages matching the severity of each log, like errors for error logs (requires --mock-data)")
+	fs.BoolVar(&c.DebugTemplates, "debug-templates", c.DebugTemplates, "Add the body template each log was expanded from, before mock data is filled in, as the trazr.template.body attribute, to tell from the backend which template produced a body (requires --mock-data)")
 	fs.StringVar(&c.SeverityText, "severity-text", c.SeverityText, "Log severity text (e.g., Info, Debug)")
 	fs.StringVar(&c.SeverityNumber, "severity-number", c.SeverityNumber, "Log severity number (1-24)")
+	fs.StringVar(&c.SeverityDistributionFile, "severity-distribution-file", c.SeverityDistributionFile, "JSON file of the weight of each severity, by number or text, like {\"Info\": 90, \"Warn\": 8, \"Error\": 2}, sampled for each log to replay a production profile. Supersedes --severity-text and --severity-number")
 	fs.StringVar(&c.TraceID, "trace-id", c.TraceID, "TraceID for the log (hex string)")
 	fs.StringVar(&c.SpanID, "span-id", c.SpanID, "SpanID for the log (hex string)")
+	fs.BoolVar(&c.Batch, "batch", c.Batch, "Whether to batch logs through a batch processor instead of exporting each log on its own")
+	fs.IntVar(&c.BatchSize, "batch-size", c.BatchSize, "Maximum number of logs per batched export (only with --batch)")
+	fs.DurationVar(&c.BatchTimeout, "batch-timeout", c.BatchTimeout, "Maximum delay before a partial batch is exported (only with --batch)")
+	fs.BoolVar(&c.FromStdin, "from-stdin", c.FromStdin, "Read log bodies from stdin, one per line (JSON objects set the body and attributes); runs until stdin is closed")
+	fs.StringSliceVar(&c.LogScopes, "log-scopes", c.LogScopes, "Instrumentation scope names, like the packages of an app, that the logs of each worker take in turn, to simulate several loggers (comma-separated or repeatable). Supersedes --worker-scope")
+	fs.Float64Var(&c.AttrDropChance, "attribute-drop-probability", c.AttrDropChance, "Probability, between 0 and 1, of leaving out each attribute of a log record, counted in its dropped attributes, to simulate an SDK over its attribute limits. The trazr.* markers are kept")
+	fs.StringVar(&c.SchemaURL, "logs-schema-url", c.SchemaURL, "Schema URL of the logs resource, to test schema translation between signals. Defaults to the semantic conventions version trazr-gen is built with")
+	fs.StringVar(&c.LogsLogLevel, "logs-log-level", c.LogsLogLevel, "Log level of the logs generator, overriding --log-level: debug, info, warn, error")
 }
 
 // SetDefaults sets the default values for the configuration
@@ -49,35 +76,167 @@ func (c *Config) Flags(fs *pflag.FlagSet) {
 func (c *Config) SetDefaults() {
 	c.Config.SetDefaults()
 	c.HTTPPath = "/v1/logs"
-	c.NumLogs = 1
+	c.NumLogs = defaultNumLogs
 	c.Body = "Log message"
+	c.BodyPreset = ""
+	c.DebugTemplates = false
 	c.SeverityText = "Info"
 	c.SeverityNumber = "9"
+	c.SeverityDistributionFile = ""
 	c.TraceID = ""
 	c.SpanID = ""
+	c.FromStdin = false
+	c.Batch = false
+	c.BatchSize = 512
+	c.BatchTimeout = time.Second
+	c.LogScopes = []string{}
+	c.AttrDropChance = 0
+	c.SchemaURL = ""
+	c.LogsLogLevel = ""
 }
 
-// Validate validates the test scenario parameters.
+// ValidationError is the error of an invalid option returned by Validate, and by Start
+// for the TLS options, whose Field tells which option it is with errors.As.
+type ValidationError = common.ValidationError
+
+// Validate validates the test scenario parameters. Its errors are *ValidationError.
 func (c *Config) Validate() error {
-	if c.TotalDuration <= 0 && c.NumLogs <= 0 {
-		return errors.New("either `logs` or `duration` must be greater than 0")
+	if !c.FromStdin && c.TotalDuration <= 0 && c.TotalBytes == "" && c.NumLogs <= 0 {
+		return common.ValidationErrorf("logs", "either `logs` or `duration` must be greater than 0")
+	}
+
+	if c.WarmupDuration < 0 {
+		return common.ValidationErrorf("warmup-duration", "`warmup-duration` must not be negative")
+	}
+
+	if c.MaxErrors < 0 {
+		return common.ValidationErrorf("max-errors", "`max-errors` must not be negative")
+	}
+
+	if c.DuplicateRatio < 0 || c.DuplicateRatio > 1 {
+		return common.ValidationErrorf("duplicate-ratio", "`duplicate-ratio` must be between 0 and 1")
+	}
+
+	if c.DropRatio < 0 || c.DropRatio > 1 {
+		return common.ValidationErrorf("drop-ratio", "`drop-ratio` must be between 0 and 1")
+	}
+
+	if c.ScopesPerResource < 0 {
+		return common.ValidationErrorf("scopes-per-resource", "`scopes-per-resource` must not be negative")
+	}
+
+	if c.FleetSize < 0 {
+		return common.ValidationErrorf("fleet-size", "`fleet-size` must not be negative")
+	}
+
+	if _, err := c.TotalBytesLimit(); err != nil {
+		return err
+	}
+
+	if c.TUI && !c.TerminalOutput {
+		return common.ValidationErrorf("tui", "`tui` requires `terminal-output`")
+	}
+
+	if err := common.ValidateEndpoint(c.Endpoint()); err != nil {
+		return common.NewValidationError("otlp-endpoint", err)
+	}
+
+	if err := common.ValidateOTLPCompat(c.OTLPCompat); err != nil {
+		return err
+	}
+
+	if err := common.ValidateExportTraceparent(c.ExportTraceparent, c.ExportTracestate); err != nil {
+		return err
+	}
+
+	if err := common.ValidateGRPCMetadata(c.GRPCMetadata); err != nil {
+		return err
+	}
+
+	if err := common.ValidateSensitiveData(c.SensitiveData); err != nil {
+		return err
+	}
+
+	if err := common.ValidateSchemaURL("logs-schema-url", c.SchemaURL); err != nil {
+		return err
+	}
+
+	if err := common.ValidateVerifyEndpoint(c.VerifyEndpoint); err != nil {
+		return err
+	}
+
+	if err := c.ValidateBoundedMemory(); err != nil {
+		return err
+	}
+	if c.BoundedMemory && !c.Batch {
+		return common.ValidationErrorf("bounded-memory", "`bounded-memory` caps the queue of the batch log processor, it requires `batch`")
+	}
+
+	if err := c.ValidateRateSchedule(); err != nil {
+		return err
+	}
+
+	switch c.BodyPreset {
+	case "":
+	case bodyPresetSeverity:
+		if !c.MockData {
+			return common.ValidationErrorf("body-preset", "`body-preset` requires `mock-data`")
+		}
+	default:
+		return common.ValidationErrorf("body-preset", "unknown `body-preset` %q, must be 'severity'", c.BodyPreset)
+	}
+
+	if c.DebugTemplates {
+		if !c.MockData {
+			return common.ValidationErrorf("debug-templates", "`debug-templates` requires `mock-data`")
+		}
+		if c.FromStdin {
+			return common.ValidationErrorf("debug-templates", "`debug-templates` can't be combined with `from-stdin`, whose bodies aren't templates")
+		}
+	}
+
+	if c.Batch && (c.BatchSize <= 0 || c.BatchTimeout <= 0) {
+		return common.ValidationErrorf("batch-size", "`batch-size` and `batch-timeout` must be greater than 0 when `batch` is enabled")
+	}
+
+	if len(c.LogScopes) > 0 && c.ScopesPerResource > 1 {
+		return common.ValidationErrorf("log-scopes", "`log-scopes` can't be combined with `scopes-per-resource`")
+	}
+	for _, name := range c.LogScopes {
+		if strings.TrimSpace(name) == "" {
+			return common.ValidationErrorf("log-scopes", "`log-scopes` must not contain empty scope names")
+		}
+	}
+
+	if c.AttrDropChance < 0 || c.AttrDropChance > 1 {
+		return common.ValidationErrorf("attribute-drop-probability", "`attribute-drop-probability` must be between 0 and 1")
 	}
 
 	if c.TraceID != "" {
 		if err := common.ValidateTraceID(c.TraceID); err != nil {
-			return err
+			return common.NewValidationError("trace-id", err)
 		}
 	}
 
 	if c.SpanID != "" {
 		if err := common.ValidateSpanID(c.SpanID); err != nil {
-			return err
+			return common.NewValidationError("span-id", err)
 		}
 	}
 
 	return nil
 }
 
+// ScopeNames returns the instrumentation scope names the logs of the given worker
+// (1-based) take in turn: the `log-scopes` when set, or else those of the common
+// configuration.
+func (c *Config) ScopeNames(worker int) []string {
+	if len(c.LogScopes) > 0 {
+		return c.LogScopes
+	}
+	return c.Config.ScopeNames(worker)
+}
+
 func (c *Config) GetHeaders() map[string]string {
 	return c.Config.GetHeaders()
 }
@@ -91,3 +250,9 @@ func (c *Config) IsMockDataEnabled() bool {
 func (c *Config) InitAttributes() error {
 	return c.Config.InitAttributes()
 }
+
+// ResourceSchemaURL returns the schema URL of the logs resource: `logs-schema-url`, or else
+// the one of the semantic conventions the resource attributes follow.
+func (c *Config) ResourceSchemaURL() string {
+	return common.ResourceSchemaURL(c.SchemaURL, semconv.SchemaURL)
+}
@@ -4,16 +4,21 @@
 package logs
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/log/logtest"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
 
 // grpcExporterOptions creates the configuration options for a gRPC-based OTLP log exporter.
-// It configures the exporter with the provided endpoint, connection security settings, and headers.
+// It configures the exporter with the provided endpoint, connection security settings, authority and headers.
 func grpcExporterOptions(cfg *Config) ([]otlploggrpc.Option, error) {
 	grpcExpOpt := []otlploggrpc.Option{
 		otlploggrpc.WithEndpoint(cfg.Endpoint()),
@@ -31,6 +36,10 @@ func grpcExporterOptions(cfg *Config) ([]otlploggrpc.Option, error) {
 		grpcExpOpt = append(grpcExpOpt, otlploggrpc.WithTLSCredentials(credentials))
 	}
 
+	if dialOpts := cfg.ExportDialOptions(); len(dialOpts) > 0 {
+		grpcExpOpt = append(grpcExpOpt, otlploggrpc.WithDialOption(dialOpts...))
+	}
+
 	headers, err := cfg.GetHeadersWithMockMarker()
 	if err != nil {
 		return nil, err
@@ -47,13 +56,15 @@ func grpcExporterOptions(cfg *Config) ([]otlploggrpc.Option, error) {
 func httpExporterOptions(cfg *Config) ([]otlploghttp.Option, error) {
 	httpExpOpt := []otlploghttp.Option{
 		otlploghttp.WithEndpoint(cfg.Endpoint()),
-		otlploghttp.WithURLPath(cfg.HTTPPath),
+		otlploghttp.WithURLPath(cfg.URLPath("logs")),
 	}
 
+	var tlsCfg *tls.Config
 	if cfg.Insecure {
 		httpExpOpt = append(httpExpOpt, otlploghttp.WithInsecure())
 	} else {
-		tlsCfg, err := common.GetTLSCredentialsForHTTPExporter(
+		var err error
+		tlsCfg, err = common.GetTLSCredentialsForHTTPExporter(
 			cfg.CaFile, cfg.ClientAuth, cfg.InsecureSkipVerify,
 		)
 		if err != nil {
@@ -69,5 +80,113 @@ func httpExporterOptions(cfg *Config) ([]otlploghttp.Option, error) {
 	if len(headers) > 0 {
 		httpExpOpt = append(httpExpOpt, otlploghttp.WithHeaders(headers))
 	}
+
+	if client := cfg.ExportHTTPClient("logs", tlsCfg); client != nil {
+		httpExpOpt = append(httpExpOpt, otlploghttp.WithHTTPClient(client))
+	}
+
 	return httpExpOpt, nil
 }
+
+// errorCountingExporter counts the outcome of the exports of the decorated log exporter
+// with common.ExportErrors.CountExport.
+type errorCountingExporter struct {
+	sdklog.Exporter
+	errors *common.ExportErrors
+}
+
+func (e errorCountingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return e.errors.CountExport(e.Exporter.Export(ctx, records), len(records))
+}
+
+// duplicatingExporter exports the `duplicate-ratio` share of the batches twice, with the
+// same records, see common.ExportDuplicated.
+type duplicatingExporter struct {
+	sdklog.Exporter
+	ratio float64
+}
+
+func (e duplicatingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return common.ExportDuplicated(e.ratio, func() error { return e.Exporter.Export(ctx, records) })
+}
+
+// droppedAttributesExporter leaves the attributes of the records picked by
+// common.KeepAttributes out, counting them in their dropped attributes like an SDK over
+// its attribute limits does.
+type droppedAttributesExporter struct {
+	sdklog.Exporter
+	probability float64
+}
+
+func (e droppedAttributesExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	out := make([]sdklog.Record, len(records))
+	for i, r := range records {
+		out[i] = withDroppedAttributes(r, e.probability)
+	}
+	return e.Exporter.Export(ctx, out)
+}
+
+// withDroppedAttributes returns r without the attributes picked by common.KeepAttributes
+// with probability p, counted in its dropped attributes. sdklog.Record has no setter for
+// its dropped attributes, so the record is built again by a logtest.RecordFactory, which
+// sets them; the attribute limits, already applied to r, aren't applied again.
+func withDroppedAttributes(r sdklog.Record, p float64) sdklog.Record {
+	attrs := make([]log.KeyValue, 0, r.AttributesLen())
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs = append(attrs, kv)
+		return true
+	})
+	kept, dropped := common.KeepAttributes(attrs, func(kv log.KeyValue) string { return kv.Key }, p)
+	if dropped == 0 {
+		return r
+	}
+	res, scope := r.Resource(), r.InstrumentationScope()
+	return logtest.RecordFactory{
+		EventName:                 r.EventName(),
+		Timestamp:                 r.Timestamp(),
+		ObservedTimestamp:         r.ObservedTimestamp(),
+		Severity:                  r.Severity(),
+		SeverityText:              r.SeverityText(),
+		Body:                      r.Body(),
+		Attributes:                kept,
+		TraceID:                   r.TraceID(),
+		SpanID:                    r.SpanID(),
+		TraceFlags:                r.TraceFlags(),
+		Resource:                  &res,
+		InstrumentationScope:      &scope,
+		DroppedAttributes:         r.DroppedAttributes() + dropped,
+		AttributeValueLengthLimit: -1,
+		AttributeCountLimit:       -1,
+	}.NewRecord()
+}
+
+// droppingExporter leaves the `drop-ratio` share of the records out of the exports, see
+// common.DropItems.
+type droppingExporter struct {
+	sdklog.Exporter
+	ratio   float64
+	skipped *common.ExportErrors
+}
+
+func (e droppingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	kept := common.DropItems(records, func(sdklog.Record) bool { return common.Chance(e.ratio) }, e.skipped)
+	if len(kept) == 0 {
+		return nil
+	}
+	return e.Exporter.Export(ctx, kept)
+}
+
+// discardExporter is a log exporter that drops everything it is given.
+type discardExporter struct{}
+
+func (discardExporter) Export(context.Context, []sdklog.Record) error {
+	return nil
+}
+
+func (discardExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (discardExporter) Shutdown(context.Context) error {
+	return nil
+}
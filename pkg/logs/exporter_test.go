@@ -5,7 +5,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.uber.org/zap/zaptest"
+
+	"github.com/medxops/trazr-gen/internal/common"
 )
 
 func TestGrpcExporterOptions_Insecure(t *testing.T) {
@@ -30,6 +33,19 @@ func TestGrpcExporterOptions_TLS_Error(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestGrpcExporterOptions_Authority(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	cfg.CustomEndpoint = "localhost:4317"
+	opts, err := grpcExporterOptions(cfg)
+	require.NoError(t, err)
+
+	cfg.GRPCAuthority = "collector.internal"
+	withAuthority, err := grpcExporterOptions(cfg)
+	require.NoError(t, err)
+	require.Len(t, withAuthority, len(opts)+1, "expected one extra option for the authority")
+}
+
 func TestHttpExporterOptions_Insecure(t *testing.T) {
 	cfg := &Config{}
 	cfg.SetDefaults()
@@ -109,3 +125,46 @@ func TestCreateExporter_gRPC_TLS_Error(t *testing.T) {
 	_, err := createExporter(cfg, logger)
 	require.Error(t, err)
 }
+
+type failingExporter struct {
+	mockExporter
+	err error
+}
+
+func (f *failingExporter) Export(_ context.Context, _ []sdklog.Record) error {
+	return f.err
+}
+
+func TestErrorCountingExporter(t *testing.T) {
+	exportErrors := common.NewExportErrors()
+	exp := errorCountingExporter{
+		Exporter: &failingExporter{err: context.DeadlineExceeded},
+		errors:   exportErrors,
+	}
+
+	err := exp.Export(context.Background(), nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int64(1), exportErrors.Count(common.ErrorTimeout))
+
+	ok := errorCountingExporter{Exporter: &mockExporter{}, errors: exportErrors}
+	require.NoError(t, ok.Export(context.Background(), nil))
+	require.Equal(t, int64(1), exportErrors.Total())
+}
+
+func TestHTTPExporterOptions_ExportTraceparent(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	opts, err := httpExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.ExportTraceparent = "random"
+	withTraceparent, err := httpExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(withTraceparent) != len(opts)+1 {
+		t.Fatalf("expected one extra option for the HTTP client, got %d instead of %d", len(withTraceparent), len(opts)+1)
+	}
+}
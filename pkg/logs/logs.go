@@ -4,9 +4,12 @@
 package logs
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,10 +19,10 @@ import (
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/log"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
-	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
+
+	"github.com/medxops/trazr-gen/internal/common"
 )
 
 const logsHelpTemplate = `
@@ -52,22 +55,44 @@ Additional help topics:
 {{end}}
 Tip: Use "--mock-data" to generate fake values for attributes and headers!`
 
+// Examples are the command lines shown in the help of the logs command.
+var Examples = []common.Example{
+	{Description: "Send 10 logs to a local collector", Command: "trazr-gen logs --logs 10"},
+	{Description: "Generate logs of random severities with mock bodies matching them", Command: "trazr-gen logs --logs 100 --mock-data --severity-number '{{Number 1 24}}' --body-preset severity"},
+	{Description: "Generate mock patient records, marking the sensitive attributes", Command: "trazr-gen logs --duration 30s --mock-data --body 'Patient {{Name}} admitted' --telemetry-attributes 'patient.ssn=\"{{SSN}}\"' --sensitive-data patient.ssn,Body"},
+	{Description: "Export over gRPC with mTLS", Command: "trazr-gen logs --otlp-protocol grpc --otlp-endpoint collector.example.com:4317 --otlp-insecure=false --ca-cert ca.pem --mtls --client-cert client.pem --client-key client-key.pem"},
+}
+
 // SetHelpTemplateForCmd sets the custom help template for the logs command.
 func SetHelpTemplateForCmd(cmd interface{ SetHelpTemplate(string) }) {
 	cmd.SetHelpTemplate(logsHelpTemplate)
 }
 
-// Start starts the log telemetry generator
-func Start(cfg *Config, logger *zap.Logger) error {
+// stdin is the source of log lines for --from-stdin, replaced in tests.
+var stdin io.Reader = os.Stdin
+
+// maxStdinLineSize is the longest stdin line accepted as a single log body.
+const maxStdinLineSize = 1024 * 1024
+
+// Start starts the log telemetry generator and returns the stats of all its runs.
+func Start(cfg *Config, logger *zap.Logger) (common.RunStats, error) {
+	var stats common.RunStats
 	if err := cfg.InitAttributes(); err != nil {
 		logger.Error("failed to initialize attributes", zap.Error(err))
-		return err
+		return stats, err
+	}
+	cfg.WarnBadAttributes(logger)
+	cfg.SeedRandomness()
+
+	if err := cfg.ApplyProtocol(); err != nil {
+		logger.Error("failed to select the OTLP protocol", zap.Error(err))
+		return stats, err
 	}
 
-	exporter, err := createExporter(cfg, logger)
+	verification, err := cfg.StartVerification("logs")
 	if err != nil {
-		logger.Error("failed to process OTLP exporter", zap.Error(err))
-		return err
+		logger.Error("failed to start the verification", zap.Error(err))
+		return stats, err
 	}
 
 	logger.Info("starting the logs generator with configuration", zap.Any("config", cfg))
@@ -75,23 +100,101 @@ func Start(cfg *Config, logger *zap.Logger) error {
 		fmt.Println("Starting logs generator")
 	}
 
-	if err := run(cfg, exporter, logger); err != nil {
-		logger.Error("failed to run logs generator", zap.Error(err))
+	// run shuts the exporter down when it finishes, so every repetition gets a fresh one
+	err = cfg.RunRepeated(logger, func() error {
+		exporter, err := createExporter(cfg, logger)
+		if err != nil {
+			logger.Error("failed to process OTLP exporter", zap.Error(err))
+			return err
+		}
+		exportErrors := common.NewExportErrors()
+		exportErrors.AbortAfter(int64(cfg.MaxErrors))
+		releasePartialSuccesses := common.CountPartialSuccesses(common.RejectedLogRecords, exportErrors)
+		runStats, err := run(cfg, errorCountingExporter{Exporter: exporter, errors: exportErrors}, exportErrors, logger)
+		releasePartialSuccesses()
+		if err != nil && !errors.Is(err, common.ErrMaxErrors) {
+			logger.Error("failed to run logs generator", zap.Error(err))
+			return err
+		}
+		exportErrors.Report(logger, cfg.TerminalOutput)
+		runStats.AddExportErrors(exportErrors)
+		stats.Add(runStats)
 		return err
+	})
+	if verifyErr := verification.Finish(stats.Delivered, logger, cfg.TerminalOutput); verifyErr != nil && err == nil {
+		err = verifyErr
 	}
+	return stats, err
+}
 
-	return nil
+// RunDiscarding runs the scenario once against an in-process exporter that discards
+// every log record, measuring the generator alone, without network or collector overhead.
+func RunDiscarding(cfg *Config, logger *zap.Logger) (common.RunStats, error) {
+	if err := cfg.InitAttributes(); err != nil {
+		return common.RunStats{}, err
+	}
+	cfg.SeedRandomness()
+	return run(cfg, discardExporter{}, nil, logger)
 }
 
-// run executes the test scenario.
-func run(c *Config, exporter sdklog.Exporter, logger *zap.Logger) error {
+// queueSize returns the size of the queue of the batch log processor: `max-queue-size`,
+// raised to the batch size when smaller.
+func queueSize(c *Config) int {
+	return max(c.BatchSize, c.QueueSize())
+}
+
+// newBatchProcessor returns the batch log processor of `batch`, exporting to exporter.
+// With `bounded-memory`, the workers wait for room in its queue.
+func newBatchProcessor(c *Config, exporter sdklog.Exporter) sdklog.Processor {
+	var slots queueSlots
+	if c.BoundedMemory {
+		slots = make(queueSlots, queueSize(c))
+		exporter = releasingExporter{Exporter: exporter, slots: slots}
+	}
+	var processor sdklog.Processor = sdklog.NewBatchProcessor(exporter,
+		sdklog.WithExportMaxBatchSize(c.BatchSize),
+		sdklog.WithMaxQueueSize(queueSize(c)),
+		sdklog.WithExportInterval(c.BatchTimeout),
+	)
+	if c.BoundedMemory {
+		processor = slottedProcessor{Processor: processor, slots: slots}
+	}
+	return processor
+}
+
+// run executes the test scenario. It is aborted once exportErrors, which may be nil,
+// reaches `max-errors`.
+func run(c *Config, exporter sdklog.Exporter, exportErrors *common.ExportErrors, logger *zap.Logger) (common.RunStats, error) {
 	if err := c.Validate(); err != nil {
-		return err
+		return common.RunStats{}, err
 	}
 
-	if c.TotalDuration > 0 {
+	severities, err := loadSeverityDistribution(c.SeverityDistributionFile)
+	if err != nil {
+		return common.RunStats{}, err
+	}
+
+	if c.DuplicateRatio > 0 {
+		logger.Info("a share of the exports is sent twice", zap.Float64("duplicate-ratio", c.DuplicateRatio))
+		exporter = duplicatingExporter{Exporter: exporter, ratio: c.DuplicateRatio}
+	}
+	if c.AttrDropChance > 0 {
+		logger.Info("log attributes are dropped", zap.Float64("attribute-drop-probability", c.AttrDropChance))
+		exporter = droppedAttributesExporter{Exporter: exporter, probability: c.AttrDropChance}
+	}
+	if c.DropRatio > 0 {
+		logger.Info("a share of the logs is never exported", zap.Float64("drop-ratio", c.DropRatio))
+		exporter = droppingExporter{Exporter: exporter, ratio: c.DropRatio, skipped: exportErrors}
+	}
+
+	if c.FromStdin {
+		logger.Info("reading log bodies from stdin, ignoring the number of logs")
+		c.NumLogs = 0
+	} else if c.IgnoresCount() {
+		c.WarnCountIgnored(logger, "logs", c.NumLogs, defaultNumLogs)
 		c.NumLogs = 0
 	}
+	c.WarnURLPath(logger, "logs")
 
 	limit := rate.Limit(c.Rate)
 	if c.Rate == 0 {
@@ -101,41 +204,90 @@ func run(c *Config, exporter sdklog.Exporter, logger *zap.Logger) error {
 		logger.Info("generation of logs is limited", zap.Float64("per-second", float64(limit)))
 	}
 
+	limiters, releaseLimiters := c.RunLimiters(limit)
+	defer releaseLimiters()
+	stopSchedule, err := c.StartRateSchedule(limiters, logger)
+	if err != nil {
+		return common.RunStats{}, err
+	}
+	defer stopSchedule()
+	if c.SharedLimiter {
+		logger.Info("rate limiter is shared across all workers")
+	}
+
 	wg := sync.WaitGroup{}
-	attrs, err := c.GetResourceAttrWithMockMarker()
+	resources, err := c.FleetResources(c.ResourceSchemaURL())
 	if err != nil {
 		logger.Fatal("failed to process resource attributes", zap.Error(err))
-		return err
+		return common.RunStats{}, err
+	}
+	if len(resources) > 1 {
+		logger.Info("logs are emitted from a fleet of hosts in turn", zap.Int("fleet-size", len(resources)))
 	}
-	res := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
 
+	var processor sdklog.Processor
+	if c.Batch {
+		processor = newBatchProcessor(c, exporter)
+		logger.Info("logs are batched", zap.Int("batch-size", c.BatchSize), zap.Duration("batch-timeout", c.BatchTimeout))
+		if c.BoundedMemory {
+			logger.Info("memory is bounded", zap.Int("max-queue-size", queueSize(c)))
+		}
+	} else {
+		processor = sdklog.NewSimpleProcessor(exporter)
+	}
+	// one provider per `fleet-size` host, sharing the processor
+	loggerProviders := make([]*sdklog.LoggerProvider, len(resources))
+	for k, res := range resources {
+		loggerProviders[k] = sdklog.NewLoggerProvider(
+			sdklog.WithResource(res),
+			sdklog.WithProcessor(processor),
+			// generated attributes are never limited, as with the other signals
+			sdklog.WithAttributeCountLimit(-1),
+		)
+	}
+	defer func() {
+		// shutting down the providers flushes the processor and stops the exporter
+		logger.Info("stopping the logger provider")
+		for _, loggerProvider := range loggerProviders {
+			if tempError := loggerProvider.Shutdown(context.Background()); tempError != nil {
+				logger.Error("failed to stop the logger provider", zap.Error(tempError))
+			}
+		}
+	}()
 	running := &atomic.Bool{}
 	running.Store(true)
+	stop := make(chan struct{})
+
+	var lines chan string
+	if c.FromStdin {
+		lines = make(chan string)
+		go readLines(stdin, lines, stop, logger)
+	}
 
 	var totalLogs int64
+	// the counted window starts once the warmup has elapsed
+	window := c.StartCountedWindow(&totalLogs)
+	var timing *common.ExportTiming
+	if c.ExportTiming {
+		timing = common.NewExportTiming(c.WorkerCount)
+	}
+	start := time.Now()
 
 	progressCh := make(chan struct{})
-	go func() {
-		count := 0
-		for range progressCh {
-			count++
-			if c.TerminalOutput {
-				fmt.Println("Logs generated:", count)
-			}
-		}
-		if c.TerminalOutput {
-			fmt.Println("Logs generated (final count):", count)
-		}
-	}()
+	go c.ConsumeProgress("Logs generated", progressCh)
 
 	for i := 0; i < c.WorkerCount; i++ {
 		wg.Add(1)
 		w := worker{
 			numLogs:        c.NumLogs,
 			limitPerSecond: limit,
+			limiter:        limiters[i],
 			body:           c.Body,
+			bodyPreset:     c.BodyPreset,
+			debugTemplates: c.DebugTemplates,
 			severityText:   c.SeverityText,
 			severityNumber: c.SeverityNumber,
+			severities:     severities,
 			totalDuration:  c.TotalDuration,
 			running:        running,
 			wg:             &wg,
@@ -144,26 +296,62 @@ func run(c *Config, exporter sdklog.Exporter, logger *zap.Logger) error {
 			traceID:        c.TraceID,
 			spanID:         c.SpanID,
 			logsCounter:    &totalLogs,
+			exportTiming:   timing.Worker(i),
 			progressCh:     progressCh,
+			otelLoggers:    scopeLoggers(loggerProviders, c.ScopeNames(i+1)),
+			lines:          lines,
+			stop:           stop,
 		}
-		defer func() {
-			w.logger.Info("stopping the exporter")
-			if tempError := exporter.Shutdown(context.Background()); tempError != nil {
-				w.logger.Error("failed to stop the exporter", zap.Error(tempError))
-			}
-		}()
-		go w.simulateLogs(c, res, exporter)
+		go w.simulateLogs(c)
 	}
 
-	if c.TotalDuration > 0 {
-		time.Sleep(c.TotalDuration)
+	stopDashboard := c.StartDashboard("logs", &totalLogs, exportErrors)
+	err = c.WaitWorkers(&wg, func() {
 		running.Store(false)
-	}
-	wg.Wait()
+		close(stop)
+	}, exportErrors, logger)
+	stopDashboard()
 	close(progressCh)
-	logger.Info("final count", zap.Int64("logs_generated", atomic.LoadInt64(&totalLogs)))
+	stats := common.NewRunStats(atomic.LoadInt64(&totalLogs), time.Since(start))
+	logger.Info("final count", zap.Int64("logs_generated", stats.Generated), zap.Float64("per-second", stats.AchievedRate))
+	window.Report(logger, "logs", c.TerminalOutput)
+	timing.Report(logger, c.TerminalOutput, c.TargetSpacing())
+
+	return stats, err
+}
 
-	return nil
+// scopeLoggers returns a logger of each of the providers for each of the scope names,
+// ordered so that taking them in turn goes through the providers before the scopes.
+func scopeLoggers(providers []*sdklog.LoggerProvider, names []string) []log.Logger {
+	loggers := make([]log.Logger, 0, len(names)*len(providers))
+	for _, name := range names {
+		for _, provider := range providers {
+			loggers = append(loggers, provider.Logger(name))
+		}
+	}
+	return loggers
+}
+
+// readLines sends every non-empty line of r to lines and closes it once r is exhausted
+// or stop is closed.
+func readLines(r io.Reader, lines chan<- string, stop <-chan struct{}, logger *zap.Logger) {
+	defer close(lines)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxStdinLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		select {
+		case lines <- line:
+		case <-stop:
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("failed to read from stdin", zap.Error(err))
+	}
 }
 
 func createExporter(cfg *Config, logger *zap.Logger) (sdklog.Exporter, error) {
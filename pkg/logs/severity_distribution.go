@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/log"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// severityWeights is the weighted distribution of the log severities read from a
+// `severity-distribution-file`, sampled for each log.
+type severityWeights struct {
+	severities []log.Severity
+	cumulative []float64 // running total of the weights, by severity
+}
+
+// loadSeverityDistribution reads a `severity-distribution-file`: a JSON object of the
+// weight of each severity, given by its number or text, like {"Info": 90, "Error": 2.5}.
+// The weights don't need to add up to anything. An empty path returns nil.
+// #nosec G304 -- path is controlled by configuration, not user input
+func loadSeverityDistribution(path string) (*severityWeights, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the `severity-distribution-file`: %w", err)
+	}
+	var weights map[string]float64
+	if err := json.Unmarshal(data, &weights); err != nil {
+		return nil, fmt.Errorf("invalid `severity-distribution-file` %s: expected a JSON object of weights by severity, like {\"Info\": 90, \"Error\": 10}: %w", path, err)
+	}
+
+	bySeverity := make(map[log.Severity]float64, len(weights))
+	for key, weight := range weights {
+		severity, err := parseSeverityKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid `severity-distribution-file` %s: %w", path, err)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("invalid `severity-distribution-file` %s: the weight of %q must not be negative", path, key)
+		}
+		bySeverity[severity] += weight
+	}
+
+	d := &severityWeights{}
+	var total float64
+	for _, severity := range slices.Sorted(maps.Keys(bySeverity)) {
+		if bySeverity[severity] == 0 {
+			continue
+		}
+		total += bySeverity[severity]
+		d.severities = append(d.severities, severity)
+		d.cumulative = append(d.cumulative, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("invalid `severity-distribution-file` %s: no severity has a positive weight", path)
+	}
+	return d, nil
+}
+
+// parseSeverityKey returns the severity of a distribution key: a severity number from 1
+// to 24 or a severity text, like "Info" or "Error2", in any case.
+func parseSeverityKey(key string) (log.Severity, error) {
+	if n, err := strconv.Atoi(key); err == nil {
+		if n < int(log.SeverityTrace1) || n > int(log.SeverityFatal4) {
+			return 0, fmt.Errorf("severity number %d is out of range, the valid range is [1,24]", n)
+		}
+		return log.Severity(n), nil
+	}
+	for n, text := range severityNumberToText {
+		if strings.EqualFold(text, key) {
+			return log.Severity(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q, expected a severity number or text like Info or Error2", key)
+}
+
+// sample picks a severity according to the weights of the distribution.
+func (d *severityWeights) sample() log.Severity {
+	total := d.cumulative[len(d.cumulative)-1]
+	r := common.RandFloat64() * total
+	i := sort.SearchFloat64s(d.cumulative, r)
+	if i < len(d.cumulative) && d.cumulative[i] == r {
+		// r falls on the upper bound of severity i, which belongs to the next one
+		i++
+	}
+	return d.severities[min(i, len(d.severities)-1)]
+}
@@ -6,8 +6,10 @@ package logs
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,9 +18,6 @@ import (
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/log"
-	sdklog "go.opentelemetry.io/otel/sdk/log"
-	"go.opentelemetry.io/otel/sdk/log/logtest"
-	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
@@ -27,23 +26,35 @@ import (
 )
 
 type worker struct {
-	running        *atomic.Bool    // pointer to shared flag that indicates it's time to stop the test
-	numLogs        int             // how many logs the worker has to generate (only when duration==0)
-	body           string          // the body of the log
-	severityNumber string          // the severityNumber of the log (string, for templating)
-	severityText   string          // the severityText of the log
-	totalDuration  time.Duration   // how long to run the test for (overrides `numLogs`)
-	limitPerSecond rate.Limit      // how many logs per second to generate
-	wg             *sync.WaitGroup // notify when done
-	logger         *zap.Logger     // logger
-	index          int             // worker index
-	traceID        string          // traceID string
-	spanID         string          // spanID string
-	logsCounter    *int64          // pointer to shared logs counter
-	progressCb     func(string)    // optional callback for terminal output
-	progressCh     chan struct{}   // channel for centralized progress reporting
+	running        *atomic.Bool         // pointer to shared flag that indicates it's time to stop the test
+	numLogs        int                  // how many logs the worker has to generate (only when duration==0)
+	body           string               // the body of the log
+	bodyPreset     string               // body preset replacing body (severity derives it from the log severity)
+	debugTemplates bool                 // add the body template of each log as the trazr.template.body attribute
+	severityNumber string               // the severityNumber of the log (string, for templating)
+	severityText   string               // the severityText of the log
+	severities     *severityWeights     // distribution the severity of each log is sampled from (nil means severityNumber and severityText)
+	totalDuration  time.Duration        // how long to run the test for (overrides `numLogs`)
+	limitPerSecond rate.Limit           // how many logs per second to generate
+	limiter        *rate.Limiter        // limiter of the worker, possibly shared across workers (nil means the worker creates its own)
+	wg             *sync.WaitGroup      // notify when done
+	logger         *zap.Logger          // logger
+	index          int                  // worker index
+	traceID        string               // traceID string
+	spanID         string               // spanID string
+	logsCounter    *int64               // pointer to shared logs counter
+	exportTiming   *common.WorkerTiming // records the time between exports (nil when disabled)
+	progressCb     func(string)         // optional callback for terminal output
+	progressCh     chan struct{}        // channel for centralized progress reporting
+	otelLoggers    []log.Logger         // OpenTelemetry loggers the generated records are emitted to, one per scope, in turn
+	lines          <-chan string        // optional stdin lines used as log bodies (nil means generated bodies)
+	stop           <-chan struct{}      // closed once the test duration has elapsed
 }
 
+// templateBodyKey is the attribute of `debug-templates` holding the body template a log
+// was expanded from.
+const templateBodyKey = "trazr.template.body"
+
 // Helper to convert []attribute.KeyValue to []log.KeyValue
 func attrToLogKeyValue(attrs []attribute.KeyValue) []log.KeyValue {
 	result := make([]log.KeyValue, len(attrs))
@@ -75,11 +86,111 @@ func (w worker) reportProgressf(format string, args ...any) {
 	}
 }
 
-func (w worker) simulateLogs(cfg *Config, res *resource.Resource, exporter sdklog.Exporter) {
-	limiter := rate.NewLimiter(w.limitPerSecond, 1)
+// parseStdinLine turns a stdin line into a log body. A JSON object line uses its "body"
+// field as the body and every other field as a log attribute; any other line is the body as-is.
+func parseStdinLine(line string) (string, []log.KeyValue) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return line, nil
+	}
+	body := line
+	if b, ok := fields["body"]; ok {
+		body = fmt.Sprint(b)
+		delete(fields, "body")
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]log.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, log.KeyValue{Key: k, Value: jsonToLogValue(fields[k])})
+	}
+	return body, attrs
+}
+
+// jsonToLogValue converts a decoded JSON value to a log value, keeping whole numbers as integers.
+func jsonToLogValue(v any) log.Value {
+	switch val := v.(type) {
+	case string:
+		return log.StringValue(val)
+	case bool:
+		return log.BoolValue(val)
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < math.MaxInt64 {
+			return log.Int64Value(int64(val))
+		}
+		return log.Float64Value(val)
+	default:
+		b, _ := json.Marshal(val)
+		return log.StringValue(string(b))
+	}
+}
+
+// nextSeverity returns the severity text and number of the next log: sampled from the
+// `severity-distribution-file`, or else the configured ones, which may be mock templates.
+func (w worker) nextSeverity(cfg *Config) (string, log.Severity) {
+	if w.severities != nil {
+		severity := w.severities.sample()
+		return severityTextFromNumber(int32(severity)), severity
+	}
+
+	// --- Process severity number with gofakeit templating per log entry ---
+	severityNumberStr := w.severityNumber
+	if cfg.MockData && len(severityNumberStr) > 0 && (strings.Contains(severityNumberStr, "{{") && strings.Contains(severityNumberStr, "}}")) {
+		parsed, parseErr := common.ProcessMockTemplate(severityNumberStr, nil)
+		if parseErr != nil {
+			w.reportProgressf("Failed to process mock template for severity-number: %v", parseErr)
+			w.logger.Error("failed to process mock template for severity-number", zap.Error(parseErr))
+			// fallback to default
+		} else {
+			severityNumberStr = parsed
+		}
+	}
+	severityNumberInt, err := strconv.Atoi(severityNumberStr)
+	if err != nil && severityNumberInt < 1 && severityNumberInt > 24 {
+		severityNumberInt = 9 // fallback to Info if parsing fails
+	}
+	// Clamp severityNumberInt to int32 range to avoid overflow (gosec: G109)
+	var safeSeverityNumberInt int32
+	switch {
+	case severityNumberInt > math.MaxInt32:
+		safeSeverityNumberInt = math.MaxInt32
+	case severityNumberInt < math.MinInt32:
+		safeSeverityNumberInt = math.MinInt32
+	default:
+		safeSeverityNumberInt = int32(severityNumberInt) //nolint:gosec // checked range above
+	}
+	severityText, severityNumber, err := parseSeverity(w.severityText, safeSeverityNumberInt)
+	if err != nil {
+		return w.severityText, log.Severity(safeSeverityNumberInt)
+	}
+	return severityText, severityNumber
+}
+
+func (w worker) simulateLogs(cfg *Config) {
+	limiter := w.limiter
+	if limiter == nil {
+		limiter = rate.NewLimiter(w.limitPerSecond, 1)
+	}
 	var i int64
 
+loop:
 	for w.running.Load() {
+		var line string
+		if w.lines != nil {
+			var ok bool
+			select {
+			case line, ok = <-w.lines:
+				if !ok {
+					break loop
+				}
+			case <-w.stop:
+				break loop
+			}
+		}
+
 		var tid trace.TraceID
 		var sid trace.SpanID
 
@@ -93,19 +204,34 @@ func (w worker) simulateLogs(cfg *Config, res *resource.Resource, exporter sdklo
 		}
 
 		// --- Get processed attribute KeyValues (including mock marker logic) ---
-		attrKVs, err := cfg.GetTelemetryAttrWithMockMarker()
-		if err != nil {
-			w.reportProgressf("Failed to process telemetry attributes: %v", err)
-			w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
-			break
+		var attrKVs []attribute.KeyValue
+		if !cfg.NoTelemetryAttributes {
+			attrs, err := cfg.GetTelemetryAttrWithMockMarker()
+			if err != nil {
+				w.reportProgressf("Failed to process telemetry attributes: %v", err)
+				w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
+				break
+			}
+			attrKVs = attrs
 		}
 
+		severityText, severityNumber := w.nextSeverity(cfg)
+
 		// --- Process log body with gofakeit templating ---
+		// the body template can reference the severity, as {{.Severity}} and {{.SeverityNumber}}
 		var body string
 		body = w.body
+		var lineAttrs []log.KeyValue
+		if w.lines != nil {
+			body, lineAttrs = parseStdinLine(line)
+		} else if w.bodyPreset == bodyPresetSeverity {
+			body = severityBodyTemplate(severityNumber)
+		}
+		template := body
 		logBodyExpanded := false
-		if cfg.MockData {
-			expanded, expandErr := common.ProcessMockTemplate(body, nil)
+		if cfg.MockData && w.lines == nil {
+			severityData := map[string]any{"Severity": severityText, "SeverityNumber": int(severityNumber)}
+			expanded, expandErr := common.ProcessMockTemplateWithData(body, severityData, nil)
 			if expandErr != nil {
 				break
 			}
@@ -117,7 +243,7 @@ func (w worker) simulateLogs(cfg *Config, res *resource.Resource, exporter sdklo
 		}
 
 		// --- If log body was expanded, append log-body to the marker ---
-		if logBodyExpanded {
+		if logBodyExpanded && !cfg.NoTelemetryAttributes {
 			found := false
 			for i, attr := range attrKVs {
 				if attr.Key == "trazr.mock.data" {
@@ -134,63 +260,34 @@ func (w worker) simulateLogs(cfg *Config, res *resource.Resource, exporter sdklo
 		}
 
 		// --- Convert to log.KeyValue and add service.name (only once) ---
-		attrs := attrToLogKeyValue(attrKVs)
-
-		// --- Process severity number with gofakeit templating per log entry ---
-		severityNumberStr := w.severityNumber
-		if cfg.MockData && len(severityNumberStr) > 0 && (strings.Contains(severityNumberStr, "{{") && strings.Contains(severityNumberStr, "}}")) {
-			parsed, parseErr := common.ProcessMockTemplate(severityNumberStr, nil)
-			if parseErr != nil {
-				w.reportProgressf("Failed to process mock template for severity-number: %v", parseErr)
-				w.logger.Error("failed to process mock template for severity-number", zap.Error(parseErr))
-				// fallback to default
-			} else {
-				severityNumberStr = parsed
-			}
-		}
-		severityNumberInt, err := strconv.Atoi(severityNumberStr)
-		if err != nil && severityNumberInt < 1 && severityNumberInt > 24 {
-			severityNumberInt = 9 // fallback to Info if parsing fails
-		}
-		// Clamp severityNumberInt to int32 range to avoid overflow (gosec: G109)
-		var safeSeverityNumberInt int32
-		switch {
-		case severityNumberInt > math.MaxInt32:
-			safeSeverityNumberInt = math.MaxInt32
-		case severityNumberInt < math.MinInt32:
-			safeSeverityNumberInt = math.MinInt32
-		default:
-			safeSeverityNumberInt = int32(severityNumberInt) //nolint:gosec // checked range above
-		}
-		severityText, severityNumber, err := parseSeverity(w.severityText, safeSeverityNumberInt)
-		if err != nil {
-			severityText = w.severityText
-			severityNumber = log.Severity(safeSeverityNumberInt)
+		attrs := append(attrToLogKeyValue(attrKVs), lineAttrs...)
+		if w.debugTemplates {
+			attrs = append(attrs, log.String(templateBodyKey, template))
 		}
 
-		rf := logtest.RecordFactory{
-			Timestamp:         time.Now(),
-			Severity:          severityNumber,
-			SeverityText:      severityText,
-			Body:              log.StringValue(body),
-			Attributes:        attrs,
-			TraceID:           tid,
-			SpanID:            sid,
-			Resource:          res,
-			DroppedAttributes: 1,
-		}
+		var record log.Record
+		record.SetTimestamp(time.Now())
+		record.SetSeverity(severityNumber)
+		record.SetSeverityText(severityText)
+		record.SetBody(log.StringValue(body))
+		record.AddAttributes(attrs...)
 
-		logs := []sdklog.Record{rf.NewRecord()}
+		// the SDK takes the trace and span IDs of a record from its context
+		ctx := context.Background()
+		if tid.IsValid() || sid.IsValid() {
+			ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid}))
+		} else if sc, ok := common.CorrelatedSpanContext("logs"); ok {
+			// the record belongs to a span the traces generator of this process emitted
+			ctx = trace.ContextWithSpanContext(ctx, sc)
+		}
 
 		if err := limiter.Wait(context.Background()); err != nil {
 			w.reportProgressf("Limiter wait failed: %v", err)
 			w.logger.Fatal("limiter wait failed, retry", zap.Error(err))
 		}
+		w.exportTiming.Mark()
 
-		if err := exporter.Export(context.Background(), logs); err != nil {
-			w.reportProgressf("Exporter failed: %v", err)
-			w.logger.Fatal("exporter failed", zap.Error(err))
-		}
+		w.otelLoggers[i%int64(len(w.otelLoggers))].Emit(ctx, record)
 
 		i++
 		if w.logsCounter != nil {
@@ -5,7 +5,12 @@ package logs
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"runtime/metrics"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +19,7 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/log"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/medxops/trazr-gen/internal/common"
@@ -27,10 +33,12 @@ const (
 )
 
 type mockExporter struct {
-	logs []sdklog.Record
+	logs    []sdklog.Record
+	exports int
 }
 
 func (m *mockExporter) Export(_ context.Context, records []sdklog.Record) error {
+	m.exports++
 	m.logs = append(m.logs, records...)
 	return nil
 }
@@ -57,7 +65,8 @@ func TestFixedNumberOfLogs(t *testing.T) {
 
 	// test
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -78,7 +87,8 @@ func TestRateOfLogs(t *testing.T) {
 	m := &mockExporter{}
 
 	// test
-	require.NoError(t, run(cfg, m, zap.NewNop()))
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	// the minimum acceptable number of logs for the rate of 10/sec for half a second
@@ -100,7 +110,8 @@ func TestUnthrottled(t *testing.T) {
 
 	// test
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	assert.Greater(t, len(m.logs), 100, "there should have been more than 100 logs, had %d", len(m.logs))
 }
@@ -119,11 +130,76 @@ func TestCustomBody(t *testing.T) {
 
 	// test
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	assert.Equal(t, "custom body", m.logs[0].Body().AsString())
 }
 
+func TestSeverityBody(t *testing.T) {
+	cfg := configWithNoAttributes(1, "{{.Severity}} ({{.SeverityNumber}}): disk full")
+	cfg.MockData = true
+	cfg.SeverityText = ""
+	cfg.SeverityNumber = "17"
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.logs, 1)
+	assert.Equal(t, "Error (17): disk full", m.logs[0].Body().AsString())
+}
+
+func TestSeverityBodyPreset(t *testing.T) {
+	cfg := configWithNoAttributes(5, "ignored")
+	cfg.MockData = true
+	cfg.BodyPreset = bodyPresetSeverity
+	cfg.SeverityText = ""
+	cfg.SeverityNumber = "17"
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.logs, 5)
+	for _, l := range m.logs {
+		body := l.Body().AsString()
+		assert.NotEqual(t, "ignored", body)
+		assert.NotContains(t, body, "{{", "the preset template should be expanded")
+		assert.Contains(t, body, "fail", "error logs should get error messages")
+	}
+}
+
+func TestDebugTemplates(t *testing.T) {
+	cfg := configWithNoAttributes(3, "user {{Name}} logged in at {{.Severity}}")
+	cfg.MockData = true
+	cfg.DebugTemplates = true
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.logs, 3)
+	for _, l := range m.logs {
+		assert.NotContains(t, l.Body().AsString(), "{{")
+		var template string
+		l.WalkAttributes(func(kv log.KeyValue) bool {
+			if kv.Key == templateBodyKey {
+				template = kv.Value.AsString()
+			}
+			return true
+		})
+		assert.Equal(t, "user {{Name}} logged in at {{.Severity}}", template)
+	}
+}
+
+func TestSeverityBodyTemplate(t *testing.T) {
+	for severity := log.SeverityTrace1; severity <= log.SeverityFatal4; severity++ {
+		assert.NotEmpty(t, severityBodyTemplate(severity), "severity %d", severity)
+	}
+	assert.Contains(t, severityBodies[len(severityBodies)-1].templates, severityBodyTemplate(log.SeverityFatal))
+}
+
 func TestLogsWithNoTelemetryAttributes(t *testing.T) {
 	cfg := configWithNoAttributes(2, "custom body")
 
@@ -131,7 +207,8 @@ func TestLogsWithNoTelemetryAttributes(t *testing.T) {
 
 	// test
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -155,6 +232,23 @@ func TestLogsWithNoTelemetryAttributes(t *testing.T) {
 	}
 }
 
+func TestLogsWithNoTelemetryAttributesFlag(t *testing.T) {
+	qty := 2
+	cfg := configWithOneAttribute(qty, "{{Word}}")
+	cfg.MockData = true
+	cfg.NoTelemetryAttributes = true
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.logs, qty)
+	for _, l := range m.logs {
+		assert.Equal(t, 0, l.AttributesLen(), "telemetry attributes and the mock marker should be skipped")
+		assert.NotContains(t, l.Body().AsString(), "{{", "the body should still be expanded")
+	}
+}
+
 func TestLogsWithOneTelemetryAttributes(t *testing.T) {
 	qty := 1
 	cfg := configWithOneAttribute(qty, "custom body")
@@ -163,7 +257,8 @@ func TestLogsWithOneTelemetryAttributes(t *testing.T) {
 
 	// test
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -201,7 +296,8 @@ func TestLogsWithMultipleTelemetryAttributes(t *testing.T) {
 
 	// test
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -235,7 +331,8 @@ func TestLogsWithTraceIDAndSpanID(t *testing.T) {
 
 	// test
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	// verify
 	require.Len(t, m.logs, qty)
@@ -245,6 +342,292 @@ func TestLogsWithTraceIDAndSpanID(t *testing.T) {
 	}
 }
 
+func TestLogsCorrelatedWithSpans(t *testing.T) {
+	cfg := configWithOneAttribute(3, "correlated")
+	span := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	defer common.EnableCorrelation("logs")()
+	common.PublishSpanContext(span)
+
+	m := &mockExporter{}
+
+	// test
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, m.logs, 3)
+	for _, l := range m.logs {
+		assert.Equal(t, span.TraceID(), l.TraceID())
+		assert.Equal(t, span.SpanID(), l.SpanID())
+		assert.Equal(t, trace.FlagsSampled, l.TraceFlags())
+	}
+}
+
+func TestLogsEmittedThroughLoggerProvider(t *testing.T) {
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+			ServiceName: "provider-test",
+		},
+		NumLogs:        2,
+		SeverityText:   "Info",
+		SeverityNumber: "9",
+	}
+
+	m := &mockExporter{}
+
+	// test
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, m.logs, 2)
+	assert.Equal(t, 2, m.exports, "without batching every log is exported on its own")
+	for _, l := range m.logs {
+		assert.Equal(t, "trazr-gen", l.InstrumentationScope().Name)
+		assert.False(t, l.ObservedTimestamp().IsZero())
+		res := l.Resource()
+		assert.Contains(t, (&res).Attributes(), attribute.String("service.name", "provider-test"))
+	}
+}
+
+func TestScopesPerResource(t *testing.T) {
+	cfg := configWithOneAttribute(4, "scoped")
+	cfg.ScopesPerResource = 2
+
+	m := &mockExporter{}
+
+	// test
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, m.logs, 4)
+	var scopes []string
+	for _, l := range m.logs {
+		scopes = append(scopes, l.InstrumentationScope().Name)
+	}
+	assert.Equal(t, []string{"trazr-gen/scope-1", "trazr-gen/scope-2", "trazr-gen/scope-1", "trazr-gen/scope-2"}, scopes)
+}
+
+func TestLogScopes(t *testing.T) {
+	cfg := configWithOneAttribute(5, "scoped")
+	cfg.WorkerScope = true
+	cfg.LogScopes = []string{"pkg.a", "pkg.b"}
+
+	m := &mockExporter{}
+
+	// test
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, m.logs, 5)
+	var scopes []string
+	for _, l := range m.logs {
+		scopes = append(scopes, l.InstrumentationScope().Name)
+	}
+	assert.Equal(t, []string{"pkg.a", "pkg.b", "pkg.a", "pkg.b", "pkg.a"}, scopes, "the log scopes supersede the worker scope")
+}
+
+func TestFleetSize(t *testing.T) {
+	cfg := configWithOneAttribute(6, "fleet")
+	cfg.FleetSize = 2
+	cfg.ScopesPerResource = 2
+
+	m := &mockExporter{}
+
+	// test
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, m.logs, 6)
+	var hosts, scopes []string
+	for _, l := range m.logs {
+		res := l.Resource()
+		host, ok := res.Set().Value("host.name")
+		require.True(t, ok)
+		hosts = append(hosts, host.AsString())
+		scopes = append(scopes, l.InstrumentationScope().Name)
+	}
+	assert.NotEqual(t, hosts[0], hosts[1], "the logs should come from each host in turn")
+	assert.Equal(t, []string{hosts[0], hosts[1], hosts[0], hosts[1], hosts[0], hosts[1]}, hosts)
+	assert.Equal(t, []string{"trazr-gen/scope-1", "trazr-gen/scope-1", "trazr-gen/scope-2", "trazr-gen/scope-2", "trazr-gen/scope-1", "trazr-gen/scope-1"}, scopes)
+}
+
+func TestBatchedLogs(t *testing.T) {
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumLogs:        10,
+		Body:           "batched",
+		SeverityText:   "Info",
+		SeverityNumber: "9",
+		Batch:          true,
+		BatchSize:      5,
+		BatchTimeout:   time.Minute,
+	}
+
+	m := &mockExporter{}
+
+	// test
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, m.logs, 10, "shutting down the processor flushes every queued log")
+	assert.LessOrEqual(t, m.exports, 3, "logs should be exported in batches, had %d exports", m.exports)
+	for _, l := range m.logs {
+		assert.Equal(t, "batched", l.Body().AsString())
+	}
+}
+
+// slowExporter is a mockExporter taking its time on each export, for the workers to fill
+// the queue of the batch processor faster than it is exported.
+type slowExporter struct {
+	mockExporter
+}
+
+func (e *slowExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	time.Sleep(time.Millisecond)
+	return e.mockExporter.Export(ctx, records)
+}
+
+func TestBoundedMemoryWaitsForQueue(t *testing.T) {
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:   2,
+			BoundedMemory: true,
+			MaxQueueSize:  10,
+		},
+		NumLogs:        500,
+		SeverityText:   "Info",
+		SeverityNumber: "9",
+		Batch:          true,
+		BatchSize:      10,
+		BatchTimeout:   time.Minute,
+	}
+	m := &slowExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+	assert.Len(t, m.logs, 1000, "the workers wait for room in the queue instead of records being dropped")
+}
+
+// heapSamplingExporter discards the records it exports, after sampling the live heap of
+// the process as of the last garbage collection. Unlike the span exporter of the traces
+// tests, it doesn't force one: the batch log processor polls its queue while an export
+// is in progress, which slows a forced collection down to hundreds of milliseconds.
+type heapSamplingExporter struct {
+	mu      sync.Mutex
+	maxHeap uint64 // largest live heap sampled
+}
+
+func (e *heapSamplingExporter) Export(context.Context, []sdklog.Record) error {
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	metrics.Read(sample)
+	e.mu.Lock()
+	e.maxHeap = max(e.maxHeap, sample[0].Value.Uint64())
+	e.mu.Unlock()
+	return nil
+}
+
+func (*heapSamplingExporter) Shutdown(context.Context) error { return nil }
+
+func (*heapSamplingExporter) ForceFlush(context.Context) error { return nil }
+
+// runBoundedMemory generates numLogs log records through the batch log processor of
+// `bounded-memory`, and returns the largest live heap sampled along the way.
+func runBoundedMemory(tb testing.TB, numLogs int) uint64 {
+	tb.Helper()
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:   1,
+			BoundedMemory: true,
+		},
+		NumLogs:        numLogs,
+		SeverityText:   "Info",
+		SeverityNumber: "9",
+		Batch:          true,
+		BatchSize:      512,
+		BatchTimeout:   time.Second,
+	}
+	exporter := &heapSamplingExporter{}
+	_, err := run(cfg, exporter, nil, zap.NewNop())
+	require.NoError(tb, err)
+	return exporter.maxHeap
+}
+
+func TestBoundedMemory(t *testing.T) {
+	small := runBoundedMemory(t, 5_000)
+	large := runBoundedMemory(t, 50_000)
+	assert.Less(t, large, small+small/2+4<<20, "the live heap of 10 times more log records should stay flat: %d then %d bytes", small, large)
+}
+
+// BenchmarkBoundedMemory generates b.N log records like TestBoundedMemory and reports the
+// largest live heap sampled, which stays flat as b.N grows, e.g. from -benchtime=1000x
+// to -benchtime=100000x.
+func BenchmarkBoundedMemory(b *testing.B) {
+	b.ReportAllocs()
+	b.ReportMetric(float64(runBoundedMemory(b, b.N)), "max-heap-B")
+}
+
+func TestLogsFromStdin(t *testing.T) {
+	orig := stdin
+	t.Cleanup(func() { stdin = orig })
+	stdin = strings.NewReader("plain line\n\n{\"body\":\"json body\",\"user\":\"alice\",\"count\":3}\n")
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		Body:           "ignored",
+		SeverityText:   "Info",
+		SeverityNumber: "9",
+		FromStdin:      true,
+	}
+
+	m := &mockExporter{}
+
+	// test
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, m.logs, 2)
+	assert.Equal(t, "plain line", m.logs[0].Body().AsString())
+	assert.Equal(t, 0, m.logs[0].AttributesLen())
+	assert.Equal(t, "json body", m.logs[1].Body().AsString())
+	got := map[string]log.Value{}
+	m.logs[1].WalkAttributes(func(attr log.KeyValue) bool {
+		got[attr.Key] = attr.Value
+		return true
+	})
+	assert.Equal(t, "alice", got["user"].AsString())
+	assert.Equal(t, int64(3), got["count"].AsInt64())
+}
+
+func TestParseStdinLine(t *testing.T) {
+	body, attrs := parseStdinLine("not json")
+	assert.Equal(t, "not json", body)
+	assert.Empty(t, attrs)
+
+	body, attrs = parseStdinLine(`{"level":"warn","ratio":0.5,"ok":true,"tags":["a"]}`)
+	assert.Equal(t, `{"level":"warn","ratio":0.5,"ok":true,"tags":["a"]}`, body, "a JSON line without a body field is kept whole")
+	require.Len(t, attrs, 4)
+	assert.Equal(t, "level", attrs[0].Key)
+	assert.Equal(t, "warn", attrs[0].Value.AsString())
+	assert.True(t, attrs[1].Value.AsBool())
+	assert.InDelta(t, 0.5, attrs[2].Value.AsFloat64(), 0)
+	assert.Equal(t, `["a"]`, attrs[3].Value.AsString())
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -284,12 +667,107 @@ func TestValidate(t *testing.T) {
 			},
 			wantErrMessage: "SpanID must be a 16 character hex string, like: '5828fa4960140870'",
 		},
+		{
+			name: "Bounded memory without batch",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount:   1,
+					BoundedMemory: true,
+				},
+				NumLogs: 5,
+			},
+			wantErrMessage: "`bounded-memory` caps the queue of the batch log processor, it requires `batch`",
+		},
+		{
+			name: "Batch without size",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumLogs:      5,
+				Batch:        true,
+				BatchTimeout: time.Second,
+			},
+			wantErrMessage: "`batch-size` and `batch-timeout` must be greater than 0 when `batch` is enabled",
+		},
+		{
+			name: "Unknown body preset",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					MockData:    true,
+				},
+				NumLogs:    5,
+				BodyPreset: "loud",
+			},
+			wantErrMessage: "unknown `body-preset` \"loud\", must be 'severity'",
+		},
+		{
+			name: "Body preset without mock data",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumLogs:    5,
+				BodyPreset: bodyPresetSeverity,
+			},
+			wantErrMessage: "`body-preset` requires `mock-data`",
+		},
+		{
+			name: "Debug templates without mock data",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumLogs:        5,
+				DebugTemplates: true,
+			},
+			wantErrMessage: "`debug-templates` requires `mock-data`",
+		},
+		{
+			name: "Debug templates from stdin",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					MockData:    true,
+				},
+				FromStdin:      true,
+				DebugTemplates: true,
+			},
+			wantErrMessage: "`debug-templates` can't be combined with `from-stdin`, whose bodies aren't templates",
+		},
+		{
+			name: "Log scopes with scopes per resource",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount:       1,
+					ScopesPerResource: 2,
+				},
+				NumLogs:   5,
+				LogScopes: []string{"pkg.a"},
+			},
+			wantErrMessage: "`log-scopes` can't be combined with `scopes-per-resource`",
+		},
+		{
+			name: "Empty log scope",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumLogs:   5,
+				LogScopes: []string{"pkg.a", " "},
+			},
+			wantErrMessage: "`log-scopes` must not contain empty scope names",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &mockExporter{}
 			logger, _ := zap.NewDevelopment()
-			require.EqualError(t, run(tt.cfg, m, logger), tt.wantErrMessage)
+			_, err := run(tt.cfg, m, nil, logger)
+			require.EqualError(t, err, tt.wantErrMessage)
+			var validationErr *common.ValidationError
+			assert.ErrorAs(t, err, &validationErr, "validation errors are typed")
 		})
 	}
 }
@@ -451,3 +929,134 @@ func TestWorker_ReportProgressf(t *testing.T) {
 		t.Fatalf("expected 'hello world', got %q", got)
 	}
 }
+
+func TestMaxErrors(t *testing.T) {
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:   1,
+			Rate:          100,
+			TotalDuration: time.Minute,
+			MaxErrors:     1,
+		},
+		SeverityText:   "Info",
+		SeverityNumber: "9",
+	}
+	exportErrors := common.NewExportErrors()
+	exportErrors.AbortAfter(int64(cfg.MaxErrors))
+
+	start := time.Now()
+	_, err := run(cfg, errorCountingExporter{Exporter: &failingExporter{err: context.DeadlineExceeded}, errors: exportErrors}, exportErrors, zap.NewNop())
+	require.ErrorIs(t, err, common.ErrMaxErrors)
+	assert.Less(t, time.Since(start), 10*time.Second, "the run must be aborted long before its duration")
+}
+
+func TestDuplicateRatio(t *testing.T) {
+	cfg := configWithOneAttribute(3, "duplicated body")
+	cfg.DuplicateRatio = 1
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.logs, 6, "every record should be sent twice")
+	for i := 0; i < len(m.logs); i += 2 {
+		assert.Equal(t, m.logs[i].Timestamp(), m.logs[i+1].Timestamp())
+		assert.Equal(t, m.logs[i].Body(), m.logs[i+1].Body())
+	}
+}
+
+func TestDropRatio(t *testing.T) {
+	cfg := configWithOneAttribute(3, "dropped body")
+	cfg.DropRatio = 1
+	m := &mockExporter{}
+	exportErrors := common.NewExportErrors()
+
+	stats, err := run(cfg, m, exportErrors, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Empty(t, m.logs, "every record should be dropped")
+	assert.Equal(t, int64(3), stats.Generated, "the dropped records are generated nonetheless")
+	assert.Equal(t, int64(3), exportErrors.Skipped())
+	assert.Zero(t, exportErrors.Total(), "skipped records aren't export errors")
+}
+
+func TestAttributeDropProbability(t *testing.T) {
+	cfg := configWithMultipleAttributes(3, "dropped attributes")
+	cfg.AttrDropChance = 1
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.logs, 3)
+	for _, l := range m.logs {
+		assert.Zero(t, l.AttributesLen())
+		assert.Equal(t, 2, l.DroppedAttributes())
+	}
+}
+
+func TestWithDroppedAttributes(t *testing.T) {
+	m := &mockExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(m)))
+	var record log.Record
+	record.AddAttributes(log.String("user.id", "1"), log.String("trazr.mock.data", "user.id"), log.String("user.name", "a"))
+	provider.Logger("test").Emit(context.Background(), record)
+	require.Len(t, m.logs, 1)
+	r := m.logs[0]
+
+	assert.Equal(t, r, withDroppedAttributes(r, 0))
+
+	got := withDroppedAttributes(r, 1)
+	var attrs []log.KeyValue
+	got.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs = append(attrs, kv)
+		return true
+	})
+	assert.Equal(t, []log.KeyValue{log.String("trazr.mock.data", "user.id")}, attrs, "the markers are kept")
+	assert.Equal(t, 2, got.DroppedAttributes())
+	assert.Equal(t, r.Timestamp(), got.Timestamp())
+	assert.Equal(t, r.Resource(), got.Resource())
+	assert.Equal(t, 3, r.AttributesLen(), "the record exported is a copy")
+}
+
+func TestSeverityDistribution(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dist.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Error": 3, "warn": 1, "21": 0}`), 0o600))
+	cfg := configWithOneAttribute(200, "body")
+	cfg.SeverityDistributionFile = path
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.logs, 200)
+	counts := map[log.Severity]int{}
+	for _, record := range m.logs {
+		counts[record.Severity()]++
+		assert.Equal(t, severityTextFromNumber(int32(record.Severity())), record.SeverityText())
+	}
+	assert.Len(t, counts, 2, "only Error and Warn have a positive weight")
+	assert.Greater(t, counts[log.SeverityError1], counts[log.SeverityWarn1])
+}
+
+func TestLoadSeverityDistributionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "Not an object", content: `["Info"]`, wantErr: "expected a JSON object of weights by severity"},
+		{name: "Unknown severity", content: `{"Loud": 1}`, wantErr: `unknown severity "Loud"`},
+		{name: "Out of range number", content: `{"25": 1}`, wantErr: "severity number 25 is out of range"},
+		{name: "Negative weight", content: `{"Info": -1}`, wantErr: `the weight of "Info" must not be negative`},
+		{name: "No weight", content: `{"Info": 0}`, wantErr: "no severity has a positive weight"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dist.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			_, err := loadSeverityDistribution(path)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
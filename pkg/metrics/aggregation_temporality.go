@@ -24,6 +24,11 @@ func (t *AggregationTemporality) Set(v string) error {
 	}
 }
 
+// UnmarshalText lets config files set the temporality by name, like the flag does.
+func (t *AggregationTemporality) UnmarshalText(text []byte) error {
+	return t.Set(string(text))
+}
+
 func (t *AggregationTemporality) String() string {
 	return string(metricdata.Temporality(*t))
 }
@@ -0,0 +1,46 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import "time"
+
+// pacingStep bounds each sleep of a worker waiting for the next interval, so that it
+// still stops soon after the generation does.
+const pacingStep = 100 * time.Millisecond
+
+// alignedTime returns the timestamp of a data point generated at now with
+// `metrics-interval`: the end of the interval now falls in, like a periodic reader
+// collecting at the interval boundaries, or one interval after the previous data point
+// when now is that boundary. The worker waits for the interval of the previous data
+// point to end before generating the next one, see waitUntil, so that the timestamp is
+// never past the end of the current interval.
+func alignedTime(now, prev time.Time, interval time.Duration) time.Time {
+	t := now.Truncate(interval)
+	if t.Before(now) {
+		t = t.Add(interval)
+	}
+	if !t.After(prev) {
+		t = prev.Add(interval)
+	}
+	return t
+}
+
+// waitUntil sleeps from now until the clock of the worker reaches t, or the generation
+// stops, and returns the time then along with whether the generation is still running.
+func (w worker) waitUntil(now, t time.Time) (time.Time, bool) {
+	for now.Before(t) {
+		if !w.running.Load() {
+			return now, false
+		}
+		w.clock.Sleep(min(t.Sub(now), pacingStep))
+		now = w.clock.Now()
+	}
+	return now, true
+}
+
+// intervalCycle returns the number of the interval, counting from 1, of a data point
+// aligned at t, for a stream whose first data point was aligned at first.
+func intervalCycle(first, t time.Time, interval time.Duration) int {
+	return int(t.Sub(first)/interval) + 1
+}
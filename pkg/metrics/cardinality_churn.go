@@ -0,0 +1,45 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// seriesAttributeKey is the data point attribute changing every
+// `cardinality-churn-interval`, making each of its values a new series.
+const seriesAttributeKey = "trazr.series"
+
+// seriesChurn replaces the series of a worker every `cardinality-churn-interval`, like
+// label churn does: the previous series goes stale and a new one starts from zero.
+type seriesChurn struct {
+	interval time.Duration
+	series   int64     // number of the current series
+	start    time.Time // start of the current series
+	first    int64     // data point counter at the start of the current series
+}
+
+func newSeriesChurn(interval time.Duration, start time.Time) *seriesChurn {
+	return &seriesChurn{interval: interval, start: start}
+}
+
+// next moves to a new series when the current one has lasted the interval at now, and
+// reports whether it did. i is the data point counter of the worker.
+func (s *seriesChurn) next(now time.Time, i int64) bool {
+	if now.Sub(s.start) < s.interval {
+		return false
+	}
+	s.series++
+	s.start = now
+	s.first = i
+	return true
+}
+
+// withSeries returns a copy of attrs tagged with the current series.
+func (s *seriesChurn) withSeries(attrs []attribute.KeyValue) []attribute.KeyValue {
+	return append(slices.Clip(attrs), attribute.Int64(seriesAttributeKey, s.series))
+}
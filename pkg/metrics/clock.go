@@ -9,6 +9,7 @@ import (
 
 type Clock interface {
 	Now() time.Time
+	Sleep(d time.Duration)
 }
 
 type realClock struct{}
@@ -17,6 +18,10 @@ func (c *realClock) Now() time.Time {
 	return time.Now()
 }
 
+func (c *realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
 type mockClock struct {
 	now time.Time
 }
@@ -25,3 +30,7 @@ func (c *mockClock) Now() time.Time {
 	c.now = c.now.Add(100 * time.Millisecond) // Add 100ms to the mock clock to avoid timestamp collisions
 	return c.now
 }
+
+func (c *mockClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+}
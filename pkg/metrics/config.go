@@ -4,14 +4,22 @@
 package metrics
 
 import (
-	"errors"
+	"fmt"
+	"math"
+	"time"
 
 	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.13.0"
+	"go.uber.org/zap"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
 
+// defaultNumMetrics is the default number of metrics generated by each worker.
+const defaultNumMetrics = 1
+
 // Config holds all metrics subcommand configuration for CLI and config file.
 // All fields must have a `mapstructure` tag matching the CLI/config key (dashed, lower-case).
 type Config struct {
@@ -22,6 +30,23 @@ type Config struct {
 	AggregationTemporality AggregationTemporality `mapstructure:"aggregation-temporality"`
 	SpanID                 string                 `mapstructure:"span-id"`
 	TraceID                string                 `mapstructure:"trace-id"`
+	ExemplarSeed           int64                  `mapstructure:"exemplar-seed"`
+	MetricStartTime        string                 `mapstructure:"metric-start-time"`
+	GaugeWalk              int64                  `mapstructure:"gauge-walk"`
+	NoReset                bool                   `mapstructure:"metrics-no-reset"`
+	BothTemporalities      bool                   `mapstructure:"metric-both-temporalities"`
+	StableAttributes       bool                   `mapstructure:"metric-stable-attributes"`
+	ChurnInterval          time.Duration          `mapstructure:"cardinality-churn-interval"`
+	AlignInterval          time.Duration          `mapstructure:"metrics-interval"`
+	Intervals              int                    `mapstructure:"intervals"`
+	TimestampEdgeCase      string                 `mapstructure:"metric-timestamp-edge-case"`
+	SpecialValueRatio      float64                `mapstructure:"metric-special-value-ratio"`
+	HistogramBuckets       []float64              `mapstructure:"histogram-buckets"`
+	ExponentialScale       int32                  `mapstructure:"exponential-histogram-scale"`
+	SummaryQuantiles       []float64              `mapstructure:"summary-quantiles"`
+	DatapointAttributes    common.KeyValue        `mapstructure:"metric-datapoint-attributes"`
+	SchemaURL              string                 `mapstructure:"metrics-schema-url"`
+	MetricsLogLevel        string                 `mapstructure:"metrics-log-level"`
 }
 
 // NewConfig creates a new Config with default values.
@@ -35,15 +60,32 @@ func NewConfig() *Config {
 func (c *Config) Flags(fs *pflag.FlagSet) {
 	c.CommonFlags(fs)
 
-	fs.StringVar(&c.HTTPPath, "otlp-http-url-path", c.HTTPPath, "Which URL path to write to")
+	fs.StringVar(&c.HTTPPath, "otlp-http-url-path", c.HTTPPath, "Which URL path to write to, where {signal} stands for 'metrics'")
 
 	fs.IntVar(&c.NumMetrics, "metrics", c.NumMetrics, "Number of metrics to generate in each worker (ignored if duration is provided)")
 
 	fs.StringVar(&c.TraceID, "trace-id", c.TraceID, "TraceID to use as exemplar")
 	fs.StringVar(&c.SpanID, "span-id", c.SpanID, "SpanID to use as exemplar")
+	fs.Int64Var(&c.ExemplarSeed, "exemplar-seed", c.ExemplarSeed, "Seed of the trace and span IDs of an exemplar attached to each data point, each of another trace, drawing the same IDs on every run (0 disables). Replaces the static --trace-id and --span-id exemplar")
 
-	fs.Var(&c.MetricType, "metric-type", "Metric type enum. must be one of 'Gauge' or 'Sum'")
+	fs.Var(&c.MetricType, "metric-type", "Metric type enum. must be one of 'Gauge', 'Sum', 'Histogram', 'ExponentialHistogram' or 'Summary'")
+	fs.Float64SliceVar(&c.HistogramBuckets, "histogram-buckets", c.HistogramBuckets, "Bucket boundaries of Histogram data points, in increasing order, as whole numbers (comma-separated or repeatable), each point drawing its whole-number measurements evenly across the buckets. Defaults to the boundaries of the SDK explicit bucket histograms")
+	fs.Int32Var(&c.ExponentialScale, "exponential-histogram-scale", c.ExponentialScale, "Scale of ExponentialHistogram data points, between -10 and 10: each power of two is split into 2^scale buckets")
+	fs.Float64SliceVar(&c.SummaryQuantiles, "summary-quantiles", c.SummaryQuantiles, "Quantiles of Summary data points, between 0 and 1 (comma-separated or repeatable). Defaults to 0.5,0.9,0.99")
 	fs.Var(&c.AggregationTemporality, "aggregation-temporality", "aggregation-temporality for metrics. Must be one of 'delta' or 'cumulative'")
+	fs.BoolVar(&c.NoReset, "metrics-no-reset", c.NoReset, "With delta temporality, start each data point where the previous one ended instead of an independent one-second window")
+	fs.BoolVar(&c.BothTemporalities, "metric-both-temporalities", c.BothTemporalities, "Emit each Sum or Histogram data point twice, as a delta and a cumulative stream told apart by the trazr.temporality attribute, to compare temporality conversion. Supersedes --aggregation-temporality")
+	fs.BoolVar(&c.StableAttributes, "metric-stable-attributes", c.StableAttributes, "Draw the data point attributes, mock data included, once per worker stream instead of for each data point, so that cumulative streams keep the same identity while their values vary")
+	fs.DurationVar(&c.AlignInterval, "metrics-interval", c.AlignInterval, "Generate one data point per interval, each worker stamped at the end of the current interval like a periodic reader exporting every interval, and make delta windows one interval long (0 uses the generation time)")
+	fs.IntVar(&c.Intervals, "intervals", c.Intervals, "Stop each worker after this many --metrics-interval cycles of the wall clock, one data point each, instead of a --metrics count (0 disables)")
+	fs.DurationVar(&c.ChurnInterval, "cardinality-churn-interval", c.ChurnInterval, "Replace the series of each worker by a new one at this interval, changing the trazr.series data point attribute and restarting cumulative streams, to simulate label churn and stale series (0 disables)")
+	fs.StringVar(&c.TimestampEdgeCase, "metric-timestamp-edge-case", c.TimestampEdgeCase, "Emit degenerate data points of the metric types with a start timestamp, all but Gauge, to test how they are handled: 'zero-duration' for a start timestamp equal to the collection one, 'start-after-time' for a start after it (invalid)")
+	fs.Float64Var(&c.SpecialValueRatio, "metric-special-value-ratio", c.SpecialValueRatio, "Share of the Gauge or Histogram data points, between 0 and 1, given a NaN, +Inf or -Inf value (as the histogram sum), to test how backends handle them")
+	fs.Int64Var(&c.GaugeWalk, "gauge-walk", c.GaugeWalk, "Make gauge values a random walk moving at most this much per data point (0 disables, values follow the data point counter)")
+	fs.Var(&c.DatapointAttributes, "metric-datapoint-attributes", "Data point attribute (key=\"value\"), used instead of --telemetry-attributes on metric data points. Repeat for multiple attributes.")
+	fs.StringVar(&c.MetricStartTime, "metric-start-time", c.MetricStartTime, "Start timestamp of cumulative streams, as an RFC3339 time or a duration before the run start (e.g. 24h). Defaults to the run start")
+	fs.StringVar(&c.SchemaURL, "metrics-schema-url", c.SchemaURL, "Schema URL of the metrics resource, to test schema translation between signals. Defaults to the semantic conventions version trazr-gen is built with")
+	fs.StringVar(&c.MetricsLogLevel, "metrics-log-level", c.MetricsLogLevel, "Log level of the metrics generator, overriding --log-level: debug, info, warn, error")
 }
 
 // SetDefaults sets the default values for the configuration
@@ -52,7 +94,7 @@ func (c *Config) Flags(fs *pflag.FlagSet) {
 func (c *Config) SetDefaults() {
 	c.Config.SetDefaults()
 	c.HTTPPath = "/v1/metrics"
-	c.NumMetrics = 1
+	c.NumMetrics = defaultNumMetrics
 
 	c.MetricName = "gen"
 	// Use Gauge as default metric type.
@@ -62,29 +104,243 @@ func (c *Config) SetDefaults() {
 
 	c.TraceID = ""
 	c.SpanID = ""
+	c.ExemplarSeed = 0
+	c.MetricStartTime = ""
+	c.GaugeWalk = 0
+	c.NoReset = false
+	c.BothTemporalities = false
+	c.StableAttributes = false
+	c.ChurnInterval = 0
+	c.AlignInterval = 0
+	c.Intervals = 0
+	c.TimestampEdgeCase = ""
+	c.SpecialValueRatio = 0
+	c.HistogramBuckets = nil
+	c.ExponentialScale = 0
+	c.SummaryQuantiles = nil
+	c.DatapointAttributes = make(common.KeyValue)
+	c.SchemaURL = ""
+	c.MetricsLogLevel = ""
 }
 
-// Validate validates the test scenario parameters.
+// ValidationError is the error of an invalid option returned by Validate, and by Start
+// for the TLS options, whose Field tells which option it is with errors.As.
+type ValidationError = common.ValidationError
+
+// Validate validates the test scenario parameters. Its errors are *ValidationError.
 func (c *Config) Validate() error {
-	if c.TotalDuration <= 0 && c.NumMetrics <= 0 {
-		return errors.New("either `metrics` or `duration` must be greater than 0")
+	if c.TotalDuration <= 0 && c.TotalBytes == "" && c.NumMetrics <= 0 && c.Intervals <= 0 {
+		return common.ValidationErrorf("metrics", "either `metrics`, `intervals` or `duration` must be greater than 0")
+	}
+
+	if c.WarmupDuration < 0 {
+		return common.ValidationErrorf("warmup-duration", "`warmup-duration` must not be negative")
+	}
+
+	if c.MaxErrors < 0 {
+		return common.ValidationErrorf("max-errors", "`max-errors` must not be negative")
+	}
+
+	if c.DuplicateRatio < 0 || c.DuplicateRatio > 1 {
+		return common.ValidationErrorf("duplicate-ratio", "`duplicate-ratio` must be between 0 and 1")
+	}
+
+	if c.DropRatio < 0 || c.DropRatio > 1 {
+		return common.ValidationErrorf("drop-ratio", "`drop-ratio` must be between 0 and 1")
+	}
+
+	if c.ScopesPerResource < 0 {
+		return common.ValidationErrorf("scopes-per-resource", "`scopes-per-resource` must not be negative")
+	}
+
+	if c.FleetSize < 0 {
+		return common.ValidationErrorf("fleet-size", "`fleet-size` must not be negative")
+	}
+
+	if _, err := c.TotalBytesLimit(); err != nil {
+		return err
+	}
+
+	if c.TUI && !c.TerminalOutput {
+		return common.ValidationErrorf("tui", "`tui` requires `terminal-output`")
+	}
+
+	if err := common.ValidateEndpoint(c.Endpoint()); err != nil {
+		return common.NewValidationError("otlp-endpoint", err)
+	}
+
+	if err := common.ValidateOTLPCompat(c.OTLPCompat); err != nil {
+		return err
+	}
+	if c.MetricType == MetricTypeExponentialHistogram && !c.SupportsOTLP(common.FeatureExponentialHistogram) {
+		return common.ValidationErrorf("otlp-compat", "OTLP %s predates the ExponentialHistogram `metric-type`, added in %s", c.OTLPCompat, common.FeatureExponentialHistogram.Since)
+	}
+
+	if err := common.ValidateExportTraceparent(c.ExportTraceparent, c.ExportTracestate); err != nil {
+		return err
+	}
+
+	if err := common.ValidateGRPCMetadata(c.GRPCMetadata); err != nil {
+		return err
+	}
+
+	if err := common.ValidateSensitiveData(c.SensitiveData); err != nil {
+		return err
+	}
+
+	if err := common.ValidateSchemaURL("metrics-schema-url", c.SchemaURL); err != nil {
+		return err
+	}
+
+	if err := common.ValidateVerifyEndpoint(c.VerifyEndpoint); err != nil {
+		return err
+	}
+
+	if err := c.ValidateBoundedMemory(); err != nil {
+		return err
+	}
+	if c.BoundedMemory {
+		return common.ValidationErrorf("bounded-memory", "`bounded-memory` caps the queues of the batch span and log processors, metrics are exported as they are generated and queue nothing")
+	}
+
+	if err := c.ValidateRateSchedule(); err != nil {
+		return err
 	}
 
 	if c.TraceID != "" {
 		if err := common.ValidateTraceID(c.TraceID); err != nil {
-			return err
+			return common.NewValidationError("trace-id", err)
 		}
 	}
 
 	if c.SpanID != "" {
 		if err := common.ValidateSpanID(c.SpanID); err != nil {
-			return err
+			return common.NewValidationError("span-id", err)
+		}
+	}
+
+	if c.ExemplarSeed != 0 && (c.TraceID != "" || c.SpanID != "") {
+		return common.ValidationErrorf("exemplar-seed", "`exemplar-seed` can't be combined with `trace-id` or `span-id`")
+	}
+
+	if c.BothTemporalities && c.MetricType != MetricTypeSum && c.MetricType != MetricTypeHistogram {
+		return common.ValidationErrorf("metric-both-temporalities", "`metric-both-temporalities` requires a Sum or Histogram `metric-type`")
+	}
+
+	if c.AlignInterval < 0 {
+		return common.ValidationErrorf("metrics-interval", "`metrics-interval` must not be negative")
+	}
+
+	if c.Intervals < 0 {
+		return common.ValidationErrorf("intervals", "`intervals` must not be negative")
+	}
+	if c.Intervals > 0 {
+		if c.AlignInterval == 0 {
+			return common.ValidationErrorf("intervals", "`intervals` requires `metrics-interval`")
+		}
+		if c.TotalDuration > 0 || c.TotalBytes != "" {
+			return common.ValidationErrorf("intervals", "`intervals` can't be combined with `duration` or `total-bytes`")
 		}
 	}
 
+	if c.ChurnInterval < 0 {
+		return common.ValidationErrorf("cardinality-churn-interval", "`cardinality-churn-interval` must not be negative")
+	}
+	if c.ChurnInterval > 0 && c.BothTemporalities {
+		return common.ValidationErrorf("cardinality-churn-interval", "`cardinality-churn-interval` can't be combined with `metric-both-temporalities`")
+	}
+
+	switch c.TimestampEdgeCase {
+	case "":
+	case timestampEdgeCaseZeroDuration, timestampEdgeCaseStartAfterTime:
+		if c.MetricType == MetricTypeGauge {
+			return common.ValidationErrorf("metric-timestamp-edge-case", "`metric-timestamp-edge-case` requires a `metric-type` with a start timestamp, not Gauge")
+		}
+		if c.BothTemporalities {
+			return common.ValidationErrorf("metric-timestamp-edge-case", "`metric-timestamp-edge-case` can't be combined with `metric-both-temporalities`")
+		}
+	default:
+		return common.ValidationErrorf("metric-timestamp-edge-case", "expected `metric-timestamp-edge-case` to be one of zero-duration or start-after-time, got %q instead", c.TimestampEdgeCase)
+	}
+
+	if c.SpecialValueRatio < 0 || c.SpecialValueRatio > 1 {
+		return common.ValidationErrorf("metric-special-value-ratio", "`metric-special-value-ratio` must be between 0 and 1")
+	}
+	if c.SpecialValueRatio > 0 {
+		if c.MetricType != MetricTypeGauge && c.MetricType != MetricTypeHistogram {
+			return common.ValidationErrorf("metric-special-value-ratio", "`metric-special-value-ratio` requires a Gauge or Histogram `metric-type`")
+		}
+		if c.BothTemporalities {
+			return common.ValidationErrorf("metric-special-value-ratio", "`metric-special-value-ratio` can't be combined with `metric-both-temporalities`")
+		}
+	}
+
+	if len(c.HistogramBuckets) > 0 && c.MetricType != MetricTypeHistogram {
+		return common.ValidationErrorf("histogram-buckets", "`histogram-buckets` requires a Histogram `metric-type`")
+	}
+	for k, bound := range c.HistogramBuckets {
+		// the data points are integer histograms, whose measurements are whole numbers
+		if bound != math.Trunc(bound) || math.Abs(bound) > maxHistogramBound {
+			return common.ValidationErrorf("histogram-buckets", "`histogram-buckets` must be whole numbers between %d and %d, got %v", -maxHistogramBound, maxHistogramBound, bound)
+		}
+		if k > 0 && bound <= c.HistogramBuckets[k-1] {
+			return common.ValidationErrorf("histogram-buckets", "`histogram-buckets` must be in strictly increasing order, got %v after %v", bound, c.HistogramBuckets[k-1])
+		}
+	}
+
+	if c.ExponentialScale < minExponentialScale || c.ExponentialScale > maxExponentialScale {
+		return common.ValidationErrorf("exponential-histogram-scale", "`exponential-histogram-scale` must be between %d and %d", minExponentialScale, maxExponentialScale)
+	}
+	if c.ExponentialScale != 0 && c.MetricType != MetricTypeExponentialHistogram {
+		return common.ValidationErrorf("exponential-histogram-scale", "`exponential-histogram-scale` requires an ExponentialHistogram `metric-type`")
+	}
+
+	if c.MetricType == MetricTypeSummary && c.AggregationTemporality.AsTemporality() == metricdata.DeltaTemporality {
+		return common.ValidationErrorf("aggregation-temporality", "the count and sum of a Summary `metric-type` are cumulative, it requires a cumulative `aggregation-temporality`")
+	}
+	if len(c.SummaryQuantiles) > 0 && c.MetricType != MetricTypeSummary {
+		return common.ValidationErrorf("summary-quantiles", "`summary-quantiles` requires a Summary `metric-type`")
+	}
+	for _, q := range c.SummaryQuantiles {
+		if !(q >= 0 && q <= 1) {
+			return common.ValidationErrorf("summary-quantiles", "`summary-quantiles` must be between 0 and 1, got %v", q)
+		}
+	}
+
+	if c.GaugeWalk < 0 {
+		return common.ValidationErrorf("gauge-walk", "`gauge-walk` must not be negative")
+	}
+
+	if _, err := c.StreamStartTime(time.Now()); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// StreamStartTime resolves the configured metric start time relative to now.
+// An empty value returns now, a duration is subtracted from now, and anything
+// else must be an RFC3339 timestamp.
+func (c *Config) StreamStartTime(now time.Time) (time.Time, error) {
+	if c.MetricStartTime == "" {
+		return now, nil
+	}
+	if d, err := time.ParseDuration(c.MetricStartTime); err == nil {
+		if d < 0 {
+			return time.Time{}, common.ValidationErrorf("metric-start-time", "`metric-start-time` duration must not be negative")
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, c.MetricStartTime)
+	if err != nil {
+		return time.Time{}, common.ValidationErrorf("metric-start-time", "`metric-start-time` must be an RFC3339 timestamp or a duration, got %q", c.MetricStartTime)
+	}
+	if t.After(now) {
+		return time.Time{}, common.ValidationErrorf("metric-start-time", "`metric-start-time` must not be in the future")
+	}
+	return t, nil
+}
+
 func (c *Config) GetHeaders() map[string]string {
 	return c.Config.GetHeaders()
 }
@@ -94,7 +350,43 @@ func (c *Config) IsMockDataEnabled() bool {
 	return c.MockData
 }
 
-// InitAttributes performs one-time initialization of attribute maps for metrics config.
+// InitAttributes performs one-time initialization of attribute maps for metrics config,
+// including the data point attributes.
 func (c *Config) InitAttributes() error {
-	return c.Config.InitAttributes()
+	if err := c.Config.InitAttributes(); err != nil {
+		return err
+	}
+	if len(c.DatapointAttributes) == 0 {
+		return nil
+	}
+	attrs, err := c.PrepareAttributes(c.DatapointAttributes)
+	if err != nil {
+		return fmt.Errorf("failed to prepare data point attributes: %w", err)
+	}
+	c.DatapointAttributes = attrs
+	return nil
+}
+
+// WarnBadAttributes logs the attributes, data point attributes included, that
+// `skip-bad-attributes` drops.
+func (c *Config) WarnBadAttributes(logger *zap.Logger) {
+	c.Config.WarnBadAttributes(logger)
+	if c.SkipBadAttributes && c.MockData {
+		common.WarnBadTemplates(logger, "data point", c.DatapointAttributes)
+	}
+}
+
+// GetDatapointAttrWithMockMarker returns the attributes of each data point: the
+// `metric-datapoint-attributes` when set, else the telemetry attributes.
+func (c *Config) GetDatapointAttrWithMockMarker() ([]attribute.KeyValue, error) {
+	if len(c.DatapointAttributes) == 0 {
+		return c.GetTelemetryAttrWithMockMarker()
+	}
+	return c.SignalAttrWithMockMarker(c.DatapointAttributes)
+}
+
+// ResourceSchemaURL returns the schema URL of the metrics resource: `metrics-schema-url`, or else
+// the one of the semantic conventions the resource attributes follow.
+func (c *Config) ResourceSchemaURL() string {
+	return common.ResourceSchemaURL(c.SchemaURL, semconv.SchemaURL)
 }
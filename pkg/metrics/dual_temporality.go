@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// temporalityAttributeKey is the data point attribute telling apart the delta and
+// cumulative streams of `metric-both-temporalities`.
+const temporalityAttributeKey = "trazr.temporality"
+
+// dualStreams builds the data points of `metric-both-temporalities`: the same metric as
+// a delta stream, whose points each cover the time since the previous one, and as a
+// cumulative stream of their running totals. Converting the delta stream to cumulative
+// must give back the cumulative one.
+type dualStreams struct {
+	start    time.Time       // start of the cumulative stream
+	prevTime time.Time       // end of the previous data point, where the next delta point starts
+	sum      int64           // running total of the cumulative sum
+	hist     histogramSample // running totals of the cumulative histogram
+}
+
+func newDualStreams(start time.Time, bounds []float64) *dualStreams {
+	return &dualStreams{
+		start:    start,
+		prevTime: start,
+		hist:     histogramSample{bounds: bounds, bucketCounts: make([]uint64, len(bounds)+1)},
+	}
+}
+
+// withTemporality returns a copy of attrs tagged with the temporality of their stream.
+func withTemporality(attrs []attribute.KeyValue, temporality metricdata.Temporality) attribute.Set {
+	name := "cumulative"
+	if temporality == metricdata.DeltaTemporality {
+		name = "delta"
+	}
+	return attribute.NewSet(append(slices.Clip(attrs), attribute.String(temporalityAttributeKey, name))...)
+}
+
+// next returns the delta and cumulative metrics of data point i, ending at now.
+func (d *dualStreams) next(w worker, i int64, now time.Time, attrs []attribute.KeyValue) []metricdata.Metrics {
+	deltaStart := d.prevTime
+	d.prevTime = now
+	deltaAttrs := withTemporality(attrs, metricdata.DeltaTemporality)
+	cumulativeAttrs := withTemporality(attrs, metricdata.CumulativeTemporality)
+
+	if w.metricType == MetricTypeHistogram {
+		sample := w.histogramSample(i)
+		for j, n := range sample.bucketCounts {
+			d.hist.bucketCounts[j] += n
+		}
+		if d.hist.count == 0 || sample.minVal < d.hist.minVal {
+			d.hist.minVal = sample.minVal
+		}
+		if d.hist.count == 0 || sample.maxVal > d.hist.maxVal {
+			d.hist.maxVal = sample.maxVal
+		}
+		d.hist.count += sample.count
+		d.hist.sum += sample.sum
+
+		cumulative := d.hist
+		cumulative.bucketCounts = slices.Clone(d.hist.bucketCounts)
+		return []metricdata.Metrics{
+			w.histogramMetric(metricdata.DeltaTemporality, deltaStart, now, sample, deltaAttrs),
+			w.histogramMetric(metricdata.CumulativeTemporality, d.start, now, cumulative, cumulativeAttrs),
+		}
+	}
+
+	// the cumulative sum follows the data point counter, like in single temporality mode
+	increment := i - d.sum
+	d.sum = i
+	return []metricdata.Metrics{
+		w.sumMetric(metricdata.DeltaTemporality, deltaStart, now, increment, deltaAttrs),
+		w.sumMetric(metricdata.CumulativeTemporality, d.start, now, d.sum, cumulativeAttrs),
+	}
+}
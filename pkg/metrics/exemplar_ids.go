@@ -0,0 +1,49 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exemplarIDs draws the trace and span IDs of the exemplars of `exemplar-seed`: each data
+// point gets an exemplar of another trace, and every run with the same seed draws the
+// same IDs, so that exemplar-to-trace links can be checked against known traces.
+type exemplarIDs struct {
+	rng *rand.Rand
+}
+
+// newExemplarIDs returns the ID generator of the given worker, each worker drawing IDs of
+// its own from the seed.
+func newExemplarIDs(seed int64, worker int) *exemplarIDs {
+	//nolint:gosec // the IDs must be reproducible from the seed, not unpredictable
+	return &exemplarIDs{rng: rand.New(rand.NewPCG(uint64(seed), uint64(worker)))}
+}
+
+// next returns the exemplars of a data point collected at now.
+func (g *exemplarIDs) next(now time.Time) []metricdata.Exemplar[int64] {
+	traceID := make([]byte, 16)
+	spanID := make([]byte, 8)
+	// all-zero IDs are invalid, draw again in the unlikely case
+	for binary.BigEndian.Uint64(traceID[:8])|binary.BigEndian.Uint64(traceID[8:]) == 0 {
+		binary.BigEndian.PutUint64(traceID[:8], g.rng.Uint64())
+		binary.BigEndian.PutUint64(traceID[8:], g.rng.Uint64())
+	}
+	for binary.BigEndian.Uint64(spanID) == 0 {
+		binary.BigEndian.PutUint64(spanID, g.rng.Uint64())
+	}
+	return []metricdata.Exemplar[int64]{{Value: 1, Time: now, TraceID: traceID, SpanID: spanID}}
+}
+
+// spanExemplars returns the exemplars of a data point collected at now, linking it to the
+// span of sc generated alongside it, for `correlate-exemplars` of the all command.
+func spanExemplars(sc trace.SpanContext, now time.Time) []metricdata.Exemplar[int64] {
+	traceID, spanID := sc.TraceID(), sc.SpanID()
+	return []metricdata.Exemplar[int64]{{Value: 1, Time: now, TraceID: traceID[:], SpanID: spanID[:]}}
+}
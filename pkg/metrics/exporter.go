@@ -4,18 +4,23 @@
 package metrics
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"strings"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
 
 func injectSensitiveHeaderMarker(cfg *Config) {
 	sensitiveKeys := []string{}
-	for _, k := range cfg.SensitiveData {
+	for _, entry := range cfg.SensitiveData {
+		k, _ := common.SensitiveKey(entry)
 		if _, ok := cfg.Headers[k]; ok {
 			sensitiveKeys = append(sensitiveKeys, k)
 		}
@@ -28,7 +33,7 @@ func injectSensitiveHeaderMarker(cfg *Config) {
 }
 
 // grpcExporterOptions creates the configuration options for a gRPC-based OTLP metric exporter.
-// It configures the exporter with the provided endpoint, connection security settings, and headers.
+// It configures the exporter with the provided endpoint, connection security settings, authority and headers.
 func grpcExporterOptions(cfg *Config) ([]otlpmetricgrpc.Option, error) {
 	grpcExpOpt := []otlpmetricgrpc.Option{
 		otlpmetricgrpc.WithEndpoint(cfg.Endpoint()),
@@ -46,6 +51,10 @@ func grpcExporterOptions(cfg *Config) ([]otlpmetricgrpc.Option, error) {
 		grpcExpOpt = append(grpcExpOpt, otlpmetricgrpc.WithTLSCredentials(credentials))
 	}
 
+	if dialOpts := cfg.ExportDialOptions(); len(dialOpts) > 0 {
+		grpcExpOpt = append(grpcExpOpt, otlpmetricgrpc.WithDialOption(dialOpts...))
+	}
+
 	injectSensitiveHeaderMarker(cfg)
 
 	headers, err := cfg.GetHeadersWithMockMarker()
@@ -64,13 +73,15 @@ func grpcExporterOptions(cfg *Config) ([]otlpmetricgrpc.Option, error) {
 func httpExporterOptions(cfg *Config) ([]otlpmetrichttp.Option, error) {
 	httpExpOpt := []otlpmetrichttp.Option{
 		otlpmetrichttp.WithEndpoint(cfg.Endpoint()),
-		otlpmetrichttp.WithURLPath(cfg.HTTPPath),
+		otlpmetrichttp.WithURLPath(cfg.URLPath("metrics")),
 	}
 
+	var tlsCfg *tls.Config
 	if cfg.Insecure {
 		httpExpOpt = append(httpExpOpt, otlpmetrichttp.WithInsecure())
 	} else {
-		tlsCfg, err := common.GetTLSCredentialsForHTTPExporter(
+		var err error
+		tlsCfg, err = common.GetTLSCredentialsForHTTPExporter(
 			cfg.CaFile, cfg.ClientAuth, cfg.InsecureSkipVerify,
 		)
 		if err != nil {
@@ -89,5 +100,80 @@ func httpExporterOptions(cfg *Config) ([]otlpmetrichttp.Option, error) {
 		httpExpOpt = append(httpExpOpt, otlpmetrichttp.WithHeaders(headers))
 	}
 
+	if client := cfg.ExportHTTPClient("metrics", tlsCfg); client != nil {
+		httpExpOpt = append(httpExpOpt, otlpmetrichttp.WithHTTPClient(client))
+	}
+
 	return httpExpOpt, nil
 }
+
+// errorCountingExporter counts the outcome of the exports of the decorated metric
+// exporter with common.ExportErrors.CountExport, by metric.
+type errorCountingExporter struct {
+	sdkmetric.Exporter
+	errors *common.ExportErrors
+}
+
+func (e errorCountingExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.errors.CountExport(e.Exporter.Export(ctx, rm), metricCount(rm))
+}
+
+// duplicatingExporter exports the `duplicate-ratio` share of the payloads twice, with the
+// same data points, see common.ExportDuplicated.
+type duplicatingExporter struct {
+	sdkmetric.Exporter
+	ratio float64
+}
+
+func (e duplicatingExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return common.ExportDuplicated(e.ratio, func() error { return e.Exporter.Export(ctx, rm) })
+}
+
+// droppingExporter leaves the `drop-ratio` share of the payloads, each holding the data
+// points of a single collection, out of the exports, like a lossy client, counting their
+// metrics as skipped.
+type droppingExporter struct {
+	sdkmetric.Exporter
+	ratio   float64
+	skipped *common.ExportErrors
+}
+
+func (e droppingExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	if !common.Chance(e.ratio) {
+		return e.Exporter.Export(ctx, rm)
+	}
+	e.skipped.Skip(metricCount(rm))
+	return nil
+}
+
+// metricCount returns the number of metrics of rm, the items the exports count.
+func metricCount(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		n += len(sm.Metrics)
+	}
+	return n
+}
+
+// discardExporter is a metric exporter that drops everything it is given.
+type discardExporter struct{}
+
+func (discardExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (discardExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (discardExporter) Export(context.Context, *metricdata.ResourceMetrics) error {
+	return nil
+}
+
+func (discardExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (discardExporter) Shutdown(context.Context) error {
+	return nil
+}
@@ -72,6 +72,25 @@ func TestGrpcExporterOptions_TLS_Error(t *testing.T) {
 	}
 }
 
+func TestGrpcExporterOptions_Authority(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	cfg.CustomEndpoint = "localhost:4317"
+	opts, err := grpcExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.GRPCAuthority = "collector.internal"
+	withAuthority, err := grpcExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(withAuthority) != len(opts)+1 {
+		t.Fatalf("expected one extra option for the authority, got %d instead of %d", len(withAuthority), len(opts)+1)
+	}
+}
+
 func TestHttpExporterOptions_Insecure(t *testing.T) {
 	cfg := &Config{}
 	cfg.SetDefaults()
@@ -126,3 +145,21 @@ func TestCreateExporter_gRPC(t *testing.T) {
 		t.Cleanup(func() { _ = exp.Shutdown(context.Background()) })
 	}
 }
+
+func TestHTTPExporterOptions_ExportTraceparent(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	opts, err := httpExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.ExportTraceparent = "random"
+	withTraceparent, err := httpExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(withTraceparent) != len(opts)+1 {
+		t.Fatalf("expected one extra option for the HTTP client, got %d instead of %d", len(withTraceparent), len(opts)+1)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"math"
+	"slices"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+const (
+	// pointMeasurements is the number of measurements summarized by each histogram or
+	// summary data point, like in histogramBucketSamples.
+	pointMeasurements = 10
+	// maxMeasurementExponent bounds the measurements of exponential histograms and
+	// summaries, drawn between 1 and 2^maxMeasurementExponent.
+	maxMeasurementExponent = 10
+	// minExponentialScale and maxExponentialScale bound `exponential-histogram-scale`. The
+	// scale of OTLP goes up to 20, but the buckets between the measurements are dense, and
+	// there are about 2^scale of them for each power of two the measurements span.
+	minExponentialScale = -10
+	maxExponentialScale = 10
+	// maxHistogramBound bounds the absolute value of `histogram-buckets`, whole numbers
+	// exactly represented as float64, with room for the measurements of the overflow bucket.
+	maxHistogramBound = 1 << 53
+)
+
+// defaultSummaryQuantiles are the quantiles of the summaries when `summary-quantiles` is
+// empty.
+var defaultSummaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// histogramSample is the content of a histogram data point.
+type histogramSample struct {
+	bounds       []float64
+	bucketCounts []uint64
+	count        uint64
+	sum          int64
+	minVal       int64
+	maxVal       int64
+}
+
+// bucketBounds returns the bucket boundaries of the histograms of the worker.
+func (w worker) bucketBounds() []float64 {
+	if len(w.histogramBounds) == 0 {
+		return histogramBounds
+	}
+	return w.histogramBounds
+}
+
+// histogramSample returns the content of histogram data point i: one of
+// histogramBucketSamples with the default bounds, or else measurements drawn into the
+// buckets of `histogram-buckets`.
+func (w worker) histogramSample(i int64) histogramSample {
+	if len(w.histogramBounds) == 0 {
+		sample := histogramBucketSamples[i%int64(len(histogramBucketSamples))]
+		s := histogramSample{bounds: histogramBounds, bucketCounts: sample.bucketCounts, sum: sample.sum}
+		for _, count := range sample.bucketCounts {
+			s.count += count
+		}
+		s.minVal, s.maxVal = histogramExtrema(histogramBounds, sample.bucketCounts)
+		return s
+	}
+	s := histogramSample{
+		bounds:       w.histogramBounds,
+		bucketCounts: make([]uint64, len(w.histogramBounds)+1),
+		count:        pointMeasurements,
+	}
+	for k := range pointMeasurements {
+		// the buckets are equally likely, whatever their width
+		b := common.RandIntN(len(s.bucketCounts))
+		s.bucketCounts[b]++
+		v := bucketValue(w.histogramBounds, b)
+		s.sum += v
+		if k == 0 || v < s.minVal {
+			s.minVal = v
+		}
+		if k == 0 || v > s.maxVal {
+			s.maxVal = v
+		}
+	}
+	return s
+}
+
+// bucketValue draws a whole measurement of bucket b of bounds, with the same limits as
+// histogramExtrema: the lower bound of the first bucket, and one above the upper bound
+// of the overflow one. The bounds are whole numbers small enough for these limits, as
+// checked by Config.Validate, so that every bucket holds the measurements drawn for it.
+func bucketValue(bounds []float64, b int) int64 {
+	switch b {
+	case 0:
+		return int64(bounds[0])
+	case len(bounds):
+		return int64(bounds[len(bounds)-1]) + 1
+	}
+	lower, upper := int64(bounds[b-1])+1, int64(bounds[b])
+	return lower + common.RandInt64N(upper-lower+1)
+}
+
+// drawMeasurements returns pointMeasurements measurements in increasing order, spread
+// evenly over the powers of two between 1 and 2^maxMeasurementExponent, like latencies.
+func drawMeasurements() []int64 {
+	values := make([]int64, pointMeasurements)
+	for k := range values {
+		values[k] = int64(math.Exp2(common.RandFloat64() * maxMeasurementExponent))
+	}
+	slices.Sort(values)
+	return values
+}
+
+// exponentialBucketIndex returns the index of the bucket of the positive value v at
+// scale, whose buckets are (base^index, base^(index+1)] with base = 2^(2^-scale).
+func exponentialBucketIndex(v float64, scale int32) int32 {
+	//nolint:gosec // safe: the measurements are below 2^maxMeasurementExponent, at most 2^maxExponentialScale buckets per power of two
+	return int32(math.Ceil(math.Log2(v)*math.Exp2(float64(scale)))) - 1
+}
+
+// measurementTotals are the totals of the measurements of an exponential histogram or
+// summary stream: those of the current data point with delta temporality, or else the
+// running totals since the start of the stream, like the cumulative histograms of
+// `metric-both-temporalities`.
+type measurementTotals struct {
+	scale   int32            // scale of the exponential histogram buckets
+	count   uint64           // number of measurements
+	sum     int64            // sum of the measurements
+	minVal  int64            // smallest measurement
+	maxVal  int64            // largest measurement
+	buckets map[int32]uint64 // measurement counts by exponential bucket index
+}
+
+// nextTotals returns the totals the next data point adds its measurements to: prev for
+// a cumulative stream, or new ones for the first data point of a stream, a new series or
+// a delta data point.
+func (w worker) nextTotals(prev *measurementTotals, newSeries bool) *measurementTotals {
+	if prev == nil || newSeries || w.aggregationTemporality.AsTemporality() == metricdata.DeltaTemporality {
+		return &measurementTotals{scale: w.exponentialScale, buckets: make(map[int32]uint64)}
+	}
+	return prev
+}
+
+// add adds values to the totals.
+func (t *measurementTotals) add(values []int64) {
+	for _, v := range values {
+		if t.count == 0 || v < t.minVal {
+			t.minVal = v
+		}
+		if t.count == 0 || v > t.maxVal {
+			t.maxVal = v
+		}
+		t.count++
+		t.sum += v
+		t.buckets[exponentialBucketIndex(float64(v), t.scale)]++
+	}
+}
+
+// exponentialHistogramPoint returns a data point of exponential histogram with the
+// totals t, without its attributes and timestamps.
+func (w worker) exponentialHistogramPoint(t *measurementTotals) metricdata.ExponentialHistogramDataPoint[int64] {
+	// the buckets between those of the extrema, empty ones included, are dense
+	offset := exponentialBucketIndex(float64(t.minVal), t.scale)
+	last := exponentialBucketIndex(float64(t.maxVal), t.scale)
+	counts := make([]uint64, last-offset+1)
+	for index, count := range t.buckets {
+		counts[index-offset] = count
+	}
+	return metricdata.ExponentialHistogramDataPoint[int64]{
+		Count:          t.count,
+		Sum:            t.sum,
+		Scale:          t.scale,
+		Min:            w.extrema(t.minVal),
+		Max:            w.extrema(t.maxVal),
+		PositiveBucket: metricdata.ExponentialBucket{Offset: offset, Counts: counts},
+	}
+}
+
+// summaryPoint returns a data point of summary with the count and sum of the totals t,
+// and quantiles, defaultSummaryQuantiles when empty, of values, the measurements of the
+// data point, like the quantiles of Prometheus summaries cover a recent window. It has
+// no attributes and timestamps.
+func summaryPoint(t *measurementTotals, values []int64, quantiles []float64) metricdata.SummaryDataPoint {
+	if len(quantiles) == 0 {
+		quantiles = defaultSummaryQuantiles
+	}
+	p := metricdata.SummaryDataPoint{
+		Count:          t.count,
+		Sum:            float64(t.sum),
+		QuantileValues: make([]metricdata.QuantileValue, len(quantiles)),
+	}
+	for k, q := range quantiles {
+		// the nearest rank, the smallest value with at least q of the values at or below it
+		rank := max(int(math.Ceil(q*float64(len(values))))-1, 0)
+		p.QuantileValues[k] = metricdata.QuantileValue{Quantile: q, Value: float64(values[rank])}
+	}
+	return p
+}
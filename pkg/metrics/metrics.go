@@ -6,6 +6,7 @@ package metrics
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -15,10 +16,10 @@ import (
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
-	"go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.13.0"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
+
+	"github.com/medxops/trazr-gen/internal/common"
 )
 
 const metricsHelpTemplate = `
@@ -51,23 +52,38 @@ Additional help topics:
 {{end}}
 Tip: Use "--mock-data" to generate fake values for attributes and headers!`
 
+// Examples are the command lines shown in the help of the metrics command.
+var Examples = []common.Example{
+	{Description: "Send 5 gauge data points to a local collector", Command: "trazr-gen metrics --metrics 5"},
+	{Description: "Generate a delta histogram for 5 minutes with mock request attributes", Command: "trazr-gen metrics --duration 5m --metric-type Histogram --aggregation-temporality delta --mock-data --telemetry-attributes 'http.route=\"/api/{{Word}}\"'"},
+	{Description: "Tag the resource with the environment and a mock host", Command: "trazr-gen metrics --metrics 10 --mock-data --otlp-attributes 'env=\"prod\",host.name=\"{{DomainName}}\"'"},
+	{Description: "Export over HTTP with TLS, verifying the collector with a private CA", Command: "trazr-gen metrics --otlp-protocol http/protobuf --otlp-endpoint collector.example.com:4318 --otlp-insecure=false --ca-cert ca.pem"},
+}
+
 // SetHelpTemplateForCmd sets the custom help template for the metrics command.
 func SetHelpTemplateForCmd(cmd interface{ SetHelpTemplate(string) }) {
 	cmd.SetHelpTemplate(metricsHelpTemplate)
 }
 
-// Start starts the metric telemetry generator
-func Start(cfg *Config, logger *zap.Logger) error {
+// Start starts the metric telemetry generator and returns the stats of all its runs.
+func Start(cfg *Config, logger *zap.Logger) (common.RunStats, error) {
+	var stats common.RunStats
 	if err := cfg.InitAttributes(); err != nil {
 		logger.Error("failed to initialize attributes", zap.Error(err))
-		return err
+		return stats, err
 	}
+	cfg.WarnBadAttributes(logger)
+	cfg.SeedRandomness()
 
-	expF := exporterFactory(cfg, logger)
-	exp, err := expF()
+	if err := cfg.ApplyProtocol(); err != nil {
+		logger.Error("failed to select the OTLP protocol", zap.Error(err))
+		return stats, err
+	}
+
+	verification, err := cfg.StartVerification("metrics")
 	if err != nil {
-		logger.Error("failed to create exporter", zap.Error(err))
-		return err
+		logger.Error("failed to start the verification", zap.Error(err))
+		return stats, err
 	}
 
 	logger.Info("starting the metrics generator with configuration", zap.Any("config", cfg))
@@ -75,22 +91,70 @@ func Start(cfg *Config, logger *zap.Logger) error {
 		fmt.Println("Starting metrics generator")
 	}
 
-	if err = run(cfg, exp, logger); err != nil {
-		logger.Error("failed to run metrics generator", zap.Error(err))
+	// run shuts the exporter down when it finishes, so every repetition gets a fresh one
+	expF := exporterFactory(cfg, logger)
+	err = cfg.RunRepeated(logger, func() error {
+		exp, err := expF()
+		if err != nil {
+			logger.Error("failed to create exporter", zap.Error(err))
+			return err
+		}
+		exportErrors := common.NewExportErrors()
+		exportErrors.AbortAfter(int64(cfg.MaxErrors))
+		releasePartialSuccesses := common.CountPartialSuccesses(common.RejectedDataPoints, exportErrors)
+		runStats, err := run(cfg, errorCountingExporter{Exporter: exp, errors: exportErrors}, exportErrors, logger)
+		releasePartialSuccesses()
+		if err != nil && !errors.Is(err, common.ErrMaxErrors) {
+			logger.Error("failed to run metrics generator", zap.Error(err))
+			return err
+		}
+		exportErrors.Report(logger, cfg.TerminalOutput)
+		runStats.AddExportErrors(exportErrors)
+		stats.Add(runStats)
 		return err
+	})
+	if verifyErr := verification.Finish(stats.Delivered, logger, cfg.TerminalOutput); verifyErr != nil && err == nil {
+		err = verifyErr
 	}
-	return nil
+	return stats, err
+}
+
+// RunDiscarding runs the scenario once against an in-process exporter that discards
+// every metric, measuring the generator alone, without network or collector overhead.
+func RunDiscarding(cfg *Config, logger *zap.Logger) (common.RunStats, error) {
+	if err := cfg.InitAttributes(); err != nil {
+		return common.RunStats{}, err
+	}
+	cfg.SeedRandomness()
+	return run(cfg, discardExporter{}, nil, logger)
 }
 
-// run executes the test scenario.
-func run(c *Config, exporter sdkmetric.Exporter, logger *zap.Logger) error {
+// run executes the test scenario. It is aborted once exportErrors, which may be nil,
+// reaches `max-errors`.
+func run(c *Config, exporter sdkmetric.Exporter, exportErrors *common.ExportErrors, logger *zap.Logger) (common.RunStats, error) {
 	if err := c.Validate(); err != nil {
-		return err
+		return common.RunStats{}, err
 	}
 
-	if c.TotalDuration > 0 {
+	if c.DuplicateRatio > 0 {
+		logger.Info("a share of the exports is sent twice", zap.Float64("duplicate-ratio", c.DuplicateRatio))
+		exporter = duplicatingExporter{Exporter: exporter, ratio: c.DuplicateRatio}
+	}
+	if c.DropRatio > 0 {
+		logger.Info("a share of the metrics is never exported", zap.Float64("drop-ratio", c.DropRatio))
+		exporter = droppingExporter{Exporter: exporter, ratio: c.DropRatio, skipped: exportErrors}
+	}
+
+	if c.IgnoresCount() {
+		c.WarnCountIgnored(logger, "metrics", c.NumMetrics, defaultNumMetrics)
+		c.NumMetrics = 0
+	}
+	if c.Intervals > 0 {
+		logger.Info("generation stops after a number of intervals", zap.Int("intervals", c.Intervals), zap.Duration("metrics-interval", c.AlignInterval))
+		c.WarnCountIgnoredBy(logger, "intervals", zap.Int("intervals", c.Intervals), "metrics", c.NumMetrics, defaultNumMetrics)
 		c.NumMetrics = 0
 	}
+	c.WarnURLPath(logger, "metrics")
 
 	limit := rate.Limit(c.Rate)
 	if c.Rate == 0 {
@@ -99,13 +163,41 @@ func run(c *Config, exporter sdkmetric.Exporter, logger *zap.Logger) error {
 	} else {
 		logger.Info("generation of metrics is limited", zap.Float64("per-second", float64(limit)))
 	}
+	if c.TimestampEdgeCase != "" {
+		logger.Warn("data points get degenerate start timestamps", zap.String("metric-timestamp-edge-case", c.TimestampEdgeCase))
+	}
+
+	limiters, releaseLimiters := c.RunLimiters(limit)
+	defer releaseLimiters()
+	stopSchedule, err := c.StartRateSchedule(limiters, logger)
+	if err != nil {
+		return common.RunStats{}, err
+	}
+	defer stopSchedule()
+	if c.SharedLimiter {
+		logger.Info("rate limiter is shared across all workers")
+	}
 
-	attrs, err := c.GetResourceAttrWithMockMarker()
+	resources, err := c.FleetResources(c.ResourceSchemaURL())
 	if err != nil {
 		logger.Fatal("failed to process resource attributes", zap.Error(err))
-		return err
+		return common.RunStats{}, err
+	}
+	if len(resources) > 1 {
+		logger.Info("metrics are emitted from a fleet of hosts in turn", zap.Int("fleet-size", len(resources)))
+	}
+
+	if c.NoReset && c.AggregationTemporality.AsTemporality() != metricdata.DeltaTemporality {
+		logger.Warn("`metrics-no-reset` only applies to delta temporality and is ignored")
+	}
+
+	if omitted := c.OmittedOTLPFeatures(); len(omitted) > 0 {
+		logger.Info("leaving out features newer than the OTLP compatibility release",
+			zap.String("otlp-compat", c.OTLPCompat), zap.Strings("features", omitted))
 	}
-	res := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+
+	// validated above, so the error can be ignored
+	streamStart, _ := c.StreamStartTime(time.Now())
 
 	wg := sync.WaitGroup{}
 
@@ -113,20 +205,16 @@ func run(c *Config, exporter sdkmetric.Exporter, logger *zap.Logger) error {
 	running.Store(true)
 
 	var totalMetrics int64
+	// the counted window starts once the warmup has elapsed
+	window := c.StartCountedWindow(&totalMetrics)
+	var timing *common.ExportTiming
+	if c.ExportTiming {
+		timing = common.NewExportTiming(c.WorkerCount)
+	}
+	start := time.Now()
 
 	progressCh := make(chan struct{})
-	go func() {
-		count := 0
-		for range progressCh {
-			count++
-			if c.TerminalOutput {
-				fmt.Println("Metrics generated:", count)
-			}
-		}
-		if c.TerminalOutput {
-			fmt.Println("Metrics generated (final count):", count)
-		}
-	}()
+	go c.ConsumeProgress("Metrics generated", progressCh)
 
 	for i := 0; i < c.WorkerCount; i++ {
 		wg.Add(1)
@@ -136,14 +224,32 @@ func run(c *Config, exporter sdkmetric.Exporter, logger *zap.Logger) error {
 			metricType:             c.MetricType,
 			aggregationTemporality: c.AggregationTemporality,
 			exemplars:              exemplarsFromConfig(c),
+			exemplarIDs:            exemplarIDsFromConfig(c, i),
 			limitPerSecond:         limit,
+			limiter:                limiters[i],
 			totalDuration:          c.TotalDuration,
 			running:                running,
 			wg:                     &wg,
 			logger:                 logger.With(zap.Int("worker", i+1)),
 			index:                  i,
 			clock:                  &realClock{},
+			startTime:              streamStart,
+			gaugeWalk:              c.GaugeWalk,
+			specialValueRatio:      c.SpecialValueRatio,
+			histogramBounds:        c.HistogramBuckets,
+			exponentialScale:       c.ExponentialScale,
+			summaryQuantiles:       c.SummaryQuantiles,
+			noReset:                c.NoReset,
+			bothTemporalities:      c.BothTemporalities,
+			stableAttributes:       c.StableAttributes,
+			churnInterval:          c.ChurnInterval,
+			alignInterval:          c.AlignInterval,
+			intervals:              c.Intervals,
+			omitMinMax:             !c.SupportsOTLP(common.FeatureHistogramMinMax),
+			timestampEdgeCase:      c.TimestampEdgeCase,
+			scopeNames:             c.ScopeNames(i + 1),
 			metricsCounter:         &totalMetrics,
+			exportTiming:           timing.Worker(i),
 			progressCh:             progressCh,
 		}
 		defer func() {
@@ -152,17 +258,18 @@ func run(c *Config, exporter sdkmetric.Exporter, logger *zap.Logger) error {
 				w.logger.Error("failed to stop the exporter", zap.Error(tempError))
 			}
 		}()
-		go w.simulateMetrics(res, exporter, c)
+		go w.simulateMetrics(resources, exporter, c)
 	}
 
-	if c.TotalDuration > 0 {
-		time.Sleep(c.TotalDuration)
-		running.Store(false)
-	}
-	wg.Wait()
+	stopDashboard := c.StartDashboard("metrics", &totalMetrics, exportErrors)
+	err = c.WaitWorkers(&wg, func() { running.Store(false) }, exportErrors, logger)
+	stopDashboard()
 	close(progressCh)
-	logger.Info("final count", zap.Int64("metrics_generated", atomic.LoadInt64(&totalMetrics)))
-	return nil
+	stats := common.NewRunStats(atomic.LoadInt64(&totalMetrics), time.Since(start))
+	logger.Info("final count", zap.Int64("metrics_generated", stats.Generated), zap.Float64("per-second", stats.AchievedRate))
+	window.Report(logger, "metrics", c.TerminalOutput)
+	timing.Report(logger, c.TerminalOutput, c.TargetSpacing())
+	return stats, err
 }
 
 type exporterFunc func() (sdkmetric.Exporter, error)
@@ -206,6 +313,15 @@ func createExporter(cfg *Config, logger *zap.Logger) (sdkmetric.Exporter, error)
 	return exp, err
 }
 
+// exemplarIDsFromConfig returns the exemplar ID generator of the given worker with
+// `exemplar-seed`, or nil without it.
+func exemplarIDsFromConfig(c *Config, worker int) *exemplarIDs {
+	if c.ExemplarSeed == 0 {
+		return nil
+	}
+	return newExemplarIDs(c.ExemplarSeed, worker)
+}
+
 func exemplarsFromConfig(c *Config) []metricdata.Exemplar[int64] {
 	if c.TraceID != "" || c.SpanID != "" {
 		var exemplars []metricdata.Exemplar[int64]
@@ -6,6 +6,7 @@ package metrics
 import (
 	"encoding/hex"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
@@ -13,6 +14,7 @@ import (
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata/metricdatatest"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func Test_exemplarsFromConfig(t *testing.T) {
@@ -118,7 +120,7 @@ func TestMetricType_Set_String_Type(t *testing.T) {
 	var mt MetricType
 
 	// Test Set with valid values
-	for _, v := range []string{"Gauge", "Sum", "Histogram"} {
+	for _, v := range []string{"Gauge", "Sum", "Histogram", "ExponentialHistogram", "Summary"} {
 		require.NoError(t, mt.Set(v))
 		assert.Equal(t, v, mt.String())
 		assert.Equal(t, "MetricType", mt.Type())
@@ -158,6 +160,35 @@ func TestConfigHelpers(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestStreamStartTime(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "empty defaults to now", input: "", want: now},
+		{name: "duration before now", input: "24h", want: now.Add(-24 * time.Hour)},
+		{name: "rfc3339 timestamp", input: "2025-05-01T00:00:00Z", want: time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "negative duration", input: "-1h", wantErr: true},
+		{name: "future timestamp", input: "2026-01-01T00:00:00Z", wantErr: true},
+		{name: "garbage", input: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{MetricStartTime: tt.input}
+			got, err := cfg.StreamStartTime(now)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 // newFlagSet is a helper to create a pflag.FlagSet for testing
 func newFlagSet() *pflag.FlagSet {
 	return &pflag.FlagSet{}
@@ -176,3 +207,52 @@ func TestSetHelpTemplateForCmd(t *testing.T) {
 		t.Error("SetHelpTemplateForCmd did not set the help template")
 	}
 }
+
+func TestRunDiscarding(t *testing.T) {
+	cfg := NewConfig()
+	cfg.NumMetrics = 3
+	cfg.TerminalOutput = false
+
+	stats, err := RunDiscarding(cfg, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Generated)
+}
+
+func TestRunDiscarding_Intervals(t *testing.T) {
+	cfg := NewConfig()
+	cfg.WorkerCount = 2
+	cfg.AlignInterval = 50 * time.Millisecond
+	cfg.Intervals = 4
+	cfg.Rate = 0
+	cfg.TerminalOutput = false
+
+	stats, err := RunDiscarding(cfg, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), stats.Generated, "the intervals take precedence over the metrics count")
+}
+
+func TestRun_IntervalsOnWallClock(t *testing.T) {
+	cfg := NewConfig()
+	cfg.MetricType = MetricTypeGauge
+	cfg.NumMetrics = 10
+	cfg.AlignInterval = 50 * time.Millisecond
+	cfg.Intervals = 4
+	cfg.Rate = 0
+	cfg.TerminalOutput = false
+	require.NoError(t, cfg.InitAttributes())
+
+	m := &mockExporter{}
+	core, logs := observer.New(zap.WarnLevel)
+	start := time.Now()
+	stats, err := run(cfg, m, nil, zap.New(core))
+	end := time.Now()
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(4), stats.Generated, "one data point per interval")
+	assert.GreaterOrEqual(t, end.Sub(start), 2*cfg.AlignInterval, "the intervals elapse on the wall clock")
+	for i, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0]
+		assert.False(t, dp.Time.After(end.Add(cfg.AlignInterval)), "data point %d is stamped past the current interval", i)
+	}
+	require.Equal(t, 1, logs.FilterMessageSnippet("`intervals` takes precedence and `metrics` is ignored").Len())
+}
@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"math"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// specialValues are the float values of `metric-special-value-ratio`, which OTLP allows
+// on float data points but normal value generation never produces.
+var specialValues = []float64{math.NaN(), math.Inf(1), math.Inf(-1)}
+
+// specialValue returns NaN, +Inf or -Inf for the `metric-special-value-ratio` share of
+// the data points, and false for the others.
+func (w worker) specialValue() (float64, bool) {
+	if w.specialValueRatio <= 0 || common.RandFloat64() >= w.specialValueRatio {
+		return 0, false
+	}
+	return specialValues[common.RandIntN(len(specialValues))], true
+}
+
+// withSpecialValue returns the float version of the gauge or histogram m carrying v: as
+// the value of the gauge data points, or as the sum of the histogram ones. Other metrics
+// are returned as is.
+func withSpecialValue(m metricdata.Metrics, v float64) metricdata.Metrics {
+	switch data := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		points := make([]metricdata.DataPoint[float64], len(data.DataPoints))
+		for k, p := range data.DataPoints {
+			points[k] = metricdata.DataPoint[float64]{
+				Attributes: p.Attributes,
+				StartTime:  p.StartTime,
+				Time:       p.Time,
+				Value:      v,
+				Exemplars:  floatExemplars(p.Exemplars),
+			}
+		}
+		m.Data = metricdata.Gauge[float64]{DataPoints: points}
+	case metricdata.Histogram[int64]:
+		points := make([]metricdata.HistogramDataPoint[float64], len(data.DataPoints))
+		for k, p := range data.DataPoints {
+			points[k] = metricdata.HistogramDataPoint[float64]{
+				Attributes:   p.Attributes,
+				StartTime:    p.StartTime,
+				Time:         p.Time,
+				Count:        p.Count,
+				Bounds:       p.Bounds,
+				BucketCounts: p.BucketCounts,
+				Min:          floatExtrema(p.Min),
+				Max:          floatExtrema(p.Max),
+				Sum:          v,
+				Exemplars:    floatExemplars(p.Exemplars),
+			}
+		}
+		m.Data = metricdata.Histogram[float64]{Temporality: data.Temporality, DataPoints: points}
+	}
+	return m
+}
+
+func floatExtrema(e metricdata.Extrema[int64]) metricdata.Extrema[float64] {
+	if v, ok := e.Value(); ok {
+		return metricdata.NewExtrema(float64(v))
+	}
+	return metricdata.Extrema[float64]{}
+}
+
+func floatExemplars(exemplars []metricdata.Exemplar[int64]) []metricdata.Exemplar[float64] {
+	if exemplars == nil {
+		return nil
+	}
+	result := make([]metricdata.Exemplar[float64], len(exemplars))
+	for k, e := range exemplars {
+		result[k] = metricdata.Exemplar[float64]{
+			FilteredAttributes: e.FilteredAttributes,
+			Time:               e.Time,
+			Value:              float64(e.Value),
+			SpanID:             e.SpanID,
+			TraceID:            e.TraceID,
+		}
+	}
+	return result
+}
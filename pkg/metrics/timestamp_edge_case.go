@@ -0,0 +1,25 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import "time"
+
+// The `metric-timestamp-edge-case` values, producing degenerate data points for
+// negative testing of collectors and backends.
+const (
+	timestampEdgeCaseZeroDuration   = "zero-duration"    // start timestamp equal to the collection timestamp
+	timestampEdgeCaseStartAfterTime = "start-after-time" // start timestamp after the collection timestamp, which is invalid
+)
+
+// pointStartTime returns the start timestamp of a data point collected at now, whose
+// stream would start at start without `metric-timestamp-edge-case`.
+func (w worker) pointStartTime(start, now time.Time) time.Time {
+	switch w.timestampEdgeCase {
+	case timestampEdgeCaseZeroDuration:
+		return now
+	case timestampEdgeCaseStartAfterTime:
+		return now.Add(time.Second)
+	}
+	return start
+}
@@ -11,11 +11,14 @@ import (
 	"time"
 
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
+
+	"github.com/medxops/trazr-gen/internal/common"
 )
 
 type worker struct {
@@ -24,14 +27,32 @@ type worker struct {
 	metricType             MetricType                   // type of metric to generate
 	aggregationTemporality AggregationTemporality       // Temporality type to use
 	exemplars              []metricdata.Exemplar[int64] // exemplars to attach to the metric
+	exemplarIDs            *exemplarIDs                 // draws the exemplars of each data point instead (nil means the static exemplars)
 	numMetrics             int                          // how many metrics the worker has to generate (only when duration==0)
 	totalDuration          time.Duration                // how long to run the test for (overrides `numMetrics`)
 	limitPerSecond         rate.Limit                   // how many metrics per second to generate
+	limiter                *rate.Limiter                // limiter of the worker, possibly shared across workers (nil means the worker creates its own)
 	wg                     *sync.WaitGroup              // notify when done
 	logger                 *zap.Logger                  // logger
 	index                  int                          // worker index
 	clock                  Clock                        // clock
+	startTime              time.Time                    // start timestamp of cumulative streams (zero means the worker start)
+	scopeNames             []string                     // instrumentation scope names of the worker's metrics, each emitting every data point
+	noReset                bool                         // chain delta data points from the previous one instead of a fresh window
+	bothTemporalities      bool                         // emit a delta and a cumulative stream, told apart by the trazr.temporality attribute
+	stableAttributes       bool                         // draw the data point attributes once per stream instead of once per data point
+	churnInterval          time.Duration                // how often the series of the worker is replaced by a new one (0 means never)
+	alignInterval          time.Duration                // period whose boundaries the data point timestamps snap to (0 means the clock time)
+	intervals              int                          // number of alignInterval cycles after which to stop (0 means no limit)
+	omitMinMax             bool                         // leave out histogram min and max, which the `otlp-compat` release predates
+	timestampEdgeCase      string                       // degenerate start timestamps of `metric-timestamp-edge-case` (empty means none)
+	gaugeWalk              int64                        // max step of the gauge random walk (0 means gauge values follow the counter)
+	specialValueRatio      float64                      // share of the gauge and histogram data points carrying NaN or an infinity
+	histogramBounds        []float64                    // bucket boundaries of the histograms (empty means histogramBounds)
+	exponentialScale       int32                        // scale of the exponential histograms
+	summaryQuantiles       []float64                    // quantiles of the summaries (empty means defaultSummaryQuantiles)
 	metricsCounter         *int64                       // pointer to shared metrics counter
+	exportTiming           *common.WorkerTiming         // records the time between exports (nil when disabled)
 	progressCb             func(string)                 // optional callback for terminal output
 	progressCh             chan struct{}                // channel for centralized progress reporting
 }
@@ -86,42 +107,212 @@ var histogramBucketSamples = []struct {
 	},
 }
 
+// histogramBounds are the explicit bucket boundaries used for generated histograms.
+// Bounds from https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/metrics/sdk.md#explicit-bucket-histogram-aggregation
+var histogramBounds = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// histogramExtrema derives min and max values consistent with the bucketed data:
+// the minimum falls just above the lower bound of the first non-empty bucket and
+// the maximum sits on the upper bound of the last non-empty bucket.
+func histogramExtrema(bounds []float64, bucketCounts []uint64) (minVal, maxVal int64) {
+	first, last := -1, -1
+	for i, count := range bucketCounts {
+		if count == 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first < 0 {
+		return 0, 0
+	}
+	if first == 0 {
+		minVal = int64(bounds[0])
+	} else {
+		minVal = int64(bounds[first-1]) + 1
+	}
+	if last >= len(bounds) {
+		maxVal = int64(bounds[len(bounds)-1]) + 1
+	} else {
+		maxVal = int64(bounds[last])
+	}
+	return minVal, maxVal
+}
+
+// extrema returns v as a histogram min or max, or an unset one when they are omitted.
+func (w worker) extrema(v int64) metricdata.Extrema[int64] {
+	if w.omitMinMax {
+		return metricdata.Extrema[int64]{}
+	}
+	return metricdata.NewExtrema(v)
+}
+
+// sumMetric returns the monotonic Sum of the worker, of temporality, with a single data
+// point of value from start to now.
+func (w worker) sumMetric(temporality metricdata.Temporality, start, now time.Time, value int64, attrs attribute.Set) metricdata.Metrics {
+	return metricdata.Metrics{
+		Name: w.metricName,
+		Data: metricdata.Sum[int64]{
+			IsMonotonic: true,
+			Temporality: temporality,
+			DataPoints: []metricdata.DataPoint[int64]{
+				{
+					StartTime:  start,
+					Time:       now,
+					Value:      value,
+					Attributes: attrs,
+					Exemplars:  w.exemplars,
+				},
+			},
+		},
+	}
+}
+
+// histogramMetric returns the Histogram of the worker, of temporality, with a single data
+// point of sample from start to now.
+func (w worker) histogramMetric(temporality metricdata.Temporality, start, now time.Time, sample histogramSample, attrs attribute.Set) metricdata.Metrics {
+	return metricdata.Metrics{
+		Name: w.metricName,
+		Data: metricdata.Histogram[int64]{
+			Temporality: temporality,
+			DataPoints: []metricdata.HistogramDataPoint[int64]{
+				{
+					StartTime:    start,
+					Time:         now,
+					Attributes:   attrs,
+					Exemplars:    w.exemplars,
+					Count:        sample.count,
+					Sum:          sample.sum,
+					Min:          w.extrema(sample.minVal),
+					Max:          w.extrema(sample.maxVal),
+					Bounds:       sample.bounds,
+					BucketCounts: sample.bucketCounts,
+				},
+			},
+		},
+	}
+}
+
+// walkStep moves a random-walk value by at most step in either direction,
+// reflecting at zero so the gauge stays non-negative like CPU or memory usage.
+func walkStep(prev, step int64) int64 {
+	if step <= 0 {
+		return prev
+	}
+	next := prev + common.RandInt64N(2*step+1) - step
+	if next < 0 {
+		next = -next
+	}
+	return next
+}
+
 func (w worker) reportProgressf(format string, args ...any) {
 	if w.progressCb != nil {
 		w.progressCb(fmt.Sprintf(format, args...))
 	}
 }
 
-func (w worker) simulateMetrics(res *resource.Resource, exporter sdkmetric.Exporter, cfg *Config) {
-	limiter := rate.NewLimiter(w.limitPerSecond, 1)
+// simulateMetrics exports the data points of the worker, from each of resources in turn.
+func (w worker) simulateMetrics(resources []*resource.Resource, exporter sdkmetric.Exporter, cfg *Config) {
+	limiter := w.limiter
+	if limiter == nil {
+		limiter = rate.NewLimiter(w.limitPerSecond, 1)
+	}
 
 	startTime := w.clock.Now()
+	if !w.startTime.IsZero() {
+		startTime = w.startTime
+	}
 
 	var i int64
+	var gaugeValue int64  // current value of the gauge random walk, kept per worker stream
+	prevTime := startTime // end of the previous data point, where chained delta points start
+	var dual *dualStreams
+	if w.bothTemporalities {
+		dual = newDualStreams(startTime, w.bucketBounds())
+	}
+	var totals *measurementTotals        // measurements of the exponential histogram or summary stream
+	var streamAttrs []attribute.KeyValue // attributes of every data point with stableAttributes, drawn with the first one
+	var churn *seriesChurn
+	if w.churnInterval > 0 {
+		churn = newSeriesChurn(w.churnInterval, startTime)
+	}
+	var firstTime time.Time // timestamp of the first data point, from which the intervals are counted
+	// the configured exemplars take precedence over those of the spans generated alongside
+	correlateExemplars := w.exemplarIDs == nil && len(w.exemplars) == 0
 	for w.running.Load() {
 		var metrics []metricdata.Metrics
 		now := w.clock.Now()
+		if w.alignInterval > 0 {
+			// one data point per interval: the previous one is stamped at the end of its interval
+			var running bool
+			if now, running = w.waitUntil(now, prevTime); !running {
+				break
+			}
+			now = alignedTime(now, prevTime, w.alignInterval)
+		}
+		if firstTime.IsZero() {
+			firstTime = now
+		}
+		newSeries := churn != nil && churn.next(now, i)
 		if w.aggregationTemporality.AsTemporality() == metricdata.DeltaTemporality {
-			startTime = now.Add(-1 * time.Second)
+			if w.noReset {
+				startTime = prevTime
+			} else if w.alignInterval > 0 {
+				startTime = now.Add(-w.alignInterval)
+			} else {
+				startTime = now.Add(-1 * time.Second)
+			}
+		} else if newSeries {
+			// the new cumulative series starts where the previous one went stale
+			startTime = prevTime
+		}
+		prevTime = now
+		if w.exemplarIDs != nil {
+			w.exemplars = w.exemplarIDs.next(now)
+		} else if sc, ok := common.CorrelatedSpanContext("metrics"); ok && correlateExemplars {
+			// the data point was measured during a span the traces generator of this process emitted
+			w.exemplars = spanExemplars(sc, now)
 		}
 
-		// Build a fresh set of signal attributes for each metric data point
-		signalAttrs, err := cfg.GetTelemetryAttrWithMockMarker()
-		if err != nil {
-			w.reportProgressf("Failed to process telemetry attributes: %v", err)
-			w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
-			break
+		// Build a fresh set of signal attributes for each metric data point, or once for
+		// the stream with stableAttributes so that it keeps its identity
+		var signalAttrs []attribute.KeyValue
+		if w.stableAttributes && streamAttrs != nil {
+			signalAttrs = streamAttrs
+		} else if !cfg.NoTelemetryAttributes {
+			attrs, err := cfg.GetDatapointAttrWithMockMarker()
+			if err != nil {
+				w.reportProgressf("Failed to process telemetry attributes: %v", err)
+				w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
+				break
+			}
+			signalAttrs = attrs
+			if w.stableAttributes {
+				streamAttrs = attrs
+			}
+		}
+		value := i
+		if churn != nil {
+			signalAttrs = churn.withSeries(signalAttrs)
+			value -= churn.first
 		}
 
 		switch w.metricType {
 		case MetricTypeGauge:
+			if w.gaugeWalk > 0 {
+				gaugeValue = walkStep(gaugeValue, w.gaugeWalk)
+				value = gaugeValue
+			}
 			metrics = append(metrics, metricdata.Metrics{
 				Name: w.metricName,
 				Data: metricdata.Gauge[int64]{
 					DataPoints: []metricdata.DataPoint[int64]{
 						{
 							Time:       now,
-							Value:      i,
+							Value:      value,
 							Attributes: attribute.NewSet(signalAttrs...),
 							Exemplars:  w.exemplars,
 						},
@@ -129,71 +320,67 @@ func (w worker) simulateMetrics(res *resource.Resource, exporter sdkmetric.Expor
 				},
 			})
 		case MetricTypeSum:
+			if dual != nil {
+				metrics = dual.next(w, i, now, signalAttrs)
+				break
+			}
+			metrics = append(metrics, w.sumMetric(w.aggregationTemporality.AsTemporality(), w.pointStartTime(startTime, now), now, value, attribute.NewSet(signalAttrs...)))
+		case MetricTypeHistogram:
+			if dual != nil {
+				metrics = dual.next(w, i, now, signalAttrs)
+				break
+			}
+			sample := w.histogramSample(i)
+			metrics = append(metrics, w.histogramMetric(w.aggregationTemporality.AsTemporality(), w.pointStartTime(startTime, now), now, sample, attribute.NewSet(signalAttrs...)))
+		case MetricTypeExponentialHistogram:
+			totals = w.nextTotals(totals, newSeries)
+			totals.add(drawMeasurements())
+			point := w.exponentialHistogramPoint(totals)
+			point.StartTime = w.pointStartTime(startTime, now)
+			point.Time = now
+			point.Attributes = attribute.NewSet(signalAttrs...)
+			point.Exemplars = w.exemplars
 			metrics = append(metrics, metricdata.Metrics{
 				Name: w.metricName,
-				Data: metricdata.Sum[int64]{
-					IsMonotonic: true,
+				Data: metricdata.ExponentialHistogram[int64]{
 					Temporality: w.aggregationTemporality.AsTemporality(),
-					DataPoints: []metricdata.DataPoint[int64]{
-						{
-							StartTime:  startTime,
-							Time:       now,
-							Value:      i,
-							Attributes: attribute.NewSet(signalAttrs...),
-							Exemplars:  w.exemplars,
-						},
-					},
+					DataPoints:  []metricdata.ExponentialHistogramDataPoint[int64]{point},
 				},
 			})
-		case MetricTypeHistogram:
-			var totalCount uint64
-			idx := int(i % 10)
-			if idx < 0 {
-				idx += 10
-			}
-			//nolint:gosec // safe: idx is always in [0,9] due to explicit bounds check above
-			iteration := uint64(idx)
-			sum := histogramBucketSamples[iteration].sum
-			bucketCounts := histogramBucketSamples[iteration].bucketCounts
-			for _, count := range bucketCounts {
-				totalCount += count
-			}
+		case MetricTypeSummary:
+			totals = w.nextTotals(totals, newSeries)
+			values := drawMeasurements()
+			totals.add(values)
+			point := summaryPoint(totals, values, w.summaryQuantiles)
+			point.StartTime = w.pointStartTime(startTime, now)
+			point.Time = now
+			point.Attributes = attribute.NewSet(signalAttrs...)
 			metrics = append(metrics, metricdata.Metrics{
 				Name: w.metricName,
-				Data: metricdata.Histogram[int64]{
-					Temporality: w.aggregationTemporality.AsTemporality(),
-					DataPoints: []metricdata.HistogramDataPoint[int64]{
-						{
-							StartTime:  startTime,
-							Time:       now,
-							Attributes: attribute.NewSet(signalAttrs...),
-							Exemplars:  w.exemplars,
-							Count:      totalCount,
-							Sum:        sum,
-							// Bounds from https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/metrics/sdk.md#explicit-bucket-histogram-aggregation
-							Bounds:       []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000},
-							BucketCounts: bucketCounts,
-						},
-					},
-				},
+				Data: metricdata.Summary{DataPoints: []metricdata.SummaryDataPoint{point}},
 			})
 		default:
 			w.logger.Fatal("unknown metric type")
 		}
+		if v, ok := w.specialValue(); ok {
+			metrics[0] = withSpecialValue(metrics[0], v)
+		}
 
 		rm := metricdata.ResourceMetrics{
-			Resource:     res,
-			ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: metrics}},
+			Resource:     resources[i%int64(len(resources))],
+			ScopeMetrics: w.scopeMetrics(metrics),
 		}
 
 		if err := limiter.Wait(context.Background()); err != nil {
 			w.reportProgressf("Limiter wait failed: %v", err)
 			w.logger.Fatal("limiter wait failed, retry", zap.Error(err))
 		}
+		w.exportTiming.Mark()
 
+		// failed exports are counted by category and reported once the run ends
 		if err := exporter.Export(context.Background(), &rm); err != nil {
 			w.reportProgressf("Exporter failed: %v", err)
-			w.logger.Fatal("exporter failed", zap.Error(err))
+			w.logger.Error("exporter failed", zap.Error(err))
 		}
 
 		i++
@@ -206,8 +393,24 @@ func (w worker) simulateMetrics(res *resource.Resource, exporter sdkmetric.Expor
 		if w.numMetrics != 0 && i >= int64(w.numMetrics) {
 			break
 		}
+		if w.intervals > 0 && intervalCycle(firstTime, now, w.alignInterval) >= w.intervals {
+			break
+		}
 	}
 
 	w.logger.Info("metrics generated", zap.Int64("metrics", i))
 	w.wg.Done()
 }
+
+// scopeMetrics nests metrics in each of the scopes of the worker, or in a single
+// unnamed one when it has none.
+func (w worker) scopeMetrics(metrics []metricdata.Metrics) []metricdata.ScopeMetrics {
+	if len(w.scopeNames) == 0 {
+		return []metricdata.ScopeMetrics{{Metrics: metrics}}
+	}
+	scopes := make([]metricdata.ScopeMetrics, len(w.scopeNames))
+	for k, name := range w.scopeNames {
+		scopes[k] = metricdata.ScopeMetrics{Scope: instrumentation.Scope{Name: name}, Metrics: metrics}
+	}
+	return scopes
+}
@@ -5,6 +5,10 @@ package metrics
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -16,6 +20,8 @@ import (
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.13.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 
@@ -30,6 +36,7 @@ const (
 )
 
 type mockExporter struct {
+	mu  sync.Mutex
 	rms []*metricdata.ResourceMetrics
 }
 
@@ -42,6 +49,8 @@ func (m *mockExporter) Aggregation(_ sdkmetric.InstrumentKind) sdkmetric.Aggrega
 }
 
 func (m *mockExporter) Export(_ context.Context, metrics *metricdata.ResourceMetrics) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.rms = append(m.rms, metrics)
 	return nil
 }
@@ -64,6 +73,10 @@ func checkMetricTemporality(t *testing.T, ms metricdata.Metrics, metricType Metr
 		histogramData, ok := ms.Data.(metricdata.Histogram[int64])
 		require.True(t, ok, "expected Histogram data type")
 		assert.Equal(t, expectedAggregationTemporality, histogramData.Temporality)
+	case MetricTypeExponentialHistogram:
+		histogramData, ok := ms.Data.(metricdata.ExponentialHistogram[int64])
+		require.True(t, ok, "expected ExponentialHistogram data type")
+		assert.Equal(t, expectedAggregationTemporality, histogramData.Temporality)
 	default:
 		t.Fatalf("unsupported metric type: %v", metricType)
 	}
@@ -82,11 +95,13 @@ func TestFixedNumberOfMetrics(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	stats, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 	time.Sleep(1 * time.Second)
 
 	// assert
 	require.Len(t, m.rms, 5)
+	assert.Equal(t, int64(5), stats.Generated)
 }
 
 func TestRateOfMetrics(t *testing.T) {
@@ -102,7 +117,8 @@ func TestRateOfMetrics(t *testing.T) {
 	m := &mockExporter{}
 
 	// act
-	require.NoError(t, run(cfg, m, zap.NewNop()))
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// assert
 	// the minimum acceptable number of metrics for the rate of 10/sec for half a second
@@ -142,6 +158,18 @@ func TestMetricsWithTemporality(t *testing.T) {
 			aggregationTemporality:         AggregationTemporality(metricdata.CumulativeTemporality),
 			expectedAggregationTemporality: metricdata.CumulativeTemporality,
 		},
+		{
+			name:                           "ExponentialHistogram: delta temporality",
+			metricType:                     MetricTypeExponentialHistogram,
+			aggregationTemporality:         AggregationTemporality(metricdata.DeltaTemporality),
+			expectedAggregationTemporality: metricdata.DeltaTemporality,
+		},
+		{
+			name:                           "ExponentialHistogram: cumulative temporality",
+			metricType:                     MetricTypeExponentialHistogram,
+			aggregationTemporality:         AggregationTemporality(metricdata.CumulativeTemporality),
+			expectedAggregationTemporality: metricdata.CumulativeTemporality,
+		},
 	}
 
 	for _, tt := range tests {
@@ -160,7 +188,8 @@ func TestMetricsWithTemporality(t *testing.T) {
 
 			// act
 			logger, _ := zap.NewDevelopment()
-			require.NoError(t, run(cfg, m, logger))
+			_, err := run(cfg, m, nil, logger)
+			require.NoError(t, err)
 
 			time.Sleep(1 * time.Second)
 
@@ -187,7 +216,8 @@ func TestUnthrottled(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	// assert
 	assert.Greater(t, len(m.rms), 100, "there should have been more than 100 metrics, had %d", len(m.rms))
@@ -201,7 +231,8 @@ func TestSumNoTelemetryAttrs(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -226,7 +257,8 @@ func TestGaugeNoTelemetryAttrs(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -251,7 +283,8 @@ func TestSumSingleTelemetryAttr(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -270,6 +303,22 @@ func TestSumSingleTelemetryAttr(t *testing.T) {
 	}
 }
 
+func TestSumNoTelemetryAttributesFlag(t *testing.T) {
+	qty := 2
+	cfg := configWithOneAttribute(MetricTypeSum, qty)
+	cfg.NoTelemetryAttributes = true
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.rms, qty)
+	for _, rm := range m.rms {
+		attr := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0].Attributes
+		assert.Equal(t, 0, attr.Len(), "telemetry attributes should be skipped")
+	}
+}
+
 func TestGaugeSingleTelemetryAttr(t *testing.T) {
 	// arrange
 	qty := 2
@@ -278,7 +327,8 @@ func TestGaugeSingleTelemetryAttr(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -305,7 +355,8 @@ func TestSumMultipleTelemetryAttr(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -334,7 +385,8 @@ func TestGaugeMultipleTelemetryAttr(t *testing.T) {
 
 	// act
 	logger, _ := zap.NewDevelopment()
-	require.NoError(t, run(cfg, m, logger))
+	_, err := run(cfg, m, nil, logger)
+	require.NoError(t, err)
 
 	time.Sleep(1 * time.Second)
 
@@ -355,6 +407,154 @@ func TestGaugeMultipleTelemetryAttr(t *testing.T) {
 	}
 }
 
+func TestWorkerScope(t *testing.T) {
+	// arrange
+	cfg := configWithNoAttributes(MetricTypeSum, 1)
+	cfg.WorkerCount = 2
+	cfg.WorkerScope = true
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// asserts
+	require.Len(t, m.rms, 2)
+	var scopes []string
+	for _, rm := range m.rms {
+		scopes = append(scopes, rm.ScopeMetrics[0].Scope.Name)
+	}
+	assert.ElementsMatch(t, []string{"trazr-gen/worker-1", "trazr-gen/worker-2"}, scopes)
+}
+
+func TestScopesPerResource(t *testing.T) {
+	// arrange
+	cfg := configWithNoAttributes(MetricTypeSum, 2)
+	cfg.ScopesPerResource = 3
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// asserts
+	require.Len(t, m.rms, 2)
+	for _, rm := range m.rms {
+		require.Len(t, rm.ScopeMetrics, 3)
+		for k, sm := range rm.ScopeMetrics {
+			assert.Equal(t, fmt.Sprintf("trazr-gen/scope-%d", k+1), sm.Scope.Name)
+			assert.Len(t, sm.Metrics, 1, "each scope should emit the data point")
+		}
+	}
+}
+
+func TestGaugeWalk(t *testing.T) {
+	// arrange
+	qty := 50
+	cfg := configWithNoAttributes(MetricTypeGauge, qty)
+	cfg.GaugeWalk = 5
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// asserts
+	require.Len(t, m.rms, qty)
+	var prev int64
+	for i, rm := range m.rms {
+		value := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0].Value
+		assert.GreaterOrEqual(t, value, int64(0), "data point %d", i)
+		assert.LessOrEqual(t, abs(value-prev), cfg.GaugeWalk, "data point %d moved too far", i)
+		prev = value
+	}
+}
+
+func TestBothTemporalities(t *testing.T) {
+	temporalityOf := func(set attribute.Set) string {
+		v, ok := set.Value(temporalityAttributeKey)
+		require.True(t, ok, "data point should carry %s", temporalityAttributeKey)
+		return v.AsString()
+	}
+
+	t.Run("sum", func(t *testing.T) {
+		qty := 5
+		cfg := configWithNoAttributes(MetricTypeSum, qty)
+		cfg.BothTemporalities = true
+		m := &mockExporter{}
+
+		_, err := run(cfg, m, nil, zap.NewNop())
+		require.NoError(t, err)
+
+		require.Len(t, m.rms, qty)
+		var total int64
+		var prevEnd time.Time
+		for i, rm := range m.rms {
+			ms := rm.ScopeMetrics[0].Metrics
+			require.Len(t, ms, 2, "data point %d should be emitted twice", i)
+			delta := ms[0].Data.(metricdata.Sum[int64])
+			cumulative := ms[1].Data.(metricdata.Sum[int64])
+			assert.Equal(t, metricdata.DeltaTemporality, delta.Temporality)
+			assert.Equal(t, metricdata.CumulativeTemporality, cumulative.Temporality)
+			assert.Equal(t, "delta", temporalityOf(delta.DataPoints[0].Attributes))
+			assert.Equal(t, "cumulative", temporalityOf(cumulative.DataPoints[0].Attributes))
+
+			total += delta.DataPoints[0].Value
+			assert.Equal(t, total, cumulative.DataPoints[0].Value, "deltas of data point %d should add up to the cumulative value", i)
+			if i > 0 {
+				assert.Equal(t, prevEnd, delta.DataPoints[0].StartTime, "delta data point %d should start where the previous one ended", i)
+			}
+			prevEnd = delta.DataPoints[0].Time
+		}
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		qty := 12
+		cfg := configWithNoAttributes(MetricTypeHistogram, qty)
+		cfg.BothTemporalities = true
+		m := &mockExporter{}
+
+		_, err := run(cfg, m, nil, zap.NewNop())
+		require.NoError(t, err)
+
+		require.Len(t, m.rms, qty)
+		var count uint64
+		var sum int64
+		for i, rm := range m.rms {
+			ms := rm.ScopeMetrics[0].Metrics
+			require.Len(t, ms, 2, "data point %d should be emitted twice", i)
+			delta := ms[0].Data.(metricdata.Histogram[int64]).DataPoints[0]
+			cumulative := ms[1].Data.(metricdata.Histogram[int64]).DataPoints[0]
+			assert.Equal(t, "delta", temporalityOf(delta.Attributes))
+			assert.Equal(t, "cumulative", temporalityOf(cumulative.Attributes))
+
+			count += delta.Count
+			sum += delta.Sum
+			assert.Equal(t, count, cumulative.Count, "data point %d", i)
+			assert.Equal(t, sum, cumulative.Sum, "data point %d", i)
+			cumulativeMin, _ := cumulative.Min.Value()
+			deltaMin, _ := delta.Min.Value()
+			assert.LessOrEqual(t, cumulativeMin, deltaMin, "data point %d", i)
+		}
+	})
+}
+
+func TestWalkStep(t *testing.T) {
+	assert.Equal(t, int64(7), walkStep(7, 0), "a zero step keeps the value")
+	for range 1000 {
+		next := walkStep(1, 3)
+		assert.GreaterOrEqual(t, next, int64(0))
+		assert.LessOrEqual(t, next, int64(4))
+	}
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -370,7 +570,7 @@ func TestValidate(t *testing.T) {
 				MetricType: MetricTypeSum,
 				TraceID:    "123",
 			},
-			wantErrMessage: "either `metrics` or `duration` must be greater than 0",
+			wantErrMessage: "either `metrics`, `intervals` or `duration` must be greater than 0",
 		},
 		{
 			name: "TraceID invalid",
@@ -397,12 +597,317 @@ func TestValidate(t *testing.T) {
 			},
 			wantErrMessage: "SpanID must be a 16 character hex string, like: '5828fa4960140870'",
 		},
+		{
+			name: "Bounded memory",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount:   1,
+					BoundedMemory: true,
+				},
+				NumMetrics: 5,
+				MetricType: MetricTypeSum,
+			},
+			wantErrMessage: "`bounded-memory` caps the queues of the batch span and log processors, metrics are exported as they are generated and queue nothing",
+		},
+		{
+			name: "Negative gauge walk",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics: 5,
+				MetricType: MetricTypeGauge,
+				GaugeWalk:  -1,
+			},
+			wantErrMessage: "`gauge-walk` must not be negative",
+		},
+		{
+			name: "Both temporalities with a gauge",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeGauge,
+				BothTemporalities: true,
+			},
+			wantErrMessage: "`metric-both-temporalities` requires a Sum or Histogram `metric-type`",
+		},
+		{
+			name: "Unknown timestamp edge case",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeSum,
+				TimestampEdgeCase: "negative",
+			},
+			wantErrMessage: "expected `metric-timestamp-edge-case` to be one of zero-duration or start-after-time, got \"negative\" instead",
+		},
+		{
+			name: "Timestamp edge case with a gauge",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeGauge,
+				TimestampEdgeCase: "zero-duration",
+			},
+			wantErrMessage: "`metric-timestamp-edge-case` requires a `metric-type` with a start timestamp, not Gauge",
+		},
+		{
+			name: "Cardinality churn with both temporalities",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeSum,
+				BothTemporalities: true,
+				ChurnInterval:     time.Minute,
+			},
+			wantErrMessage: "`cardinality-churn-interval` can't be combined with `metric-both-temporalities`",
+		},
+		{
+			name: "Exemplar seed with a trace ID",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:   5,
+				MetricType:   MetricTypeGauge,
+				TraceID:      "ae87dadd90e9935a4bc9660628efd569",
+				ExemplarSeed: 42,
+			},
+			wantErrMessage: "`exemplar-seed` can't be combined with `trace-id` or `span-id`",
+		},
+		{
+			name: "Special value ratio above 1",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeGauge,
+				SpecialValueRatio: 1.5,
+			},
+			wantErrMessage: "`metric-special-value-ratio` must be between 0 and 1",
+		},
+		{
+			name: "Special value ratio with a sum",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeSum,
+				SpecialValueRatio: 0.5,
+			},
+			wantErrMessage: "`metric-special-value-ratio` requires a Gauge or Histogram `metric-type`",
+		},
+		{
+			name: "Special value ratio with a summary",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeSummary,
+				SpecialValueRatio: 0.5,
+			},
+			wantErrMessage: "`metric-special-value-ratio` requires a Gauge or Histogram `metric-type`",
+		},
+		{
+			name: "Both temporalities with an exponential histogram",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeExponentialHistogram,
+				BothTemporalities: true,
+			},
+			wantErrMessage: "`metric-both-temporalities` requires a Sum or Histogram `metric-type`",
+		},
+		{
+			name: "Histogram buckets with a gauge",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeGauge,
+				HistogramBuckets: []float64{1, 10},
+			},
+			wantErrMessage: "`histogram-buckets` requires a Histogram `metric-type`",
+		},
+		{
+			name: "Histogram buckets out of order",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeHistogram,
+				HistogramBuckets: []float64{1, 10, 10},
+			},
+			wantErrMessage: "`histogram-buckets` must be in strictly increasing order, got 10 after 10",
+		},
+		{
+			name: "Histogram buckets not finite",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeHistogram,
+				HistogramBuckets: []float64{1, math.Inf(1)},
+			},
+			wantErrMessage: "`histogram-buckets` must be whole numbers between -9007199254740992 and 9007199254740992, got +Inf",
+		},
+		{
+			name: "Histogram buckets not whole numbers",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeHistogram,
+				HistogramBuckets: []float64{0.1, 0.5, 1},
+			},
+			wantErrMessage: "`histogram-buckets` must be whole numbers between -9007199254740992 and 9007199254740992, got 0.1",
+		},
+		{
+			name: "Histogram buckets too large",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeHistogram,
+				HistogramBuckets: []float64{1, 1e19},
+			},
+			wantErrMessage: "`histogram-buckets` must be whole numbers between -9007199254740992 and 9007199254740992, got 1e+19",
+		},
+		{
+			name: "Special value ratio with an exponential histogram",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:        5,
+				MetricType:        MetricTypeExponentialHistogram,
+				SpecialValueRatio: 0.5,
+			},
+			wantErrMessage: "`metric-special-value-ratio` requires a Gauge or Histogram `metric-type`",
+		},
+		{
+			name: "ExponentialHistogram before its OTLP release",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					OTLPCompat:  "0.10",
+				},
+				NumMetrics: 5,
+				MetricType: MetricTypeExponentialHistogram,
+			},
+			wantErrMessage: "OTLP 0.10 predates the ExponentialHistogram `metric-type`, added in 0.11",
+		},
+		{
+			name: "Summary with delta temporality",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:             5,
+				MetricType:             MetricTypeSummary,
+				AggregationTemporality: AggregationTemporality(metricdata.DeltaTemporality),
+			},
+			wantErrMessage: "the count and sum of a Summary `metric-type` are cumulative, it requires a cumulative `aggregation-temporality`",
+		},
+		{
+			name: "Exponential histogram scale out of range",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeExponentialHistogram,
+				ExponentialScale: 20,
+			},
+			wantErrMessage: "`exponential-histogram-scale` must be between -10 and 10",
+		},
+		{
+			name: "Exponential histogram scale with a histogram",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeHistogram,
+				ExponentialScale: 2,
+			},
+			wantErrMessage: "`exponential-histogram-scale` requires an ExponentialHistogram `metric-type`",
+		},
+		{
+			name: "Summary quantiles with a histogram",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeHistogram,
+				SummaryQuantiles: []float64{0.5},
+			},
+			wantErrMessage: "`summary-quantiles` requires a Summary `metric-type`",
+		},
+		{
+			name: "Summary quantile above 1",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumMetrics:       5,
+				MetricType:       MetricTypeSummary,
+				SummaryQuantiles: []float64{0.5, 99},
+			},
+			wantErrMessage: "`summary-quantiles` must be between 0 and 1, got 99",
+		},
+		{
+			name: "Intervals without a metrics interval",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				MetricType: MetricTypeGauge,
+				Intervals:  3,
+			},
+			wantErrMessage: "`intervals` requires `metrics-interval`",
+		},
+		{
+			name: "Intervals with a duration",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount:   1,
+					TotalDuration: time.Minute,
+				},
+				MetricType:    MetricTypeGauge,
+				AlignInterval: time.Second,
+				Intervals:     3,
+			},
+			wantErrMessage: "`intervals` can't be combined with `duration` or `total-bytes`",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &mockExporter{}
 			logger, _ := zap.NewDevelopment()
-			require.EqualError(t, run(tt.cfg, m, logger), tt.wantErrMessage)
+			_, err := run(tt.cfg, m, nil, logger)
+			require.EqualError(t, err, tt.wantErrMessage)
+			var validationErr *common.ValidationError
+			assert.ErrorAs(t, err, &validationErr, "validation errors are typed")
 		})
 	}
 }
@@ -499,7 +1004,7 @@ func TestTemporalityStartTimes(t *testing.T) {
 			}
 
 			cfg := &Config{}
-			w.simulateMetrics(resource.Default(), m, cfg)
+			w.simulateMetrics([]*resource.Resource{resource.Default()}, m, cfg)
 
 			wg.Wait()
 
@@ -516,23 +1021,64 @@ func TestTemporalityStartTimes(t *testing.T) {
 	}
 }
 
-func logTimestampDiff(t *testing.T, firstTime, secondTime time.Time) {
-	t.Logf("Timestamp debug logging:\n"+
-		"First start time:  %s\n"+
-		"Second start time: %s\n"+
-		"Difference: %v",
-		firstTime.String(),
-		secondTime.String(),
-		secondTime.Sub(firstTime))
-}
+func TestDeltaNoReset(t *testing.T) {
+	for _, noReset := range []bool{false, true} {
+		t.Run(fmt.Sprintf("no-reset=%t", noReset), func(t *testing.T) {
+			m := &mockExporter{}
+			streamStart := time.Now()
+			running := &atomic.Bool{}
+			running.Store(true)
+			wg := &sync.WaitGroup{}
+			wg.Add(1)
 
-func TestWorker_ReportProgressf(t *testing.T) {
-	var called bool
-	var got string
-	w := worker{
-		progressCb: func(msg string) {
-			called = true
-			got = msg
+			w := worker{
+				metricName:             "test_metric",
+				metricType:             MetricTypeSum,
+				aggregationTemporality: AggregationTemporality(metricdata.DeltaTemporality),
+				numMetrics:             3,
+				running:                running,
+				limitPerSecond:         rate.Inf,
+				logger:                 zap.NewNop(),
+				wg:                     wg,
+				clock:                  &mockClock{now: streamStart},
+				startTime:              streamStart,
+				noReset:                noReset,
+			}
+			w.simulateMetrics([]*resource.Resource{resource.Default()}, m, &Config{})
+			wg.Wait()
+
+			require.Len(t, m.rms, 3)
+			prevTime := streamStart
+			for i, rm := range m.rms {
+				dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0]
+				if noReset {
+					assert.Equal(t, prevTime, dp.StartTime, "data point %d should start where the previous one ended", i)
+				} else {
+					assert.Equal(t, dp.Time.Add(-time.Second), dp.StartTime, "data point %d should cover its own window", i)
+				}
+				prevTime = dp.Time
+			}
+		})
+	}
+}
+
+func logTimestampDiff(t *testing.T, firstTime, secondTime time.Time) {
+	t.Logf("Timestamp debug logging:\n"+
+		"First start time:  %s\n"+
+		"Second start time: %s\n"+
+		"Difference: %v",
+		firstTime.String(),
+		secondTime.String(),
+		secondTime.Sub(firstTime))
+}
+
+func TestWorker_ReportProgressf(t *testing.T) {
+	var called bool
+	var got string
+	w := worker{
+		progressCb: func(msg string) {
+			called = true
+			got = msg
 		},
 	}
 	w.reportProgressf("hello %s", "world")
@@ -543,3 +1089,797 @@ func TestWorker_ReportProgressf(t *testing.T) {
 		t.Fatalf("expected 'hello world', got %q", got)
 	}
 }
+
+func TestHistogramMinMax(t *testing.T) {
+	// arrange
+	qty := 10
+	cfg := configWithNoAttributes(MetricTypeHistogram, qty)
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// assert
+	require.Len(t, m.rms, qty)
+	for _, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64]).DataPoints[0]
+		minVal, ok := dp.Min.Value()
+		require.True(t, ok, "min should be set")
+		maxVal, ok := dp.Max.Value()
+		require.True(t, ok, "max should be set")
+		assert.LessOrEqual(t, minVal, maxVal)
+		//nolint:gosec // test values are small
+		assert.LessOrEqual(t, minVal*int64(dp.Count), dp.Sum, "sum must not be below min*count")
+		//nolint:gosec // test values are small
+		assert.GreaterOrEqual(t, maxVal*int64(dp.Count), dp.Sum, "sum must not exceed max*count")
+	}
+}
+
+func TestHistogramMinMaxOTLPCompat(t *testing.T) {
+	qty := 3
+	cfg := configWithNoAttributes(MetricTypeHistogram, qty)
+	cfg.OTLPCompat = "0.12"
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.rms, qty)
+	for _, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64]).DataPoints[0]
+		_, ok := dp.Min.Value()
+		assert.False(t, ok, "min should be left out")
+		_, ok = dp.Max.Value()
+		assert.False(t, ok, "max should be left out")
+		assert.NotZero(t, dp.Count)
+	}
+}
+
+func TestHistogramExtrema(t *testing.T) {
+	tests := []struct {
+		name         string
+		bucketCounts []uint64
+		wantMin      int64
+		wantMax      int64
+	}{
+		{"empty", []uint64{0, 0, 0, 0}, 0, 0},
+		{"first bucket only", []uint64{2, 0, 0, 0}, 0, 0},
+		{"middle buckets", []uint64{0, 1, 3, 0}, 1, 10},
+		{"overflow bucket", []uint64{0, 0, 1, 1}, 6, 11},
+	}
+	bounds := []float64{0, 5, 10}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMin, gotMax := histogramExtrema(bounds, tt.bucketCounts)
+			assert.Equal(t, tt.wantMin, gotMin)
+			assert.Equal(t, tt.wantMax, gotMax)
+		})
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	qty := 20
+	cfg := configWithNoAttributes(MetricTypeHistogram, qty)
+	cfg.HistogramBuckets = []float64{10, 100, 1000}
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.rms, qty)
+	for _, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64]).DataPoints[0]
+		assert.Equal(t, cfg.HistogramBuckets, dp.Bounds)
+		require.Len(t, dp.BucketCounts, len(cfg.HistogramBuckets)+1)
+		var count uint64
+		for _, n := range dp.BucketCounts {
+			count += n
+		}
+		assert.Equal(t, dp.Count, count, "the buckets should add up to the count")
+		minVal, _ := dp.Min.Value()
+		maxVal, _ := dp.Max.Value()
+		assert.GreaterOrEqual(t, minVal, int64(10))
+		assert.LessOrEqual(t, maxVal, int64(1001))
+		//nolint:gosec // test values are small
+		assert.LessOrEqual(t, minVal*int64(dp.Count), dp.Sum, "sum must not be below min*count")
+		//nolint:gosec // test values are small
+		assert.GreaterOrEqual(t, maxVal*int64(dp.Count), dp.Sum, "sum must not exceed max*count")
+	}
+
+	t.Run("both temporalities", func(t *testing.T) {
+		cfg := configWithNoAttributes(MetricTypeHistogram, 3)
+		cfg.HistogramBuckets = []float64{10, 100}
+		cfg.BothTemporalities = true
+		m := &mockExporter{}
+
+		_, err := run(cfg, m, nil, zap.NewNop())
+		require.NoError(t, err)
+
+		require.Len(t, m.rms, 3)
+		cumulative := m.rms[2].ScopeMetrics[0].Metrics[1].Data.(metricdata.Histogram[int64]).DataPoints[0]
+		assert.Equal(t, cfg.HistogramBuckets, cumulative.Bounds)
+		assert.Equal(t, uint64(3*pointMeasurements), cumulative.Count)
+	})
+}
+
+func TestExponentialHistogram(t *testing.T) {
+	for _, scale := range []int32{-2, 0, 3} {
+		t.Run(fmt.Sprintf("scale %d", scale), func(t *testing.T) {
+			qty := 10
+			cfg := configWithNoAttributes(MetricTypeExponentialHistogram, qty)
+			cfg.ExponentialScale = scale
+			m := &mockExporter{}
+
+			_, err := run(cfg, m, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			require.Len(t, m.rms, qty)
+			for k, rm := range m.rms {
+				dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.ExponentialHistogram[int64]).DataPoints[0]
+				assert.Equal(t, scale, dp.Scale)
+				assert.Equal(t, uint64((k+1)*pointMeasurements), dp.Count, "the counts of a cumulative stream add up")
+				var count uint64
+				for _, n := range dp.PositiveBucket.Counts {
+					count += n
+				}
+				assert.Equal(t, dp.Count, count+dp.ZeroCount, "the buckets should add up to the count")
+				require.NotEmpty(t, dp.PositiveBucket.Counts)
+				assert.NotZero(t, dp.PositiveBucket.Counts[0], "the first bucket holds the min")
+				assert.NotZero(t, dp.PositiveBucket.Counts[len(dp.PositiveBucket.Counts)-1], "the last bucket holds the max")
+				minVal, ok := dp.Min.Value()
+				require.True(t, ok)
+				maxVal, ok := dp.Max.Value()
+				require.True(t, ok)
+				assert.Equal(t, exponentialBucketIndex(float64(minVal), scale), dp.PositiveBucket.Offset)
+				//nolint:gosec // test values are small
+				assert.LessOrEqual(t, minVal*int64(dp.Count), dp.Sum, "sum must not be below min*count")
+				//nolint:gosec // test values are small
+				assert.GreaterOrEqual(t, maxVal*int64(dp.Count), dp.Sum, "sum must not exceed max*count")
+			}
+		})
+	}
+}
+
+func TestExponentialHistogramDelta(t *testing.T) {
+	qty := 5
+	cfg := configWithNoAttributes(MetricTypeExponentialHistogram, qty)
+	cfg.AggregationTemporality = AggregationTemporality(metricdata.DeltaTemporality)
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.rms, qty)
+	for _, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.ExponentialHistogram[int64]).DataPoints[0]
+		assert.Equal(t, uint64(pointMeasurements), dp.Count, "each delta data point has its own measurements")
+	}
+}
+
+func TestExponentialBucketIndex(t *testing.T) {
+	tests := []struct {
+		v     float64
+		scale int32
+		want  int32
+	}{
+		{1, 0, -1},
+		{2, 0, 0},
+		{3, 0, 1},
+		{4, 0, 1},
+		{5, 0, 2},
+		{1024, 0, 9},
+		{3, 1, 3},
+		{4, 1, 3},
+		{5, 1, 4},
+		{4, -1, 0},
+		{5, -1, 1},
+		{1024, 3, 79},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, exponentialBucketIndex(tt.v, tt.scale), "index of %v at scale %d", tt.v, tt.scale)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	qty := 10
+	cfg := configWithNoAttributes(MetricTypeSummary, qty)
+	m := &mockExporter{}
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.rms, qty)
+	var prevSum float64
+	for k, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Summary).DataPoints[0]
+		assert.Equal(t, uint64((k+1)*pointMeasurements), dp.Count, "the count of a summary is cumulative")
+		assert.Greater(t, dp.Sum, prevSum, "the sum of a summary is cumulative")
+		prevSum = dp.Sum
+		require.Len(t, dp.QuantileValues, len(defaultSummaryQuantiles))
+		for k, qv := range dp.QuantileValues {
+			assert.Equal(t, defaultSummaryQuantiles[k], qv.Quantile)
+			if k > 0 {
+				assert.GreaterOrEqual(t, qv.Value, dp.QuantileValues[k-1].Value, "the quantile values should increase")
+			}
+		}
+		assert.LessOrEqual(t, dp.QuantileValues[len(dp.QuantileValues)-1].Value, dp.Sum, "the largest quantile should not exceed the sum")
+		assert.False(t, dp.StartTime.After(dp.Time))
+	}
+
+	t.Run("quantiles", func(t *testing.T) {
+		values := drawMeasurements()
+		totals := &measurementTotals{buckets: make(map[int32]uint64)}
+		totals.add(values)
+		p := summaryPoint(totals, values, []float64{0, 0.5, 1})
+		require.Len(t, p.QuantileValues, 3)
+		assert.LessOrEqual(t, p.QuantileValues[0].Value, p.QuantileValues[1].Value)
+		assert.LessOrEqual(t, p.QuantileValues[1].Value, p.QuantileValues[2].Value)
+		assert.GreaterOrEqual(t, p.QuantileValues[0].Value, 1.0, "the min is the smallest measurement")
+		assert.LessOrEqual(t, p.QuantileValues[2].Value, math.Exp2(maxMeasurementExponent), "the max is the largest measurement")
+	})
+}
+
+func TestCumulativeMetricStartTime(t *testing.T) {
+	// arrange
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumMetrics:             2,
+		MetricName:             "test",
+		MetricType:             MetricTypeSum,
+		AggregationTemporality: AggregationTemporality(metricdata.CumulativeTemporality),
+		MetricStartTime:        "1h",
+	}
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// assert
+	require.Len(t, m.rms, 2)
+	for _, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0]
+		assert.GreaterOrEqual(t, dp.Time.Sub(dp.StartTime), time.Hour, "stream should have started an hour before the data point")
+	}
+}
+
+func TestHistogramMultipleTelemetryAttr(t *testing.T) {
+	// arrange
+	qty := 2
+	cfg := configWithMultipleAttributes(MetricTypeHistogram, qty)
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// asserts
+	require.Len(t, m.rms, qty)
+	var actualValue attribute.Value
+	for _, rm := range m.rms {
+		attr := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64]).DataPoints[0].Attributes
+		assert.Equal(t, 2, attr.Len(), "it must have multiple attributes here")
+		actualValue, _ = attr.Value(telemetryAttrKeyOne)
+		assert.Equal(t, telemetryAttrValueOne, actualValue.AsString(), "it should be "+telemetryAttrValueOne)
+		actualValue, _ = attr.Value(telemetryAttrKeyTwo)
+		assert.Equal(t, telemetryAttrValueTwo, actualValue.AsString(), "it should be "+telemetryAttrValueTwo)
+	}
+}
+
+func TestMetricsMockTelemetryAttr(t *testing.T) {
+	// arrange
+	common.InitMockData(1)
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:         1,
+			MockData:            true,
+			TelemetryAttributes: common.KeyValue{"patient.name": "{{Name}}"},
+		},
+		NumMetrics: 1,
+		MetricName: "test",
+		MetricType: MetricTypeGauge,
+	}
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// assert: metrics carry the same mock marker as traces and logs
+	require.Len(t, m.rms, 1)
+	attr := m.rms[0].ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0].Attributes
+	name, ok := attr.Value("patient.name")
+	require.True(t, ok)
+	assert.NotEqual(t, "{{Name}}", name.AsString())
+	marker, ok := attr.Value("trazr.mock.data")
+	require.True(t, ok)
+	assert.Equal(t, "patient.name", marker.AsString())
+}
+
+func TestDatapointAttributes(t *testing.T) {
+	// arrange
+	cfg := configWithOneAttribute(MetricTypeGauge, 1)
+	cfg.Tags = common.KeyValue{"env": "test"}
+	cfg.DatapointAttributes = common.KeyValue{"http.route": "/checkout"}
+	require.NoError(t, cfg.InitAttributes())
+	m := &mockExporter{}
+
+	// act
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// assert: data point attributes replace the telemetry attributes, tags still apply
+	require.Len(t, m.rms, 1)
+	attr := m.rms[0].ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0].Attributes
+	assert.Equal(t, 2, attr.Len())
+	route, ok := attr.Value("http.route")
+	require.True(t, ok)
+	assert.Equal(t, "/checkout", route.AsString())
+	env, ok := attr.Value("env")
+	require.True(t, ok)
+	assert.Equal(t, "test", env.AsString())
+	_, ok = attr.Value(telemetryAttrKeyOne)
+	assert.False(t, ok, "telemetry attributes are not used when data point attributes are set")
+}
+
+// failingExporter is a metric exporter whose exports all fail.
+type failingExporter struct {
+	mockExporter
+}
+
+func (f *failingExporter) Export(context.Context, *metricdata.ResourceMetrics) error {
+	return context.DeadlineExceeded
+}
+
+func TestMaxErrors(t *testing.T) {
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:   1,
+			Rate:          100,
+			TotalDuration: time.Minute,
+			MaxErrors:     3,
+		},
+		MetricType: MetricTypeSum,
+	}
+	exportErrors := common.NewExportErrors()
+	exportErrors.AbortAfter(int64(cfg.MaxErrors))
+
+	start := time.Now()
+	_, err := run(cfg, errorCountingExporter{Exporter: &failingExporter{}, errors: exportErrors}, exportErrors, zap.NewNop())
+	require.ErrorIs(t, err, common.ErrMaxErrors)
+	assert.Less(t, time.Since(start), 10*time.Second, "the run must be aborted long before its duration")
+	assert.GreaterOrEqual(t, exportErrors.Total(), int64(3))
+}
+
+func TestTimestampEdgeCase(t *testing.T) {
+	for _, edgeCase := range []string{timestampEdgeCaseZeroDuration, timestampEdgeCaseStartAfterTime} {
+		for _, metricType := range []MetricType{MetricTypeSum, MetricTypeHistogram} {
+			t.Run(edgeCase+"/"+string(metricType), func(t *testing.T) {
+				m := &mockExporter{}
+				cfg := configWithNoAttributes(metricType, 3)
+				cfg.TimestampEdgeCase = edgeCase
+
+				_, err := run(cfg, m, nil, zap.NewNop())
+				require.NoError(t, err)
+
+				require.Len(t, m.rms, 3)
+				for _, rm := range m.rms {
+					var start, end time.Time
+					switch data := rm.ScopeMetrics[0].Metrics[0].Data.(type) {
+					case metricdata.Sum[int64]:
+						start, end = data.DataPoints[0].StartTime, data.DataPoints[0].Time
+					case metricdata.Histogram[int64]:
+						start, end = data.DataPoints[0].StartTime, data.DataPoints[0].Time
+					}
+					if edgeCase == timestampEdgeCaseZeroDuration {
+						assert.Equal(t, end, start)
+					} else {
+						assert.True(t, start.After(end), "the start %s should be after the time %s", start, end)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSpecialValueRatio(t *testing.T) {
+	special := func(v float64) bool { return math.IsNaN(v) || math.IsInf(v, 0) }
+	for _, metricType := range []MetricType{MetricTypeGauge, MetricTypeHistogram} {
+		t.Run(string(metricType), func(t *testing.T) {
+			m := &mockExporter{}
+			cfg := configWithNoAttributes(metricType, 3)
+			cfg.TraceID = "ae87dadd90e9935a4bc9660628efd569"
+			cfg.SpecialValueRatio = 1
+
+			_, err := run(cfg, m, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			require.Len(t, m.rms, 3)
+			for _, rm := range m.rms {
+				switch data := rm.ScopeMetrics[0].Metrics[0].Data.(type) {
+				case metricdata.Gauge[float64]:
+					assert.True(t, special(data.DataPoints[0].Value), "got %v", data.DataPoints[0].Value)
+					assert.Len(t, data.DataPoints[0].Exemplars, 1, "the exemplars are kept")
+				case metricdata.Histogram[float64]:
+					point := data.DataPoints[0]
+					assert.True(t, special(point.Sum), "got %v", point.Sum)
+					assert.NotZero(t, point.Count, "only the sum is special")
+					assert.Len(t, point.BucketCounts, len(histogramBounds)+1)
+				default:
+					t.Fatalf("expected float data points, got %T", data)
+				}
+			}
+		})
+	}
+
+	m := &mockExporter{}
+	cfg := configWithNoAttributes(MetricTypeGauge, 3)
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+	for _, rm := range m.rms {
+		assert.IsType(t, metricdata.Gauge[int64]{}, rm.ScopeMetrics[0].Metrics[0].Data, "values are integers by default")
+	}
+}
+
+func TestExemplarSeed(t *testing.T) {
+	traceIDs := func(seed int64) []string {
+		m := &mockExporter{}
+		cfg := configWithNoAttributes(MetricTypeHistogram, 4)
+		cfg.ExemplarSeed = seed
+
+		_, err := run(cfg, m, nil, zap.NewNop())
+		require.NoError(t, err)
+
+		require.Len(t, m.rms, 4)
+		var ids []string
+		for _, rm := range m.rms {
+			point := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[int64]).DataPoints[0]
+			require.Len(t, point.Exemplars, 1)
+			exemplar := point.Exemplars[0]
+			assert.Equal(t, point.Time, exemplar.Time)
+			require.NoError(t, common.ValidateTraceID(hex.EncodeToString(exemplar.TraceID)))
+			require.NoError(t, common.ValidateSpanID(hex.EncodeToString(exemplar.SpanID)))
+			ids = append(ids, hex.EncodeToString(exemplar.TraceID))
+		}
+		return ids
+	}
+
+	ids := traceIDs(42)
+	assert.Len(t, slices.Compact(slices.Sorted(slices.Values(ids))), 4, "each data point points to another trace")
+	assert.Equal(t, ids, traceIDs(42), "the same seed draws the same IDs")
+	assert.NotEqual(t, ids, traceIDs(7))
+}
+
+func TestResourceSchemaURL(t *testing.T) {
+	m := &mockExporter{}
+	cfg := configWithNoAttributes(MetricTypeSum, 1)
+	cfg.SchemaURL = "https://opentelemetry.io/schemas/1.4.0"
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.rms, 1)
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.4.0", m.rms[0].Resource.SchemaURL())
+	assert.Equal(t, semconv.SchemaURL, NewConfig().ResourceSchemaURL(), "defaults to the semantic conventions schema")
+}
+
+func TestDuplicateRatio(t *testing.T) {
+	m := &mockExporter{}
+	cfg := configWithNoAttributes(MetricTypeSum, 3)
+	cfg.DuplicateRatio = 1
+
+	_, err := run(cfg, m, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Len(t, m.rms, 6, "every payload should be sent twice")
+	for i := 0; i < len(m.rms); i += 2 {
+		assert.Same(t, m.rms[i], m.rms[i+1])
+	}
+}
+
+func TestDropRatio(t *testing.T) {
+	m := &mockExporter{}
+	cfg := configWithNoAttributes(MetricTypeSum, 3)
+	cfg.DropRatio = 1
+	exportErrors := common.NewExportErrors()
+
+	stats, err := run(cfg, m, exportErrors, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Empty(t, m.rms, "every payload should be dropped")
+	assert.Equal(t, int64(3), stats.Generated, "the dropped metrics are generated nonetheless")
+	assert.Equal(t, int64(3), exportErrors.Skipped())
+	assert.Zero(t, exportErrors.Total(), "skipped metrics aren't export errors")
+}
+
+func TestStableAttributes(t *testing.T) {
+	common.InitMockData(1)
+	for _, stable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stable=%v", stable), func(t *testing.T) {
+			cfg := &Config{
+				Config: common.Config{
+					WorkerCount:         1,
+					MockData:            true,
+					TelemetryAttributes: common.KeyValue{"request.id": "{{UUID}}"},
+				},
+				NumMetrics:             3,
+				MetricName:             "test",
+				MetricType:             MetricTypeSum,
+				AggregationTemporality: AggregationTemporality(metricdata.CumulativeTemporality),
+				StableAttributes:       stable,
+			}
+			m := &mockExporter{}
+
+			_, err := run(cfg, m, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			require.Len(t, m.rms, 3)
+			ids := map[string]bool{}
+			for _, rm := range m.rms {
+				dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0]
+				id, ok := dp.Attributes.Value("request.id")
+				require.True(t, ok)
+				ids[id.AsString()] = true
+			}
+			if stable {
+				assert.Len(t, ids, 1, "every data point should belong to the same stream")
+			} else {
+				assert.Len(t, ids, 3, "each data point should draw its own attributes")
+			}
+		})
+	}
+}
+
+func TestCardinalityChurn(t *testing.T) {
+	m := &mockExporter{}
+	streamStart := time.Now()
+	running := &atomic.Bool{}
+	running.Store(true)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	w := worker{
+		metricName:             "test_metric",
+		metricType:             MetricTypeSum,
+		aggregationTemporality: AggregationTemporality(metricdata.CumulativeTemporality),
+		numMetrics:             8,
+		running:                running,
+		limitPerSecond:         rate.Inf,
+		logger:                 zap.NewNop(),
+		wg:                     wg,
+		clock:                  &mockClock{now: streamStart},
+		startTime:              streamStart,
+		churnInterval:          250 * time.Millisecond,
+	}
+	w.simulateMetrics([]*resource.Resource{resource.Default()}, m, &Config{})
+	wg.Wait()
+
+	require.Len(t, m.rms, 8)
+	series := map[int64]bool{}
+	var prev metricdata.DataPoint[int64]
+	for i, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0]
+		v, ok := dp.Attributes.Value(seriesAttributeKey)
+		require.True(t, ok, "data point %d should have the %s attribute", i, seriesAttributeKey)
+		current := v.AsInt64()
+		series[current] = true
+		if i == 0 {
+			assert.Equal(t, int64(0), current)
+			assert.Equal(t, streamStart, dp.StartTime)
+			assert.Equal(t, int64(0), dp.Value)
+		} else {
+			prevSeries, _ := prev.Attributes.Value(seriesAttributeKey)
+			if current != prevSeries.AsInt64() {
+				assert.Equal(t, prevSeries.AsInt64()+1, current, "data point %d should start the next series", i)
+				assert.Equal(t, prev.Time, dp.StartTime, "the series of data point %d should start where the previous one went stale", i)
+				assert.Equal(t, int64(0), dp.Value, "the series of data point %d should count from zero", i)
+			} else {
+				assert.Equal(t, prev.StartTime, dp.StartTime, "data point %d should continue its cumulative series", i)
+				assert.Equal(t, prev.Value+1, dp.Value)
+			}
+		}
+		prev = dp
+	}
+	assert.Greater(t, len(series), 2, "the series should churn several times")
+}
+
+func TestFleetSize(t *testing.T) {
+	m := &mockExporter{}
+	running := &atomic.Bool{}
+	running.Store(true)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	cfg := &Config{Config: common.Config{FleetSize: 3}}
+	resources, err := cfg.FleetResources(cfg.ResourceSchemaURL())
+	require.NoError(t, err)
+	w := worker{
+		metricName:             "test_metric",
+		metricType:             MetricTypeSum,
+		aggregationTemporality: AggregationTemporality(metricdata.CumulativeTemporality),
+		numMetrics:             6,
+		running:                running,
+		limitPerSecond:         rate.Inf,
+		logger:                 zap.NewNop(),
+		wg:                     wg,
+		clock:                  &realClock{},
+	}
+	w.simulateMetrics(resources, m, cfg)
+	wg.Wait()
+
+	require.Len(t, m.rms, 6)
+	for i, rm := range m.rms {
+		assert.Same(t, resources[i%3], rm.Resource, "data point %d should come from the next host", i)
+	}
+}
+
+func TestAlignedInterval(t *testing.T) {
+	for _, temporality := range []metricdata.Temporality{metricdata.CumulativeTemporality, metricdata.DeltaTemporality} {
+		t.Run(temporality.String(), func(t *testing.T) {
+			m := &mockExporter{}
+			streamStart := time.Date(2024, 1, 1, 12, 0, 0, 500_000_000, time.UTC)
+			running := &atomic.Bool{}
+			running.Store(true)
+			wg := &sync.WaitGroup{}
+			wg.Add(1)
+
+			w := worker{
+				metricName:             "test_metric",
+				metricType:             MetricTypeSum,
+				aggregationTemporality: AggregationTemporality(temporality),
+				numMetrics:             4,
+				running:                running,
+				limitPerSecond:         rate.Inf,
+				logger:                 zap.NewNop(),
+				wg:                     wg,
+				clock:                  &mockClock{now: streamStart},
+				startTime:              streamStart,
+				alignInterval:          10 * time.Second,
+			}
+			w.simulateMetrics([]*resource.Resource{resource.Default()}, m, &Config{})
+			wg.Wait()
+
+			require.Len(t, m.rms, 4)
+			for i, rm := range m.rms {
+				dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0]
+				want := time.Date(2024, 1, 1, 12, 0, 10*(i+1), 0, time.UTC)
+				assert.Equal(t, want, dp.Time, "data point %d should be on the next interval boundary", i)
+				if temporality == metricdata.DeltaTemporality {
+					assert.Equal(t, want.Add(-10*time.Second), dp.StartTime, "data point %d should cover one interval", i)
+				} else {
+					assert.Equal(t, streamStart, dp.StartTime)
+				}
+			}
+		})
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	m := &mockExporter{}
+	streamStart := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	running := &atomic.Bool{}
+	running.Store(true)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	w := worker{
+		metricName:             "test_metric",
+		metricType:             MetricTypeGauge,
+		aggregationTemporality: AggregationTemporality(metricdata.CumulativeTemporality),
+		running:                running,
+		limitPerSecond:         rate.Inf,
+		logger:                 zap.NewNop(),
+		wg:                     wg,
+		clock:                  &mockClock{now: streamStart},
+		alignInterval:          10 * time.Second,
+		intervals:              5,
+	}
+	w.simulateMetrics([]*resource.Resource{resource.Default()}, m, &Config{})
+	wg.Wait()
+
+	require.Len(t, m.rms, 5, "one data point per interval")
+	last := m.rms[4].ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0]
+	assert.Equal(t, streamStart.Add(50*time.Second), last.Time)
+}
+
+func TestExemplarsCorrelatedWithSpans(t *testing.T) {
+	span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1, 2, 3}, SpanID: trace.SpanID{4, 5, 6}})
+	defer common.EnableCorrelation("metrics")()
+	common.PublishSpanContext(span)
+
+	for name, exemplars := range map[string][]metricdata.Exemplar[int64]{
+		"correlated": nil,
+		"static":     {{Value: 1, TraceID: []byte{9}, SpanID: []byte{9}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := &mockExporter{}
+			running := &atomic.Bool{}
+			running.Store(true)
+			wg := &sync.WaitGroup{}
+			wg.Add(1)
+
+			w := worker{
+				metricName:             "test_metric",
+				metricType:             MetricTypeGauge,
+				aggregationTemporality: AggregationTemporality(metricdata.CumulativeTemporality),
+				exemplars:              exemplars,
+				numMetrics:             2,
+				running:                running,
+				limitPerSecond:         rate.Inf,
+				logger:                 zap.NewNop(),
+				wg:                     wg,
+				clock:                  &realClock{},
+			}
+			w.simulateMetrics([]*resource.Resource{resource.Default()}, m, &Config{})
+			wg.Wait()
+
+			require.Len(t, m.rms, 2)
+			for _, rm := range m.rms {
+				dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0]
+				require.Len(t, dp.Exemplars, 1)
+				if exemplars != nil {
+					assert.Equal(t, []byte{9}, dp.Exemplars[0].TraceID, "the configured exemplars take precedence")
+					continue
+				}
+				assert.Equal(t, span.TraceID().String(), hex.EncodeToString(dp.Exemplars[0].TraceID))
+				assert.Equal(t, span.SpanID().String(), hex.EncodeToString(dp.Exemplars[0].SpanID))
+			}
+		})
+	}
+}
+
+func TestIntervalCycle(t *testing.T) {
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, 1, intervalCycle(first, first, time.Minute))
+	assert.Equal(t, 3, intervalCycle(first, first.Add(2*time.Minute), time.Minute))
+}
+
+func TestAlignedTime(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, base.Add(time.Minute), alignedTime(base.Add(3*time.Second), base.Add(-time.Hour), time.Minute))
+	assert.Equal(t, base, alignedTime(base, base.Add(-time.Hour), time.Minute), "a boundary is aligned already")
+	assert.Equal(t, base.Add(2*time.Minute), alignedTime(base.Add(time.Minute), base.Add(time.Minute), time.Minute), "timestamps keep increasing")
+}
+
+func TestAlignedIntervalPacing(t *testing.T) {
+	m := &mockExporter{}
+	streamStart := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := &mockClock{now: streamStart}
+	running := &atomic.Bool{}
+	running.Store(true)
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	w := worker{
+		metricName:             "test_metric",
+		metricType:             MetricTypeGauge,
+		aggregationTemporality: AggregationTemporality(metricdata.CumulativeTemporality),
+		numMetrics:             5,
+		running:                running,
+		limitPerSecond:         rate.Inf,
+		logger:                 zap.NewNop(),
+		wg:                     wg,
+		clock:                  clock,
+		alignInterval:          time.Minute,
+	}
+	w.simulateMetrics([]*resource.Resource{resource.Default()}, m, &Config{})
+	wg.Wait()
+
+	require.Len(t, m.rms, 5)
+	for i, rm := range m.rms {
+		dp := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints[0]
+		assert.Equal(t, streamStart.Add(time.Duration(i+1)*time.Minute), dp.Time, "data point %d should be on its own interval boundary", i)
+	}
+	assert.False(t, clock.now.Before(streamStart.Add(4*time.Minute)), "the worker waits for the intervals to end")
+}
+
+func TestAlignedIntervalPacingStops(t *testing.T) {
+	running := &atomic.Bool{}
+	w := worker{running: running, clock: &mockClock{}}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	_, ok := w.waitUntil(start, start.Add(time.Hour))
+	assert.False(t, ok, "a stopped generation doesn't wait for the next interval")
+}
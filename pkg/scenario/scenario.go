@@ -0,0 +1,177 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package scenario runs scenario files: ordered phases of telemetry generation, each
+// generating one signal with its own options, to script workloads like an incident,
+// from normal traffic to an error spike, in a single run.
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/medxops/trazr-gen/internal/common"
+	"github.com/medxops/trazr-gen/pkg/logs"
+	"github.com/medxops/trazr-gen/pkg/metrics"
+	"github.com/medxops/trazr-gen/pkg/traces"
+)
+
+// signals are the signals a phase can generate.
+var signals = []string{"traces", "metrics", "logs"}
+
+// Scenario is the content of a scenario file, in YAML or JSON.
+type Scenario struct {
+	Phases []Phase `mapstructure:"phases"`
+}
+
+// Phase is a step of a scenario, generating a single signal.
+type Phase struct {
+	// Name identifies the phase in the logs and errors, "phase N" when empty.
+	Name string `mapstructure:"name"`
+	// Signal is the signal generated: traces, metrics or logs.
+	Signal string `mapstructure:"signal"`
+	// Duration is how long the phase generates, superseding the `duration` of its
+	// options. When 0, the phase ends like the command of its signal would, after the
+	// item count of its options for instance.
+	Duration time.Duration `mapstructure:"duration"`
+	// Options are the options of the phase, keyed like in the config file, like `rate` or
+	// `status-code`, applied on top of those of the base config of the signal.
+	Options map[string]any `mapstructure:"options"`
+}
+
+// Load reads the scenario file at path, in YAML or JSON as told by its extension.
+func Load(path string) (*Scenario, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read the scenario file: %w", err)
+	}
+	var s Scenario
+	if err := v.UnmarshalExact(&s, viper.DecodeHook(mapstructure.StringToTimeDurationHookFunc())); err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Runner runs the phases of scenarios. The config of each phase is a copy of the base
+// config of its signal, with the options of the phase on top.
+type Runner struct {
+	Traces  *traces.Config // base config of the traces phases, the defaults when nil
+	Metrics *metrics.Config
+	Logs    *logs.Config
+}
+
+// plannedPhase is a phase whose config is built and valid, ready to start.
+type plannedPhase struct {
+	name     string
+	signal   string
+	duration time.Duration // `duration` of its config, 0 meaning up to its item count
+	start    func(logger *zap.Logger) (common.RunStats, error)
+}
+
+// Run runs the phases of s one after the other and returns the stats of each. Every phase
+// is checked before the first one starts, so that a mistake in the last phase does not
+// show after the others have run. The run stops at the first phase that fails.
+func (r *Runner) Run(s *Scenario, logger *zap.Logger) ([]common.RunStats, error) {
+	phases, err := r.plan(s)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]common.RunStats, 0, len(phases))
+	for i, p := range phases {
+		logger.Info("starting a phase of the scenario", zap.String("phase", p.name), zap.String("signal", p.signal),
+			zap.Duration("duration", p.duration), zap.Int("number", i+1), zap.Int("phases", len(phases)))
+		phaseStats, err := p.start(logger.Named(p.signal).With(zap.String("phase", p.name)))
+		stats = append(stats, phaseStats)
+		if err != nil {
+			return stats, fmt.Errorf("phase %q: %w", p.name, err)
+		}
+	}
+	logger.Info("scenario completed", zap.Int("phases", len(phases)))
+	return stats, nil
+}
+
+// plan builds and validates the config of every phase of s.
+func (r *Runner) plan(s *Scenario) ([]plannedPhase, error) {
+	if s == nil || len(s.Phases) == 0 {
+		return nil, errors.New("the scenario has no phases, expected at least one under `phases`")
+	}
+	phases := make([]plannedPhase, len(s.Phases))
+	for i, phase := range s.Phases {
+		name := phase.Name
+		if name == "" {
+			name = fmt.Sprintf("phase %d", i+1)
+		}
+		start, duration, err := r.phaseStart(phase)
+		if err != nil {
+			return nil, fmt.Errorf("phase %q: %w", name, err)
+		}
+		phases[i] = plannedPhase{name: name, signal: phase.Signal, duration: duration, start: start}
+	}
+	return phases, nil
+}
+
+// phaseStart returns the function starting the generator of phase with its config, along
+// with the `duration` of that config.
+func (r *Runner) phaseStart(phase Phase) (func(logger *zap.Logger) (common.RunStats, error), time.Duration, error) {
+	if phase.Duration < 0 {
+		return nil, 0, common.ValidationErrorf("duration", "`duration` must not be negative")
+	}
+	switch phase.Signal {
+	case "traces":
+		cfg := cloneConfig(orNew(r.Traces, traces.NewConfig))
+		if err := decodeOptions(phase, cfg, &cfg.TotalDuration, cfg.Validate); err != nil {
+			return nil, 0, err
+		}
+		return func(logger *zap.Logger) (common.RunStats, error) { return traces.Start(cfg, logger) }, cfg.TotalDuration, nil
+	case "metrics":
+		cfg := cloneConfig(orNew(r.Metrics, metrics.NewConfig))
+		if err := decodeOptions(phase, cfg, &cfg.TotalDuration, cfg.Validate); err != nil {
+			return nil, 0, err
+		}
+		return func(logger *zap.Logger) (common.RunStats, error) { return metrics.Start(cfg, logger) }, cfg.TotalDuration, nil
+	case "logs":
+		cfg := cloneConfig(orNew(r.Logs, logs.NewConfig))
+		if err := decodeOptions(phase, cfg, &cfg.TotalDuration, cfg.Validate); err != nil {
+			return nil, 0, err
+		}
+		return func(logger *zap.Logger) (common.RunStats, error) { return logs.Start(cfg, logger) }, cfg.TotalDuration, nil
+	}
+	return nil, 0, common.ValidationErrorf("signal", "expected `signal` to be one of %s, got %q instead", strings.Join(signals, ", "), phase.Signal)
+}
+
+// orNew returns cfg, or a new config when it is nil.
+func orNew[T any](cfg *T, newConfig func() *T) *T {
+	if cfg == nil {
+		return newConfig()
+	}
+	return cfg
+}
+
+// decodeOptions decodes the options of phase into cfg, sets its duration and validates it.
+func decodeOptions(phase Phase, cfg any, duration *time.Duration, validate func() error) error {
+	if unknown := common.UnknownConfigKeys(phase.Options, cfg); len(unknown) > 0 {
+		return fmt.Errorf("unknown %s options: %s", phase.Signal, strings.Join(unknown, ", "))
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       common.ConfigDecodeHook,
+		WeaklyTypedInput: true,
+		Result:           cfg,
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(phase.Options); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	if phase.Duration > 0 {
+		*duration = phase.Duration
+	}
+	return validate()
+}
@@ -0,0 +1,189 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package scenario
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+
+	"github.com/medxops/trazr-gen/internal/common"
+	"github.com/medxops/trazr-gen/pkg/metrics"
+	"github.com/medxops/trazr-gen/pkg/traces"
+)
+
+// recordingExporter keeps the spans of every phase, unlike tracetest.InMemoryExporter
+// which forgets them when the phase shuts it down.
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (e *recordingExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (*recordingExporter) Shutdown(context.Context) error { return nil }
+
+// recorded is the exporter of the "scenario-test" `exporter`. The registry outlives a test,
+// so the tests reading it start with newRecording.
+var recorded = &recordingExporter{}
+
+func init() {
+	traces.RegisterExporter("scenario-test", func(*traces.Config) (sdktrace.SpanExporter, error) { return recorded, nil })
+}
+
+// newRecording clears the spans recorded so far, and again once the test is done, so that
+// a test only sees the spans of its own phases even when run with -count.
+func newRecording(t *testing.T) *recordingExporter {
+	reset := func() {
+		recorded.mu.Lock()
+		defer recorded.mu.Unlock()
+		recorded.spans = nil
+	}
+	reset()
+	t.Cleanup(reset)
+	return recorded
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	want := &Scenario{Phases: []Phase{
+		{Name: "normal", Signal: "traces", Duration: 30 * time.Second, Options: map[string]any{"rate": 10}},
+		{Name: "error spike", Signal: "traces", Duration: 10 * time.Second, Options: map[string]any{"status-code": "Error"}},
+	}}
+
+	s, err := Load(writeFile(t, "incident.yaml", `
+phases:
+  - name: normal
+    signal: traces
+    duration: 30s
+    options:
+      rate: 10
+  - name: error spike
+    signal: traces
+    duration: 10s
+    options:
+      status-code: Error
+`))
+	require.NoError(t, err)
+	assert.Equal(t, want, s)
+
+	s, err = Load(writeFile(t, "incident.json", `{"phases": [
+		{"name": "normal", "signal": "traces", "duration": "30s", "options": {"rate": 10}},
+		{"name": "error spike", "signal": "traces", "duration": "10s", "options": {"status-code": "Error"}}
+	]}`))
+	require.NoError(t, err)
+	require.Len(t, s.Phases, 2)
+	assert.Equal(t, want.Phases[1], s.Phases[1])
+	assert.InDelta(t, 10, s.Phases[0].Options["rate"], 0, "JSON numbers are floats")
+
+	_, err = Load(writeFile(t, "typo.yaml", "phases:\n  - signal: traces\n    durration: 1s\n"))
+	require.ErrorContains(t, err, "durration")
+}
+
+func TestRun(t *testing.T) {
+	recording := newRecording(t)
+	base := traces.NewConfig()
+	base.TerminalOutput = false
+	base.Exporter = "scenario-test"
+	base.NumTraces = 5
+	base.ResourceAttributes = common.KeyValue{"env": "test"}
+	s := &Scenario{Phases: []Phase{
+		{Name: "normal", Signal: "traces", Options: map[string]any{"traces": 2}},
+		{Name: "error spike", Signal: "traces", Options: map[string]any{"traces": "1", "status-code": "Error", "otlp-attributes": map[string]any{"incident": "true"}}},
+	}}
+
+	stats, err := (&Runner{Traces: base}).Run(s, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, int64(2), stats[0].Generated)
+	assert.Equal(t, int64(1), stats[1].Generated)
+
+	recording.mu.Lock()
+	defer recording.mu.Unlock()
+	require.Len(t, recording.spans, 6, "traces of a parent and a child span")
+	for _, span := range recording.spans[:4] {
+		assert.NotEqual(t, "Error", span.Status().Code.String())
+	}
+	for _, span := range recording.spans[4:] {
+		assert.Equal(t, "Error", span.Status().Code.String())
+		incident, _ := span.Resource().Set().Value("incident")
+		assert.Equal(t, "true", incident.AsString())
+	}
+
+	assert.Equal(t, 5, base.NumTraces, "the phases leave the base config as is")
+	assert.Equal(t, common.KeyValue{"env": "test"}, base.ResourceAttributes)
+}
+
+func TestRun_ChecksEveryPhaseFirst(t *testing.T) {
+	tests := []struct {
+		name  string
+		phase Phase
+		err   string
+	}{
+		{name: "unknown signal", phase: Phase{Signal: "profiles"}, err: "expected `signal` to be one of traces, metrics, logs"},
+		{name: "negative duration", phase: Phase{Signal: "logs", Duration: -time.Second}, err: "`duration` must not be negative"},
+		{name: "unknown option", phase: Phase{Signal: "metrics", Options: map[string]any{"rtae": 1}}, err: "unknown metrics options: rtae"},
+		{name: "mistyped option", phase: Phase{Signal: "metrics", Options: map[string]any{"workers": "many"}}, err: "invalid options"},
+		{name: "invalid option", phase: Phase{Signal: "logs", Options: map[string]any{"drop-ratio": 2}}, err: "`drop-ratio` must be between 0 and 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{Phases: []Phase{{Signal: "traces", Options: map[string]any{"exporter": "scenario-test"}}, tt.phase}}
+			stats, err := (&Runner{}).Run(s, zap.NewNop())
+			require.ErrorContains(t, err, `phase "phase 2": `+tt.err)
+			assert.Nil(t, stats, "no phase runs")
+		})
+	}
+
+	_, err := (&Runner{}).Run(&Scenario{}, zap.NewNop())
+	require.ErrorContains(t, err, "the scenario has no phases")
+}
+
+func TestPhaseDuration(t *testing.T) {
+	r := &Runner{Metrics: metrics.NewConfig()}
+	phases, err := r.plan(&Scenario{Phases: []Phase{
+		{Signal: "metrics", Duration: time.Minute, Options: map[string]any{"duration": "5s", "rate": 20}},
+		{Signal: "metrics", Options: map[string]any{"duration": "5s"}},
+	}})
+	require.NoError(t, err)
+	require.Len(t, phases, 2)
+	assert.Equal(t, "phase 1", phases[0].name)
+	assert.Equal(t, time.Minute, phases[0].duration, "the duration of the phase supersedes the one of its options")
+	assert.Equal(t, 5*time.Second, phases[1].duration, "without its own duration, the phase runs for the one of its options")
+	assert.Zero(t, r.Metrics.TotalDuration)
+}
+
+func TestCloneConfig(t *testing.T) {
+	base := traces.NewConfig()
+	base.ResourceAttributes = common.KeyValue{"host.ip": []any{"10.0.0.1"}}
+	base.ChildSpanKinds = []string{"client"}
+	base.ClientAuth.Enabled = true
+
+	clone := cloneConfig(base)
+	assert.Equal(t, base, clone)
+	clone.ResourceAttributes["host.ip"].([]any)[0] = "10.0.0.2"
+	clone.ResourceAttributes["env"] = "prod"
+	clone.ChildSpanKinds[0] = "server"
+
+	assert.Equal(t, common.KeyValue{"host.ip": []any{"10.0.0.1"}}, base.ResourceAttributes)
+	assert.Equal(t, []string{"client"}, base.ChildSpanKinds)
+}
@@ -4,25 +4,50 @@
 package traces
 
 import (
-	"errors"
+	"encoding/hex"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
 
+// defaultNumTraces is the default number of traces generated by each worker.
+const defaultNumTraces = 1
+
 // Config holds all traces subcommand configuration for CLI and config file.
 // All fields must have a `mapstructure` tag matching the CLI/config key (dashed, lower-case).
 type Config struct {
-	common.Config    `mapstructure:",squash"`
-	NumTraces        int           `mapstructure:"traces"`
-	NumChildSpans    int           `mapstructure:"child-spans"`
-	PropagateContext bool          `mapstructure:"marshal"`
-	StatusCode       string        `mapstructure:"status-code"`
-	Batch            bool          `mapstructure:"batch"`
-	LoadSize         int           `mapstructure:"size"`
-	SpanDuration     time.Duration `mapstructure:"span-duration"`
+	common.Config     `mapstructure:",squash"`
+	NumTraces         int           `mapstructure:"traces"`
+	NumChildSpans     int           `mapstructure:"child-spans"`
+	TraceSizes        string        `mapstructure:"trace-size-distribution"`
+	Topology          string        `mapstructure:"topology"`
+	ConcurrentTraces  int           `mapstructure:"concurrent-traces"`
+	RatePerTrace      bool          `mapstructure:"rate-per-trace"`
+	PropagateContext  bool          `mapstructure:"marshal"`
+	StatusCode        string        `mapstructure:"status-code"`
+	Batch             bool          `mapstructure:"batch"`
+	LoadSize          int           `mapstructure:"size"`
+	SpanDuration      time.Duration `mapstructure:"span-duration"`
+	PeerAddress       string        `mapstructure:"peer-address"`
+	PeerService       string        `mapstructure:"peer-service"`
+	EmitException     bool          `mapstructure:"emit-exception"`
+	ChildSpanKinds    []string      `mapstructure:"child-span-kinds"`
+	TraceFlags        string        `mapstructure:"trace-flags"`
+	SlowSpanRatio     float64       `mapstructure:"slow-span-ratio"`
+	SlowSpanFactor    float64       `mapstructure:"slow-span-factor"`
+	TimestampEdgeCase string        `mapstructure:"span-timestamp-edge-case"`
+	LegacyLibrary     bool          `mapstructure:"emit-legacy-library-attrs"`
+	SelfDescribe      bool          `mapstructure:"self-describe"`
+	AttrDropChance    float64       `mapstructure:"attribute-drop-probability"`
+	SchemaURL         string        `mapstructure:"traces-schema-url"`
+	Exporter          string        `mapstructure:"exporter"`
+	TracesLogLevel    string        `mapstructure:"traces-log-level"`
 }
 
 func NewConfig() *Config {
@@ -35,15 +60,33 @@ func NewConfig() *Config {
 func (c *Config) Flags(fs *pflag.FlagSet) {
 	c.CommonFlags(fs)
 
-	fs.StringVar(&c.HTTPPath, "otlp-http-url-path", c.HTTPPath, "Which URL path to write to")
+	fs.StringVar(&c.HTTPPath, "otlp-http-url-path", c.HTTPPath, "Which URL path to write to, where {signal} stands for 'traces'")
 
 	fs.IntVar(&c.NumTraces, "traces", c.NumTraces, "Number of traces to generate in each worker (ignored if duration is provided)")
 	fs.IntVar(&c.NumChildSpans, "child-spans", c.NumChildSpans, "Number of child spans to generate for each trace")
+	fs.StringVar(&c.TraceSizes, "trace-size-distribution", c.TraceSizes, "Weights of the numbers of child spans of the traces, as SIZE:WEIGHT pairs like '1:80,50:20' for mostly short traces and a few long ones, picked as each trace starts. Supersedes --child-spans")
+	fs.StringVar(&c.Topology, "topology", c.Topology, "Services the traces walk, each span emitted by its service with its own service.name resource: a preset among "+strings.Join(topologyPresetNames(), ", ")+", or a YAML or JSON file of services and the calls they make. Supersedes --child-spans, --trace-size-distribution and --child-span-kinds")
+	fs.IntVar(&c.ConcurrentTraces, "concurrent-traces", c.ConcurrentTraces, "Number of traces each worker keeps open at once, taking turns emitting their child spans like a server handling concurrent requests, instead of completing each trace before the next")
+	fs.BoolVar(&c.RatePerTrace, "rate-per-trace", c.RatePerTrace, "Make --rate count whole traces instead of spans, emitting the child spans of a trace without waiting for the rate limiter")
 	fs.BoolVar(&c.PropagateContext, "marshal", c.PropagateContext, "Whether to marshal trace context via HTTP headers")
 	fs.StringVar(&c.StatusCode, "status-code", c.StatusCode, "Status code to use for the spans, one of (Unset, Error, Ok) or the equivalent integer (0,1,2)")
 	fs.BoolVar(&c.Batch, "batch", c.Batch, "Whether to batch traces")
 	fs.IntVar(&c.LoadSize, "size", c.LoadSize, "Desired minimum size in MB of string data for each trace generated. This can be used to test traces with large payloads, i.e. when testing the OTLP receiver endpoint max receive size.")
 	fs.DurationVar(&c.SpanDuration, "span-duration", c.SpanDuration, "The duration of each generated span.")
+	fs.Float64Var(&c.SlowSpanRatio, "slow-span-ratio", c.SlowSpanRatio, "Share of child spans, between 0 and 1, lasting --slow-span-factor times --span-duration, to produce tail latency outliers for latency alerts")
+	fs.Float64Var(&c.SlowSpanFactor, "slow-span-factor", c.SlowSpanFactor, "How many times longer than --span-duration the slow spans of --slow-span-ratio last")
+	fs.StringVar(&c.TimestampEdgeCase, "span-timestamp-edge-case", c.TimestampEdgeCase, "Emit child spans that are not contained in their parent to test how they are handled: 'child-starts-early' for children starting before their parent, 'child-ends-late' for children ending after it")
+	fs.BoolVar(&c.LegacyLibrary, "emit-legacy-library-attrs", c.LegacyLibrary, "Also set the instrumentation scope in the otel.library.name and otel.library.version span attributes, the convention predating scopes that older backends key on")
+	fs.BoolVar(&c.SelfDescribe, "self-describe", c.SelfDescribe, "Tag each span with the trazr.worker.id of the worker emitting it and the trazr.target.rate it is meant to emit at, to correlate the rate the backend observes with the generator")
+	fs.Float64Var(&c.AttrDropChance, "attribute-drop-probability", c.AttrDropChance, "Probability, between 0 and 1, of leaving out each attribute of a span, counted in its dropped attributes, to simulate an SDK over its attribute limits. The trazr.* markers are kept")
+	fs.StringVar(&c.PeerAddress, "peer-address", c.PeerAddress, "Value of the net.sock.peer.addr span attribute, mock-data templates supported")
+	fs.StringVar(&c.PeerService, "peer-service", c.PeerService, "Value of the peer.service span attribute, mock-data templates supported (default: trazr-gen-server for parents, trazr-gen-client for children)")
+	fs.BoolVar(&c.EmitException, "emit-exception", c.EmitException, "Whether to record an exception event on spans with an Error status")
+	fs.StringVar(&c.TraceFlags, "trace-flags", c.TraceFlags, "W3C trace flags, as two hex digits, of the generated span contexts, e.g. 00 (unsampled) or 01 (sampled): the exported spans, the traceparent propagated with --marshal and the correlated logs carry them, and the unsampled spans are exported nonetheless. Defaults to sampled")
+	fs.StringSliceVar(&c.ChildSpanKinds, "child-span-kinds", c.ChildSpanKinds, "Span kinds to pick from at random for each child span, among internal, server, client, producer and consumer (default: server)")
+	fs.StringVar(&c.Exporter, "exporter", c.Exporter, "Span exporter to send the traces with: otlp, or the name of an exporter registered with traces.RegisterExporter by a program embedding trazr-gen")
+	fs.StringVar(&c.SchemaURL, "traces-schema-url", c.SchemaURL, "Schema URL of the traces resource, to test schema translation between signals. Defaults to the semantic conventions version trazr-gen is built with")
+	fs.StringVar(&c.TracesLogLevel, "traces-log-level", c.TracesLogLevel, "Log level of the traces generator, overriding --log-level: debug, info, warn, error")
 }
 
 // SetDefaults sets the default values for the configuration
@@ -52,23 +95,180 @@ func (c *Config) Flags(fs *pflag.FlagSet) {
 func (c *Config) SetDefaults() {
 	c.Config.SetDefaults()
 	c.HTTPPath = "/v1/traces"
-	c.NumTraces = 1
+	c.NumTraces = defaultNumTraces
 	c.NumChildSpans = 1
+	c.TraceSizes = ""
+	c.Topology = ""
+	c.ConcurrentTraces = 1
+	c.RatePerTrace = false
 	c.PropagateContext = false
 	c.StatusCode = "0"
 	c.Batch = true
 	c.LoadSize = 0
 	c.SpanDuration = 123 * time.Microsecond
+	c.SlowSpanRatio = 0
+	c.SlowSpanFactor = 100
+	c.TimestampEdgeCase = ""
+	c.LegacyLibrary = false
+	c.SelfDescribe = false
+	c.AttrDropChance = 0
+	c.PeerAddress = fakeIP
+	c.PeerService = ""
+	c.EmitException = false
+	c.ChildSpanKinds = []string{}
+	c.TraceFlags = ""
+	c.SchemaURL = ""
+	c.Exporter = ExporterOTLP
+	c.TracesLogLevel = ""
 }
 
-// Validate validates the test scenario parameters.
+// ValidationError is the error of an invalid option returned by Validate, and by Start
+// for the TLS options, whose Field tells which option it is with errors.As.
+type ValidationError = common.ValidationError
+
+// Validate validates the test scenario parameters. Its errors are *ValidationError.
 func (c *Config) Validate() error {
-	if c.TotalDuration <= 0 && c.NumTraces <= 0 {
-		return errors.New("either `traces` or `duration` must be greater than 0")
+	if c.TotalDuration <= 0 && c.TotalBytes == "" && c.NumTraces <= 0 {
+		return common.ValidationErrorf("traces", "either `traces` or `duration` must be greater than 0")
+	}
+
+	if c.WarmupDuration < 0 {
+		return common.ValidationErrorf("warmup-duration", "`warmup-duration` must not be negative")
+	}
+
+	if c.MaxErrors < 0 {
+		return common.ValidationErrorf("max-errors", "`max-errors` must not be negative")
+	}
+
+	if c.DuplicateRatio < 0 || c.DuplicateRatio > 1 {
+		return common.ValidationErrorf("duplicate-ratio", "`duplicate-ratio` must be between 0 and 1")
+	}
+
+	if c.DropRatio < 0 || c.DropRatio > 1 {
+		return common.ValidationErrorf("drop-ratio", "`drop-ratio` must be between 0 and 1")
+	}
+
+	if c.ScopesPerResource < 0 {
+		return common.ValidationErrorf("scopes-per-resource", "`scopes-per-resource` must not be negative")
+	}
+
+	if c.FleetSize < 0 {
+		return common.ValidationErrorf("fleet-size", "`fleet-size` must not be negative")
+	}
+
+	if _, err := c.TotalBytesLimit(); err != nil {
+		return err
+	}
+
+	if c.TUI && !c.TerminalOutput {
+		return common.ValidationErrorf("tui", "`tui` requires `terminal-output`")
+	}
+
+	if err := common.ValidateEndpoint(c.Endpoint()); err != nil {
+		return common.NewValidationError("otlp-endpoint", err)
+	}
+
+	if err := common.ValidateOTLPCompat(c.OTLPCompat); err != nil {
+		return err
+	}
+
+	if err := common.ValidateExportTraceparent(c.ExportTraceparent, c.ExportTracestate); err != nil {
+		return err
+	}
+
+	if err := common.ValidateGRPCMetadata(c.GRPCMetadata); err != nil {
+		return err
+	}
+
+	if err := common.ValidateSensitiveData(c.SensitiveData); err != nil {
+		return err
+	}
+
+	if err := common.ValidateSchemaURL("traces-schema-url", c.SchemaURL); err != nil {
+		return err
+	}
+
+	if err := common.ValidateVerifyEndpoint(c.VerifyEndpoint); err != nil {
+		return err
+	}
+
+	if err := c.ValidateBoundedMemory(); err != nil {
+		return err
+	}
+	if c.BoundedMemory && !c.Batch {
+		return common.ValidationErrorf("bounded-memory", "`bounded-memory` caps the queue of the batch span processor, it requires `batch`")
+	}
+
+	if err := c.ValidateRateSchedule(); err != nil {
+		return err
+	}
+
+	if err := validateExporter(c.Exporter); err != nil {
+		return err
+	}
+	if c.TotalBytes != "" && c.Exporter != "" && c.Exporter != ExporterOTLP {
+		return common.ValidationErrorf("total-bytes", "`total-bytes` counts the bytes of the OTLP export requests, it can't be combined with the registered `exporter` %q", c.Exporter)
+	}
+	if c.NullAttributes && c.Exporter != "" && c.Exporter != ExporterOTLP {
+		return common.ValidationErrorf("null-attributes", "`null-attributes` empties the values of the OTLP export requests, it can't be combined with the registered `exporter` %q", c.Exporter)
+	}
+
+	if _, _, err := c.GetTraceFlags(); err != nil {
+		return err
+	}
+
+	if c.ConcurrentTraces < 0 {
+		return common.ValidationErrorf("concurrent-traces", "`concurrent-traces` must not be negative")
+	}
+
+	if c.AttrDropChance < 0 || c.AttrDropChance > 1 {
+		return common.ValidationErrorf("attribute-drop-probability", "`attribute-drop-probability` must be between 0 and 1")
+	}
+
+	if c.SlowSpanRatio < 0 || c.SlowSpanRatio > 1 {
+		return common.ValidationErrorf("slow-span-ratio", "`slow-span-ratio` must be between 0 and 1")
+	}
+	if c.SlowSpanRatio > 0 {
+		// NaN fails every comparison
+		if !(c.SlowSpanFactor >= 1) || math.IsInf(c.SlowSpanFactor, 1) {
+			return common.ValidationErrorf("slow-span-factor", "`slow-span-factor` must be a finite number of at least 1")
+		}
+		if float64(c.SpanDuration)*c.SlowSpanFactor >= math.MaxInt64 {
+			return common.ValidationErrorf("slow-span-factor", "`slow-span-factor` times `span-duration` must be below %s", time.Duration(math.MaxInt64))
+		}
+	}
+
+	switch c.TimestampEdgeCase {
+	case "", timestampEdgeCaseChildStartsEarly, timestampEdgeCaseChildEndsLate:
+	default:
+		return common.ValidationErrorf("span-timestamp-edge-case", "expected `span-timestamp-edge-case` to be one of child-starts-early or child-ends-late, got %q instead", c.TimestampEdgeCase)
+	}
+
+	if _, err := parseTraceSizeDistribution(c.TraceSizes); err != nil {
+		return common.NewValidationError("trace-size-distribution", err)
+	}
+
+	if _, err := loadTopology(c.Topology); err != nil {
+		return common.NewValidationError("topology", err)
+	}
+	if c.Topology != "" && c.FleetSize > 1 {
+		return common.ValidationErrorf("topology", "`topology` gives each service its own resource, it can't be combined with `fleet-size`")
 	}
 	return nil
 }
 
+// GetTraceFlags returns the trace flags set with `trace-flags` and whether they are set.
+func (c *Config) GetTraceFlags() (trace.TraceFlags, bool, error) {
+	if c.TraceFlags == "" {
+		return 0, false, nil
+	}
+	b, err := hex.DecodeString(c.TraceFlags)
+	if err != nil || len(b) != 1 {
+		return 0, false, common.ValidationErrorf("trace-flags", "expected `trace-flags` to be two hex digits, like 00 or 01, got %q instead", c.TraceFlags)
+	}
+	return trace.TraceFlags(b[0]), true, nil
+}
+
 func (c *Config) GetHeaders() map[string]string {
 	return c.Config.GetHeaders()
 }
@@ -82,3 +282,9 @@ func (c *Config) IsMockDataEnabled() bool {
 func (c *Config) InitAttributes() error {
 	return c.Config.InitAttributes()
 }
+
+// ResourceSchemaURL returns the schema URL of the traces resource: `traces-schema-url`, or else
+// the one of the semantic conventions the resource attributes follow.
+func (c *Config) ResourceSchemaURL() string {
+	return common.ResourceSchemaURL(c.SchemaURL, semconv.SchemaURL)
+}
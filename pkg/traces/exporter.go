@@ -4,16 +4,23 @@
 package traces
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
 	"fmt"
+	"math"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
 
 // grpcExporterOptions creates the configuration options for a gRPC-based OTLP trace exporter.
-// It configures the exporter with the provided endpoint, connection security settings, and headers.
+// It configures the exporter with the provided endpoint, connection security settings, authority and headers.
 func grpcExporterOptions(cfg *Config) ([]otlptracegrpc.Option, error) {
 	grpcExpOpt := []otlptracegrpc.Option{
 		otlptracegrpc.WithEndpoint(cfg.Endpoint()),
@@ -31,6 +38,10 @@ func grpcExporterOptions(cfg *Config) ([]otlptracegrpc.Option, error) {
 		grpcExpOpt = append(grpcExpOpt, otlptracegrpc.WithTLSCredentials(credentials))
 	}
 
+	if dialOpts := cfg.ExportDialOptions(); len(dialOpts) > 0 {
+		grpcExpOpt = append(grpcExpOpt, otlptracegrpc.WithDialOption(dialOpts...))
+	}
+
 	headers, err := cfg.GetHeadersWithMockMarker()
 	if err != nil {
 		return nil, err
@@ -47,13 +58,15 @@ func grpcExporterOptions(cfg *Config) ([]otlptracegrpc.Option, error) {
 func httpExporterOptions(cfg *Config) ([]otlptracehttp.Option, error) {
 	httpExpOpt := []otlptracehttp.Option{
 		otlptracehttp.WithEndpoint(cfg.Endpoint()),
-		otlptracehttp.WithURLPath(cfg.HTTPPath),
+		otlptracehttp.WithURLPath(cfg.URLPath("traces")),
 	}
 
+	var tlsCfg *tls.Config
 	if cfg.Insecure {
 		httpExpOpt = append(httpExpOpt, otlptracehttp.WithInsecure())
 	} else {
-		tlsCfg, err := common.GetTLSCredentialsForHTTPExporter(
+		var err error
+		tlsCfg, err = common.GetTLSCredentialsForHTTPExporter(
 			cfg.CaFile, cfg.ClientAuth, cfg.InsecureSkipVerify,
 		)
 		if err != nil {
@@ -70,5 +83,96 @@ func httpExporterOptions(cfg *Config) ([]otlptracehttp.Option, error) {
 		httpExpOpt = append(httpExpOpt, otlptracehttp.WithHeaders(headers))
 	}
 
+	if client := cfg.ExportHTTPClient("traces", tlsCfg); client != nil {
+		httpExpOpt = append(httpExpOpt, otlptracehttp.WithHTTPClient(client))
+	}
+
 	return httpExpOpt, nil
 }
+
+// errorCountingExporter counts the outcome of the exports of the decorated span
+// exporter with common.ExportErrors.CountExport.
+type errorCountingExporter struct {
+	sdktrace.SpanExporter
+	errors *common.ExportErrors
+}
+
+func (e errorCountingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.errors.CountExport(e.SpanExporter.ExportSpans(ctx, spans), len(spans))
+}
+
+// duplicatingExporter exports the `duplicate-ratio` share of the batches twice, with the
+// same trace and span IDs, see common.ExportDuplicated.
+type duplicatingExporter struct {
+	sdktrace.SpanExporter
+	ratio float64
+}
+
+func (e duplicatingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return common.ExportDuplicated(e.ratio, func() error { return e.SpanExporter.ExportSpans(ctx, spans) })
+}
+
+// droppingExporter leaves the spans of the `drop-ratio` share of the traces out of the
+// exports, see common.DropItems. It decides on the trace ID, so that the spans of a
+// trace are dropped together whichever batch they are in.
+type droppingExporter struct {
+	sdktrace.SpanExporter
+	ratio   float64
+	skipped *common.ExportErrors
+}
+
+func (e droppingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	kept := common.DropItems(spans, func(s sdktrace.ReadOnlySpan) bool {
+		return dropsTrace(s.SpanContext().TraceID(), e.ratio)
+	}, e.skipped)
+	if len(kept) == 0 {
+		return nil
+	}
+	return e.SpanExporter.ExportSpans(ctx, kept)
+}
+
+// dropsTrace reports whether the trace falls in the ratio share of the traces. Trace IDs
+// are random, so their low bytes spread evenly.
+func dropsTrace(id trace.TraceID, ratio float64) bool {
+	return float64(binary.BigEndian.Uint64(id[8:])) < ratio*math.MaxUint64
+}
+
+// droppedAttributesExporter leaves the attributes of the spans picked by
+// common.KeepAttributes out, counting them in their dropped attributes like an SDK over
+// its attribute limits does.
+type droppedAttributesExporter struct {
+	sdktrace.SpanExporter
+	probability float64
+}
+
+func (e droppedAttributesExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	out := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, s := range spans {
+		kept, dropped := common.KeepAttributes(s.Attributes(), attributeKey, e.probability)
+		out[i] = s
+		if dropped > 0 {
+			out[i] = droppedAttributesSpan{ReadOnlySpan: s, attrs: kept, dropped: s.DroppedAttributes() + dropped}
+		}
+	}
+	return e.SpanExporter.ExportSpans(ctx, out)
+}
+
+// attributeKey returns the key of a span attribute.
+func attributeKey(kv attribute.KeyValue) string {
+	return string(kv.Key)
+}
+
+// droppedAttributesSpan overrides the attributes of a span and their dropped count.
+type droppedAttributesSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs   []attribute.KeyValue
+	dropped int
+}
+
+func (s droppedAttributesSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+func (s droppedAttributesSpan) DroppedAttributes() int {
+	return s.dropped
+}
@@ -0,0 +1,79 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// ExporterOTLP is the built-in `exporter`, sending spans over OTLP gRPC or HTTP.
+const ExporterOTLP = "otlp"
+
+// ExporterFactory creates the span exporter of a scenario. The decorators of trazr-gen,
+// counting errors or rewriting trace flags, are applied on top of it.
+type ExporterFactory func(cfg *Config) (sdktrace.SpanExporter, error)
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]ExporterFactory{}
+)
+
+// RegisterExporter makes a span exporter selectable with `exporter` name, to send spans to
+// a destination trazr-gen has no built-in support for. It is meant to be called from the
+// init function of a downstream package, and panics when name is empty, is already
+// registered or when factory is nil.
+func RegisterExporter(name string, factory ExporterFactory) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	if name == "" {
+		panic("traces: RegisterExporter called with an empty name")
+	}
+	if factory == nil {
+		panic("traces: RegisterExporter factory is nil for exporter " + name)
+	}
+	if _, dup := exporters[name]; dup || name == ExporterOTLP {
+		panic("traces: RegisterExporter called twice for exporter " + name)
+	}
+	exporters[name] = factory
+}
+
+// unregisterExporter removes the exporter registered as name, for the tests registering
+// their own to leave the registry as they found it.
+func unregisterExporter(name string) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	delete(exporters, name)
+}
+
+// registeredExporter returns the factory registered as name.
+func registeredExporter(name string) (ExporterFactory, bool) {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	factory, ok := exporters[name]
+	return factory, ok
+}
+
+// validateExporter checks that an `exporter` value is the built-in one or registered.
+func validateExporter(name string) error {
+	if name == "" || name == ExporterOTLP {
+		return nil
+	}
+	if _, ok := registeredExporter(name); ok {
+		return nil
+	}
+	exportersMu.RLock()
+	names := []string{ExporterOTLP}
+	for n := range exporters {
+		names = append(names, n)
+	}
+	exportersMu.RUnlock()
+	slices.Sort(names)
+	return common.ValidationErrorf("exporter", "expected `exporter` to be one of %s, got %q instead", strings.Join(names, ", "), name)
+}
@@ -6,18 +6,30 @@ package traces
 import (
 	"context"
 	"encoding/pem"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
@@ -164,6 +176,99 @@ func TestGrpcExporterOptions_TLS_Error(t *testing.T) {
 	}
 }
 
+func TestGrpcExporterOptions_Authority(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	cfg.CustomEndpoint = "localhost:4317"
+	opts, err := grpcExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.GRPCAuthority = "collector.internal"
+	withAuthority, err := grpcExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(withAuthority) != len(opts)+1 {
+		t.Fatalf("expected one extra option for the authority, got %d instead of %d", len(withAuthority), len(opts)+1)
+	}
+}
+
+// traceCollector is an OTLP gRPC trace receiver recording the metadata of the requests.
+type traceCollector struct {
+	coltracepb.UnimplementedTraceServiceServer
+
+	mu sync.Mutex
+	md []metadata.MD
+}
+
+func (c *traceCollector) Export(ctx context.Context, _ *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.md = append(c.md, md)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func (c *traceCollector) metadata() []metadata.MD {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.md
+}
+
+// startTraceCollector starts a traceCollector and returns it with its address.
+func startTraceCollector(t *testing.T) (*traceCollector, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	collector := &traceCollector{}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, collector)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return collector, lis.Addr().String()
+}
+
+// exportSpanOverGRPC exports a span to endpoint with the gRPC exporter of cfg.
+func exportSpanOverGRPC(t *testing.T, cfg *Config, endpoint string) {
+	cfg.CustomEndpoint = endpoint
+	cfg.Insecure = true
+	opts, err := grpcExporterOptions(cfg)
+	require.NoError(t, err)
+	exp, err := otlptracegrpc.New(context.Background(), opts...)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, exp.Shutdown(context.Background())) }()
+	require.NoError(t, exp.ExportSpans(context.Background(), tracetest.SpanStubs{{Name: "span"}}.Snapshots()))
+}
+
+func TestGrpcExporter_AuthorityWithTotalBytes(t *testing.T) {
+	collector, endpoint := startTraceCollector(t)
+	cfg := NewConfig()
+	cfg.GRPCAuthority = "collector.internal"
+	cfg.TotalBytes = "1MB"
+
+	exportSpanOverGRPC(t, cfg, endpoint)
+
+	md := collector.metadata()
+	require.Len(t, md, 1)
+	assert.Equal(t, []string{"collector.internal"}, md[0].Get(":authority"))
+	assert.Positive(t, cfg.ExportVolume().Total(), "the request should be counted")
+}
+
+func TestGrpcExporter_AuthorityWithMetadata(t *testing.T) {
+	collector, endpoint := startTraceCollector(t)
+	cfg := NewConfig()
+	cfg.GRPCAuthority = "collector.internal"
+	cfg.GRPCMetadata = common.KeyValue{"x-gateway-key": "secret"}
+
+	exportSpanOverGRPC(t, cfg, endpoint)
+
+	md := collector.metadata()
+	require.Len(t, md, 1)
+	assert.Equal(t, []string{"collector.internal"}, md[0].Get(":authority"))
+	assert.Equal(t, []string{"secret"}, md[0].Get("x-gateway-key"))
+}
+
 func TestCreateExporter_HTTP(t *testing.T) {
 	cfg := &Config{}
 	cfg.SetDefaults()
@@ -191,3 +296,169 @@ func TestCreateExporter_gRPC(t *testing.T) {
 		t.Cleanup(func() { _ = exp.Shutdown(context.Background()) })
 	}
 }
+
+func TestTraceFlags(t *testing.T) {
+	syncer := &mockSyncer{}
+	processor := unsampledSpanProcessor{SpanProcessor: sdktrace.NewSimpleSpanProcessor(unsampledSpanExporter{SpanExporter: syncer})}
+	cfg := &Config{TraceFlags: "02"}
+	tracerProvider := sdktrace.NewTracerProvider(tracerProviderOptions(cfg, sdktrace.WithSpanProcessor(processor))...)
+	tracer := tracerProvider.Tracer("test")
+
+	ctx, parent := tracer.Start(withTraceFlags(context.Background(), 0x02), "parent")
+	assert.Equal(t, trace.TraceFlags(0x02), parent.SpanContext().TraceFlags(), "the live span context carries the flags")
+	header := propagation.HeaderCarrier{}
+	propagation.TraceContext{}.Inject(ctx, header)
+	assert.True(t, strings.HasSuffix(header.Get("traceparent"), "-00"), "the propagated traceparent is unsampled, got %q", header.Get("traceparent"))
+	_, child := tracer.Start(propagation.TraceContext{}.Extract(context.Background(), header), "child")
+	child.End()
+	parent.End()
+
+	require.Len(t, syncer.spans, 2, "the unsampled spans are exported")
+	for _, span := range syncer.spans {
+		assert.False(t, span.SpanContext().IsSampled(), "span %s should be unsampled", span.Name())
+		assert.True(t, span.SpanContext().IsValid())
+	}
+	assert.Equal(t, trace.TraceFlags(0x02), syncer.spans[1].SpanContext().TraceFlags())
+	assert.False(t, syncer.spans[0].Parent().IsSampled(), "child's parent should be unsampled")
+	assert.False(t, syncer.spans[1].Parent().IsValid(), "root span has no parent")
+}
+
+func TestGetTraceFlags(t *testing.T) {
+	cfg := &Config{}
+	_, ok, err := cfg.GetTraceFlags()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	cfg.TraceFlags = "01"
+	flags, ok, err := cfg.GetTraceFlags()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, flags.IsSampled())
+
+	cfg.TraceFlags = "1"
+	_, _, err = cfg.GetTraceFlags()
+	require.EqualError(t, err, "expected `trace-flags` to be two hex digits, like 00 or 01, got \"1\" instead")
+}
+
+func TestHTTPExporterOptions_ExportTraceparent(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	opts, err := httpExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.ExportTraceparent = "random"
+	withTraceparent, err := httpExporterOptions(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(withTraceparent) != len(opts)+1 {
+		t.Fatalf("expected one extra option for the HTTP client, got %d instead of %d", len(withTraceparent), len(opts)+1)
+	}
+}
+
+func TestDuplicatingExporter(t *testing.T) {
+	tracerProvider := sdktrace.NewTracerProvider()
+	syncer := &mockSyncer{}
+	tracerProvider.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(duplicatingExporter{SpanExporter: syncer, ratio: 1}))
+	_, span := tracerProvider.Tracer("test").Start(context.Background(), "span")
+	span.End()
+
+	if len(syncer.spans) != 2 {
+		t.Fatalf("expected the span to be exported twice, got %d exports", len(syncer.spans))
+	}
+	if !syncer.spans[0].SpanContext().Equal(syncer.spans[1].SpanContext()) {
+		t.Fatalf("expected the duplicate to keep the trace and span IDs")
+	}
+}
+
+func TestDroppingExporter(t *testing.T) {
+	tracerProvider := sdktrace.NewTracerProvider()
+	syncer := &mockSyncer{}
+	skipped := common.NewExportErrors()
+	tracerProvider.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(droppingExporter{SpanExporter: syncer, ratio: 0.5, skipped: skipped}))
+	tracer := tracerProvider.Tracer("test")
+	for range 200 {
+		ctx, root := tracer.Start(context.Background(), "root")
+		_, child := tracer.Start(ctx, "child")
+		child.End()
+		root.End()
+	}
+
+	assert.Equal(t, int64(400), int64(len(syncer.spans))+skipped.Skipped(), "every span is either exported or skipped")
+	assert.InDelta(t, 200, len(syncer.spans), 80)
+	exported := map[trace.TraceID]int{}
+	for _, s := range syncer.spans {
+		exported[s.SpanContext().TraceID()]++
+	}
+	for id, n := range exported {
+		assert.Equal(t, 2, n, "the spans of trace %s are dropped together", id)
+	}
+
+	assert.False(t, dropsTrace(trace.TraceID{15: 1}, 0))
+	assert.True(t, dropsTrace(trace.TraceID{8: 0xff, 15: 0xff}, 1))
+}
+
+func TestDroppedAttributesExporter(t *testing.T) {
+	for _, p := range []float64{0, 1} {
+		tracerProvider := sdktrace.NewTracerProvider()
+		syncer := &mockSyncer{}
+		tracerProvider.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(droppedAttributesExporter{SpanExporter: syncer, probability: p}))
+		_, span := tracerProvider.Tracer("test").Start(context.Background(), "span", trace.WithAttributes(
+			attribute.String("user.id", "1"),
+			attribute.String("trazr.mock.data", "user.id"),
+			attribute.String("user.name", "a"),
+		))
+		span.End()
+
+		require.Len(t, syncer.spans, 1)
+		s := syncer.spans[0]
+		if p == 0 {
+			assert.Len(t, s.Attributes(), 3)
+			assert.Zero(t, s.DroppedAttributes())
+			continue
+		}
+		assert.Equal(t, []attribute.KeyValue{attribute.String("trazr.mock.data", "user.id")}, s.Attributes(), "the markers are kept")
+		assert.Equal(t, 2, s.DroppedAttributes())
+	}
+}
+
+func TestRegisterExporter(t *testing.T) {
+	syncer := &mockSyncer{}
+	var got *Config
+	RegisterExporter("test-registered", func(cfg *Config) (sdktrace.SpanExporter, error) {
+		got = cfg
+		return syncer, nil
+	})
+	t.Cleanup(func() { unregisterExporter("test-registered") })
+
+	cfg := NewConfig()
+	cfg.Exporter = "test-registered"
+	require.NoError(t, cfg.Validate())
+	exp, err := createExporter(cfg, zap.NewNop())
+	require.NoError(t, err)
+	assert.Same(t, syncer, exp)
+	assert.Same(t, cfg, got)
+
+	cfg.TotalBytes = "1MB"
+	require.ErrorContains(t, cfg.Validate(), "`total-bytes` counts the bytes of the OTLP export requests")
+	cfg.TotalBytes = ""
+	cfg.NullAttributes = true
+	require.ErrorContains(t, cfg.Validate(), "`null-attributes` empties the values of the OTLP export requests")
+
+	assert.Panics(t, func() {
+		RegisterExporter("test-registered", func(*Config) (sdktrace.SpanExporter, error) { return syncer, nil })
+	})
+	assert.Panics(t, func() {
+		RegisterExporter(ExporterOTLP, func(*Config) (sdktrace.SpanExporter, error) { return syncer, nil })
+	})
+}
+
+func TestUnknownExporter(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Exporter = "nope"
+	require.ErrorContains(t, cfg.Validate(), "expected `exporter` to be one of")
+	_, err := createExporter(cfg, zap.NewNop())
+	require.ErrorContains(t, err, `got "nope" instead`)
+}
@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// fleetTracerProvider emits the traces from the providers of the hosts of a `fleet-size`
+// fleet, one trace per host in turn. Child spans stay on the host of their parent, told
+// by the context they are started with, also when `marshal` propagates it.
+type fleetTracerProvider struct {
+	embedded.TracerProvider
+
+	hosts []trace.TracerProvider
+	next  *atomic.Uint64 // number of traces started, picking the host of the next one
+}
+
+func newFleetTracerProvider(hosts []trace.TracerProvider) fleetTracerProvider {
+	return fleetTracerProvider{hosts: hosts, next: &atomic.Uint64{}}
+}
+
+// Tracer returns a tracer starting the spans of each trace on the next host.
+func (p fleetTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	tracers := make([]trace.Tracer, len(p.hosts))
+	for k, host := range p.hosts {
+		tracers[k] = host.Tracer(name, opts...)
+	}
+	return fleetTracer{tracers: tracers, next: p.next}
+}
+
+type fleetTracer struct {
+	embedded.Tracer
+
+	tracers []trace.Tracer // tracer of each host
+	next    *atomic.Uint64
+}
+
+// fleetHostContextKey is the key of the host a trace is emitted from in the context of
+// its spans.
+type fleetHostContextKey struct{}
+
+func (t fleetTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if host, ok := ctx.Value(fleetHostContextKey{}).(uint64); ok && trace.SpanContextFromContext(ctx).IsValid() {
+		// a child span, started by the tracer of its parent's host
+		return t.tracers[host].Start(ctx, name, opts...)
+	}
+	host := (t.next.Add(1) - 1) % uint64(len(t.tracers))
+	return t.tracers[host].Start(context.WithValue(ctx, fleetHostContextKey{}, host), name, opts...)
+}
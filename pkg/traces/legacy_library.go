@@ -0,0 +1,27 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The span attributes naming the instrumentation library before instrumentation scopes
+// replaced them, which older backends still key on.
+const (
+	legacyLibraryNameKey    = "otel.library.name"
+	legacyLibraryVersionKey = "otel.library.version"
+)
+
+// legacyLibraryAttributes returns the `emit-legacy-library-attrs` span attributes of the
+// spans of a scope: its name and the version of trazr-gen, when the build records one.
+func legacyLibraryAttributes(scope string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String(legacyLibraryNameKey, scope)}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		attrs = append(attrs, attribute.String(legacyLibraryVersionKey, info.Main.Version))
+	}
+	return attrs
+}
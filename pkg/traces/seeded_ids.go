@@ -0,0 +1,24 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// seededIDGenerator draws the trace and span IDs from the source of `seed`, instead of the
+// randomly seeded one of the SDK, so that seeded runs generate the same IDs.
+type seededIDGenerator struct{}
+
+func (seededIDGenerator) NewIDs(context.Context) (trace.TraceID, trace.SpanID) {
+	return common.RandTraceID(), common.RandSpanID()
+}
+
+func (seededIDGenerator) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	return common.RandSpanID()
+}
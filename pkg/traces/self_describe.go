@@ -0,0 +1,30 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/time/rate"
+)
+
+// The `self-describe` span attributes, telling which worker emitted a span and at which
+// rate it was meant to, to correlate the volume the backend sees with the generator.
+const (
+	selfWorkerIDKey   = "trazr.worker.id"
+	selfTargetRateKey = "trazr.target.rate"
+)
+
+// selfDescribeAttributes returns the `self-describe` span attributes of the worker-th
+// worker: its number and the current rate of its limiter, which changes with
+// `rate-schedule` and the live rate, 0 meaning unthrottled.
+func selfDescribeAttributes(worker int, limiter *rate.Limiter) []attribute.KeyValue {
+	target := float64(limiter.Limit())
+	if limiter.Limit() == rate.Inf {
+		target = 0
+	}
+	return []attribute.KeyValue{
+		attribute.Int(selfWorkerIDKey, worker),
+		attribute.Float64(selfTargetRateKey, target),
+	}
+}
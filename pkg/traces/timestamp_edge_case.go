@@ -0,0 +1,30 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import "time"
+
+// The `span-timestamp-edge-case` values, producing child spans that are not contained
+// in their parent for negative testing of collectors and backends.
+const (
+	timestampEdgeCaseChildStartsEarly = "child-starts-early" // child spans start before their parent
+	timestampEdgeCaseChildEndsLate    = "child-ends-late"    // child spans end after their parent
+)
+
+// timestampEdgeCaseSkew is how far child spans reach out of their parent with
+// `span-timestamp-edge-case`.
+const timestampEdgeCaseSkew = time.Second
+
+// childTimestamps returns the start and end timestamps of a child span, which would
+// last from start to end without `span-timestamp-edge-case`. The parent keeps its own
+// timestamps, so the child overflows it.
+func (w worker) childTimestamps(start, end time.Time) (time.Time, time.Time) {
+	switch w.timestampEdgeCase {
+	case timestampEdgeCaseChildStartsEarly:
+		return start.Add(-timestampEdgeCaseSkew), end
+	case timestampEdgeCaseChildEndsLate:
+		return start, end.Add(timestampEdgeCaseSkew)
+	}
+	return start, end
+}
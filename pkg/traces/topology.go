@@ -0,0 +1,370 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"golang.org/x/time/rate"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// maxTopologySpans bounds the spans of a trace walking a `topology`, which grows with
+// every path through the graph of services.
+const maxTopologySpans = 10000
+
+// topologySpec is a `topology` file: the services of a distributed system, the first one
+// receiving the requests each trace starts with.
+type topologySpec struct {
+	Services []topologyService `mapstructure:"services"`
+}
+
+type topologyService struct {
+	Name string `mapstructure:"name"`
+	// Operation is the name of the spans handling the requests of the service, or of the
+	// queries of a database.
+	Operation string `mapstructure:"operation"`
+	// Database makes the service a database of that db.system, which isn't instrumented:
+	// its callers emit the client spans of their queries, and it makes no calls.
+	Database string         `mapstructure:"database"`
+	Calls    []topologyCall `mapstructure:"calls"`
+}
+
+// topologyCall is a call a service makes, one after the other, while handling a request.
+type topologyCall struct {
+	Service string `mapstructure:"service"`
+	// Queue sends the call as a message through that queue, published by a producer span
+	// of the caller and processed by a consumer span of the service.
+	Queue string `mapstructure:"queue"`
+}
+
+// operationName returns the name of the spans handling the requests of s.
+func (s topologyService) operationName() string {
+	if s.Database != "" {
+		return cmp.Or(s.Operation, "SELECT "+s.Name)
+	}
+	return cmp.Or(s.Operation, "GET /"+s.Name)
+}
+
+// topologyPresets are the built-in topologies, selected by name with `topology`.
+var topologyPresets = map[string]topologySpec{
+	"three-tier": {Services: []topologyService{
+		{Name: "frontend", Operation: "GET /checkout", Calls: []topologyCall{{Service: "api"}}},
+		{Name: "api", Operation: "POST /orders", Calls: []topologyCall{{Service: "postgres"}}},
+		{Name: "postgres", Operation: "INSERT orders", Database: "postgresql"},
+	}},
+	"fanout": {Services: []topologyService{
+		{Name: "gateway", Operation: "GET /search", Calls: []topologyCall{
+			{Service: "search-1"}, {Service: "search-2"}, {Service: "search-3"}, {Service: "search-4"}, {Service: "search-5"},
+		}},
+		{Name: "search-1", Operation: "GET /shard"},
+		{Name: "search-2", Operation: "GET /shard"},
+		{Name: "search-3", Operation: "GET /shard"},
+		{Name: "search-4", Operation: "GET /shard"},
+		{Name: "search-5", Operation: "GET /shard"},
+	}},
+	"microservices-10": {Services: []topologyService{
+		{Name: "frontend", Operation: "POST /checkout", Calls: []topologyCall{
+			{Service: "auth"}, {Service: "product-catalog"}, {Service: "cart"}, {Service: "checkout"},
+		}},
+		{Name: "auth", Operation: "GET /session"},
+		{Name: "product-catalog", Operation: "GET /products", Calls: []topologyCall{{Service: "catalog-db"}}},
+		{Name: "catalog-db", Operation: "find products", Database: "mongodb"},
+		{Name: "cart", Operation: "GET /cart", Calls: []topologyCall{{Service: "cart-cache"}}},
+		{Name: "cart-cache", Operation: "HGETALL", Database: "redis"},
+		{Name: "checkout", Operation: "POST /orders", Calls: []topologyCall{
+			{Service: "payment"}, {Service: "shipping"}, {Service: "email", Queue: "orders"},
+		}},
+		{Name: "payment", Operation: "POST /charge"},
+		{Name: "shipping", Operation: "POST /quote"},
+		{Name: "email", Operation: "send confirmation"},
+	}},
+}
+
+// topologyPresetNames returns the names of the built-in topologies, sorted.
+func topologyPresetNames() []string {
+	names := make([]string, 0, len(topologyPresets))
+	for name := range topologyPresets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// topologySpan is a span of every trace walking a topology.
+type topologySpan struct {
+	service string // service emitting the span
+	name    string
+	kind    trace.SpanKind
+	parent  int  // index of the parent span, -1 for the root span
+	remote  bool // whether the parent is a span of another service
+	attrs   []attribute.KeyValue
+}
+
+// topology is the graph of services the traces walk with `topology`.
+type topology struct {
+	services []string       // instrumented services, each emitting spans with its own resource
+	spans    []topologySpan // spans of each trace, every parent before its children
+	children [][]int        // indexes of the children of each span
+}
+
+// loadTopology returns the topology of a `topology` value: the name of a preset or the
+// path of a YAML or JSON file of services. An empty value returns nil.
+func loadTopology(value string) (*topology, error) {
+	if value == "" {
+		return nil, nil
+	}
+	spec, ok := topologyPresets[value]
+	if !ok {
+		if _, err := os.Stat(value); err != nil {
+			return nil, fmt.Errorf("expected `topology` to be one of %s or a YAML or JSON file, got %q instead", strings.Join(topologyPresetNames(), ", "), value)
+		}
+		v := viper.New()
+		v.SetConfigFile(value)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read `topology` %q: %w", value, err)
+		}
+		if err := v.UnmarshalExact(&spec); err != nil {
+			return nil, fmt.Errorf("invalid `topology` %q: %w", value, err)
+		}
+	}
+	t, err := buildTopology(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid `topology` %q: %w", value, err)
+	}
+	return t, nil
+}
+
+// buildTopology checks spec and lays out the spans of the traces walking it, from the
+// first service.
+func buildTopology(spec topologySpec) (*topology, error) {
+	if len(spec.Services) == 0 {
+		return nil, errors.New("no services, expected at least one under `services`")
+	}
+	services := make(map[string]topologyService, len(spec.Services))
+	for _, s := range spec.Services {
+		if s.Name == "" {
+			return nil, errors.New("every service must have a `name`")
+		}
+		if _, dup := services[s.Name]; dup {
+			return nil, fmt.Errorf("service %q is defined twice", s.Name)
+		}
+		if s.Database != "" && len(s.Calls) > 0 {
+			return nil, fmt.Errorf("database %q can't make calls", s.Name)
+		}
+		services[s.Name] = s
+	}
+	t := &topology{}
+	for _, s := range spec.Services {
+		for _, call := range s.Calls {
+			callee, ok := services[call.Service]
+			if !ok {
+				return nil, fmt.Errorf("service %q calls %q, which isn't defined", s.Name, call.Service)
+			}
+			if call.Queue != "" && callee.Database != "" {
+				return nil, fmt.Errorf("service %q calls database %q through a queue, only instrumented services consume messages", s.Name, call.Service)
+			}
+		}
+		if s.Database == "" {
+			t.services = append(t.services, s.Name)
+		}
+	}
+	entry := spec.Services[0]
+	if entry.Database != "" {
+		return nil, fmt.Errorf("the first service, %q, receives the requests and can't be a database", entry.Name)
+	}
+	if err := t.walk(services, entry, -1, trace.SpanKindServer, entry.operationName(), nil, nil); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// walk adds the span of s handling a request, child of the span parent of its caller,
+// followed by the spans of the calls s makes. path is the services the request went
+// through, to reject calls going back to one of them.
+func (t *topology) walk(services map[string]topologyService, s topologyService, parent int, kind trace.SpanKind, name string, attrs []attribute.KeyValue, path []string) error {
+	if slices.Contains(path, s.Name) {
+		return fmt.Errorf("the calls go round in a loop: %s -> %s", strings.Join(path, " -> "), s.Name)
+	}
+	if len(t.spans) >= maxTopologySpans {
+		return fmt.Errorf("the traces would have more than %d spans", maxTopologySpans)
+	}
+	path = append(slices.Clip(path), s.Name)
+	span := t.add(topologySpan{service: s.Name, name: name, kind: kind, parent: parent, remote: parent >= 0, attrs: attrs})
+	for _, call := range s.Calls {
+		callee := services[call.Service]
+		switch {
+		case callee.Database != "":
+			t.add(topologySpan{service: s.Name, name: callee.operationName(), kind: trace.SpanKindClient, parent: span, attrs: []attribute.KeyValue{
+				semconv.DBSystemKey.String(callee.Database),
+				semconv.PeerService(callee.Name),
+			}})
+		case call.Queue != "":
+			producer := t.add(topologySpan{service: s.Name, name: call.Queue + " publish", kind: trace.SpanKindProducer, parent: span, attrs: []attribute.KeyValue{
+				semconv.MessagingDestinationName(call.Queue),
+				semconv.MessagingOperationPublish,
+			}})
+			if err := t.walk(services, callee, producer, trace.SpanKindConsumer, call.Queue+" process", []attribute.KeyValue{
+				semconv.MessagingDestinationName(call.Queue),
+				semconv.MessagingOperationDeliver,
+			}, path); err != nil {
+				return err
+			}
+		default:
+			client := t.add(topologySpan{service: s.Name, name: callee.operationName(), kind: trace.SpanKindClient, parent: span, attrs: []attribute.KeyValue{
+				semconv.PeerService(callee.Name),
+			}})
+			if err := t.walk(services, callee, client, trace.SpanKindServer, callee.operationName(), nil, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add appends span to the spans of t and returns its index.
+func (t *topology) add(span topologySpan) int {
+	i := len(t.spans)
+	t.spans = append(t.spans, span)
+	t.children = append(t.children, nil)
+	if span.parent >= 0 {
+		t.children[span.parent] = append(t.children[span.parent], i)
+	}
+	return i
+}
+
+// spanWindow is when a span starts and ends.
+type spanWindow struct {
+	start, end time.Time
+}
+
+// topologyTrace is the state of a trace walking the topology of its worker.
+type topologyTrace struct {
+	windows  []spanWindow      // timestamps of each span of the topology
+	contexts []context.Context // context of each span emitted so far, parent of its children
+}
+
+// newTopologyTrace lays out the timestamps of a trace walking w.topology from start: the
+// calls of a span are made one after the other, the span lasting a span duration on top
+// of them, while the consumer of a message processes it once it is published.
+func (w worker) newTopologyTrace(start time.Time) *topologyTrace {
+	windows := make([]spanWindow, len(w.topology.spans))
+	var place func(i int, start time.Time) time.Time
+	place = func(i int, start time.Time) time.Time {
+		if w.topology.spans[i].kind == trace.SpanKindProducer {
+			end := start.Add(w.nextSpanDuration())
+			for _, child := range w.topology.children[i] {
+				place(child, end)
+			}
+			windows[i] = spanWindow{start: start, end: end}
+			return end
+		}
+		cursor := start
+		for _, child := range w.topology.children[i] {
+			cursor = place(child, cursor)
+		}
+		end := cursor.Add(w.nextSpanDuration())
+		windows[i] = spanWindow{start: start, end: end}
+		return end
+	}
+	place(0, start)
+	return &topologyTrace{windows: windows, contexts: make([]context.Context, len(windows))}
+}
+
+// emitTopologySpan emits the next span of t, a trace walking w.topology, from the service
+// of the span, with attrs on top of the attributes of the topology.
+func (w worker) emitTopologySpan(t *openTrace, attrs []attribute.KeyValue, limiter *rate.Limiter) {
+	i := t.children + 1
+	span := w.topology.spans[i]
+	ctx := t.topology.contexts[span.parent]
+	if span.remote && w.propagateContext {
+		ctx = propagate(ctx)
+	}
+	start, end := w.childTimestamps(t.topology.windows[i].start, t.topology.windows[i].end)
+	ctx, sp := t.tracer.Start(withService(ctx, span.service), span.name,
+		trace.WithAttributes(span.attrs...),
+		trace.WithSpanKind(span.kind),
+		trace.WithTimestamp(start),
+	)
+	sp.SetAttributes(attrs...)
+	sp.SetAttributes(t.scopeAttrs...)
+	common.PublishSpanContext(sp.SpanContext())
+	if w.selfDescribe {
+		sp.SetAttributes(selfDescribeAttributes(w.id, limiter)...)
+	}
+	w.recordException(sp, span.name, end)
+	sp.SetStatus(w.statusCode, "")
+	sp.End(trace.WithTimestamp(end))
+	t.topology.contexts[i] = ctx
+}
+
+type serviceContextKey struct{}
+
+// withService returns ctx telling the tracer of a topologyTracerProvider to start the
+// spans of service.
+func withService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceContextKey{}, service)
+}
+
+// topologyTracerProvider emits the spans of a `topology` from the provider of their
+// service, each with its own service.name resource, as told by the context they are
+// started with.
+type topologyTracerProvider struct {
+	embedded.TracerProvider
+
+	services map[string]trace.TracerProvider
+	entry    string // service whose provider starts the spans of no service
+}
+
+// newTopologyTracerProvider returns the provider of the spans of t, creating the provider
+// of each service with newProvider, from res with the service.name of the service.
+func newTopologyTracerProvider(t *topology, res *resource.Resource, newProvider func(*resource.Resource) trace.TracerProvider) (topologyTracerProvider, error) {
+	p := topologyTracerProvider{services: make(map[string]trace.TracerProvider, len(t.services)), entry: t.spans[0].service}
+	for _, service := range t.services {
+		serviceRes, err := resource.Merge(res, resource.NewSchemaless(semconv.ServiceName(service)))
+		if err != nil {
+			return p, err
+		}
+		p.services[service] = newProvider(serviceRes)
+	}
+	return p, nil
+}
+
+func (p topologyTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	tracers := make(map[string]trace.Tracer, len(p.services))
+	for service, provider := range p.services {
+		tracers[service] = provider.Tracer(name, opts...)
+	}
+	return topologyTracer{tracers: tracers, entry: tracers[p.entry]}
+}
+
+type topologyTracer struct {
+	embedded.Tracer
+
+	tracers map[string]trace.Tracer // tracer of each service
+	entry   trace.Tracer
+}
+
+func (t topologyTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if service, ok := ctx.Value(serviceContextKey{}).(string); ok {
+		if tracer, ok := t.tracers[service]; ok {
+			return tracer.Start(ctx, name, opts...)
+		}
+	}
+	return t.entry.Start(ctx, name, opts...)
+}
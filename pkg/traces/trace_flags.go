@@ -0,0 +1,81 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"context"
+	"fmt"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceFlagsSampler records every span, and samples them as the sampled flag of
+// `trace-flags` says, so that the span contexts themselves carry it: the exported spans,
+// the propagated traceparent and the logs and exemplars correlated with the spans alike.
+type traceFlagsSampler struct {
+	flags trace.TraceFlags
+}
+
+func (s traceFlagsSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := sdktrace.RecordOnly
+	if s.flags.IsSampled() {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s traceFlagsSampler) Description() string {
+	return fmt.Sprintf("TraceFlags{%s}", s.flags)
+}
+
+// withTraceFlags returns ctx holding flags, which the SDK sets on the root spans started
+// with it along with the sampled flag its sampler decides on.
+func withTraceFlags(ctx context.Context, flags trace.TraceFlags) context.Context {
+	return trace.ContextWithSpanContext(ctx, trace.SpanContext{}.WithTraceFlags(flags))
+}
+
+// unsampledSpanProcessor hands the recorded spans without the sampled flag to the
+// decorated span processor, which only exports sampled spans, marked sampled by a
+// sampledMarkSpan that unsampledSpanExporter takes off before they are exported.
+type unsampledSpanProcessor struct {
+	sdktrace.SpanProcessor
+}
+
+func (p unsampledSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		s = sampledMarkSpan{ReadOnlySpan: s}
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// unsampledSpanExporter exports the spans of unsampledSpanProcessor with their own trace
+// flags. It must be the exporter of its decorated span processor.
+type unsampledSpanExporter struct {
+	sdktrace.SpanExporter
+}
+
+func (e unsampledSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	unmarked := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, s := range spans {
+		if marked, ok := s.(sampledMarkSpan); ok {
+			s = marked.ReadOnlySpan
+		}
+		unmarked[i] = s
+	}
+	return e.SpanExporter.ExportSpans(ctx, unmarked)
+}
+
+// sampledMarkSpan shows an unsampled span as sampled to a span processor.
+type sampledMarkSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s sampledMarkSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
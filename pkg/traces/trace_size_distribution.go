@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package traces
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/medxops/trazr-gen/internal/common"
+)
+
+// traceSizes is the weighted distribution of the number of child spans of each trace
+// given with `trace-size-distribution`, sampled as each trace starts.
+type traceSizes struct {
+	sizes      []int
+	cumulative []float64 // running total of the weights, by size
+}
+
+// parseTraceSizeDistribution parses a `trace-size-distribution`: comma-separated
+// SIZE:WEIGHT pairs giving the weight of the traces of SIZE child spans, like "1:80,50:20"
+// for mostly short traces and a few long ones. The weights don't need to add up to
+// anything. An empty spec returns nil.
+func parseTraceSizeDistribution(spec string) (*traceSizes, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	d := &traceSizes{}
+	var total float64
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		s, w, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid `trace-size-distribution` %q: expected SIZE:WEIGHT pairs like 1:80,50:20, got %q instead", spec, entry)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size < 1 {
+			return nil, fmt.Errorf("invalid `trace-size-distribution` %q: the size of %q must be a number of child spans of at least 1", spec, entry)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(w), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid `trace-size-distribution` %q: the weight of %q must be a number that isn't negative", spec, entry)
+		}
+		if weight == 0 {
+			continue
+		}
+		total += weight
+		d.sizes = append(d.sizes, size)
+		d.cumulative = append(d.cumulative, total)
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("invalid `trace-size-distribution` %q: no size has a positive weight", spec)
+	}
+	return d, nil
+}
+
+// sample picks a number of child spans according to the weights of the distribution.
+func (d *traceSizes) sample() int {
+	total := d.cumulative[len(d.cumulative)-1]
+	r := common.RandFloat64() * total
+	i := sort.SearchFloat64s(d.cumulative, r)
+	if i < len(d.cumulative) && d.cumulative[i] == r {
+		// r falls on the upper bound of size i, which belongs to the next one
+		i++
+	}
+	return d.sizes[min(i, len(d.sizes)-1)]
+}
@@ -14,14 +14,16 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
+
+	"github.com/medxops/trazr-gen/internal/common"
 )
 
 const tracesHelpTemplate = `
@@ -54,47 +56,59 @@ Additional help topics:
 {{end}}
 Tip: Use "--mock-data" to generate fake values for attributes and headers!`
 
+// Examples are the command lines shown in the help of the traces command.
+var Examples = []common.Example{
+	{Description: "Send 10 traces of 3 child spans each to a local collector", Command: "trazr-gen traces --traces 10 --child-spans 3"},
+	{Description: "Generate traces for a minute at 100 spans per second with mock user attributes", Command: "trazr-gen traces --duration 1m --rate 100 --mock-data --telemetry-attributes 'user.name=\"{{Name}}\",user.email=\"{{Email}}\"'"},
+	{Description: "Tag the resource and make every span fail with an exception event", Command: "trazr-gen traces --otlp-attributes env=prod --otlp-attributes 'host.ip=[\"10.0.0.1\",\"10.0.0.2\"]' --status-code Error --emit-exception"},
+	{Description: "Export over gRPC with TLS, verifying the collector with a private CA", Command: "trazr-gen traces --otlp-protocol grpc --otlp-endpoint collector.example.com:4317 --otlp-insecure=false --ca-cert ca.pem"},
+	{Description: "Export over mTLS with an authentication header", Command: "trazr-gen traces --otlp-insecure=false --ca-cert ca.pem --mtls --client-cert client.pem --client-key client-key.pem --otlp-header 'Authorization=\"Bearer token\"'"},
+	{Description: "Generate traces crossing ten services, each span emitted by its service with its own service.name", Command: "trazr-gen traces --duration 1m --rate 100 --topology microservices-10"},
+}
+
 // SetHelpTemplateForCmd sets the custom help template for the traces command.
 func SetHelpTemplateForCmd(cmd interface{ SetHelpTemplate(string) }) {
 	cmd.SetHelpTemplate(tracesHelpTemplate)
 }
 
-func Start(cfg *Config, logger *zap.Logger) error {
+// Start starts the span telemetry generator and returns the stats of all its runs.
+func Start(cfg *Config, logger *zap.Logger) (stats common.RunStats, err error) {
 	if err := cfg.InitAttributes(); err != nil {
 		logger.Error("failed to initialize attributes", zap.Error(err))
-		return err
+		return stats, err
 	}
+	cfg.WarnBadAttributes(logger)
+	cfg.SeedRandomness()
 
-	var exp *otlptrace.Exporter
-	if cfg.UseHTTP {
-		var exporterOpts []otlptracehttp.Option
+	if err := cfg.ApplyProtocol(); err != nil {
+		logger.Error("failed to select the OTLP protocol", zap.Error(err))
+		return stats, err
+	}
 
-		logger.Info("starting HTTP exporter")
-		exporterOpts, err := httpExporterOptions(cfg)
-		if err != nil {
-			logger.Error("failed to process OTLP HTTP", zap.Error(err))
-			return err
-		}
-		exp, err = otlptracehttp.New(context.Background(), exporterOpts...)
-		if err != nil {
-			logger.Error("failed to obtain OTLP HTTP exporter", zap.Error(err))
-			return err
-		}
-	} else {
-		var exporterOpts []otlptracegrpc.Option
+	verification, err := cfg.StartVerification("traces")
+	if err != nil {
+		logger.Error("failed to start the verification", zap.Error(err))
+		return stats, err
+	}
 
-		logger.Info("starting gRPC exporter")
-		exporterOpts, err := grpcExporterOptions(cfg)
-		if err != nil {
-			logger.Error("failed to process OTLP gRPC", zap.Error(err))
-			return err
-		}
-		exp, err = otlptracegrpc.New(context.Background(), exporterOpts...)
-		if err != nil {
-			logger.Error("failed to obtain OTLP gRPC exporter", zap.Error(err))
-			return err
-		}
+	exp, err := createExporter(cfg, logger)
+	if err != nil {
+		logger.Error("failed to process the span exporter", zap.Error(err))
+		return stats, err
 	}
+	// reported last, once the span processor has flushed the remaining spans
+	exportErrors := common.NewExportErrors()
+	exportErrors.AbortAfter(int64(cfg.MaxErrors))
+	defer common.CountPartialSuccesses(common.RejectedSpans, exportErrors)()
+	defer func() {
+		if verifyErr := verification.Finish(exportErrors.Delivered(), logger, cfg.TerminalOutput); verifyErr != nil && err == nil {
+			err = verifyErr
+		}
+	}()
+	defer func() {
+		exportErrors.Report(logger, cfg.TerminalOutput)
+		stats.AddExportErrors(exportErrors)
+	}()
 	defer func() {
 		logger.Info("stopping the exporter")
 		if tempError := exp.Shutdown(context.Background()); tempError != nil {
@@ -102,9 +116,36 @@ func Start(cfg *Config, logger *zap.Logger) error {
 		}
 	}()
 
+	var spanExporter sdktrace.SpanExporter = errorCountingExporter{SpanExporter: exp, errors: exportErrors}
+	flags, ok, err := cfg.GetTraceFlags()
+	if err != nil {
+		logger.Error("failed to parse the trace flags", zap.Error(err))
+		return stats, err
+	}
+	if cfg.DuplicateRatio > 0 {
+		logger.Info("a share of the exports is sent twice", zap.Float64("duplicate-ratio", cfg.DuplicateRatio))
+		spanExporter = duplicatingExporter{SpanExporter: spanExporter, ratio: cfg.DuplicateRatio}
+	}
+	if cfg.AttrDropChance > 0 {
+		logger.Info("span attributes are dropped", zap.Float64("attribute-drop-probability", cfg.AttrDropChance))
+		spanExporter = droppedAttributesExporter{SpanExporter: spanExporter, probability: cfg.AttrDropChance}
+	}
+	if cfg.DropRatio > 0 {
+		logger.Info("a share of the traces is never exported", zap.Float64("drop-ratio", cfg.DropRatio))
+		spanExporter = droppingExporter{SpanExporter: spanExporter, ratio: cfg.DropRatio, skipped: exportErrors}
+	}
+	unsampled := ok && !flags.IsSampled()
+	if unsampled {
+		logger.Info("spans are exported unsampled", zap.String("trace-flags", cfg.TraceFlags))
+		spanExporter = unsampledSpanExporter{SpanExporter: spanExporter}
+	}
+
 	var ssp sdktrace.SpanProcessor
 	if cfg.Batch {
-		ssp = sdktrace.NewBatchSpanProcessor(exp, sdktrace.WithBatchTimeout(time.Second))
+		if cfg.BoundedMemory {
+			logger.Info("memory is bounded", zap.Int("max-queue-size", cfg.QueueSize()))
+		}
+		ssp = sdktrace.NewBatchSpanProcessor(spanExporter, batchSpanProcessorOptions(cfg)...)
 		defer func() {
 			logger.Info("stop the batch span processor")
 
@@ -112,53 +153,180 @@ func Start(cfg *Config, logger *zap.Logger) error {
 				logger.Error("failed to stop the batch span processor", zap.Error(tempError))
 			}
 		}()
+		if unsampled {
+			ssp = unsampledSpanProcessor{SpanProcessor: ssp}
+		}
 	}
 
-	attrs, err := cfg.GetResourceAttrWithMockMarker()
+	resources, err := cfg.FleetResources(cfg.ResourceSchemaURL())
 	if err != nil {
 		logger.Error("failed to process resource attributes", zap.Error(err))
-		return err
+		return stats, err
 	}
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
-	)
-
-	if cfg.Batch {
-		tracerProvider.RegisterSpanProcessor(ssp)
+	newTracerProvider := func(res *resource.Resource) trace.TracerProvider {
+		tracerProvider := sdktrace.NewTracerProvider(tracerProviderOptions(cfg, sdktrace.WithResource(res))...)
+		if cfg.Batch {
+			tracerProvider.RegisterSpanProcessor(ssp)
+		}
+		return tracerProvider
 	}
 
-	otel.SetTracerProvider(tracerProvider)
+	topo, err := loadTopology(cfg.Topology)
+	if err != nil {
+		logger.Error("failed to load the topology", zap.Error(err))
+		return stats, err
+	}
+	switch {
+	case topo != nil:
+		logger.Info("traces walk a topology of services", zap.Strings("services", topo.services))
+		tracerProvider, err := newTopologyTracerProvider(topo, resources[0], newTracerProvider)
+		if err != nil {
+			logger.Error("failed to process the resources of the services", zap.Error(err))
+			return stats, err
+		}
+		otel.SetTracerProvider(tracerProvider)
+	case len(resources) > 1:
+		hosts := make([]trace.TracerProvider, len(resources))
+		for k, res := range resources {
+			hosts[k] = newTracerProvider(res)
+		}
+		logger.Info("traces are emitted from a fleet of hosts in turn", zap.Int("fleet-size", len(hosts)))
+		otel.SetTracerProvider(newFleetTracerProvider(hosts))
+	default:
+		otel.SetTracerProvider(newTracerProvider(resources[0]))
+	}
 
 	if cfg.TerminalOutput {
 		fmt.Println("Starting traces generator")
 	}
 	logger.Info("starting the traces generator with configuration", zap.Any("config", cfg))
 
-	if err := run(cfg, logger); err != nil {
-		logger.Error("failed to run the traces generator", zap.Error(err))
+	err = cfg.RunRepeated(logger, func() error {
+		runStats, err := run(cfg, exportErrors, logger)
+		stats.Add(runStats)
 		return err
+	})
+	if err != nil {
+		logger.Error("failed to run the traces generator", zap.Error(err))
+	}
+	return stats, err
+}
+
+// createExporter creates the exporter selected with `exporter`: a registered one, or else
+// the OTLP gRPC or HTTP exporter.
+func createExporter(cfg *Config, logger *zap.Logger) (sdktrace.SpanExporter, error) {
+	if cfg.Exporter != "" && cfg.Exporter != ExporterOTLP {
+		factory, ok := registeredExporter(cfg.Exporter)
+		if !ok {
+			return nil, validateExporter(cfg.Exporter)
+		}
+		logger.Info("starting registered exporter", zap.String("exporter", cfg.Exporter))
+		exp, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain %s exporter: %w", cfg.Exporter, err)
+		}
+		return exp, nil
+	}
+
+	if cfg.UseHTTP {
+		logger.Info("starting HTTP exporter")
+		exporterOpts, err := httpExporterOptions(cfg)
+		if err != nil {
+			return nil, err
+		}
+		exp, err := otlptracehttp.New(context.Background(), exporterOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain OTLP HTTP exporter: %w", err)
+		}
+		return exp, nil
+	}
+
+	logger.Info("starting gRPC exporter")
+	exporterOpts, err := grpcExporterOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := otlptracegrpc.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain OTLP gRPC exporter: %w", err)
+	}
+	return exp, nil
+}
+
+// RunDiscarding runs the scenario once against an in-process exporter that discards
+// every span, measuring the generator alone, without network or collector overhead.
+func RunDiscarding(cfg *Config, logger *zap.Logger) (common.RunStats, error) {
+	if err := cfg.InitAttributes(); err != nil {
+		return common.RunStats{}, err
+	}
+	cfg.SeedRandomness()
+	tracerProvider := sdktrace.NewTracerProvider(tracerProviderOptions(cfg, sdktrace.WithSyncer(tracetest.NewNoopExporter()))...)
+	defer func() {
+		if err := tracerProvider.Shutdown(context.Background()); err != nil {
+			logger.Error("failed to stop the tracer provider", zap.Error(err))
+		}
+	}()
+	otel.SetTracerProvider(tracerProvider)
+	return run(cfg, nil, logger)
+}
+
+// batchSpanProcessorOptions returns the options of the batch span processor of `batch`,
+// whose queue holds up to `max-queue-size` spans.
+func batchSpanProcessorOptions(cfg *Config) []sdktrace.BatchSpanProcessorOption {
+	opts := []sdktrace.BatchSpanProcessorOption{sdktrace.WithBatchTimeout(time.Second), sdktrace.WithMaxQueueSize(cfg.QueueSize())}
+	if cfg.BoundedMemory {
+		// the workers wait for room in the queue instead of its spans being dropped
+		opts = append(opts, sdktrace.WithBlocking())
+	}
+	return opts
+}
+
+// tracerProviderOptions returns opts, with the ID generator of `seed` and the sampler of
+// `trace-flags` when they are set.
+func tracerProviderOptions(cfg *Config, opts ...sdktrace.TracerProviderOption) []sdktrace.TracerProviderOption {
+	if cfg.Seed != 0 {
+		opts = append(opts, sdktrace.WithIDGenerator(seededIDGenerator{}))
 	}
-	return nil
+	if flags, ok, err := cfg.GetTraceFlags(); err == nil && ok {
+		opts = append(opts, sdktrace.WithSampler(traceFlagsSampler{flags: flags}))
+	}
+	return opts
 }
 
-// run executes the test scenario.
-func run(c *Config, logger *zap.Logger) error {
+// run executes the test scenario. It is aborted once exportErrors, which may be nil,
+// reaches `max-errors`.
+func run(c *Config, exportErrors *common.ExportErrors, logger *zap.Logger) (common.RunStats, error) {
 	if err := c.Validate(); err != nil {
-		return err
+		return common.RunStats{}, err
 	}
 
-	if c.TotalDuration > 0 {
+	if c.IgnoresCount() {
+		c.WarnCountIgnored(logger, "traces", c.NumTraces, defaultNumTraces)
 		c.NumTraces = 0
 	}
+	c.WarnURLPath(logger, "traces")
 
 	limit := rate.Limit(c.Rate)
 	if c.Rate == 0 {
 		limit = rate.Inf
 		logger.Info("generation of traces isn't being throttled")
 	} else {
-		logger.Info("generation of traces is limited", zap.Float64("per-second", float64(limit)))
+		logger.Info("generation of traces is limited", zap.Float64("per-second", float64(limit)), zap.Bool("per-trace", c.RatePerTrace))
+	}
+
+	limiters, releaseLimiters := c.RunLimiters(limit)
+	defer releaseLimiters()
+	stopSchedule, err := c.StartRateSchedule(limiters, logger)
+	if err != nil {
+		return common.RunStats{}, err
+	}
+	defer stopSchedule()
+	if c.SharedLimiter {
+		logger.Info("rate limiter is shared across all workers")
 	}
 
+	// validated above
+	traceFlags, _, _ := c.GetTraceFlags()
 	var statusCode codes.Code
 
 	switch strings.ToLower(c.StatusCode) {
@@ -169,7 +337,22 @@ func run(c *Config, logger *zap.Logger) error {
 	case "2", "ok":
 		statusCode = codes.Ok
 	default:
-		return fmt.Errorf("expected `status-code` to be one of (Unset, Error, Ok) or (0, 1, 2), got %q instead", c.StatusCode)
+		return common.RunStats{}, fmt.Errorf("expected `status-code` to be one of (Unset, Error, Ok) or (0, 1, 2), got %q instead", c.StatusCode)
+	}
+
+	childSpanKinds, err := parseSpanKinds(c.ChildSpanKinds)
+	if err != nil {
+		return common.RunStats{}, err
+	}
+
+	sizes, err := parseTraceSizeDistribution(c.TraceSizes)
+	if err != nil {
+		return common.RunStats{}, err
+	}
+
+	topo, err := loadTopology(c.Topology)
+	if err != nil {
+		return common.RunStats{}, err
 	}
 
 	wg := sync.WaitGroup{}
@@ -178,49 +361,86 @@ func run(c *Config, logger *zap.Logger) error {
 	running.Store(true)
 
 	var totalTraces int64
+	// the counted window starts once the warmup has elapsed
+	window := c.StartCountedWindow(&totalTraces)
+	var timing *common.ExportTiming
+	if c.ExportTiming {
+		timing = common.NewExportTiming(c.WorkerCount)
+	}
+	start := time.Now()
 
 	progressCh := make(chan struct{})
-	go func() {
-		count := 0
-		for range progressCh {
-			count++
-			if c.TerminalOutput {
-				fmt.Println("Traces generated:", count)
-			}
-		}
-		if c.TerminalOutput {
-			fmt.Println("Traces generated (final count):", count)
-		}
-	}()
+	go c.ConsumeProgress("Traces generated", progressCh)
 
 	for i := 0; i < c.WorkerCount; i++ {
 		wg.Add(1)
 
 		w := worker{
-			numTraces:        c.NumTraces,
-			numChildSpans:    int(math.Max(1, float64(c.NumChildSpans))),
-			propagateContext: c.PropagateContext,
-			statusCode:       statusCode,
-			limitPerSecond:   limit,
-			totalDuration:    c.TotalDuration,
-			running:          running,
-			wg:               &wg,
-			logger:           logger.With(zap.Int("worker", i+1)),
-			loadSize:         c.LoadSize,
-			spanDuration:     c.SpanDuration,
-			tracesCounter:    &totalTraces,
-			progressCh:       progressCh,
+			numTraces:         c.NumTraces,
+			numChildSpans:     int(math.Max(1, float64(c.NumChildSpans))),
+			traceSizes:        sizes,
+			topology:          topo,
+			concurrentTraces:  c.ConcurrentTraces,
+			ratePerTrace:      c.RatePerTrace,
+			propagateContext:  c.PropagateContext,
+			traceFlags:        traceFlags,
+			statusCode:        statusCode,
+			limitPerSecond:    limit,
+			limiter:           limiters[i],
+			totalDuration:     c.TotalDuration,
+			running:           running,
+			wg:                &wg,
+			logger:            logger.With(zap.Int("worker", i+1)),
+			loadSize:          c.LoadSize,
+			spanDuration:      c.SpanDuration,
+			slowSpanRatio:     c.SlowSpanRatio,
+			slowSpanFactor:    c.SlowSpanFactor,
+			timestampEdgeCase: c.TimestampEdgeCase,
+			legacyLibrary:     c.LegacyLibrary,
+			selfDescribe:      c.SelfDescribe,
+			id:                i + 1,
+			peerAddress:       c.PeerAddress,
+			peerService:       c.PeerService,
+			emitException:     c.EmitException,
+			childSpanKinds:    childSpanKinds,
+			scopeNames:        c.ScopeNames(i + 1),
+			tracesCounter:     &totalTraces,
+			exportTiming:      timing.Worker(i),
+			progressCh:        progressCh,
 		}
 
 		go w.simulateTraces(c)
 	}
 
-	if c.TotalDuration > 0 {
-		time.Sleep(c.TotalDuration)
-		running.Store(false)
-	}
-	wg.Wait()
+	stopDashboard := c.StartDashboard("traces", &totalTraces, exportErrors)
+	err = c.WaitWorkers(&wg, func() { running.Store(false) }, exportErrors, logger)
+	stopDashboard()
 	close(progressCh)
-	logger.Info("final count", zap.Int64("traces_generated", atomic.LoadInt64(&totalTraces)))
-	return nil
+	stats := common.NewRunStats(atomic.LoadInt64(&totalTraces), time.Since(start))
+	logger.Info("final count", zap.Int64("traces_generated", stats.Generated), zap.Float64("per-second", stats.AchievedRate))
+	window.Report(logger, "traces", c.TerminalOutput)
+	timing.Report(logger, c.TerminalOutput, c.TargetSpacing())
+	return stats, err
+}
+
+// parseSpanKinds converts span kind names, like "client", to span kinds.
+func parseSpanKinds(names []string) ([]trace.SpanKind, error) {
+	kinds := make([]trace.SpanKind, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "internal":
+			kinds = append(kinds, trace.SpanKindInternal)
+		case "server":
+			kinds = append(kinds, trace.SpanKindServer)
+		case "client":
+			kinds = append(kinds, trace.SpanKindClient)
+		case "producer":
+			kinds = append(kinds, trace.SpanKindProducer)
+		case "consumer":
+			kinds = append(kinds, trace.SpanKindConsumer)
+		default:
+			return nil, fmt.Errorf("expected `child-span-kinds` to contain only internal, server, client, producer or consumer, got %q instead", name)
+		}
+	}
+	return kinds, nil
 }
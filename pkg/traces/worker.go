@@ -6,7 +6,10 @@ package traces
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,23 +22,43 @@ import (
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"golang.org/x/time/rate"
+
+	"github.com/medxops/trazr-gen/internal/common"
 )
 
 type worker struct {
-	running          *atomic.Bool    // pointer to shared flag that indicates it's time to stop the test
-	numTraces        int             // how many traces the worker has to generate (only when duration==0)
-	numChildSpans    int             // how many child spans the worker has to generate per trace
-	propagateContext bool            // whether the worker needs to propagate the trace context via HTTP headers
-	statusCode       codes.Code      // the status code set for the child and parent spans
-	totalDuration    time.Duration   // how long to run the test for (overrides `numTraces`)
-	limitPerSecond   rate.Limit      // how many spans per second to generate
-	wg               *sync.WaitGroup // notify when done
-	loadSize         int             // desired minimum size in MB of string data for each generated trace
-	spanDuration     time.Duration   // duration of generated spans
-	logger           *zap.Logger
-	tracesCounter    *int64        // pointer to shared traces counter
-	progressCb       func(string)  // optional callback for terminal output
-	progressCh       chan struct{} // channel for centralized progress reporting
+	running           *atomic.Bool     // pointer to shared flag that indicates it's time to stop the test
+	numTraces         int              // how many traces the worker has to generate (only when duration==0)
+	numChildSpans     int              // how many child spans the worker has to generate per trace
+	traceSizes        *traceSizes      // distribution of the number of child spans per trace, superseding numChildSpans (nil means none)
+	topology          *topology        // services the traces walk, superseding numChildSpans, traceSizes and childSpanKinds (nil means none)
+	concurrentTraces  int              // how many traces the worker keeps open at once, interleaving their child spans
+	ratePerTrace      bool             // wait for the limiter once per trace instead of once per span
+	propagateContext  bool             // whether the worker needs to propagate the trace context via HTTP headers
+	traceFlags        trace.TraceFlags // `trace-flags` of the root spans, besides the sampled flag the sampler sets (zero means none)
+	statusCode        codes.Code       // the status code set for the child and parent spans
+	totalDuration     time.Duration    // how long to run the test for (overrides `numTraces`)
+	limitPerSecond    rate.Limit       // how many spans, or traces with ratePerTrace, per second to generate
+	limiter           *rate.Limiter    // limiter of the worker, possibly shared across workers (nil means the worker creates its own)
+	wg                *sync.WaitGroup  // notify when done
+	loadSize          int              // desired minimum size in MB of string data for each generated trace
+	spanDuration      time.Duration    // duration of generated spans
+	slowSpanRatio     float64          // share of child spans lasting slowSpanFactor times spanDuration
+	slowSpanFactor    float64          // how many times longer than spanDuration slow spans last
+	timestampEdgeCase string           // `span-timestamp-edge-case` making child spans overflow their parent
+	legacyLibrary     bool             // also name the scope in the otel.library.name and otel.library.version span attributes
+	selfDescribe      bool             // tag the spans with the number of the worker and its target rate
+	id                int              // number of the worker, from 1
+	peerAddress       string           // value of net.sock.peer.addr, may contain mock templates
+	peerService       string           // value of peer.service, may contain mock templates (empty means per-span default)
+	emitException     bool             // whether to record an exception event on error spans
+	childSpanKinds    []trace.SpanKind // kinds picked at random for child spans (empty means server)
+	scopeNames        []string         // instrumentation scope names of the worker's tracers, taking turns trace by trace
+	logger            *zap.Logger
+	tracesCounter     *int64               // pointer to shared traces counter
+	exportTiming      *common.WorkerTiming // records the time between exports (nil when disabled)
+	progressCb        func(string)         // optional callback for terminal output
+	progressCh        chan struct{}        // channel for centralized progress reporting
 }
 
 const (
@@ -50,85 +73,105 @@ func (w worker) reportProgressf(format string, args ...any) {
 	}
 }
 
-func (w worker) simulateTraces(cfg *Config) {
-	tracer := otel.Tracer("trazr-gen")
-	limiter := rate.NewLimiter(w.limitPerSecond, 1)
-	var i int
-
-	for w.running.Load() {
-		spanStart := time.Now()
-		spanEnd := spanStart.Add(w.spanDuration)
-
-		if err := limiter.Wait(context.Background()); err != nil {
-			w.reportProgressf("Limiter wait failed: %v", err)
-			w.logger.Fatal("limiter waited failed, retry", zap.Error(err))
-		}
-
-		// Build a fresh set of telemetry attributes for each trace/span
-		telemetryAttrs, err := cfg.GetTelemetryAttrWithMockMarker()
-		if err != nil {
-			w.reportProgressf("Failed to process telemetry attributes: %v", err)
-			w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
-			break
-		}
+// peerAttributes returns the peer address and peer service attributes for a span.
+// Mock templates are expanded when mock data is enabled; a template that fails to
+// expand is logged and emitted as-is.
+func (w worker) peerAttributes(mockData bool, defaultService string) []attribute.KeyValue {
+	addr := w.peerAddress
+	if addr == "" {
+		addr = fakeIP
+	}
+	service := w.peerService
+	if service == "" {
+		service = defaultService
+	}
+	if mockData {
+		addr = w.expandMockTemplate(addr)
+		service = w.expandMockTemplate(service)
+	}
+	return []attribute.KeyValue{
+		semconv.NetSockPeerAddr(addr),
+		semconv.PeerService(service),
+	}
+}
 
-		ctx, sp := tracer.Start(context.Background(), "lets-go", trace.WithAttributes(
-			semconv.NetSockPeerAddr(fakeIP),
-			semconv.PeerService("trazr-gen-server"),
-		),
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithTimestamp(spanStart),
-		)
-		sp.SetAttributes(telemetryAttrs...)
-		for j := 0; j < w.loadSize; j++ {
-			sp.SetAttributes(attribute.String(fmt.Sprintf("load-%v", j), string(make([]byte, charactersPerMB))))
-		}
+func (w worker) expandMockTemplate(value string) string {
+	if !strings.Contains(value, "{{") || !strings.Contains(value, "}}") {
+		return value
+	}
+	expanded, err := common.ProcessMockTemplate(value, nil)
+	if err != nil {
+		w.logger.Error("failed to process mock template", zap.String("template", value), zap.Error(err))
+		return value
+	}
+	return expanded
+}
 
-		childCtx := ctx
-		if w.propagateContext {
-			header := propagation.HeaderCarrier{}
-			// simulates going remote
-			otel.GetTextMapPropagator().Inject(childCtx, header)
+// recordException adds a semconv exception event to sp when exceptions are enabled
+// and the span is being ended with an Error status.
+func (w worker) recordException(sp trace.Span, name string, ts time.Time) {
+	if !w.emitException || w.statusCode != codes.Error {
+		return
+	}
+	sp.AddEvent(semconv.ExceptionEventName, trace.WithTimestamp(ts), trace.WithAttributes(
+		semconv.ExceptionType("trazr.SimulatedError"),
+		semconv.ExceptionMessage("simulated failure in "+name),
+		semconv.ExceptionStacktrace(string(debug.Stack())),
+	))
+}
 
-			// simulates getting a request from a client
-			childCtx = otel.GetTextMapPropagator().Extract(childCtx, header)
-		}
-		var endTimestamp trace.SpanEventOption
+// childSpanKind returns the kind of the next child span, picked at random among the
+// configured kinds, or server when none are configured.
+func (w worker) childSpanKind() trace.SpanKind {
+	if len(w.childSpanKinds) == 0 {
+		return trace.SpanKindServer
+	}
+	return w.childSpanKinds[common.RandIntN(len(w.childSpanKinds))]
+}
 
-		for j := 0; j < w.numChildSpans; j++ {
-			if err := limiter.Wait(context.Background()); err != nil {
-				w.reportProgressf("Limiter wait failed: %v", err)
-				w.logger.Fatal("limiter waited failed, retry", zap.Error(err))
-			}
+// nextSpanDuration returns the duration of the next child span: spanDuration, except for
+// the slowSpanRatio share of the spans, which are slowSpanFactor times longer.
+func (w worker) nextSpanDuration() time.Duration {
+	if w.slowSpanRatio > 0 && common.RandFloat64() < w.slowSpanRatio {
+		return time.Duration(float64(w.spanDuration) * w.slowSpanFactor)
+	}
+	return w.spanDuration
+}
 
-			// Build a fresh set of telemetry attributes for each child span
-			childAttrs, err := cfg.GetTelemetryAttrWithMockMarker()
-			if err != nil {
-				w.reportProgressf("Failed to process telemetry attributes: %v", err)
-				w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
-				break
-			}
+// openTrace is a trace of a worker whose root span is started and whose child spans are
+// emitted one at a time, possibly interleaved with those of other traces.
+type openTrace struct {
+	tracer     trace.Tracer
+	scopeAttrs []attribute.KeyValue // `emit-legacy-library-attrs` span attributes of its scope
+	root       trace.Span
+	childCtx   context.Context // parent context of the child spans
+	spanStart  time.Time       // start of the next child span
+	spanEnd    time.Time       // end of the next child span
+	rootEnd    time.Time       // end of the root span, which is the end of the last child span
+	children   int             // number of child spans emitted so far
+	size       int             // number of child spans of the trace
+	topology   *topologyTrace  // state of the trace walking the topology of the worker (nil means none)
+}
 
-			_, child := tracer.Start(childCtx, "okey-dokey-"+strconv.Itoa(j), trace.WithAttributes(
-				semconv.NetSockPeerAddr(fakeIP),
-				semconv.PeerService("trazr-gen-client"),
-			),
-				trace.WithSpanKind(trace.SpanKindServer),
-				trace.WithTimestamp(spanStart),
-			)
-			child.SetAttributes(childAttrs...)
-
-			endTimestamp = trace.WithTimestamp(spanEnd)
-			child.SetStatus(w.statusCode, "")
-			child.End(endTimestamp)
-
-			// Reset the start and end for next span
-			spanStart = spanEnd
-			spanEnd = spanStart.Add(w.spanDuration)
+func (w worker) simulateTraces(cfg *Config) {
+	tracers := make([]trace.Tracer, len(w.scopeNames))
+	legacyAttrs := make([][]attribute.KeyValue, len(w.scopeNames)) // span attributes of each scope with legacyLibrary
+	for k, name := range w.scopeNames {
+		tracers[k] = otel.Tracer(name)
+		if w.legacyLibrary {
+			legacyAttrs[k] = legacyLibraryAttributes(name)
 		}
-		sp.SetStatus(w.statusCode, "")
-		sp.End(endTimestamp)
-
+	}
+	limiter := w.limiter
+	if limiter == nil {
+		limiter = rate.NewLimiter(w.limitPerSecond, 1)
+	}
+	concurrent := max(w.concurrentTraces, 1)
+	var i, started int // traces ended and started
+	var open []*openTrace
+	next := 0 // index in open of the trace emitting the next child span
+	endTrace := func(t *openTrace) {
+		w.endTrace(t)
 		i++
 		if w.tracesCounter != nil {
 			atomic.AddInt64(w.tracesCounter, 1)
@@ -136,12 +179,205 @@ func (w worker) simulateTraces(cfg *Config) {
 		if w.progressCh != nil {
 			w.progressCh <- struct{}{}
 		}
-		if w.numTraces != 0 {
-			if i >= w.numTraces {
+	}
+
+loop:
+	for w.running.Load() {
+		// keep concurrent traces open, without starting more than numTraces
+		for len(open) < concurrent && (w.numTraces == 0 || started < w.numTraces) {
+			t, ok := w.startTrace(cfg, limiter, tracers[started%len(tracers)], legacyAttrs[started%len(tracers)])
+			if !ok {
+				break loop
+			}
+			open = append(open, t)
+			started++
+		}
+		if len(open) == 0 {
+			break
+		}
+
+		// the open traces take turns emitting their child spans
+		next %= len(open)
+		t := open[next]
+		if !w.emitChildSpan(cfg, limiter, t) {
+			break
+		}
+		if t.children < t.size {
+			next++
+			continue
+		}
+		endTrace(t)
+		open = slices.Delete(open, next, next+1)
+	}
+	// the traces open at the end of the run get all their child spans, like the traces
+	// generated one at a time, which the end of the run never interrupts
+	for _, t := range open {
+		for t.children < t.size {
+			if !w.emitChildSpan(cfg, limiter, t) {
 				break
 			}
 		}
+		endTrace(t)
 	}
 	w.logger.Info("traces generated", zap.Int("traces", i))
 	w.wg.Done()
 }
+
+// startTrace waits for the limiter and starts the root span of a new trace. It reports
+// false when the trace can't be started.
+func (w worker) startTrace(cfg *Config, limiter *rate.Limiter, tracer trace.Tracer, scopeAttrs []attribute.KeyValue) (*openTrace, bool) {
+	spanStart := time.Now()
+
+	if err := limiter.Wait(context.Background()); err != nil {
+		w.reportProgressf("Limiter wait failed: %v", err)
+		w.logger.Fatal("limiter waited failed, retry", zap.Error(err))
+	}
+	w.exportTiming.Mark()
+
+	// Build a fresh set of telemetry attributes for each trace/span
+	var telemetryAttrs []attribute.KeyValue
+	if !cfg.NoTelemetryAttributes {
+		attrs, err := cfg.GetTelemetryAttrWithMockMarker()
+		if err != nil {
+			w.reportProgressf("Failed to process telemetry attributes: %v", err)
+			w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
+			return nil, false
+		}
+		telemetryAttrs = attrs
+	}
+
+	ctx := context.Background()
+	if w.traceFlags != 0 {
+		ctx = withTraceFlags(ctx, w.traceFlags)
+	}
+	name, kind, attrs := "lets-go", trace.SpanKindClient, w.peerAttributes(cfg.MockData, "trazr-gen-server")
+	if w.topology != nil {
+		root := w.topology.spans[0]
+		ctx = withService(ctx, root.service)
+		name, kind, attrs = root.name, root.kind, root.attrs
+	}
+	ctx, sp := tracer.Start(ctx, name, trace.WithAttributes(attrs...),
+		trace.WithSpanKind(kind),
+		trace.WithTimestamp(spanStart),
+	)
+	sp.SetAttributes(telemetryAttrs...)
+	sp.SetAttributes(scopeAttrs...)
+	common.PublishSpanContext(sp.SpanContext())
+	if w.selfDescribe {
+		sp.SetAttributes(selfDescribeAttributes(w.id, limiter)...)
+	}
+	for j := 0; j < w.loadSize; j++ {
+		sp.SetAttributes(attribute.String(fmt.Sprintf("load-%v", j), string(make([]byte, charactersPerMB))))
+	}
+
+	childCtx := ctx
+	if w.propagateContext {
+		childCtx = propagate(childCtx)
+	}
+	t := &openTrace{
+		tracer:     tracer,
+		scopeAttrs: scopeAttrs,
+		root:       sp,
+		childCtx:   childCtx,
+		spanStart:  spanStart,
+		spanEnd:    spanStart.Add(w.nextSpanDuration()),
+		rootEnd:    spanStart,
+		size:       w.traceSize(),
+	}
+	if w.topology != nil {
+		t.topology = w.newTopologyTrace(spanStart)
+		t.topology.contexts[0] = ctx
+		t.rootEnd = t.topology.windows[0].end
+	}
+	return t, true
+}
+
+// propagate returns the context of ctx as a remote service gets it, once sent in the
+// headers of an HTTP request.
+func propagate(ctx context.Context) context.Context {
+	header := propagation.HeaderCarrier{}
+	// simulates going remote
+	otel.GetTextMapPropagator().Inject(ctx, header)
+
+	// simulates getting a request from a client
+	return otel.GetTextMapPropagator().Extract(ctx, header)
+}
+
+// emitChildSpan emits the next child span of t, waiting for the limiter unless it counts
+// whole traces. It reports false when the span can't be emitted.
+func (w worker) emitChildSpan(cfg *Config, limiter *rate.Limiter, t *openTrace) bool {
+	if !w.ratePerTrace {
+		if err := limiter.Wait(context.Background()); err != nil {
+			w.reportProgressf("Limiter wait failed: %v", err)
+			w.logger.Fatal("limiter waited failed, retry", zap.Error(err))
+		}
+		w.exportTiming.Mark()
+	}
+
+	// Build a fresh set of telemetry attributes for each child span
+	var childAttrs []attribute.KeyValue
+	if !cfg.NoTelemetryAttributes {
+		attrs, err := cfg.GetTelemetryAttrWithMockMarker()
+		if err != nil {
+			w.reportProgressf("Failed to process telemetry attributes: %v", err)
+			w.logger.Fatal("failed to process telemetry attributes", zap.Error(err))
+			return false
+		}
+		childAttrs = attrs
+	}
+
+	if t.topology != nil {
+		w.emitTopologySpan(t, childAttrs, limiter)
+		t.children++
+		return true
+	}
+
+	childName := "okey-dokey-" + strconv.Itoa(t.children)
+	childStart, childEnd := w.childTimestamps(t.spanStart, t.spanEnd)
+	_, child := t.tracer.Start(t.childCtx, childName, trace.WithAttributes(
+		w.peerAttributes(cfg.MockData, "trazr-gen-client")...,
+	),
+		trace.WithSpanKind(w.childSpanKind()),
+		trace.WithTimestamp(childStart),
+	)
+	child.SetAttributes(childAttrs...)
+	child.SetAttributes(t.scopeAttrs...)
+	common.PublishSpanContext(child.SpanContext())
+	if w.selfDescribe {
+		child.SetAttributes(selfDescribeAttributes(w.id, limiter)...)
+	}
+
+	t.rootEnd = t.spanEnd
+	w.recordException(child, childName, childEnd)
+	child.SetStatus(w.statusCode, "")
+	child.End(trace.WithTimestamp(childEnd))
+
+	// Reset the start and end for next span
+	t.spanStart = t.spanEnd
+	t.spanEnd = t.spanStart.Add(w.nextSpanDuration())
+	t.children++
+	return true
+}
+
+// traceSize returns the number of child spans of the next trace: numChildSpans, a size
+// sampled from the traceSizes distribution, or the spans of the topology but its root.
+func (w worker) traceSize() int {
+	if w.topology != nil {
+		return len(w.topology.spans) - 1
+	}
+	if w.traceSizes != nil {
+		return w.traceSizes.sample()
+	}
+	return w.numChildSpans
+}
+
+// endTrace ends the root span of t.
+func (w worker) endTrace(t *openTrace) {
+	name := "lets-go"
+	if w.topology != nil {
+		name = w.topology.spans[0].name
+	}
+	w.recordException(t.root, name, t.rootEnd)
+	t.root.SetStatus(w.statusCode, "")
+	t.root.End(trace.WithTimestamp(t.rootEnd))
+}
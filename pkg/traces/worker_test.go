@@ -5,6 +5,13 @@ package traces
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,10 +19,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 
 	"github.com/medxops/trazr-gen/internal/common"
 )
@@ -44,10 +55,13 @@ func TestFixedNumberOfTraces(t *testing.T) {
 	}
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	stats, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	assert.Len(t, syncer.spans, 2) // each trace has two spans
+	assert.Equal(t, int64(1), stats.Generated)
+	assert.Positive(t, stats.Duration)
 }
 
 func TestNumberOfSpans(t *testing.T) {
@@ -69,7 +83,8 @@ func TestNumberOfSpans(t *testing.T) {
 	expectedNumSpans := cfg.NumChildSpans + 1 // each trace has 1 + NumChildSpans spans
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	assert.Len(t, syncer.spans, expectedNumSpans)
@@ -96,7 +111,8 @@ func TestRateOfSpans(t *testing.T) {
 	require.Empty(t, syncer.spans)
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	// the minimum acceptable number of spans for the rate of 10/sec for half a second
@@ -105,6 +121,34 @@ func TestRateOfSpans(t *testing.T) {
 	assert.LessOrEqual(t, len(syncer.spans), 20, "there should have been less than 20 spans, had %d", len(syncer.spans))
 }
 
+func TestSharedLimiter(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			Rate:          10,
+			TotalDuration: time.Second / 2,
+			WorkerCount:   4,
+			SharedLimiter: true,
+		},
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	// with a shared limiter the rate is global, so four workers must not exceed the single-worker ceiling
+	assert.GreaterOrEqual(t, len(syncer.spans), 4, "there should have been at least 4 spans, had %d", len(syncer.spans))
+	assert.LessOrEqual(t, len(syncer.spans), 20, "there should have been less than 20 spans, had %d", len(syncer.spans))
+}
+
 func TestSpanDuration(t *testing.T) {
 	// prepare
 	syncer := &mockSyncer{}
@@ -128,7 +172,8 @@ func TestSpanDuration(t *testing.T) {
 	require.Empty(t, syncer.spans)
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	for _, span := range syncer.spans {
 		startTime, endTime := span.StartTime(), span.EndTime()
@@ -137,6 +182,60 @@ func TestSpanDuration(t *testing.T) {
 	}
 }
 
+func TestSlowSpans(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	baseDuration := time.Millisecond
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumTraces:      2,
+		NumChildSpans:  3,
+		SpanDuration:   baseDuration,
+		SlowSpanRatio:  1,
+		SlowSpanFactor: 100,
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, syncer.spans, 8)
+	for _, span := range syncer.spans {
+		duration := span.EndTime().Sub(span.StartTime())
+		if span.Parent().IsValid() {
+			assert.Equal(t, 100*baseDuration, duration, "every child span should be slow")
+		} else {
+			assert.Equal(t, 3*100*baseDuration, duration, "the parent span should cover its slow children")
+		}
+	}
+}
+
+func TestNextSpanDuration(t *testing.T) {
+	w := worker{spanDuration: time.Second, slowSpanFactor: 10}
+	assert.Equal(t, time.Second, w.nextSpanDuration())
+
+	w.slowSpanRatio = 1
+	assert.Equal(t, 10*time.Second, w.nextSpanDuration())
+
+	w.slowSpanRatio = 0.5
+	slow := 0
+	for range 1000 {
+		if w.nextSpanDuration() > time.Second {
+			slow++
+		}
+	}
+	assert.InDelta(t, 500, slow, 100, "about half of the spans should be slow")
+}
+
 func TestUnthrottled(t *testing.T) {
 	// prepare
 	syncer := &mockSyncer{}
@@ -157,7 +256,8 @@ func TestUnthrottled(t *testing.T) {
 	require.Empty(t, syncer.spans)
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	// the minimum acceptable number of spans -- the real number should be > 10k, but CI env might be slower
@@ -181,7 +281,8 @@ func TestSpanKind(t *testing.T) {
 	}
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify that the default Span Kind is being overridden
 	for _, span := range syncer.spans {
@@ -232,13 +333,15 @@ func TestSpanStatuses(t *testing.T) {
 
 			// test the program given input, including erroneous inputs
 			if tt.validInput {
-				require.NoError(t, run(cfg, zap.NewNop()))
+				_, err := run(cfg, nil, zap.NewNop())
+				require.NoError(t, err)
 				// verify that the default the span status is set as expected
 				for _, span := range syncer.spans {
 					assert.Equalf(t, span.Status().Code, tt.spanStatus, "span status: %v and expected status %v", span.Status().Code, tt.spanStatus)
 				}
 			} else {
-				require.Error(t, run(cfg, zap.NewNop()))
+				_, err := run(cfg, nil, zap.NewNop())
+				require.Error(t, err)
 			}
 		})
 	}
@@ -256,7 +359,8 @@ func TestSpansWithNoAttrs(t *testing.T) {
 	cfg := configWithNoAttributes(2, "")
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	assert.Len(t, syncer.spans, 4) // each trace has two spans
@@ -278,7 +382,8 @@ func TestSpansWithOneAttrs(t *testing.T) {
 	cfg := configWithOneAttribute(2, "")
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	assert.Len(t, syncer.spans, 4) // each trace has two spans
@@ -288,6 +393,30 @@ func TestSpansWithOneAttrs(t *testing.T) {
 	}
 }
 
+func TestSpansWithNoTelemetryAttributes(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := configWithOneAttribute(2, "")
+	cfg.NoTelemetryAttributes = true
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	assert.Len(t, syncer.spans, 4) // each trace has two spans
+	for _, span := range syncer.spans {
+		attributes := span.Attributes()
+		assert.Len(t, attributes, 2, "telemetry attributes should be skipped, leaving the 2 fixed attributes")
+	}
+}
+
 func TestSpansWithMultipleAttrs(t *testing.T) {
 	// prepare
 	syncer := &mockSyncer{}
@@ -300,7 +429,8 @@ func TestSpansWithMultipleAttrs(t *testing.T) {
 	cfg := configWithMultipleAttributes(2, "")
 
 	// test
-	require.NoError(t, run(cfg, zap.NewNop()))
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
 
 	// verify
 	assert.Len(t, syncer.spans, 4) // each trace has two spans
@@ -310,6 +440,540 @@ func TestSpansWithMultipleAttrs(t *testing.T) {
 	}
 }
 
+func TestPeerOverrides(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	common.InitMockData(1)
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+			MockData:    true,
+		},
+		NumTraces:   1,
+		PeerAddress: "{{IPv4Address}}",
+		PeerService: "billing",
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, syncer.spans, 2)
+	for _, span := range syncer.spans {
+		attrs := attribute.NewSet(span.Attributes()...)
+		addr, ok := attrs.Value("net.sock.peer.addr")
+		require.True(t, ok)
+		assert.NotEqual(t, "{{IPv4Address}}", addr.AsString())
+		assert.NotEqual(t, fakeIP, addr.AsString())
+		service, ok := attrs.Value("peer.service")
+		require.True(t, ok)
+		assert.Equal(t, "billing", service.AsString())
+	}
+}
+
+func TestPeerDefaults(t *testing.T) {
+	w := worker{logger: zap.NewNop()}
+	attrs := attribute.NewSet(w.peerAttributes(false, "trazr-gen-server")...)
+	addr, _ := attrs.Value("net.sock.peer.addr")
+	assert.Equal(t, fakeIP, addr.AsString())
+	service, _ := attrs.Value("peer.service")
+	assert.Equal(t, "trazr-gen-server", service.AsString())
+}
+
+func TestWorkerScope(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+			WorkerScope: true,
+		},
+		NumTraces: 1,
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, syncer.spans, 2)
+	for _, span := range syncer.spans {
+		assert.Equal(t, "trazr-gen/worker-1", span.InstrumentationScope().Name)
+	}
+}
+
+func TestLegacyLibraryAttributes(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		t.Run("legacy="+strconv.FormatBool(legacy), func(t *testing.T) {
+			// prepare
+			syncer := &mockSyncer{}
+
+			tracerProvider := sdktrace.NewTracerProvider()
+			sp := sdktrace.NewSimpleSpanProcessor(syncer)
+			tracerProvider.RegisterSpanProcessor(sp)
+			otel.SetTracerProvider(tracerProvider)
+
+			cfg := &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					WorkerScope: true,
+				},
+				NumTraces:     1,
+				LegacyLibrary: legacy,
+			}
+
+			// test
+			_, err := run(cfg, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			// verify
+			require.Len(t, syncer.spans, 2)
+			for _, span := range syncer.spans {
+				attrs := attribute.NewSet(span.Attributes()...)
+				name, ok := attrs.Value(legacyLibraryNameKey)
+				assert.Equal(t, legacy, ok, "span %s", span.Name())
+				if legacy {
+					assert.Equal(t, span.InstrumentationScope().Name, name.AsString())
+				}
+			}
+		})
+	}
+}
+
+func TestSelfDescribe(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 2,
+			Rate:        1000,
+		},
+		NumTraces:    1,
+		SelfDescribe: true,
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, syncer.spans, 4)
+	workers := map[int64]int{}
+	for _, span := range syncer.spans {
+		attrs := attribute.NewSet(span.Attributes()...)
+		id, ok := attrs.Value(selfWorkerIDKey)
+		require.True(t, ok, "span %s", span.Name())
+		workers[id.AsInt64()]++
+		target, ok := attrs.Value(selfTargetRateKey)
+		require.True(t, ok, "span %s", span.Name())
+		assert.InDelta(t, 1000, target.AsFloat64(), 0)
+	}
+	assert.Equal(t, map[int64]int{1: 2, 2: 2}, workers, "each worker tags its root and child span")
+}
+
+func TestSelfDescribeAttributes(t *testing.T) {
+	attrs := selfDescribeAttributes(3, rate.NewLimiter(rate.Inf, 1))
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.Int(selfWorkerIDKey, 3),
+		attribute.Float64(selfTargetRateKey, 0),
+	}, attrs, "an unthrottled worker has a target rate of 0")
+}
+
+// heapSamplingExporter discards the spans it exports, after sampling the live heap of
+// the process, queued spans included.
+type heapSamplingExporter struct {
+	mu      sync.Mutex
+	maxHeap uint64 // largest live heap sampled
+}
+
+func (e *heapSamplingExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	runtime.GC()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	e.mu.Lock()
+	e.maxHeap = max(e.maxHeap, mem.HeapAlloc)
+	e.mu.Unlock()
+	return nil
+}
+
+func (*heapSamplingExporter) Shutdown(context.Context) error { return nil }
+
+// runBoundedMemory generates numTraces traces through the batch span processor Start
+// builds with `bounded-memory`, and returns the largest live heap sampled along the way.
+func runBoundedMemory(tb testing.TB, numTraces int) uint64 {
+	tb.Helper()
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:   1,
+			BoundedMemory: true,
+		},
+		NumTraces:     numTraces,
+		NumChildSpans: 1,
+		Batch:         true,
+	}
+	exporter := &heapSamplingExporter{}
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter, batchSpanProcessorOptions(cfg)...))
+	otel.SetTracerProvider(tracerProvider)
+
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(tb, err)
+	require.NoError(tb, tracerProvider.Shutdown(context.Background()))
+	return exporter.maxHeap
+}
+
+func TestBoundedMemory(t *testing.T) {
+	small := runBoundedMemory(t, 2_000)
+	large := runBoundedMemory(t, 20_000)
+	assert.Less(t, large, small+small/2+4<<20, "the live heap of 10 times more traces should stay flat: %d then %d bytes", small, large)
+}
+
+// BenchmarkBoundedMemory generates b.N traces like TestBoundedMemory and reports the
+// largest live heap sampled, which stays flat as b.N grows, e.g. from -benchtime=1000x
+// to -benchtime=100000x.
+func BenchmarkBoundedMemory(b *testing.B) {
+	b.ReportAllocs()
+	b.ReportMetric(float64(runBoundedMemory(b, b.N)), "max-heap-B")
+}
+
+func TestConcurrentTraces(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumTraces:        4,
+		NumChildSpans:    2,
+		ConcurrentTraces: 3,
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, syncer.spans, 12)
+	var children []trace.TraceID
+	for _, span := range syncer.spans {
+		if span.Parent().IsValid() {
+			children = append(children, span.SpanContext().TraceID())
+		}
+	}
+	require.Len(t, children, 8)
+	assert.NotEqual(t, children[0], children[1], "the open traces should take turns")
+	assert.NotEqual(t, children[1], children[2], "the open traces should take turns")
+	assert.NotEqual(t, children[0], children[2], "the open traces should take turns")
+	assert.Equal(t, children[0], children[3], "the first trace should emit its second child span after the others")
+
+	perTrace := map[trace.TraceID]int{}
+	for _, id := range children {
+		perTrace[id]++
+	}
+	assert.Len(t, perTrace, 4)
+	for _, n := range perTrace {
+		assert.Equal(t, 2, n, "every trace should get all its child spans")
+	}
+}
+
+func TestFullTracesWithDuration(t *testing.T) {
+	for _, concurrent := range []int{0, 3} {
+		t.Run(fmt.Sprintf("concurrent traces %d", concurrent), func(t *testing.T) {
+			// prepare
+			syncer := &mockSyncer{}
+
+			tracerProvider := sdktrace.NewTracerProvider()
+			sp := sdktrace.NewSimpleSpanProcessor(syncer)
+			tracerProvider.RegisterSpanProcessor(sp)
+			otel.SetTracerProvider(tracerProvider)
+
+			cfg := &Config{
+				Config: common.Config{
+					Rate:          50,
+					TotalDuration: 200 * time.Millisecond,
+					WorkerCount:   1,
+				},
+				NumChildSpans:    4,
+				ConcurrentTraces: concurrent,
+			}
+
+			// test
+			_, err := run(cfg, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			// verify
+			var roots []trace.TraceID
+			children := map[trace.TraceID]int{}
+			for _, span := range syncer.spans {
+				if span.Parent().IsValid() {
+					children[span.SpanContext().TraceID()]++
+				} else {
+					roots = append(roots, span.SpanContext().TraceID())
+				}
+			}
+			require.NotEmpty(t, roots)
+			for _, id := range roots {
+				assert.Equal(t, 4, children[id], "trace %s should get all its child spans, the last one included", id)
+			}
+		})
+	}
+}
+
+func TestScopesPerResource(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:       1,
+			ScopesPerResource: 2,
+		},
+		NumTraces: 4,
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, syncer.spans, 8)
+	scopes := map[trace.TraceID]string{}
+	for _, span := range syncer.spans {
+		name := span.InstrumentationScope().Name
+		if scope, ok := scopes[span.SpanContext().TraceID()]; ok {
+			assert.Equal(t, scope, name, "the spans of a trace should share their scope")
+		}
+		scopes[span.SpanContext().TraceID()] = name
+	}
+	counts := map[string]int{}
+	for _, name := range scopes {
+		counts[name]++
+	}
+	assert.Equal(t, map[string]int{"trazr-gen/scope-1": 2, "trazr-gen/scope-2": 2}, counts)
+}
+
+func TestFleetSize(t *testing.T) {
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	for _, propagateContext := range []bool{false, true} {
+		t.Run(fmt.Sprintf("marshal=%t", propagateContext), func(t *testing.T) {
+			// prepare
+			syncer := &mockSyncer{}
+			sp := sdktrace.NewSimpleSpanProcessor(syncer)
+			cfg := &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					FleetSize:   2,
+				},
+				NumTraces:        4,
+				NumChildSpans:    2,
+				PropagateContext: propagateContext,
+			}
+			resources, err := cfg.FleetResources(cfg.ResourceSchemaURL())
+			require.NoError(t, err)
+			hosts := make([]trace.TracerProvider, len(resources))
+			for k, res := range resources {
+				tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithResource(res))
+				tracerProvider.RegisterSpanProcessor(sp)
+				hosts[k] = tracerProvider
+			}
+			otel.SetTracerProvider(newFleetTracerProvider(hosts))
+
+			// test
+			_, err = run(cfg, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			// verify
+			require.Len(t, syncer.spans, 12)
+			traceHosts := map[trace.TraceID]string{}
+			for _, span := range syncer.spans {
+				host, ok := span.Resource().Set().Value("host.name")
+				require.True(t, ok)
+				if name, ok := traceHosts[span.SpanContext().TraceID()]; ok {
+					assert.Equal(t, name, host.AsString(), "the spans of a trace should share their host")
+				}
+				traceHosts[span.SpanContext().TraceID()] = host.AsString()
+			}
+			counts := map[string]int{}
+			for _, host := range traceHosts {
+				counts[host]++
+			}
+			require.Len(t, counts, 2)
+			for host, count := range counts {
+				assert.Equal(t, 2, count, "host %s should emit every other trace", host)
+			}
+		})
+	}
+}
+
+func TestParseTraceSizeDistribution(t *testing.T) {
+	d, err := parseTraceSizeDistribution("")
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	d, err = parseTraceSizeDistribution(" 1:80, 50:20 ,3:0")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 50}, d.sizes)
+	assert.Equal(t, []float64{80, 100}, d.cumulative)
+
+	for _, spec := range []string{"1", "0:10", "x:10", "1:-1", "1:x", "1:0"} {
+		_, err := parseTraceSizeDistribution(spec)
+		assert.ErrorContains(t, err, "`trace-size-distribution`", spec)
+	}
+}
+
+func TestTraceSizeDistribution(t *testing.T) {
+	// prepare
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumTraces:  50,
+		TraceSizes: "1:1,5:1",
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	spans := map[trace.TraceID]int{}
+	for _, span := range syncer.spans {
+		spans[span.SpanContext().TraceID()]++
+	}
+	require.Len(t, spans, 50)
+	sizes := map[int]int{}
+	for _, count := range spans {
+		sizes[count-1]++ // child spans, besides the root span
+	}
+	assert.Len(t, sizes, 2, "the traces should have either size: %v", sizes)
+	assert.Positive(t, sizes[1])
+	assert.Positive(t, sizes[5])
+}
+
+func TestEmitException(t *testing.T) {
+	for _, status := range []string{"Error", "Ok"} {
+		t.Run("status="+status, func(t *testing.T) {
+			syncer := &mockSyncer{}
+
+			tracerProvider := sdktrace.NewTracerProvider()
+			sp := sdktrace.NewSimpleSpanProcessor(syncer)
+			tracerProvider.RegisterSpanProcessor(sp)
+			otel.SetTracerProvider(tracerProvider)
+
+			cfg := &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:     1,
+				NumChildSpans: 1,
+				StatusCode:    status,
+				EmitException: true,
+			}
+
+			// test
+			_, err := run(cfg, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			// verify
+			require.Len(t, syncer.spans, 2)
+			for _, span := range syncer.spans {
+				if status != "Error" {
+					assert.Empty(t, span.Events(), "only error spans record exceptions")
+					continue
+				}
+				require.Len(t, span.Events(), 1)
+				event := span.Events()[0]
+				assert.Equal(t, "exception", event.Name)
+				attrs := attribute.NewSet(event.Attributes...)
+				excType, _ := attrs.Value("exception.type")
+				assert.Equal(t, "trazr.SimulatedError", excType.AsString())
+				msg, _ := attrs.Value("exception.message")
+				assert.Equal(t, "simulated failure in "+span.Name(), msg.AsString())
+				stack, _ := attrs.Value("exception.stacktrace")
+				assert.NotEmpty(t, stack.AsString())
+			}
+		})
+	}
+}
+
+func TestChildSpanKinds(t *testing.T) {
+	syncer := &mockSyncer{}
+
+	tracerProvider := sdktrace.NewTracerProvider()
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	tracerProvider.RegisterSpanProcessor(sp)
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumTraces:      1,
+		NumChildSpans:  20,
+		ChildSpanKinds: []string{"internal", "Producer"},
+	}
+
+	// test
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// verify
+	require.Len(t, syncer.spans, 21)
+	for _, span := range syncer.spans {
+		if span.Name() == "lets-go" {
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			continue
+		}
+		assert.Contains(t, []trace.SpanKind{trace.SpanKindInternal, trace.SpanKindProducer}, span.SpanKind())
+	}
+}
+
+func TestParseSpanKinds(t *testing.T) {
+	kinds, err := parseSpanKinds([]string{"server", " client", "CONSUMER"})
+	require.NoError(t, err)
+	assert.Equal(t, []trace.SpanKind{trace.SpanKindServer, trace.SpanKindClient, trace.SpanKindConsumer}, kinds)
+
+	_, err = parseSpanKinds([]string{"remote"})
+	require.EqualError(t, err, "expected `child-span-kinds` to contain only internal, server, client, producer or consumer, got \"remote\" instead")
+}
+
 func TestValidate(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -325,6 +989,167 @@ func TestValidate(t *testing.T) {
 			},
 			wantErrMessage: "either `traces` or `duration` must be greater than 0",
 		},
+		{
+			name: "Negative warmup",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount:    1,
+					WarmupDuration: -time.Second,
+				},
+				NumTraces: 1,
+			},
+			wantErrMessage: "`warmup-duration` must not be negative",
+		},
+		{
+			name: "Negative max errors",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					MaxErrors:   -1,
+				},
+				NumTraces: 1,
+			},
+			wantErrMessage: "`max-errors` must not be negative",
+		},
+		{
+			name: "Bounded memory without batch",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount:   1,
+					BoundedMemory: true,
+				},
+				NumTraces: 1,
+			},
+			wantErrMessage: "`bounded-memory` caps the queue of the batch span processor, it requires `batch`",
+		},
+		{
+			name: "Dashboard without terminal output",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					TUI:         true,
+				},
+				NumTraces: 1,
+			},
+			wantErrMessage: "`tui` requires `terminal-output`",
+		},
+		{
+			name: "Unbracketed IPv6 endpoint",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount:    1,
+					CustomEndpoint: "::1:4317",
+				},
+				NumTraces: 1,
+			},
+			wantErrMessage: "invalid endpoint \"::1:4317\": IPv6 addresses must be in brackets, like '[::1]:4317'",
+		},
+		{
+			name: "Slow span ratio out of range",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:      1,
+				SlowSpanRatio:  1.5,
+				SlowSpanFactor: 100,
+			},
+			wantErrMessage: "`slow-span-ratio` must be between 0 and 1",
+		},
+		{
+			name: "Slow span factor below 1",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:      1,
+				SlowSpanRatio:  0.1,
+				SlowSpanFactor: 0.5,
+			},
+			wantErrMessage: "`slow-span-factor` must be a finite number of at least 1",
+		},
+		{
+			name: "Slow span factor NaN",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:      1,
+				SlowSpanRatio:  0.1,
+				SlowSpanFactor: math.NaN(),
+			},
+			wantErrMessage: "`slow-span-factor` must be a finite number of at least 1",
+		},
+		{
+			name: "Slow span factor infinite",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:      1,
+				SlowSpanRatio:  0.1,
+				SlowSpanFactor: math.Inf(1),
+			},
+			wantErrMessage: "`slow-span-factor` must be a finite number of at least 1",
+		},
+		{
+			name: "Slow span duration overflow",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:      1,
+				SpanDuration:   time.Hour,
+				SlowSpanRatio:  0.1,
+				SlowSpanFactor: 1e10,
+			},
+			wantErrMessage: "`slow-span-factor` times `span-duration` must be below 2562047h47m16.854775807s",
+		},
+		{
+			name: "Unknown timestamp edge case",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:         1,
+				TimestampEdgeCase: "child-outlives-parent",
+			},
+			wantErrMessage: "expected `span-timestamp-edge-case` to be one of child-starts-early or child-ends-late, got \"child-outlives-parent\" instead",
+		},
+		{
+			name: "Unknown topology",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces: 1,
+				Topology:  "mesh",
+			},
+			wantErrMessage: "expected `topology` to be one of fanout, microservices-10, three-tier or a YAML or JSON file, got \"mesh\" instead",
+		},
+		{
+			name: "Topology in a fleet",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+					FleetSize:   3,
+				},
+				NumTraces: 1,
+				Topology:  "three-tier",
+			},
+			wantErrMessage: "`topology` gives each service its own resource, it can't be combined with `fleet-size`",
+		},
+		{
+			name: "Invalid trace size distribution",
+			cfg: &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:  1,
+				TraceSizes: "1:80,50",
+			},
+			wantErrMessage: "invalid `trace-size-distribution` \"1:80,50\": expected SIZE:WEIGHT pairs like 1:80,50:20, got \"50\" instead",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -335,7 +1160,10 @@ func TestValidate(t *testing.T) {
 			tracerProvider.RegisterSpanProcessor(sp)
 			otel.SetTracerProvider(tracerProvider)
 			logger, _ := zap.NewDevelopment()
-			require.EqualError(t, run(tt.cfg, logger), tt.wantErrMessage)
+			_, err := run(tt.cfg, nil, logger)
+			require.EqualError(t, err, tt.wantErrMessage)
+			var validationErr *common.ValidationError
+			assert.ErrorAs(t, err, &validationErr, "validation errors are typed")
 		})
 	}
 }
@@ -461,3 +1289,311 @@ func TestSetHelpTemplateForCmd(t *testing.T) {
 func newFlagSet() *pflag.FlagSet {
 	return &pflag.FlagSet{}
 }
+
+// failingSyncer is a span exporter whose exports all fail.
+type failingSyncer struct {
+	mockSyncer
+}
+
+func (f *failingSyncer) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error {
+	return context.DeadlineExceeded
+}
+
+func TestMaxErrors(t *testing.T) {
+	exportErrors := common.NewExportErrors()
+	exportErrors.AbortAfter(3)
+	tracerProvider := sdktrace.NewTracerProvider()
+	tracerProvider.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(errorCountingExporter{SpanExporter: &failingSyncer{}, errors: exportErrors}))
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount:   1,
+			Rate:          100,
+			TotalDuration: time.Minute,
+			MaxErrors:     3,
+		},
+	}
+
+	start := time.Now()
+	_, err := run(cfg, exportErrors, zap.NewNop())
+	require.ErrorIs(t, err, common.ErrMaxErrors)
+	assert.Less(t, time.Since(start), 10*time.Second, "the run must be aborted long before its duration")
+}
+
+func TestRatePerTrace(t *testing.T) {
+	syncer := &mockSyncer{}
+	tracerProvider := sdktrace.NewTracerProvider()
+	tracerProvider.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(syncer))
+	otel.SetTracerProvider(tracerProvider)
+
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+			Rate:        5,
+		},
+		NumTraces:     3,
+		NumChildSpans: 10,
+		RatePerTrace:  true,
+	}
+
+	start := time.Now()
+	_, err := run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// limited per span, the 33 spans would take over 6s at 5 per second
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Len(t, syncer.spans, 33)
+}
+
+func TestTimestampEdgeCase(t *testing.T) {
+	for _, edgeCase := range []string{timestampEdgeCaseChildStartsEarly, timestampEdgeCaseChildEndsLate} {
+		t.Run(edgeCase, func(t *testing.T) {
+			syncer := &mockSyncer{}
+			tracerProvider := sdktrace.NewTracerProvider()
+			tracerProvider.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(syncer))
+			otel.SetTracerProvider(tracerProvider)
+
+			cfg := &Config{
+				Config: common.Config{
+					WorkerCount: 1,
+				},
+				NumTraces:         1,
+				NumChildSpans:     2,
+				SpanDuration:      time.Millisecond,
+				TimestampEdgeCase: edgeCase,
+			}
+			_, err := run(cfg, nil, zap.NewNop())
+			require.NoError(t, err)
+			require.Len(t, syncer.spans, 3)
+
+			// children end first, the parent is the last span
+			parent := syncer.spans[2]
+			for _, child := range syncer.spans[:2] {
+				require.Equal(t, parent.SpanContext().SpanID(), child.Parent().SpanID())
+				if edgeCase == timestampEdgeCaseChildStartsEarly {
+					assert.True(t, child.StartTime().Before(parent.StartTime()), "child should start before its parent")
+					assert.False(t, child.EndTime().After(parent.EndTime()))
+				} else {
+					assert.False(t, child.StartTime().Before(parent.StartTime()))
+					assert.True(t, child.EndTime().After(parent.EndTime()), "child should end after its parent")
+				}
+			}
+		})
+	}
+}
+
+func TestSeed(t *testing.T) {
+	generate := func() []sdktrace.ReadOnlySpan {
+		syncer := &mockSyncer{}
+		cfg := &Config{
+			Config: common.Config{
+				WorkerCount:         1,
+				Seed:                42,
+				MockData:            true,
+				TelemetryAttributes: common.KeyValue{"user.id": "{{UUID}}"},
+			},
+			NumTraces:      3,
+			NumChildSpans:  2,
+			ChildSpanKinds: []string{"client", "server", "producer"},
+		}
+		cfg.SeedRandomness()
+		tracerProvider := sdktrace.NewTracerProvider(tracerProviderOptions(cfg)...)
+		tracerProvider.RegisterSpanProcessor(sdktrace.NewSimpleSpanProcessor(syncer))
+		otel.SetTracerProvider(tracerProvider)
+
+		_, err := run(cfg, nil, zap.NewNop())
+		require.NoError(t, err)
+		return syncer.spans
+	}
+	defer (&common.Config{}).SeedRandomness()
+
+	first, second := generate(), generate()
+	require.Len(t, second, len(first))
+	for i := range first {
+		assert.Equal(t, first[i].SpanContext().TraceID(), second[i].SpanContext().TraceID(), "span %d", i)
+		assert.Equal(t, first[i].SpanContext().SpanID(), second[i].SpanContext().SpanID(), "span %d", i)
+		assert.Equal(t, first[i].SpanKind(), second[i].SpanKind(), "span %d", i)
+		assert.Equal(t, first[i].Attributes(), second[i].Attributes(), "span %d", i)
+	}
+}
+
+// keepingSyncer is a mockSyncer that can be shut down, as the exporter of every run of Start.
+type keepingSyncer struct{ mockSyncer }
+
+func (*keepingSyncer) Shutdown(context.Context) error { return nil }
+
+func TestSeed_Repeat(t *testing.T) {
+	var syncer *keepingSyncer
+	RegisterExporter("test-seed-repeat", func(*Config) (sdktrace.SpanExporter, error) { return syncer, nil })
+	t.Cleanup(func() { unregisterExporter("test-seed-repeat") })
+	generate := func() []sdktrace.ReadOnlySpan {
+		syncer = &keepingSyncer{}
+		cfg := NewConfig()
+		cfg.TerminalOutput = false
+		cfg.Exporter = "test-seed-repeat"
+		cfg.Seed = 42
+		cfg.Repeat = 2
+		cfg.MockData = true
+		cfg.TelemetryAttributes = common.KeyValue{"user.id": "{{UUID}}"}
+		cfg.NumTraces = 1
+		_, err := Start(cfg, zap.NewNop())
+		require.NoError(t, err)
+		return syncer.spans
+	}
+	defer (&common.Config{}).SeedRandomness()
+
+	first, second := generate(), generate()
+	require.Len(t, first, 4, "a parent and a child span per run")
+	require.Len(t, second, len(first))
+	for i := range first {
+		assert.Equal(t, first[i].SpanContext().TraceID(), second[i].SpanContext().TraceID(), "span %d", i)
+		assert.Equal(t, first[i].Attributes(), second[i].Attributes(), "span %d", i)
+	}
+	assert.NotEqual(t, first[0].Attributes(), first[2].Attributes(), "each run gets fresh mock data")
+}
+
+// runTopology runs cfg with the topology of cfg.Topology and returns the spans by name.
+func runTopology(t *testing.T, cfg *Config) map[string][]sdktrace.ReadOnlySpan {
+	syncer := &mockSyncer{}
+	sp := sdktrace.NewSimpleSpanProcessor(syncer)
+	topo, err := loadTopology(cfg.Topology)
+	require.NoError(t, err)
+	tracerProvider, err := newTopologyTracerProvider(topo, resource.NewSchemaless(attribute.String("env", "test")), func(res *resource.Resource) trace.TracerProvider {
+		tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithResource(res))
+		tracerProvider.RegisterSpanProcessor(sp)
+		return tracerProvider
+	})
+	require.NoError(t, err)
+	otel.SetTracerProvider(tracerProvider)
+
+	_, err = run(cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range syncer.spans {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	return spans
+}
+
+func serviceName(span sdktrace.ReadOnlySpan) string {
+	name, _ := span.Resource().Set().Value("service.name")
+	return name.AsString()
+}
+
+func TestTopology(t *testing.T) {
+	cfg := &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumTraces:     2,
+		NumChildSpans: 7,
+		SpanDuration:  time.Millisecond,
+		Topology:      "three-tier",
+	}
+	spans := runTopology(t, cfg)
+
+	// the frontend calls the api, which queries the database
+	require.Len(t, spans["GET /checkout"], 2)
+	require.Len(t, spans["POST /orders"], 4, "the client span of the frontend and the server span of the api")
+	require.Len(t, spans["INSERT orders"], 2)
+	for k, root := range spans["GET /checkout"] {
+		assert.Equal(t, "frontend", serviceName(root))
+		assert.Equal(t, trace.SpanKindServer, root.SpanKind())
+		assert.False(t, root.Parent().IsValid())
+		env, _ := root.Resource().Set().Value("env")
+		assert.Equal(t, "test", env.AsString(), "the services share the resource attributes")
+
+		client, server := spans["POST /orders"][2*k], spans["POST /orders"][2*k+1]
+		assert.Equal(t, "frontend", serviceName(client))
+		assert.Equal(t, trace.SpanKindClient, client.SpanKind())
+		assert.Equal(t, root.SpanContext().SpanID(), client.Parent().SpanID())
+		assert.Contains(t, client.Attributes(), attribute.String("peer.service", "api"))
+
+		assert.Equal(t, "api", serviceName(server))
+		assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+		assert.Equal(t, client.SpanContext().SpanID(), server.Parent().SpanID())
+
+		query := spans["INSERT orders"][k]
+		assert.Equal(t, "api", serviceName(query), "the database isn't instrumented, its caller emits the query")
+		assert.Equal(t, trace.SpanKindClient, query.SpanKind())
+		assert.Equal(t, server.SpanContext().SpanID(), query.Parent().SpanID())
+		assert.Contains(t, query.Attributes(), attribute.String("db.system", "postgresql"))
+
+		for _, span := range []sdktrace.ReadOnlySpan{client, server, query} {
+			assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID())
+			assert.False(t, span.StartTime().Before(root.StartTime()))
+			assert.True(t, span.EndTime().Before(root.EndTime()), "the calls are made while the request is handled")
+		}
+	}
+}
+
+func TestTopologyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  - name: api
+    operation: POST /orders
+    calls:
+      - service: worker
+        queue: orders
+  - name: worker
+`), 0o600))
+	spans := runTopology(t, &Config{
+		Config: common.Config{
+			WorkerCount: 1,
+		},
+		NumTraces:    1,
+		SpanDuration: time.Millisecond,
+		Topology:     path,
+	})
+
+	require.Len(t, spans["orders publish"], 1)
+	require.Len(t, spans["orders process"], 1)
+	publish, process := spans["orders publish"][0], spans["orders process"][0]
+	assert.Equal(t, "api", serviceName(publish))
+	assert.Equal(t, trace.SpanKindProducer, publish.SpanKind())
+	assert.Equal(t, spans["POST /orders"][0].SpanContext().SpanID(), publish.Parent().SpanID())
+	assert.Equal(t, "worker", serviceName(process))
+	assert.Equal(t, trace.SpanKindConsumer, process.SpanKind())
+	assert.Equal(t, publish.SpanContext().SpanID(), process.Parent().SpanID())
+	assert.Contains(t, process.Attributes(), attribute.String("messaging.destination.name", "orders"))
+	assert.False(t, process.StartTime().Before(publish.EndTime()), "the message is processed once published")
+}
+
+func TestLoadTopology(t *testing.T) {
+	for _, name := range topologyPresetNames() {
+		topo, err := loadTopology(name)
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, topo.services)
+	}
+	topo, err := loadTopology("microservices-10")
+	require.NoError(t, err)
+	assert.Len(t, topo.services, 8, "10 services, 2 of them databases")
+
+	tests := []struct {
+		name     string
+		services []topologyService
+		err      string
+	}{
+		{name: "no services", err: "no services"},
+		{name: "unknown callee", services: []topologyService{{Name: "a", Calls: []topologyCall{{Service: "b"}}}}, err: `service "a" calls "b", which isn't defined`},
+		{name: "loop", services: []topologyService{
+			{Name: "a", Calls: []topologyCall{{Service: "b"}}},
+			{Name: "b", Calls: []topologyCall{{Service: "a"}}},
+		}, err: "the calls go round in a loop: a -> b -> a"},
+		{name: "database making calls", services: []topologyService{
+			{Name: "a", Calls: []topologyCall{{Service: "db"}}},
+			{Name: "db", Database: "mysql", Calls: []topologyCall{{Service: "a"}}},
+		}, err: `database "db" can't make calls`},
+		{name: "database entry", services: []topologyService{{Name: "db", Database: "mysql"}}, err: "can't be a database"},
+		{name: "duplicate", services: []topologyService{{Name: "a"}, {Name: "a"}}, err: `service "a" is defined twice`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildTopology(topologySpec{Services: tt.services})
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}